@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditEntry is a command waiting for its first line of output before it
+// is written to the audit log.
+type auditEntry struct {
+	Time     time.Time
+	ChatID   int64
+	Username string
+	Command  string
+}
+
+// auditLogMu serializes appends so concurrent sessions don't interleave lines.
+var auditLogMu sync.Mutex
+
+// auditLogPath returns the audit log written when Config.AuditLog is set.
+func auditLogPath() string {
+	return filepath.Join(getConfigDir(), "audit.log")
+}
+
+// formatAuditLine renders one tab-separated audit record. The command and
+// result are quoted so embedded tabs or quotes can't forge extra fields.
+func formatAuditLine(e auditEntry, result string) string {
+	return fmt.Sprintf("%s\tchat=%d\tuser=@%s\tcmd=%q\tresult=%q\n",
+		e.Time.UTC().Format(time.RFC3339), e.ChatID, e.Username, e.Command, result)
+}
+
+// firstResultLine returns the first non-blank line of output, skipping the
+// PTY's echo of the command itself.
+func firstResultLine(output, command string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != strings.TrimSpace(command) {
+			return line
+		}
+	}
+	return ""
+}
+
+// appendAuditLog appends a record to the audit log, creating it with 0600
+// permissions and tightening an existing file that is more permissive.
+func appendAuditLog(e auditEntry, result string) error {
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(auditLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+	_, err = f.WriteString(formatAuditLine(e, result))
+	return err
+}
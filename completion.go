@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxCompletions is how many paths an inline query offers; Telegram
+	// accepts up to 50 results.
+	maxCompletions = 20
+
+	// maxCompletionScan caps the directory entries read per query, so a
+	// huge directory doesn't stall the bot.
+	maxCompletionScan = 2000
+)
+
+// splitCompletionQuery splits an inline query such as "ls src/ma" into
+// the text kept as typed ("ls ") and the partial path being completed
+// ("src/ma"), which is the last space-separated word.
+func splitCompletionQuery(query string) (prefix, partial string) {
+	i := strings.LastIndex(query, " ")
+	return query[:i+1], query[i+1:]
+}
+
+// completePath returns the paths partial can be completed to, spelled the
+// way it was typed and with a trailing "/" on directories. Relative paths
+// are taken from dir (the bridge's directory if empty) and "~/" from the
+// home directory. Hidden entries are only offered when the name being
+// completed starts with ".". At most limit paths are returned, sorted.
+func completePath(dir, partial string, limit int) []string {
+	typedDir, base := "", partial
+	if i := strings.LastIndex(partial, "/"); i >= 0 {
+		typedDir, base = partial[:i+1], partial[i+1:]
+	}
+	lookIn := expandPath(typedDir)
+	if lookIn == "" {
+		lookIn = "."
+	}
+	if !filepath.IsAbs(lookIn) && dir != "" {
+		lookIn = filepath.Join(dir, lookIn)
+	}
+
+	f, err := os.Open(lookIn)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	entries, _ := f.ReadDir(maxCompletionScan)
+
+	var matches []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		path := typedDir + name
+		if e.IsDir() {
+			path += "/"
+		} else if e.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(lookIn, name)); err == nil && info.IsDir() {
+				path += "/"
+			}
+		}
+		matches = append(matches, path)
+	}
+	sort.Strings(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// handleInlineQuery answers "@bot ls src/ma" with path completions from
+// the user's session directory. Picking one sends the completed command
+// to the chat. Queries from users not on the whitelist get no answer.
+func (tb *TelegramBridge) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	if query.From == nil || !tb.isAllowed(query.From.ID) {
+		if query.From != nil {
+			log.Printf("⚠️  Unauthorized inline query: @%s (ID: %d)\n", query.From.UserName, query.From.ID)
+		}
+		return
+	}
+
+	// An inline query has no chat; in a private chat the chat ID is the
+	// user's ID, which is where their session lives
+	prefix, partial := splitCompletionQuery(query.Query)
+	paths := completePath(tb.sessionDir(query.From.ID), partial, maxCompletions)
+
+	results := make([]interface{}, 0, len(paths))
+	for i, path := range paths {
+		results = append(results, tgbotapi.NewInlineQueryResultArticle(strconv.Itoa(i), path, prefix+path))
+	}
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		IsPersonal:    true,
+	}
+	if _, err := tb.bot.Request(answer); err != nil {
+		log.Printf("Warning: failed to answer inline query: %v\n", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCompletionQuery(t *testing.T) {
+	tests := []struct{ query, prefix, partial string }{
+		{"ls src/ma", "ls ", "src/ma"},
+		{"ls ", "ls ", ""},
+		{"src", "", "src"},
+		{"cp a.txt b", "cp a.txt ", "b"},
+	}
+	for _, tt := range tests {
+		prefix, partial := splitCompletionQuery(tt.query)
+		if prefix != tt.prefix || partial != tt.partial {
+			t.Errorf("splitCompletionQuery(%q) = %q, %q, want %q, %q", tt.query, prefix, partial, tt.prefix, tt.partial)
+		}
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src", "main"), 0755)
+	for _, name := range []string{"src/main.go", "src/make.sh", "src/other.go", "src/.hidden", "readme.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		partial string
+		limit   int
+		want    []string
+	}{
+		{"src/ma", 10, []string{"src/main.go", "src/main/", "src/make.sh"}},
+		{"src/ma", 2, []string{"src/main.go", "src/main/"}},
+		{"src/", 10, []string{"src/main.go", "src/main/", "src/make.sh", "src/other.go"}},
+		{"src/.h", 10, []string{"src/.hidden"}},
+		{"r", 10, []string{"readme.md"}},
+		{"nope/x", 10, nil},
+		{filepath.Join(dir, "src") + "/o", 10, []string{filepath.Join(dir, "src") + "/other.go"}},
+	}
+	for _, tt := range tests {
+		if got := completePath(dir, tt.partial, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completePath(%q, %d) = %q, want %q", tt.partial, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+//go:build !windows
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// controlTimeout bounds a single control socket exchange so a stuck client
+// cannot hold a connection open forever.
+const controlTimeout = 5 * time.Second
+
+// controlRequest is the JSON request sent over the control socket.
+type controlRequest struct {
+	Command string `json:"command"`
+}
+
+// controlResponse is the JSON response returned over the control socket.
+type controlResponse struct {
+	Sessions []sessionInfo `json:"sessions,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// rpcRequest is a JSON-RPC style call on the control socket, told apart
+// from a controlRequest by its method. Calls must carry the token from
+// controlTokenPath; the ID is echoed back so clients can match replies.
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Token  string          `json:"token"`
+}
+
+// rpcResponse is the reply to an rpcRequest: a result or an error.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// rpcSessionParams selects a session for stop_session and send_command.
+type rpcSessionParams struct {
+	ChatID  int64  `json:"chat_id"`
+	Command string `json:"command,omitempty"`
+}
+
+// rpcStatus is the result of the status method.
+type rpcStatus struct {
+	Version  string        `json:"version"`
+	Uptime   string        `json:"uptime"`
+	Sessions []sessionInfo `json:"sessions"`
+}
+
+// rpcUser is one entry in the result of the list_users method.
+type rpcUser struct {
+	ID    int64 `json:"id"`
+	Owner bool  `json:"owner"`
+}
+
+// rpcOK is the result of methods that only report success.
+type rpcOK struct {
+	OK bool `json:"ok"`
+}
+
+// controlSocketPath returns the path to the daemon's control socket.
+func controlSocketPath() string {
+	return filepath.Join(getConfigDir(), "remote-term.sock")
+}
+
+// controlTokenPath returns the file holding the control API token.
+func controlTokenPath() string {
+	return filepath.Join(getConfigDir(), "control.token")
+}
+
+// loadControlToken returns the control API token, generating a random one
+// with 0600 permissions on first use. An existing file that is more
+// permissive is tightened.
+func loadControlToken() (string, error) {
+	path := controlTokenPath()
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			if err := os.Chmod(path, 0600); err != nil {
+				return "", err
+			}
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// serveControl listens on the control socket and answers requests about the
+// bridge until the returned listener is closed. A stale socket left behind by
+// a previous run is removed first. Without a control token the JSON-RPC
+// methods are refused, but --list-sessions keeps working.
+func (tb *TelegramBridge) serveControl() (net.Listener, error) {
+	token, err := loadControlToken()
+	if err != nil {
+		log.Printf("Warning: control API disabled, could not load token: %v\n", err)
+	}
+
+	path := controlSocketPath()
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	os.Chmod(path, 0600) // Session details are for the owner only
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Listener closed
+			}
+			go tb.handleControl(conn, token)
+		}
+	}()
+	return ln, nil
+}
+
+// handleControl answers a single JSON request on conn: a JSON-RPC call
+// when it names a method, otherwise a controlRequest.
+func (tb *TelegramBridge) handleControl(conn net.Conn, token string) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	var raw json.RawMessage
+	var rpc rpcRequest
+	var req controlRequest
+	var resp interface{}
+	if err := json.NewDecoder(conn).Decode(&raw); err != nil {
+		resp = controlResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	} else if json.Unmarshal(raw, &rpc) == nil && rpc.Method != "" {
+		resp = tb.handleRPC(rpc, token)
+	} else if err := json.Unmarshal(raw, &req); err != nil {
+		resp = controlResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	} else {
+		switch req.Command {
+		case "list-sessions":
+			resp = controlResponse{Sessions: tb.listSessions()}
+		default:
+			resp = controlResponse{Error: fmt.Sprintf("unknown command: %q", req.Command)}
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Error writing control response: %v\n", err)
+	}
+}
+
+// handleRPC authenticates a JSON-RPC call against token and dispatches it.
+func (tb *TelegramBridge) handleRPC(req rpcRequest, token string) rpcResponse {
+	resp := rpcResponse{ID: req.ID}
+	if token == "" || subtle.ConstantTimeCompare([]byte(req.Token), []byte(token)) != 1 {
+		log.Printf("⚠️  Rejected control API call %q: bad token\n", req.Method)
+		resp.Error = "unauthorized"
+		return resp
+	}
+
+	result, err := tb.dispatchRPC(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+// dispatchRPC runs a control API method. Commands sent this way are logged
+// and audited as user "control".
+func (tb *TelegramBridge) dispatchRPC(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "status":
+		return rpcStatus{
+			Version:  version,
+			Uptime:   time.Since(tb.startedAt).Round(time.Second).String(),
+			Sessions: tb.listSessions(),
+		}, nil
+
+	case "list_users":
+		tb.mu.RLock()
+		defer tb.mu.RUnlock()
+		users := make([]rpcUser, 0, len(tb.config.AllowedUsers))
+		for i, id := range tb.config.AllowedUsers {
+			users = append(users, rpcUser{ID: id, Owner: i == 0})
+		}
+		return users, nil
+
+	case "stop_session", "send_command":
+		var p rpcSessionParams
+		if len(params) == 0 || json.Unmarshal(params, &p) != nil || p.ChatID == 0 {
+			return nil, fmt.Errorf("params must include chat_id")
+		}
+		if !tb.hasActiveSession(p.ChatID) {
+			return nil, fmt.Errorf("no active session for chat %d", p.ChatID)
+		}
+		if method == "stop_session" {
+			tb.stopSession(p.ChatID, "control")
+			return rpcOK{OK: true}, nil
+		}
+		if strings.TrimSpace(p.Command) == "" {
+			return nil, fmt.Errorf("params must include command")
+		}
+		tb.handleCommand(tb.currentConfig(), p.ChatID, 0, "control", p.Command)
+		return rpcOK{OK: true}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown method: %q", method)
+	}
+}
+
+// queryControl sends req to the running daemon and returns its response.
+func queryControl(req controlRequest) (*controlResponse, error) {
+	conn, err := net.DialTimeout("unix", controlSocketPath(), controlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// daemonListSessions prints the active sessions of the running daemon.
+func daemonListSessions() {
+	resp, err := queryControl(controlRequest{Command: "list-sessions"})
+	if err != nil {
+		fmt.Printf("Cannot reach daemon: %v\n", err)
+		fmt.Println("Is it running? Check with --status.")
+		os.Exit(1)
+	}
+
+	if len(resp.Sessions) == 0 {
+		fmt.Println("No active sessions.")
+		return
+	}
+	fmt.Print(formatSessionList(resp.Sessions))
+}
@@ -0,0 +1,197 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestControlListSessions verifies the control socket reports the bridge's
+// active sessions and skips inactive ones
+func TestControlListSessions(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	tb.sessions[200] = &Session{Active: true, Command: "htop", StartedAt: time.Now().Add(-time.Minute)}
+	tb.sessions[100] = &Session{Active: true, Command: "bash", StartedAt: time.Now()}
+	tb.sessions[300] = &Session{Active: false, Command: "gone", StartedAt: time.Now()}
+
+	ln, err := tb.serveControl()
+	if err != nil {
+		t.Fatalf("serveControl() error: %v", err)
+	}
+	defer ln.Close()
+
+	resp, err := queryControl(controlRequest{Command: "list-sessions"})
+	if err != nil {
+		t.Fatalf("queryControl() error: %v", err)
+	}
+	if len(resp.Sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2: %+v", len(resp.Sessions), resp.Sessions)
+	}
+	if resp.Sessions[0].ID != 100 || resp.Sessions[1].ID != 200 {
+		t.Errorf("sessions not ordered by chat ID: %+v", resp.Sessions)
+	}
+	if resp.Sessions[1].Command != "htop" || resp.Sessions[1].Duration != "1m0s" {
+		t.Errorf("unexpected session details: %+v", resp.Sessions[1])
+	}
+
+	table := formatSessionList(resp.Sessions)
+	if !strings.Contains(table, "CHAT ID") || !strings.Contains(table, "htop") {
+		t.Errorf("formatSessionList() = %q", table)
+	}
+}
+
+// TestControlUnknownCommand verifies unknown requests return an error
+func TestControlUnknownCommand(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	ln, err := tb.serveControl()
+	if err != nil {
+		t.Fatalf("serveControl() error: %v", err)
+	}
+	defer ln.Close()
+
+	if _, err := queryControl(controlRequest{Command: "reboot"}); err == nil {
+		t.Error("expected error for unknown command")
+	}
+}
+
+// TestControlNoDaemon verifies queries fail cleanly when nothing is listening
+func TestControlNoDaemon(t *testing.T) {
+	setTempConfigPath(t)
+	if _, err := queryControl(controlRequest{Command: "list-sessions"}); err == nil {
+		t.Error("expected error with no control socket")
+	}
+}
+
+// callRPC sends a JSON-RPC request to the control socket and decodes the reply.
+func callRPC(t *testing.T, req rpcRequest) (result json.RawMessage, errMsg string) {
+	t.Helper()
+	conn, err := net.Dial("unix", controlSocketPath())
+	if err != nil {
+		t.Fatalf("dial control socket: %v", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		t.Fatalf("send request: %v", err)
+	}
+	var resp struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if string(resp.ID) != string(req.ID) {
+		t.Errorf("response id = %s, want %s", resp.ID, req.ID)
+	}
+	return resp.Result, resp.Error
+}
+
+// TestControlTokenCreated verifies the control token is generated once with
+// 0600 permissions and reused afterwards
+func TestControlTokenCreated(t *testing.T) {
+	setTempConfigPath(t)
+	token, err := loadControlToken()
+	if err != nil {
+		t.Fatalf("loadControlToken() error: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("token length = %d, want 64", len(token))
+	}
+	info, err := os.Stat(controlTokenPath())
+	if err != nil {
+		t.Fatalf("stat token: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("token permissions = %o, want 600", perm)
+	}
+
+	again, err := loadControlToken()
+	if err != nil || again != token {
+		t.Errorf("second load = %q, %v; want %q", again, err, token)
+	}
+}
+
+// TestControlRPCAuth verifies calls without the right token are rejected
+func TestControlRPCAuth(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	ln, err := tb.serveControl()
+	if err != nil {
+		t.Fatalf("serveControl() error: %v", err)
+	}
+	defer ln.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		if _, errMsg := callRPC(t, rpcRequest{ID: json.RawMessage("1"), Method: "status", Token: token}); errMsg != "unauthorized" {
+			t.Errorf("token %q: error = %q, want unauthorized", token, errMsg)
+		}
+	}
+}
+
+// TestControlRPCMethods verifies the status and list_users methods and the
+// errors for unknown methods and missing sessions
+func TestControlRPCMethods(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111, 222}})
+	tb.sessions[100] = &Session{Active: true, Command: "bash", StartedAt: time.Now()}
+
+	ln, err := tb.serveControl()
+	if err != nil {
+		t.Fatalf("serveControl() error: %v", err)
+	}
+	defer ln.Close()
+	token, err := loadControlToken()
+	if err != nil {
+		t.Fatalf("loadControlToken() error: %v", err)
+	}
+
+	result, errMsg := callRPC(t, rpcRequest{ID: json.RawMessage(`"a"`), Method: "status", Token: token})
+	if errMsg != "" {
+		t.Fatalf("status error: %s", errMsg)
+	}
+	var status rpcStatus
+	if err := json.Unmarshal(result, &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Version != version || len(status.Sessions) != 1 || status.Sessions[0].ID != 100 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	result, errMsg = callRPC(t, rpcRequest{ID: json.RawMessage("2"), Method: "list_users", Token: token})
+	if errMsg != "" {
+		t.Fatalf("list_users error: %s", errMsg)
+	}
+	var users []rpcUser
+	if err := json.Unmarshal(result, &users); err != nil {
+		t.Fatalf("decode users: %v", err)
+	}
+	if len(users) != 2 || !users[0].Owner || users[1].Owner || users[1].ID != 222 {
+		t.Errorf("unexpected users: %+v", users)
+	}
+
+	errCases := []struct {
+		method string
+		params string
+		want   string
+	}{
+		{"reboot", "", "unknown method"},
+		{"stop_session", "", "chat_id"},
+		{"stop_session", `{"chat_id":999}`, "no active session"},
+		{"send_command", `{"chat_id":999,"command":"ls"}`, "no active session"},
+		{"send_command", `{"chat_id":100}`, "command"},
+	}
+	for _, tc := range errCases {
+		req := rpcRequest{ID: json.RawMessage("3"), Method: tc.method, Token: token}
+		if tc.params != "" {
+			req.Params = json.RawMessage(tc.params)
+		}
+		if _, errMsg := callRPC(t, req); !strings.Contains(errMsg, tc.want) {
+			t.Errorf("%s %s: error = %q, want %q", tc.method, tc.params, errMsg, tc.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// serveControl is a no-op on Windows, where there is no control socket.
+func (tb *TelegramBridge) serveControl() (net.Listener, error) {
+	return nil, nil
+}
+
+// daemonListSessions prints an unsupported message on Windows and exits.
+func daemonListSessions() {
+	fmt.Println("Daemon mode is not supported on Windows.")
+	fmt.Println("Use 'nohup remote-term &' or run as a Windows service.")
+	os.Exit(1)
+}
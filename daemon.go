@@ -4,9 +4,11 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -174,6 +176,53 @@ func daemonStop() {
 	removePIDFile()
 }
 
+// daemonRestart stops the running daemon, if any, and starts a new one
+// with extraArgs, as --daemon would.
+func daemonRestart(extraArgs []string) {
+	daemonStop()
+	daemonize(extraArgs)
+}
+
+// selfRestartSupported reports whether reexecSelf can replace the process.
+const selfRestartSupported = true
+
+// reexecSelf replaces the process with a fresh run of the same binary and
+// arguments, keeping its PID. The PID file was removed by the shutdown
+// cleanup, so a daemon child writes it again first.
+func reexecSelf() error {
+	path, err := exec.LookPath(launchArgs[0])
+	if err != nil {
+		return err
+	}
+	if slices.Contains(launchArgs[1:], "--daemon-child") {
+		if err := writePIDFile(os.Getpid()); err != nil {
+			log.Printf("Warning: Failed to write PID file: %v\n", err)
+		}
+	}
+	return syscall.Exec(path, launchArgs, os.Environ())
+}
+
+// daemonReload sends SIGHUP to the running daemon so it re-reads its config.
+func daemonReload() {
+	pid, err := readPIDFile()
+	if err != nil {
+		fmt.Println("No daemon is running (PID file not found).")
+		return
+	}
+
+	if !isProcessAlive(pid) {
+		fmt.Printf("Daemon (PID %d) is not running. Removing stale PID file.\n", pid)
+		removePIDFile()
+		return
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		fmt.Printf("Error sending SIGHUP: %v\n", err)
+		return
+	}
+	fmt.Printf("Asked daemon (PID %d) to reload its config. See %s for the result.\n", pid, logFilePath())
+}
+
 // daemonStatus prints the current status of the daemon.
 func daemonStatus() {
 	pid, err := readPIDFile()
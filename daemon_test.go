@@ -6,7 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"syscall"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // setTempConfigPath sets configPathOverride to a temp directory and registers cleanup.
@@ -265,3 +269,94 @@ func TestDaemonCleanupHookVariable(t *testing.T) {
 		t.Error("PID file should be removed after calling daemonCleanupHook")
 	}
 }
+
+// TestReloadConfigOnSIGHUP edits the config file and sends SIGHUP, verifying
+// the bridge swaps in the new config.
+func TestReloadConfigOnSIGHUP(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{111}})
+	idle := 1
+	if err := saveConfig(&Config{AllowedUsers: []int64{111, 222}, IdleTimeoutMinutes: &idle}); err != nil {
+		t.Fatalf("saveConfig() error: %v", err)
+	}
+
+	reloaded := make(chan error, 1)
+	stop := tb.watchReloadSignal(func(err error) { reloaded <- err })
+	t.Cleanup(stop)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("sending SIGHUP: %v", err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("reload after SIGHUP failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded after SIGHUP")
+	}
+	if !tb.isAllowed(222) {
+		t.Error("user 222 not allowed after SIGHUP")
+	}
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	if tb.idleTimeout != time.Minute {
+		t.Errorf("idleTimeout = %v after reload, want 1m", tb.idleTimeout)
+	}
+}
+
+// TestReloadConfigKeepsCurrentOnError verifies an unparseable config file
+// leaves the running config in place.
+func TestReloadConfigKeepsCurrentOnError(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{111}})
+	if err := os.WriteFile(getConfigPath(), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tb.reloadConfig(); err == nil {
+		t.Fatal("reloadConfig() with a corrupt file: expected error")
+	}
+	if !tb.isAllowed(111) {
+		t.Error("user 111 no longer allowed; a failed reload replaced the config")
+	}
+}
+
+// TestReloadConfigDuringCommands reloads the config while commands are
+// handled; under -race this catches reads of tb.config that bypass
+// currentConfig.
+func TestReloadConfigDuringCommands(t *testing.T) {
+	config := &Config{AllowedUsers: []int64{111}, DeniedCommands: []string{"rm"}}
+	tb := newTestBridge(t, config)
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig() error: %v", err)
+	}
+	bot := &mockBot{}
+	tb.bot = bot
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := tb.reloadConfig(); err != nil {
+				t.Errorf("reloadConfig() error: %v", err)
+				return
+			}
+		}
+	}()
+	for id := 1; id <= 20; id++ {
+		tb.handleUpdate(tgbotapi.Update{
+			UpdateID: id,
+			Message: &tgbotapi.Message{
+				From: &tgbotapi.User{ID: 111, UserName: "alice"},
+				Chat: &tgbotapi.Chat{ID: 111},
+				Text: "rm -rf /tmp/x",
+			},
+		})
+	}
+	<-done
+
+	for _, c := range bot.sent {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.Text != "🚫 command not permitted" {
+			t.Errorf("reply = %q, want every rm refused", msg.Text)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -72,6 +73,28 @@ func daemonStop() {
 	os.Exit(1)
 }
 
+// daemonReload prints an unsupported message on Windows and exits.
+func daemonReload() {
+	fmt.Println("Daemon mode is not supported on Windows.")
+	fmt.Println("Use 'nohup remote-term &' or run as a Windows service.")
+	os.Exit(1)
+}
+
+// daemonRestart prints an unsupported message on Windows and exits.
+func daemonRestart(extraArgs []string) {
+	fmt.Println("Daemon mode is not supported on Windows.")
+	fmt.Println("Use 'nohup remote-term &' or run as a Windows service.")
+	os.Exit(1)
+}
+
+// selfRestartSupported reports whether reexecSelf can replace the process.
+const selfRestartSupported = false
+
+// reexecSelf is a stub on Windows, which can't replace a running process.
+func reexecSelf() error {
+	return errors.New("restarting is not supported on Windows")
+}
+
 // daemonStatus prints an unsupported message on Windows and exits.
 func daemonStatus() {
 	fmt.Println("Daemon mode is not supported on Windows.")
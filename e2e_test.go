@@ -1,15 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // TestE2ESimpleCommand tests a simple command end-to-end
 func TestE2ESimpleCommand(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -37,7 +46,7 @@ func TestE2ESimpleCommand(t *testing.T) {
 // TestE2EListFiles tests listing files
 func TestE2EListFiles(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -71,7 +80,7 @@ func TestE2EListFiles(t *testing.T) {
 // TestE2EMultipleCommands tests running multiple commands
 func TestE2EMultipleCommands(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -100,7 +109,7 @@ func TestE2EMultipleCommands(t *testing.T) {
 // TestE2ESlowOutput tests handling of slow/interactive output
 func TestE2ESlowOutput(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -137,7 +146,7 @@ func TestE2ESlowOutput(t *testing.T) {
 // TestE2EPythonREPL tests interactive Python REPL (if available)
 func TestE2EPythonREPL(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -159,7 +168,7 @@ func TestE2EPythonREPL(t *testing.T) {
 // TestE2EErrorHandling tests error command handling
 func TestE2EErrorHandling(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -190,7 +199,7 @@ func TestE2EErrorHandling(t *testing.T) {
 // TestE2ELongOutput tests handling of very long output
 func TestE2ELongOutput(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -218,7 +227,7 @@ func TestE2ELongOutput(t *testing.T) {
 // BenchmarkE2ECommand benchmarks command execution
 func BenchmarkE2ECommand(b *testing.B) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		b.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -231,3 +240,641 @@ func BenchmarkE2ECommand(b *testing.B) {
 		term.StreamOutput()
 	}
 }
+
+// TestE2ECommandTimeout verifies StreamOutput abandons a long-running command
+// after the configured timeout, reports it, and closes the terminal
+func TestE2ECommandTimeout(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	config := &Config{CommandTimeout: 2}
+	term.SetCommandTimeout(config.commandTimeout())
+
+	term.SendCommand("sleep 60")
+
+	start := time.Now()
+	term.StreamOutput()
+	elapsed := time.Since(start)
+
+	if elapsed > 3*time.Second {
+		t.Errorf("StreamOutput took %v, want ~2s timeout", elapsed)
+	}
+
+	allOutput := strings.Join(sink.Outputs, "\n")
+	if !strings.Contains(allOutput, "command timed out after 2s") {
+		t.Errorf("Expected timeout status, got: %q", allOutput)
+	}
+
+	if !term.isClosed() {
+		t.Error("Terminal should be closed after command timeout")
+	}
+}
+
+// TestE2ERunCommandQuietPastSilence verifies a one-shot command that stays
+// quiet longer than the silence threshold runs until it finishes, and is
+// only abandoned once the configured timeout passes
+func TestE2ERunCommandQuietPastSilence(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, &Config{Shell: "/bin/sh"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+	term.SetCommandTimeout(10 * time.Second)
+
+	term.RunCommand("sleep 5; echo done")
+	term.StreamOutput()
+
+	got := strings.Join(sink.Outputs, "\n")
+	if !strings.Contains(got, "done") || strings.Contains(got, "timed out") {
+		t.Errorf("quiet command output = %q, want it to finish", got)
+	}
+	if last := sink.Outputs[len(sink.Outputs)-1]; last != "✅ exit 0" {
+		t.Errorf("last output = %q, want the exit status", last)
+	}
+	if term.isClosed() {
+		t.Error("terminal closed although the command finished in time")
+	}
+
+	sink.Outputs = nil
+	term.SetCommandTimeout(4 * time.Second)
+	start := time.Now()
+	term.RunCommand("sleep 60")
+	term.StreamOutput()
+	if elapsed := time.Since(start); elapsed < 4*time.Second {
+		t.Errorf("StreamOutput returned after %v, before the 4s timeout", elapsed)
+	}
+	if got := strings.Join(sink.Outputs, "\n"); !strings.Contains(got, "command timed out after 4s") {
+		t.Errorf("expected timeout status, got: %q", got)
+	}
+}
+
+// TestConfigCommandTimeoutDefault verifies the 30s default when unset
+func TestConfigCommandTimeoutDefault(t *testing.T) {
+	var nilConfig *Config
+	if got := nilConfig.commandTimeout(); got != 30*time.Second {
+		t.Errorf("nil config timeout = %v, want 30s", got)
+	}
+	if got := (&Config{}).commandTimeout(); got != 30*time.Second {
+		t.Errorf("unset timeout = %v, want 30s", got)
+	}
+	if got := (&Config{CommandTimeout: 5}).commandTimeout(); got != 5*time.Second {
+		t.Errorf("configured timeout = %v, want 5s", got)
+	}
+}
+
+// TestE2ECustomShell verifies Config.Shell is used when the file exists
+func TestE2ECustomShell(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, &Config{Shell: "/bin/sh"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	if term.cmd.Path != "/bin/sh" {
+		t.Errorf("shell = %q, want /bin/sh", term.cmd.Path)
+	}
+
+	term.SendCommand("echo custom-shell-ok")
+	term.StreamOutput()
+
+	if !strings.Contains(strings.Join(sink.Outputs, ""), "custom-shell-ok") {
+		t.Errorf("expected command output, got %v", sink.Outputs)
+	}
+}
+
+// TestE2ELoadShellRC verifies an alias from the user's rc file is only
+// available with Config.LoadShellRC
+func TestE2ELoadShellRC(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	home := t.TempDir()
+	os.WriteFile(filepath.Join(home, ".bashrc"), []byte("alias rcalias='echo rc-alias-ok'\n"), 0644)
+	os.WriteFile(filepath.Join(home, ".bash_profile"), []byte(". ~/.bashrc\n"), 0644)
+	env := map[string]string{"HOME": home}
+
+	run := func(config *Config) string {
+		sink := &MockSink{}
+		term, err := NewTerminal(sink, config, env)
+		if err != nil {
+			t.Fatalf("Failed to create terminal: %v", err)
+		}
+		defer term.Close()
+		term.SendCommand("rcalias")
+		term.StreamOutput()
+		return strings.Join(sink.Outputs, "")
+	}
+
+	if got := run(&Config{LoadShellRC: true}); !strings.Contains(got, "rc-alias-ok") {
+		t.Errorf("alias from .bashrc not available with load_shell_rc, got %q", got)
+	}
+	if got := run(&Config{}); strings.Contains(got, "rc-alias-ok") {
+		t.Errorf("rc files loaded without load_shell_rc: %q", got)
+	}
+}
+
+// TestE2EWorkingDir verifies a profile's WorkingDir is where the shell starts
+func TestE2EWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	config := (&Config{UserProfiles: map[int64]UserProfile{7: {WorkingDir: dir}}}).forUser(7)
+
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, config, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	if term.cmd.Dir != dir {
+		t.Errorf("cmd.Dir = %q, want %q", term.cmd.Dir, dir)
+	}
+	term.SendCommand("pwd")
+	term.StreamOutput()
+
+	if !strings.Contains(strings.Join(sink.Outputs, ""), filepath.Base(dir)) {
+		t.Errorf("expected pwd in %s, got %v", dir, sink.Outputs)
+	}
+}
+
+// TestE2ERunAsUser verifies the shell runs with the configured user's UID
+// (requires root to switch to "nobody")
+func TestE2ERunAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, &Config{RunAsUser: "nobody"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	term.SendCommand("echo uid=$(id -u)")
+	term.StreamOutput()
+
+	if want := "uid=" + nobody.Uid; !strings.Contains(strings.Join(sink.Outputs, ""), want) {
+		t.Errorf("expected %s, got %v", want, sink.Outputs)
+	}
+}
+
+// TestRunAsUserUnknown verifies an unknown user fails clearly instead of
+// falling back to the daemon's own user
+func TestRunAsUserUnknown(t *testing.T) {
+	_, err := NewTerminal(&MockSink{}, &Config{RunAsUser: "no-such-user-xyz"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no-such-user-xyz") {
+		t.Errorf("NewTerminal() error = %v, want lookup failure naming the user", err)
+	}
+}
+
+// TestResolveShellFallback verifies a missing Config.Shell falls back to the default
+func TestResolveShellFallback(t *testing.T) {
+	defaultCmd, _ := getShell(false)
+
+	if got, _ := resolveShell(&Config{Shell: "/nonexistent/zsh"}); got != defaultCmd {
+		t.Errorf("missing shell resolved to %q, want default %q", got, defaultCmd)
+	}
+	if got, _ := resolveShell(nil); got != defaultCmd {
+		t.Errorf("nil config resolved to %q, want default %q", got, defaultCmd)
+	}
+}
+
+// TestNewTerminalShellNotFound verifies a machine without any usable shell
+// fails with an error naming the shells tried, shown to the user
+func TestNewTerminalShellNotFound(t *testing.T) {
+	saved := defaultShells
+	defaultShells = []string{"/nonexistent/bash", "/nonexistent/sh"}
+	defer func() { defaultShells = saved }()
+
+	_, err := NewTerminal(&MockSink{}, &Config{Shell: "/nonexistent/zsh"}, nil)
+	if err == nil {
+		t.Fatal("NewTerminal() succeeded without a shell")
+	}
+	for _, want := range []string{"/nonexistent/zsh", "/nonexistent/bash", "/nonexistent/sh", `"shell"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewTerminal() error = %q, want it to mention %s", err, want)
+		}
+	}
+	if got := terminalErrorText("❌ Error creating session", err); !strings.Contains(got, "no shell found") {
+		t.Errorf("terminalErrorText() = %q, want the reason", got)
+	}
+	if got := terminalErrorText("❌ Error creating session", io.ErrClosedPipe); got != "❌ Error creating session" {
+		t.Errorf("terminalErrorText(other error) = %q, want the text alone", got)
+	}
+}
+
+// TestE2EOutputTruncated verifies large output is capped per command
+func TestE2EOutputTruncated(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, &Config{MaxOutputBytes: 500}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	// 60 lines of 100 chars: a full screen is well past the cap
+	term.SendCommand("head -c 6000 /dev/zero | tr '\\0' x | fold -w 100")
+	term.StreamOutput()
+
+	all := strings.Join(sink.Outputs, "")
+	if !strings.Contains(all, "output truncated") {
+		t.Fatalf("expected truncation notice, got %d bytes of output", len(all))
+	}
+	if len(all) > 1000 {
+		t.Errorf("sent %d bytes, want roughly the 500 byte cap plus notice", len(all))
+	}
+
+	// The cap resets for the next command
+	sink.Outputs = nil
+	term.SendCommand("echo after-truncation")
+	term.StreamOutput()
+	if !strings.Contains(strings.Join(sink.Outputs, ""), "after-truncation") {
+		t.Errorf("next command output missing: %v", sink.Outputs)
+	}
+}
+
+// lockedBuffer is a goroutine-safe strings.Builder for capturing output
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+// TestE2EStandaloneInteractive verifies REPL input/output passes through
+// until the /exit sentinel
+func TestE2EStandaloneInteractive(t *testing.T) {
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	inR, inW := io.Pipe()
+	out := &lockedBuffer{}
+	done := make(chan struct{})
+	go func() {
+		runInteractive(term, bufio.NewScanner(inR), out)
+		close(done)
+	}()
+
+	term.SendCommand("python3")
+	fmt.Fprintln(inW, "print(6 * 7)")
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(out.String(), "42") {
+		if time.Now().After(deadline) {
+			t.Fatalf("REPL output never arrived: %q", out.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	fmt.Fprintln(inW, standaloneExitSentinel)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runInteractive did not return after /exit")
+	}
+	if !term.isClosed() {
+		t.Error("terminal should be closed after leaving interactive mode")
+	}
+}
+
+// TestE2ESessionEnv verifies env overrides reach the shell and win over defaults
+func TestE2ESessionEnv(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, nil, map[string]string{"REMOTE_TERM_TEST": "from-env", "TERM": "dumb"})
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	term.SendCommand("echo \"$REMOTE_TERM_TEST:$TERM\"")
+	term.StreamOutput()
+
+	if !strings.Contains(strings.Join(sink.Outputs, ""), "from-env:dumb") {
+		t.Errorf("expected env overrides in output, got %v", sink.Outputs)
+	}
+}
+
+// TestTerminalPID verifies PID reports the shell process
+func TestTerminalPID(t *testing.T) {
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	if pid := term.PID(); pid <= 0 || pid != term.cmd.Process.Pid {
+		t.Errorf("PID() = %d, want shell pid %d", pid, term.cmd.Process.Pid)
+	}
+
+	var nilTerm *Terminal
+	if pid := nilTerm.PID(); pid != 0 {
+		t.Errorf("nil terminal PID() = %d, want 0", pid)
+	}
+}
+
+// TestE2EConfiguredTerminalSize verifies Config.DefaultRows/DefaultCols
+// set the PTY window size seen by programs
+func TestE2EConfiguredTerminalSize(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, &Config{DefaultRows: 33, DefaultCols: 97}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	term.SendCommand("stty size")
+	term.StreamOutput()
+
+	if got := strings.Join(sink.Outputs, ""); !strings.Contains(got, "33 97") {
+		t.Errorf("expected stty size 33 97, got %q", got)
+	}
+}
+
+// TestResizeSession verifies /resize sets the PTY size programs see and
+// asks the streaming goroutine to resize its virtual screen to match
+func TestResizeSession(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+	bot := &mockBot{}
+	tb.bot = bot
+
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+	session := &Session{Terminal: term, Active: true, resizeReq: make(chan termSize, 1)}
+	tb.sessions[1] = session
+
+	tb.resizeSession(1, "alice", " 30 90")
+	select {
+	case size := <-session.resizeReq:
+		if size != (termSize{rows: 30, cols: 90}) {
+			t.Errorf("screen resize request = %+v, want 30x90", size)
+		}
+	default:
+		t.Error("no resize request for the virtual screen")
+	}
+	if reply := bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text; !strings.Contains(reply, "30 rows × 90 columns") {
+		t.Errorf("reply = %q, want the new size", reply)
+	}
+
+	term.SendCommand("stty size")
+	term.StreamOutput()
+	if got := strings.Join(sink.Outputs, ""); !strings.Contains(got, "30 90") {
+		t.Errorf("expected stty size 30 90, got %q", got)
+	}
+
+	// Out-of-range sizes are refused without touching the session
+	for _, arg := range []string{"", "30", "2 90", "30 5000", "x 90"} {
+		tb.resizeSession(1, "alice", arg)
+		if reply := bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text; !strings.HasPrefix(reply, "❌") {
+			t.Errorf("/resize %q reply = %q, want an error", arg, reply)
+		}
+	}
+	if len(session.resizeReq) != 0 {
+		t.Error("an invalid /resize requested a screen resize")
+	}
+}
+
+// TestSplitCommandLine verifies shell-style splitting and that lines
+// needing a shell are refused
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"python3 script.py", []string{"python3", "script.py"}},
+		{"  vim   'my file.txt' ", []string{"vim", "my file.txt"}},
+		{`node -e "console.log(\"hi\")"`, []string{"node", "-e", `console.log("hi")`}},
+		{`less my\ notes.md ""`, []string{"less", "my notes.md", ""}},
+		{"sh -c 'echo $HOME | wc'", []string{"sh", "-c", "echo $HOME | wc"}},
+	}
+	for _, tt := range tests {
+		got, ok := splitCommandLine(tt.line)
+		if !ok || strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+			t.Errorf("splitCommandLine(%q) = %q, %v; want %q", tt.line, got, ok, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		"", "python3 $SCRIPT", "vim *.go", "claude | tee log", "cd /tmp && vim",
+		`echo "$HOME"`, "FOO=1 python3", "vim 'unterminated", `vim trailing\`,
+	} {
+		if got, ok := splitCommandLine(line); ok {
+			t.Errorf("splitCommandLine(%q) = %q, want refusal", line, got)
+		}
+	}
+}
+
+// TestE2EDirectExec verifies Config.DirectExec runs interactive commands as
+// the PTY child, while commands needing a shell still get one
+func TestE2EDirectExec(t *testing.T) {
+	config := &Config{DirectExec: true, InteractiveCommands: []string{"sh"}}
+
+	sink := &MockSink{}
+	term, err := NewSessionTerminal(sink, config, nil, "sh -c 'echo direct $0'")
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+	if !term.direct {
+		t.Fatal("expected a direct terminal")
+	}
+	term.Launch("sh -c 'echo direct $0'")
+	term.StreamOutput()
+
+	if got := strings.Join(sink.Outputs, ""); !strings.Contains(got, "direct sh") {
+		t.Errorf("expected program output, got %q", got)
+	}
+
+	shell, err := NewSessionTerminal(&MockSink{}, config, nil, "sh -c 'true' | cat")
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer shell.Close()
+	if shell.direct {
+		t.Error("a pipeline should run in a shell")
+	}
+}
+
+// TestE2EStreamOutputShellExit verifies StreamOutput returns as soon as the
+// shell exits, with its final output, instead of waiting for silence
+func TestE2EStreamOutputShellExit(t *testing.T) {
+	sink := &MockSink{}
+	term, err := NewTerminal(sink, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	term.SendCommand("echo goodbye; exit")
+	start := time.Now()
+	term.StreamOutput()
+
+	// The silence-based stop takes at least 3s
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("StreamOutput took %v after the shell exited", elapsed)
+	}
+	if got := strings.Join(sink.Outputs, ""); !strings.Contains(got, "goodbye") {
+		t.Errorf("final output not flushed, got %q", got)
+	}
+}
+
+// TestE2ERunCommandExitStatus checks that one-shot commands end with their
+// exit status and that the marker never reaches the sink
+func TestE2ERunCommandExitStatus(t *testing.T) {
+	tests := []struct {
+		command string
+		output  string
+		status  string
+	}{
+		{"printf 'ok-%s\\n' out", "ok-out", "✅ exit 0"},
+		{"printf 'fail-%s\\n' out; sh -c 'exit 2'", "fail-out", "❌ exit 2"},
+		// Quiet for longer than the silence threshold before failing
+		{"printf 'slow-%s\\n' out; sleep 4; false", "slow-out", "❌ exit 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			sink := &MockSink{}
+			term, err := NewTerminal(sink, &Config{Shell: "/bin/sh"}, nil)
+			if err != nil {
+				t.Fatalf("Failed to create terminal: %v", err)
+			}
+			defer term.Close()
+
+			term.RunCommand(tt.command)
+			term.StreamOutput()
+
+			if len(sink.Outputs) == 0 {
+				t.Fatal("No output received")
+			}
+			if last := sink.Outputs[len(sink.Outputs)-1]; last != tt.status {
+				t.Errorf("last output = %q, want %q", last, tt.status)
+			}
+			got := strings.Join(sink.Outputs, "")
+			if !strings.Contains(got, tt.output) {
+				t.Errorf("output %q missing %q", got, tt.output)
+			}
+			if strings.Contains(got, "__EXIT_") {
+				t.Errorf("exit marker not stripped: %q", got)
+			}
+		})
+	}
+}
+
+func TestWithExitMarker(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"ls", `ls; echo "__EXIT_$?__"`},
+		{"ls; ", `ls; echo "__EXIT_$?__"`},
+		{"sleep 5 &", `sleep 5 & echo "__EXIT_$?__"`},
+		{"true && false", `true && false; echo "__EXIT_$?__"`},
+	}
+	for _, tt := range tests {
+		if got := withExitMarker(tt.in); got != tt.want {
+			t.Errorf("withExitMarker(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestE2EOutputModes verifies each output_mode on output that moves the
+// cursor: vte renders it, strip drops the escape, raw passes it through
+func TestE2EOutputModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "xbc"},
+		{outputModeVTE, "xbc"},
+		{outputModeStrip, "abcx"},
+		{outputModeRaw, "abc\x1b[1Gx"},
+	}
+	for _, tt := range tests {
+		t.Run("mode="+tt.mode, func(t *testing.T) {
+			sink := &MockSink{}
+			term, err := NewTerminal(sink, &Config{OutputMode: tt.mode}, nil)
+			if err != nil {
+				t.Fatalf("Failed to create terminal: %v", err)
+			}
+			defer term.Close()
+
+			term.SendCommand(`printf 'abc\033[1Gx\n'`)
+			term.StreamOutput()
+
+			got := strings.Join(sink.Outputs, "")
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("output %q missing %q", got, tt.want)
+			}
+			if tt.mode == outputModeStrip && strings.Contains(got, "\x1b") {
+				t.Errorf("escape codes left in stripped output: %q", got)
+			}
+		})
+	}
+}
+
+// TestE2ESessionInitCommand verifies the init command's effect reaches the
+// first command while its output, and its echo, are hidden
+func TestE2ESessionInitCommand(t *testing.T) {
+	sink := &MockSink{}
+	config := &Config{SessionInitCommand: "export INIT_VAR=from-init; echo init-noise;"}
+	term, err := NewTerminal(sink, config, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	term.SendCommand("echo value=$INIT_VAR")
+	term.StreamOutput()
+
+	got := strings.Join(sink.Outputs, "")
+	if !strings.Contains(got, "value=from-init") {
+		t.Errorf("output %q missing the variable set by the init command", got)
+	}
+	for _, hidden := range []string{"init-noise", "INIT_VAR=from-init", "__INIT"} {
+		if strings.Contains(got, hidden) {
+			t.Errorf("output %q shows init command output %q", got, hidden)
+		}
+	}
+}
+
+// TestE2ESessionInitTimeout verifies a hanging init command doesn't hold
+// up the session
+func TestE2ESessionInitTimeout(t *testing.T) {
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	start := time.Now()
+	term.runInit("sleep 3", 200*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runInit() waited %s for a hanging command", elapsed)
+	}
+	if term.skippingInit.Load() {
+		t.Error("output still discarded after the init timeout")
+	}
+}
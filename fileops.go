@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fileOpSource resolves the source of /cp or /mv: an existing regular file
+// inside root. Relative paths are taken from dir, the session's directory.
+func fileOpSource(root, dir, arg string) (string, error) {
+	path := expandPath(arg)
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolveInRoot(root, path)
+	switch {
+	case errors.Is(err, errOutsideFileRoot):
+		return "", fmt.Errorf("%s is outside %s", arg, root)
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("file not found: %s", arg)
+	case err != nil:
+		return "", fmt.Errorf("cannot access %s: %w", arg, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("cannot access %s: %w", arg, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file — /cp and /mv work on single files", arg)
+	}
+	return resolved, nil
+}
+
+// fileOpDest resolves the destination of /cp or /mv. A destination that is
+// an existing directory receives the file under its own name. Like /save,
+// the result must be inside root and is never an existing file.
+func fileOpDest(root, dir, src, arg string) (string, error) {
+	path := expandPath(arg)
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, filepath.Base(src))
+	}
+	dst, err := savePath(root, "", path)
+	if err != nil {
+		return "", err
+	}
+	if dst == src {
+		return "", fmt.Errorf("%s and %s are the same file", filepath.Base(src), arg)
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	return dst, nil
+}
+
+// copyFile copies src to a new file dst with the same permissions and
+// returns the number of bytes copied. dst is never overwritten.
+func copyFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if errors.Is(err, fs.ErrExist) {
+		return 0, fmt.Errorf("%s already exists", dst)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return 0, err
+	}
+	return n, nil
+}
+
+// moveFile moves src to dst, which must not exist, and returns the file's
+// size. Across filesystems, where rename fails, it copies and removes src.
+func moveFile(src, dst string) (int64, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return 0, fmt.Errorf("%s already exists", dst)
+	}
+	err = os.Rename(src, dst)
+	if err == nil {
+		return info.Size(), nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return 0, err
+	}
+	n, err := copyFile(src, dst)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(src); err != nil {
+		return n, fmt.Errorf("copied to %s but could not remove %s: %w", dst, src, err)
+	}
+	return n, nil
+}
+
+// fileOp handles /cp and /mv <src> <dst>. Both run in Go rather than the
+// shell, so they behave the same everywhere and fail with plain errors.
+func (tb *TelegramBridge) fileOp(chatID int64, username, name string, args []string) {
+	if len(args) != 2 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Usage: /%s <src> <dst>", name)))
+		return
+	}
+	tb.mu.RLock()
+	root := tb.config.fileRoot()
+	tb.mu.RUnlock()
+	dir := tb.sessionDir(chatID)
+
+	src, err := fileOpSource(root, dir, args[0])
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	dst, err := fileOpDest(root, dir, src, args[1])
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	fmt.Printf("📱 @%s → [%s] %s %s\n\n", username, name, src, dst)
+
+	op, verb := copyFile, "Copied"
+	if name == "mv" {
+		op, verb = moveFile, "Moved"
+	}
+	n, err := op(src, dst)
+	if err != nil {
+		log.Printf("❌ /%s failed: %v\n", name, err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📄 %s %s to %s (%d bytes)", verb, args[0], dst, n)))
+}
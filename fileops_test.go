@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newFileOpRoot returns a file root holding src/a.txt and an empty dst/.
+func newFileOpRoot(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(root, "src"), 0755)
+	os.Mkdir(filepath.Join(root, "dst"), 0755)
+	if err := os.WriteFile(filepath.Join(root, "src", "a.txt"), []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFileOpSource(t *testing.T) {
+	root := newFileOpRoot(t)
+	srcDir := filepath.Join(root, "src")
+
+	if got, err := fileOpSource(root, srcDir, "a.txt"); err != nil || got != filepath.Join(srcDir, "a.txt") {
+		t.Errorf("fileOpSource(relative) = %q, %v", got, err)
+	}
+	tests := []struct{ arg, wantErr string }{
+		{"missing.txt", "file not found"},
+		{"../../escape.txt", "outside"},
+		{".", "not a regular file"},
+	}
+	for _, tt := range tests {
+		if _, err := fileOpSource(root, srcDir, tt.arg); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("fileOpSource(%q) error = %v, want %q", tt.arg, err, tt.wantErr)
+		}
+	}
+}
+
+// TestFileOpDest verifies directory destinations keep the file's name and
+// existing files are refused rather than overwritten
+func TestFileOpDest(t *testing.T) {
+	root := newFileOpRoot(t)
+	src := filepath.Join(root, "src", "a.txt")
+
+	if got, err := fileOpDest(root, root, src, "dst"); err != nil || got != filepath.Join(root, "dst", "a.txt") {
+		t.Errorf("fileOpDest(directory) = %q, %v", got, err)
+	}
+	if got, err := fileOpDest(root, root, src, "dst/b.txt"); err != nil || got != filepath.Join(root, "dst", "b.txt") {
+		t.Errorf("fileOpDest(file) = %q, %v", got, err)
+	}
+	if _, err := fileOpDest(root, root, src, "src"); err == nil || !strings.Contains(err.Error(), "same file") {
+		t.Errorf("same file error = %v", err)
+	}
+	os.WriteFile(filepath.Join(root, "dst", "taken.txt"), []byte("keep"), 0644)
+	if _, err := fileOpDest(root, root, src, "dst/taken.txt"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("overwrite error = %v", err)
+	}
+	if _, err := fileOpDest(root, root, src, "../escape.txt"); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("escaping destination error = %v", err)
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	root := newFileOpRoot(t)
+	src := filepath.Join(root, "src", "a.txt")
+	dst := filepath.Join(root, "dst", "a.txt")
+
+	n, err := copyFile(src, dst)
+	if err != nil || n != 5 {
+		t.Fatalf("copyFile() = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("copied %q", data)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("source gone after copy: %v", err)
+	}
+
+	os.WriteFile(src, []byte("changed"), 0640)
+	if _, err := copyFile(src, dst); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("overwrite error = %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("existing file overwritten with %q", data)
+	}
+
+	if _, err := copyFile(filepath.Join(root, "missing.txt"), filepath.Join(root, "dst", "m.txt")); err == nil {
+		t.Error("copyFile() of a missing source succeeded")
+	}
+}
+
+// TestMoveFile verifies a move across directories and that existing files
+// and missing sources are reported
+func TestMoveFile(t *testing.T) {
+	root := newFileOpRoot(t)
+	src := filepath.Join(root, "src", "a.txt")
+	dst := filepath.Join(root, "dst", "moved.txt")
+
+	n, err := moveFile(src, dst)
+	if err != nil || n != 5 {
+		t.Fatalf("moveFile() = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("moved %q", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists after move: %v", err)
+	}
+
+	if _, err := moveFile(src, filepath.Join(root, "dst", "again.txt")); err == nil {
+		t.Error("moveFile() of a missing source succeeded")
+	}
+
+	other := filepath.Join(root, "src", "b.txt")
+	os.WriteFile(other, []byte("other"), 0644)
+	if _, err := moveFile(other, dst); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("overwrite error = %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("existing file overwritten with %q", data)
+	}
+}
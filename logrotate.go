@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// defaultLogBackups is how many rotated daemon logs are kept
+// (remote-term.log.1 … remote-term.log.3).
+const defaultLogBackups = 3
+
+// rotatingWriter is an io.Writer that appends to a log file and rotates it
+// once it would grow past maxSize: path.1 becomes path.2 and so on, the
+// oldest backup is dropped, the current file becomes path.1, and a fresh
+// file is opened. A non-positive maxSize disables rotation.
+type rotatingWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+// newRotatingWriter opens path for appending, picking up its current size
+// so an existing log counts towards the limit.
+func newRotatingWriter(path string, maxSize int64, backups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize, backups: backups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p to the log, rotating first if p would push it past maxSize.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// open opens the log file in append mode and records its size.
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups along, moves the current log to path.1 and
+// reopens an empty log. Caller must hold w.mu.
+func (w *rotatingWriter) rotate() error {
+	w.file.Close()
+
+	os.Remove(w.backupPath(w.backups))
+	for i := w.backups - 1; i >= 1; i-- {
+		os.Rename(w.backupPath(i), w.backupPath(i+1))
+	}
+	if w.backups > 0 {
+		os.Rename(w.path, w.backupPath(1))
+	} else {
+		os.Remove(w.path)
+	}
+	return w.open()
+}
+
+// backupPath returns the path of the n-th rotated log.
+func (w *rotatingWriter) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", w.path, n)
+}
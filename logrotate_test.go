@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRotatingWriterRotatesAtLimit verifies a write that would exceed the
+// limit moves the current log to .1 and starts a fresh file
+func TestRotatingWriterRotatesAtLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remote-term.log")
+	w, err := newRotatingWriter(path, 10, 3)
+	if err != nil {
+		t.Fatalf("newRotatingWriter() error: %v", err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("12345678\n")) // 9 bytes, under the limit
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatal("rotated before reaching the limit")
+	}
+
+	w.Write([]byte("second\n")) // would make 16 bytes
+	old, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("expected rotated log: %v", err)
+	}
+	if string(old) != "12345678\n" {
+		t.Errorf("rotated log = %q", old)
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "second\n" {
+		t.Errorf("current log = %q", current)
+	}
+}
+
+// TestRotatingWriterKeepsBackups verifies only the configured number of
+// rotated logs are kept, newest in .1
+func TestRotatingWriterKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remote-term.log")
+	w, err := newRotatingWriter(path, 5, 3)
+	if err != nil {
+		t.Fatalf("newRotatingWriter() error: %v", err)
+	}
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n"} {
+		w.Write([]byte(line))
+	}
+
+	want := map[string]string{
+		path:        "eeee\n",
+		path + ".1": "dddd\n",
+		path + ".2": "cccc\n",
+		path + ".3": "bbbb\n",
+	}
+	for file, content := range want {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("missing %s: %v", filepath.Base(file), err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".4"); !os.IsNotExist(err) {
+		t.Error("kept more backups than configured")
+	}
+}
+
+// TestRotatingWriterCountsExistingLog verifies an existing log's size counts
+// towards the limit after a restart
+func TestRotatingWriterCountsExistingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remote-term.log")
+	os.WriteFile(path, []byte(strings.Repeat("x", 10)), 0644)
+
+	w, err := newRotatingWriter(path, 10, 3)
+	if err != nil {
+		t.Fatalf("newRotatingWriter() error: %v", err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("new\n"))
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("expected rotation of pre-existing log: %v", err)
+	}
+}
+
+// TestLogMaxSizeDefaults verifies the rotation size falls back to 10MB and
+// negative values disable rotation
+func TestLogMaxSizeDefaults(t *testing.T) {
+	var nilConfig *Config
+	if got := nilConfig.logMaxSize(); got != defaultLogMaxSize {
+		t.Errorf("nil config logMaxSize() = %d, want %d", got, defaultLogMaxSize)
+	}
+	if got := (&Config{LogMaxSize: 1024}).logMaxSize(); got != 1024 {
+		t.Errorf("logMaxSize() = %d, want 1024", got)
+	}
+	if got := (&Config{LogMaxSize: -1}).logMaxSize(); got != 0 {
+		t.Errorf("negative logMaxSize() = %d, want 0", got)
+	}
+}
@@ -6,14 +6,19 @@ import (
 	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Version is set at build time via ldflags
@@ -23,9 +28,416 @@ type Config struct {
 	BotToken          string  `json:"bot_token"`
 	AllowedUsers      []int64 `json:"allowed_users"`
 	WebUIPasswordHash string  `json:"webui_password_hash,omitempty"`
+	WelcomeMessage    string  `json:"welcome_message,omitempty"` // Markdown reply to /start (default: connection help)
+	CommandPrefix     string  `json:"command_prefix,omitempty"`  // If set, only messages starting with it (or /commands) run, e.g. "!" in groups
+	CommandTimeout    int     `json:"command_timeout,omitempty"` // Seconds before a one-shot command is abandoned (default 30)
+	MaxUploadSize     int64   `json:"max_upload_size,omitempty"` // Max bytes accepted for Telegram document uploads (default 20MB)
+
+	Bots []BotConfig `json:"bots,omitempty"` // Several Telegram bots in one process; replaces bot_token/allowed_users
+
+	InteractiveCommands []string `json:"interactive_commands,omitempty"`  // Extra REPLs/TUIs that need a persistent session
+	RateLimitPerMinute  int      `json:"rate_limit_per_minute,omitempty"` // Commands per user per minute (default 20, negative disables)
+	MaxSessions         int      `json:"max_sessions,omitempty"`          // Active sessions per bot and per WebUI (default 20, negative disables)
+	DeniedCommands      []string `json:"denied_commands,omitempty"`       // Globs for first words that are refused, e.g. "rm*"
+	AllowedCommands     []string `json:"allowed_commands,omitempty"`      // Strict mode: only first words matching these globs run
+
+	TLSCert string `json:"tls_cert,omitempty"` // WebUI TLS certificate file (enables HTTPS with TLSKey)
+	TLSKey  string `json:"tls_key,omitempty"`  // WebUI TLS private key file
+
+	WebUIHost          string   `json:"webui_host,omitempty"`           // WebUI bind address (default localhost, 0.0.0.0 for all interfaces)
+	Shell              string   `json:"shell,omitempty"`                // Shell binary for sessions, e.g. /usr/bin/zsh (default bash, then sh)
+	LoadShellRC        bool     `json:"load_shell_rc,omitempty"`        // Start the shell as a login shell that loads the user's rc files
+	WorkingDir         string   `json:"working_dir,omitempty"`          // Directory sessions start in (default the bridge's)
+	SessionInitCommand string   `json:"session_init_command,omitempty"` // Run in each new shell before the first command, output hidden (e.g. source venv/bin/activate)
+	FileRoot           string   `json:"file_root,omitempty"`            // Directory the WebUI file browser, /save, /cp and /mv are confined to (default home)
+	RunAsUser          string   `json:"run_as_user,omitempty"`          // Run session shells as this user (Unix, requires root)
+	WebUIURL           string   `json:"webui_url,omitempty"`            // Public WebUI address for /upload links (default the bind address)
+	WebUITheme         string   `json:"webui_theme,omitempty"`          // WebUI color theme: matrix (default), solarized-dark, or light
+	QuickCommands      []string `json:"quick_commands,omitempty"`       // Commands shown as WebUI quick buttons, sent with Enter
+	DirectExec         bool     `json:"direct_exec,omitempty"`          // Exec interactive commands in the PTY instead of typing them into a shell
+
+	MaxOutputBytes           int    `json:"max_output_bytes,omitempty"`            // Output sent per command before truncating (default 100KB, negative disables)
+	OutputMode               string `json:"output_mode,omitempty"`                 // How one-shot output is processed: vte (default), strip, or raw
+	PreserveColors           bool   `json:"preserve_colors,omitempty"`             // Render colored/bold terminal output as <code>/<b> in Telegram
+	FileAttachThresholdBytes int    `json:"file_attach_threshold_bytes,omitempty"` // Output above this is sent as a .txt file (default 8KB, negative disables)
+	BinaryThresholdPercent   int    `json:"binary_threshold_percent,omitempty"`    // Percent of non-printable characters that marks output as binary (default 10, negative disables)
+	ChunkDelayMs             int    `json:"chunk_delay_ms,omitempty"`              // Pause between chunks of a long Telegram message (default 100, negative disables)
+
+	WebUIResumeGrace    int   `json:"webui_resume_grace,omitempty"`     // Seconds a dropped WebUI session waits for a reconnect (default 60, negative disables)
+	WebUIIdleLogout     int   `json:"webui_idle_logout,omitempty"`      // Minutes without activity before a WebUI login expires (default 120, negative disables)
+	WebUILoginMaxHours  int   `json:"webui_login_max_hours,omitempty"`  // Hours a WebUI login lasts at most, however active (default 24)
+	WebUIAutoStartShell *bool `json:"webui_auto_start_shell,omitempty"` // Start a shell as soon as a tab connects (default true); false waits for Start or a command
+
+	DefaultRows int `json:"default_rows,omitempty"` // Initial terminal height in rows (default 50)
+	DefaultCols int `json:"default_cols,omitempty"` // Initial terminal width in columns (default 120)
+
+	LogMaxSize int64 `json:"log_max_size,omitempty"` // Daemon log bytes before rotating (default 10MB, negative disables)
+	BcryptCost int   `json:"bcrypt_cost,omitempty"`  // bcrypt work factor for the WebUI password (default 10, max 31)
+	AuditLog   bool  `json:"audit_log,omitempty"`    // Append each Telegram command and its first output line to audit.log
+
+	RecordSessions bool `json:"record_sessions,omitempty"` // Write every session's raw output, timestamped, to transcripts/ in the config dir
+
+	IdleTimeoutMinutes *int `json:"idle_timeout_minutes,omitempty"` // Minutes without output before a session is stopped (default 30, 0 disables)
+
+	Timezone string `json:"timezone,omitempty"` // IANA time zone for times shown in Telegram, e.g. Europe/Berlin (default the server's)
+
+	UserProfiles map[int64]UserProfile `json:"user_profiles,omitempty"` // Per-user session settings, keyed by Telegram user ID
+	Aliases      map[string]string     `json:"aliases,omitempty"`       // Command shortcuts expanded on the first word, e.g. "ll": "ls -la"
+}
+
+// BotConfig is one Telegram bot in Config.Bots, with its own whitelist.
+// All other settings are shared.
+type BotConfig struct {
+	BotToken     string  `json:"bot_token"`
+	AllowedUsers []int64 `json:"allowed_users"`
+}
+
+// botConfigs returns the bots to run: Config.Bots, or the top-level
+// bot_token and allowed_users as a single bot.
+func (c *Config) botConfigs() []BotConfig {
+	if len(c.Bots) > 0 {
+		return c.Bots
+	}
+	return []BotConfig{{BotToken: c.BotToken, AllowedUsers: c.AllowedUsers}}
+}
+
+// forBot returns a copy of the config with a bot's token and whitelist in
+// the top-level fields the bridge reads.
+func (c *Config) forBot(bot BotConfig) *Config {
+	copied := *c
+	copied.BotToken = bot.BotToken
+	copied.AllowedUsers = append([]int64(nil), bot.AllowedUsers...)
+	return &copied
+}
+
+// UserProfile overrides the global session settings for one Telegram user.
+// Empty fields fall back to the global Config.
+type UserProfile struct {
+	Shell      string            `json:"shell,omitempty"`       // Shell binary for the user's sessions
+	WorkingDir string            `json:"working_dir,omitempty"` // Directory the user's sessions start in
+	Env        map[string]string `json:"env,omitempty"`         // Environment variables for the user's sessions
+}
+
+// forUser returns the config for creating userID's terminals: a copy with
+// the user's profile Shell and WorkingDir applied, or c itself when the
+// user has no profile.
+func (c *Config) forUser(userID int64) *Config {
+	if c == nil {
+		return nil
+	}
+	profile, ok := c.UserProfiles[userID]
+	if !ok {
+		return c
+	}
+	cfg := *c
+	if profile.Shell != "" {
+		cfg.Shell = profile.Shell
+	}
+	if profile.WorkingDir != "" {
+		cfg.WorkingDir = profile.WorkingDir
+	}
+	return &cfg
+}
+
+// withProfileEnv layers env over userID's profile Env, so per-chat
+// overrides win. It returns nil when both are empty.
+func (c *Config) withProfileEnv(userID int64, env map[string]string) map[string]string {
+	var profileEnv map[string]string
+	if c != nil {
+		profileEnv = c.UserProfiles[userID].Env
+	}
+	if len(profileEnv) == 0 {
+		return env
+	}
+	merged := make(map[string]string, len(profileEnv)+len(env))
+	for k, v := range profileEnv {
+		merged[k] = v
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return merged
+}
+
+// defaultCommandTimeout is used when Config.CommandTimeout is unset.
+const defaultCommandTimeout = 30 * time.Second
+
+// defaultMaxUploadSize matches the Bot API getFile download limit.
+const defaultMaxUploadSize = 20 * 1024 * 1024
+
+// maxUploadSize returns the upload size limit, falling back to the default when unset.
+func (c *Config) maxUploadSize() int64 {
+	if c == nil || c.MaxUploadSize <= 0 {
+		return defaultMaxUploadSize
+	}
+	return c.MaxUploadSize
+}
+
+// defaultRateLimitPerMinute is used when Config.RateLimitPerMinute is unset.
+const defaultRateLimitPerMinute = 20
+
+// rateLimitPerMinute returns the per-user command limit. Zero means unlimited.
+func (c *Config) rateLimitPerMinute() int {
+	if c == nil || c.RateLimitPerMinute == 0 {
+		return defaultRateLimitPerMinute
+	}
+	if c.RateLimitPerMinute < 0 {
+		return 0
+	}
+	return c.RateLimitPerMinute
+}
+
+// Output modes for one-shot commands (Config.OutputMode).
+const (
+	outputModeVTE   = "vte"   // Render through a virtual terminal and send what changed on screen
+	outputModeStrip = "strip" // Remove escape codes, keeping the text as printed
+	outputModeRaw   = "raw"   // Send output untouched, for clients that emulate a terminal
+)
+
+// outputMode returns how one-shot command output is processed: vte unless
+// Config.OutputMode names another mode.
+func (c *Config) outputMode() string {
+	if c != nil && (c.OutputMode == outputModeStrip || c.OutputMode == outputModeRaw) {
+		return c.OutputMode
+	}
+	return outputModeVTE
+}
+
+// defaultMaxSessions is used when Config.MaxSessions is unset.
+const defaultMaxSessions = 20
+
+// maxSessions returns how many sessions may be active at once. Zero means
+// unlimited.
+func (c *Config) maxSessions() int {
+	if c == nil || c.MaxSessions == 0 {
+		return defaultMaxSessions
+	}
+	if c.MaxSessions < 0 {
+		return 0
+	}
+	return c.MaxSessions
+}
+
+// defaultMaxOutputBytes is used when Config.MaxOutputBytes is unset.
+const defaultMaxOutputBytes = 100 * 1024
+
+// maxOutputBytes returns the per-command output cap. Zero means unlimited.
+func (c *Config) maxOutputBytes() int {
+	if c == nil || c.MaxOutputBytes == 0 {
+		return defaultMaxOutputBytes
+	}
+	if c.MaxOutputBytes < 0 {
+		return 0
+	}
+	return c.MaxOutputBytes
+}
+
+// defaultFileAttachThreshold is used when Config.FileAttachThresholdBytes is unset.
+const defaultFileAttachThreshold = 8 * 1024
+
+// fileAttachThreshold returns the output size above which Telegram output
+// is sent as a file. Zero means always send inline.
+func (c *Config) fileAttachThreshold() int {
+	if c == nil || c.FileAttachThresholdBytes == 0 {
+		return defaultFileAttachThreshold
+	}
+	if c.FileAttachThresholdBytes < 0 {
+		return 0
+	}
+	return c.FileAttachThresholdBytes
+}
+
+// defaultBinaryThresholdPercent is used when Config.BinaryThresholdPercent is unset.
+const defaultBinaryThresholdPercent = 10
+
+// binaryThresholdPercent returns the share of non-printable characters, in
+// percent, above which Telegram output is withheld as binary. Zero means
+// output is never treated as binary.
+func (c *Config) binaryThresholdPercent() int {
+	if c == nil || c.BinaryThresholdPercent == 0 {
+		return defaultBinaryThresholdPercent
+	}
+	if c.BinaryThresholdPercent < 0 {
+		return 0
+	}
+	return c.BinaryThresholdPercent
+}
+
+// defaultChunkDelay is used when Config.ChunkDelayMs is unset.
+const defaultChunkDelay = 100 * time.Millisecond
+
+// chunkDelay returns the pause between the chunks of a long Telegram
+// message, which keeps bursts under Telegram's flood limits. Zero means
+// chunks are sent back to back.
+func (c *Config) chunkDelay() time.Duration {
+	if c == nil || c.ChunkDelayMs == 0 {
+		return defaultChunkDelay
+	}
+	if c.ChunkDelayMs < 0 {
+		return 0
+	}
+	return time.Duration(c.ChunkDelayMs) * time.Millisecond
+}
+
+// defaultRows and defaultCols are the terminal size used when
+// Config.DefaultRows/DefaultCols are unset.
+const (
+	defaultRows = 50
+	defaultCols = 120
+)
+
+// terminalSize returns the initial rows and columns for session terminals.
+func (c *Config) terminalSize() (rows, cols int) {
+	rows, cols = defaultRows, defaultCols
+	if c != nil && c.DefaultRows > 0 {
+		rows = c.DefaultRows
+	}
+	if c != nil && c.DefaultCols > 0 {
+		cols = c.DefaultCols
+	}
+	return rows, cols
+}
+
+// defaultResumeGrace is used when Config.WebUIResumeGrace is unset.
+const defaultResumeGrace = 60 * time.Second
+
+// resumeGrace returns how long a disconnected WebUI session is kept for a
+// reconnect. Zero means clean up immediately.
+func (c *Config) resumeGrace() time.Duration {
+	if c == nil || c.WebUIResumeGrace == 0 {
+		return defaultResumeGrace
+	}
+	if c.WebUIResumeGrace < 0 {
+		return 0
+	}
+	return time.Duration(c.WebUIResumeGrace) * time.Second
+}
+
+// webUIAutoStartShell reports whether a WebUI tab gets a shell as soon as
+// it connects. Unset means true.
+func (c *Config) webUIAutoStartShell() bool {
+	return c == nil || c.WebUIAutoStartShell == nil || *c.WebUIAutoStartShell
+}
+
+// defaultWebUIIdleLogout is used when Config.WebUIIdleLogout is unset.
+const defaultWebUIIdleLogout = 2 * time.Hour
+
+// webUIIdleLogout returns how long a WebUI login survives without activity.
+// Zero means only the absolute limit applies.
+func (c *Config) webUIIdleLogout() time.Duration {
+	if c == nil || c.WebUIIdleLogout == 0 {
+		return defaultWebUIIdleLogout
+	}
+	if c.WebUIIdleLogout < 0 {
+		return 0
+	}
+	return time.Duration(c.WebUIIdleLogout) * time.Minute
+}
+
+// defaultWebUILoginMax is used when Config.WebUILoginMaxHours is unset.
+const defaultWebUILoginMax = 24 * time.Hour
+
+// webUILoginMax returns the absolute lifetime of a WebUI login.
+func (c *Config) webUILoginMax() time.Duration {
+	if c == nil || c.WebUILoginMaxHours <= 0 {
+		return defaultWebUILoginMax
+	}
+	return time.Duration(c.WebUILoginMaxHours) * time.Hour
+}
+
+// defaultLogMaxSize is used when Config.LogMaxSize is unset.
+const defaultLogMaxSize = 10 * 1024 * 1024
+
+// logMaxSize returns the daemon log size that triggers rotation. Zero means
+// never rotate.
+func (c *Config) logMaxSize() int64 {
+	if c == nil || c.LogMaxSize == 0 {
+		return defaultLogMaxSize
+	}
+	if c.LogMaxSize < 0 {
+		return 0
+	}
+	return c.LogMaxSize
+}
+
+// bcryptCost returns the work factor for hashing the WebUI password,
+// falling back to bcrypt.DefaultCost when unset or below bcrypt.MinCost.
+func (c *Config) bcryptCost() int {
+	if c == nil || c.BcryptCost < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	if c.BcryptCost > bcrypt.MaxCost {
+		return bcrypt.MaxCost
+	}
+	return c.BcryptCost
+}
+
+// fileRoot returns the directory the WebUI file browser may show and /save
+// may write to: Config.FileRoot, or the user's home directory. Empty if
+// neither is known.
+func (c *Config) fileRoot() string {
+	if c != nil && c.FileRoot != "" {
+		return c.FileRoot
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// defaultIdleTimeout is used when Config.IdleTimeoutMinutes is unset.
+const defaultIdleTimeout = 30 * time.Minute
+
+// location returns the time zone for times shown to Telegram users:
+// Config.Timezone, or the server's local zone when it is unset or not a
+// known IANA name.
+func (c *Config) location() *time.Location {
+	if c == nil || c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		log.Printf("Warning: unknown timezone %q, using server local time: %v\n", c.Timezone, err)
+		return time.Local
+	}
+	return loc
+}
+
+// idleTimeout returns how long a session may go without output before it
+// is stopped. Zero means never. Unlike the other limits an explicit 0
+// disables it, hence the pointer field.
+func (c *Config) idleTimeout() time.Duration {
+	if c == nil || c.IdleTimeoutMinutes == nil {
+		return defaultIdleTimeout
+	}
+	if *c.IdleTimeoutMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(*c.IdleTimeoutMinutes) * time.Minute
+}
+
+// idleTimeoutMessage is the notice sent when a session idles out.
+func idleTimeoutMessage(timeout time.Duration) string {
+	idle := timeout.String()
+	if timeout%time.Minute == 0 {
+		idle = fmt.Sprintf("%dmin", int(timeout.Minutes()))
+	}
+	return fmt.Sprintf("⏱️ Session timed out (%s idle)", idle)
+}
+
+// commandTimeout returns the one-shot command timeout, falling back to the
+// default when unset. Safe to call on a nil config (WebUI before setup).
+func (c *Config) commandTimeout() time.Duration {
+	if c == nil || c.CommandTimeout <= 0 {
+		return defaultCommandTimeout
+	}
+	return time.Duration(c.CommandTimeout) * time.Second
 }
 
 func main() {
+	launchArgs = slices.Clone(os.Args)
+
 	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
 		fmt.Printf("remote-term v%s\n", version)
 		return
@@ -43,6 +455,24 @@ func main() {
 		return
 	}
 
+	// --reload: make the running daemon re-read its config
+	if len(os.Args) > 1 && os.Args[1] == "--reload" {
+		daemonReload()
+		return
+	}
+
+	// --restart: stop the running daemon and start it again
+	if len(os.Args) > 1 && os.Args[1] == "--restart" {
+		daemonRestart(os.Args[2:])
+		return
+	}
+
+	// --list-sessions: show sessions of the running daemon
+	if len(os.Args) > 1 && os.Args[1] == "--list-sessions" {
+		daemonListSessions()
+		return
+	}
+
 	// --daemon: start as background daemon
 	// --daemon-child: internal flag used by the daemon parent process
 	// Check for --daemon or --daemon-child anywhere in args (can combine with --web)
@@ -81,10 +511,14 @@ func main() {
 		}
 		os.Args = cleanArgs
 
-		// Set up log output to the log file
-		logFile, err := os.OpenFile(logFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		// Set up log output to the log file, rotating it by size. Stdout and
+		// stderr are routed through the same writer so session echoes and
+		// error output rotate with it.
+		config, _ := loadConfig() // nil-safe: falls back to the default size
+		logWriter, err := newRotatingWriter(logFilePath(), config.logMaxSize(), defaultLogBackups)
 		if err == nil {
-			log.SetOutput(logFile)
+			log.SetOutput(logWriter)
+			redirectOutput(logWriter)
 		}
 
 		// Set cleanup hook for signal-based shutdown (os.Exit bypasses defers)
@@ -108,6 +542,8 @@ func main() {
 		}
 		config, _ := loadConfig() // nil-safe: config may not exist yet for first-time WebUI
 		server := NewWebUIServer(config)
+		server.SetTLS(flagValue(os.Args, "--web-cert"), flagValue(os.Args, "--web-key"))
+		server.SetHost(flagValue(os.Args, "--web-host"))
 		server.Start(port)
 		return
 	}
@@ -124,11 +560,40 @@ func main() {
 	}
 }
 
+// flagValue returns the value following a "--name value" flag in args,
+// or "" if the flag is absent or has no value.
+func flagValue(args []string, name string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// redirectOutput replaces os.Stdout and os.Stderr with a pipe copied into
+// w, so fmt.Print and error output from the daemon child go through the
+// rotating log writer instead of the file descriptors inherited from the
+// parent, which still point at the log file after it is rotated away.
+func redirectOutput(w io.Writer) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	os.Stdout = pw
+	os.Stderr = pw
+	go io.Copy(w, r)
+}
+
+// launchArgs are the arguments the process started with, before the daemon
+// child's flag is stripped, so /restart daemon can run it again the same way.
+var launchArgs []string
+
 // configPathOverride allows tests to redirect config to a temp directory
 var configPathOverride string
 
 // daemonCleanupHook is set when running as daemon child to clean up PID file on signal shutdown.
-// This is called by TelegramBridge.Listen() signal handler (since os.Exit bypasses defers).
+// This is called by the shutdownOnSignal handler (since os.Exit bypasses defers).
 var daemonCleanupHook func()
 
 func getConfigPath() string {
@@ -163,6 +628,34 @@ func saveConfig(config *Config) error {
 	return os.WriteFile(getConfigPath(), data, 0600)
 }
 
+// updateConfig applies change to the config file's current contents and
+// saves the result. The daemon and a --web process each keep their own copy
+// of the config, so saving a whole copy would undo whatever the other one
+// saved since it loaded; changing just the fields on the fresh file doesn't.
+// base is used instead when there is no config file yet.
+func updateConfig(base *Config, change func(*Config) error) error {
+	configSaveMu.Lock()
+	defer configSaveMu.Unlock()
+	config, err := loadConfig()
+	if os.IsNotExist(err) {
+		config, err = &Config{}, nil
+		if base != nil {
+			*config = *base
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := change(config); err != nil {
+		return err
+	}
+	return saveConfig(config)
+}
+
+// configSaveMu serializes config saves within the process, including
+// read-modify-write ones between bridges.
+var configSaveMu sync.Mutex
+
 func generateCode() (string, error) {
 	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
 	if err != nil {
@@ -180,7 +673,11 @@ func setupWithApproval() {
 		line := strings.TrimSpace(scanner.Text())
 
 		if strings.HasPrefix(line, "/setup ") {
-			token := strings.TrimPrefix(line, "/setup ")
+			token, err := validateBotToken(strings.TrimPrefix(line, "/setup "))
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				continue
+			}
 
 			fmt.Println("\n⏳ Connecting to Telegram...")
 
@@ -288,6 +785,7 @@ func setupWithApproval() {
 					log.Fatalf("Error creating bridge: %v", err)
 				}
 
+				shutdownOnSignal([]*TelegramBridge{bridge}, daemonCleanupHook)
 				bridge.Listen()
 			}
 
@@ -298,6 +796,82 @@ func setupWithApproval() {
 	}
 }
 
+// botTokenPattern matches the shape of tokens issued by @BotFather:
+// a numeric bot ID, a colon, and a secret of URL-safe characters.
+var botTokenPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+
+// minBotSecretSize is below the 35 characters BotFather issues, leaving
+// room for format changes while still catching truncated pastes.
+const minBotSecretSize = 30
+
+// validateBotToken trims whitespace and stray quotes from a pasted token and
+// checks its shape, so an obviously wrong token fails with a specific
+// message instead of after a round trip to Telegram.
+func validateBotToken(token string) (string, error) {
+	token = strings.Trim(strings.TrimSpace(token), `"'`)
+	if token == "" {
+		return "", fmt.Errorf("bot token is empty")
+	}
+	id, secret, found := strings.Cut(token, ":")
+	if !found {
+		return "", fmt.Errorf("invalid bot token: expected <bot-id>:<secret> as issued by @BotFather")
+	}
+	if !botTokenPattern.MatchString(token) {
+		if id == "" || strings.Trim(id, "0123456789") != "" {
+			return "", fmt.Errorf("invalid bot token: the part before ':' must be the numeric bot ID")
+		}
+		return "", fmt.Errorf("invalid bot token: the secret may only contain letters, digits, '_' and '-'")
+	}
+	if len(secret) < minBotSecretSize {
+		return "", fmt.Errorf("invalid bot token: the secret is too short (truncated copy?)")
+	}
+	return token, nil
+}
+
+// newTelegramBridges creates a bridge per configured bot, connecting each
+// token with connect. A config without Config.Bots yields one bridge for
+// the top-level bot_token, exactly as before multi-bot support.
+func newTelegramBridges(config *Config, connect func(token string) (*tgbotapi.BotAPI, error)) ([]*TelegramBridge, error) {
+	bots := config.botConfigs()
+	// Errors name the bot only when there is more than one
+	botErr := func(i int, err error) error {
+		if len(bots) > 1 {
+			return fmt.Errorf("bot %d: %w", i+1, err)
+		}
+		return err
+	}
+
+	seen := make(map[string]int)
+	var bridges []*TelegramBridge
+	for i, botConfig := range bots {
+		token, err := validateBotToken(botConfig.BotToken)
+		if err != nil {
+			return nil, botErr(i, err)
+		}
+		if prev, dup := seen[token]; dup {
+			return nil, botErr(i, fmt.Errorf("same token as bot %d", prev+1))
+		}
+		seen[token] = i
+
+		bot, err := connect(token)
+		if err != nil {
+			return nil, botErr(i, fmt.Errorf("error connecting: %w", err))
+		}
+		botConfig.BotToken = token
+		bridge, err := NewTelegramBridge(bot, config.forBot(botConfig))
+		if err != nil {
+			return nil, err
+		}
+		if len(config.Bots) > 0 {
+			id, _, _ := strings.Cut(token, ":")
+			bridge.botIndex = i
+			bridge.sessionIndexFile = "telegram-sessions-" + id + ".json"
+		}
+		bridges = append(bridges, bridge)
+	}
+	return bridges, nil
+}
+
 func startListening() {
 	config, err := loadConfig()
 	if err != nil {
@@ -305,37 +879,61 @@ func startListening() {
 		return
 	}
 
-	bot, err := tgbotapi.NewBotAPI(config.BotToken)
+	bridges, err := newTelegramBridges(config, tgbotapi.NewBotAPI)
 	if err != nil {
-		fmt.Printf("❌ Error connecting: %v\n", err)
+		fmt.Printf("❌ %v (check bot_token in %s)\n", err, getConfigPath())
 		return
 	}
 
 	fmt.Printf("Remote Terminal v%s\n", version)
 	fmt.Printf("✅ Configuration loaded\n")
-	fmt.Printf("👥 Allowed users: %d\n", len(config.AllowedUsers))
+	for _, bridge := range bridges {
+		fmt.Printf("🤖 @%s — 👥 Allowed users: %d\n", bridge.botName, len(bridge.config.AllowedUsers))
+	}
 	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("[Ready] Listening for commands...")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 
-	bridge, err := NewTelegramBridge(bot, config)
-	if err != nil {
-		log.Fatalf("Error creating bridge: %v", err)
+	// Sessions from a previous run died with it; tell their chats
+	for _, bridge := range bridges {
+		bridge.notifyInterruptedSessions()
 	}
 
-	// Set cleanup hook for daemon mode (PID file removal on signal shutdown)
-	if daemonCleanupHook != nil {
-		bridge.cleanupHook = daemonCleanupHook
+	// Control socket lets --list-sessions query this process. There is one
+	// socket per process, so with several bots it serves the first.
+	control, err := bridges[0].serveControl()
+	if err != nil {
+		log.Printf("Warning: %v\n", err)
 	}
 
-	bridge.Listen()
+	// Signal-based shutdown stops every bridge's sessions, then closes the
+	// control socket and, in daemon mode, removes the PID file
+	shutdownOnSignal(bridges, func() {
+		if control != nil {
+			control.Close()
+		}
+		if daemonCleanupHook != nil {
+			daemonCleanupHook()
+		}
+	})
+
+	var wg sync.WaitGroup
+	for _, bridge := range bridges {
+		wg.Add(1)
+		go func(bridge *TelegramBridge) {
+			defer wg.Done()
+			bridge.Listen()
+		}(bridge)
+	}
+	wg.Wait()
 }
 
 func cleanANSI(s string) string {
 	// Comprehensive ANSI escape sequence removal
 	result := strings.Builder{}
 	i := 0
+	linkURL, linkStart := "", 0 // Open OSC 8 hyperlink and where its text starts
 	
 	for i < len(s) {
 		if s[i] == '\x1b' || s[i] == '\u001b' { // ESC character
@@ -358,17 +956,30 @@ func cleanANSI(s string) string {
 			// OSC sequences: ESC ] ... (terminated by BEL or ESC \)
 			if s[i] == ']' {
 				i++
-				for i < len(s) {
-					if s[i] == '\x07' { // BEL
-						i++
-						break
-					}
-					if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
-						i += 2
-						break
-					}
+				start := i
+				for i < len(s) && s[i] != '\x07' && !(s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\') {
 					i++
 				}
+				payload := s[start:i]
+				if i < len(s) && s[i] == '\x07' { // BEL
+					i++
+				} else if i < len(s) {
+					i += 2
+				}
+
+				// OSC 8 hyperlinks (ESC ]8;params;url ST text ESC ]8;; ST)
+				// keep their text, followed by the URL unless the text is it
+				if rest, ok := strings.CutPrefix(payload, "8;"); ok {
+					_, url, _ := strings.Cut(rest, ";")
+					if url != "" {
+						linkURL, linkStart = url, result.Len()
+					} else if linkURL != "" {
+						if text := strings.TrimSpace(result.String()[linkStart:]); text != linkURL {
+							result.WriteString(" (" + linkURL + ")")
+						}
+						linkURL = ""
+					}
+				}
 				continue
 			}
 			
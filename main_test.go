@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // TestGenerateCode tests approval code generation
@@ -133,6 +135,16 @@ func TestCleanANSI(t *testing.T) {
 			input: "\x1b[31m\x1b[0m\x1b[2J",
 			want:  "",
 		},
+		{
+			name:  "osc8_link",
+			input: "see \x1b]8;;https://example.com/docs\x1b\\the docs\x1b]8;;\x1b\\ now",
+			want:  "see the docs (https://example.com/docs) now",
+		},
+		{
+			name:  "osc8_link_bel_with_params",
+			input: "\x1b]8;id=1;https://example.com\x07https://example.com\x1b]8;;\x07",
+			want:  "https://example.com",
+		},
 	}
 
 	for _, tt := range tests {
@@ -228,6 +240,76 @@ func TestConfigSaveLoad(t *testing.T) {
 	}
 }
 
+// TestUserProfilesSaveLoad verifies per-user profiles survive a save/load
+// round trip, keyed by user ID
+func TestUserProfilesSaveLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	config := &Config{
+		BotToken:     "123456:ABCdefGHIjklMNOpqrsTUVwxyz",
+		AllowedUsers: []int64{111, 222},
+		UserProfiles: map[int64]UserProfile{
+			222: {Shell: "/bin/zsh", WorkingDir: "/srv/app", Env: map[string]string{"EDITOR": "vim"}},
+		},
+	}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig() error = %v", err)
+	}
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	profile, ok := loaded.UserProfiles[222]
+	if !ok {
+		t.Fatalf("profile for 222 not loaded: %+v", loaded.UserProfiles)
+	}
+	if profile.Shell != "/bin/zsh" || profile.WorkingDir != "/srv/app" || profile.Env["EDITOR"] != "vim" {
+		t.Errorf("loaded profile = %+v", profile)
+	}
+	if _, ok := loaded.UserProfiles[111]; ok {
+		t.Error("unexpected profile for 111")
+	}
+}
+
+// TestConfigForUser verifies profiles override the global shell, working
+// dir and env, and users without one get the global config
+func TestConfigForUser(t *testing.T) {
+	config := &Config{
+		Shell:      "/bin/bash",
+		WorkingDir: "/home/shared",
+		UserProfiles: map[int64]UserProfile{
+			1: {Shell: "/bin/zsh", Env: map[string]string{"A": "profile", "B": "profile"}},
+		},
+	}
+
+	if got := config.forUser(2); got != config {
+		t.Error("forUser() without a profile should return the global config")
+	}
+	got := config.forUser(1)
+	if got.Shell != "/bin/zsh" || got.WorkingDir != "/home/shared" {
+		t.Errorf("forUser(1) shell=%q dir=%q, want /bin/zsh and the global dir", got.Shell, got.WorkingDir)
+	}
+	if config.Shell != "/bin/bash" {
+		t.Error("forUser() modified the global config")
+	}
+
+	env := config.withProfileEnv(1, map[string]string{"B": "chat"})
+	if env["A"] != "profile" || env["B"] != "chat" {
+		t.Errorf("withProfileEnv() = %v, want chat overrides on top of the profile", env)
+	}
+	if env := config.withProfileEnv(2, nil); env != nil {
+		t.Errorf("withProfileEnv() without a profile = %v, want nil", env)
+	}
+	var nilConfig *Config
+	if nilConfig.forUser(1) != nil {
+		t.Error("nil config forUser() should be nil")
+	}
+}
+
 // TestConfigLoadNonExistent tests loading when config doesn't exist
 func TestConfigLoadNonExistent(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -630,3 +712,179 @@ func TestCollectOutputWithMultipleReaders(t *testing.T) {
 		t.Errorf("collected %d characters, want 10", result.Len())
 	}
 }
+
+// TestFlagValue tests "--name value" flag lookup
+func TestFlagValue(t *testing.T) {
+	args := []string{"remote-term", "--web", "8443", "--web-cert", "cert.pem", "--web-key"}
+
+	if got := flagValue(args, "--web-cert"); got != "cert.pem" {
+		t.Errorf("flagValue(--web-cert) = %q, want cert.pem", got)
+	}
+	if got := flagValue(args, "--web-key"); got != "" {
+		t.Errorf("flagValue(--web-key) with no value = %q, want empty", got)
+	}
+	if got := flagValue(args, "--missing"); got != "" {
+		t.Errorf("flagValue(--missing) = %q, want empty", got)
+	}
+}
+
+// TestIdleTimeout tests the idle timeout default and that an explicit 0 disables it
+func TestWebUILoginLimits(t *testing.T) {
+	var nilConfig *Config
+	if got := nilConfig.webUIIdleLogout(); got != defaultWebUIIdleLogout {
+		t.Errorf("nil config webUIIdleLogout() = %v, want %v", got, defaultWebUIIdleLogout)
+	}
+	if got := (&Config{WebUIIdleLogout: 15}).webUIIdleLogout(); got != 15*time.Minute {
+		t.Errorf("webUIIdleLogout() = %v, want 15m", got)
+	}
+	if got := (&Config{WebUIIdleLogout: -1}).webUIIdleLogout(); got != 0 {
+		t.Errorf("negative webUIIdleLogout() = %v, want disabled", got)
+	}
+	if got := nilConfig.webUILoginMax(); got != defaultWebUILoginMax {
+		t.Errorf("nil config webUILoginMax() = %v, want %v", got, defaultWebUILoginMax)
+	}
+	if got := (&Config{WebUILoginMaxHours: 8}).webUILoginMax(); got != 8*time.Hour {
+		t.Errorf("webUILoginMax() = %v, want 8h", got)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	minutes := func(n int) *int { return &n }
+
+	var nilConfig *Config
+	if got := nilConfig.idleTimeout(); got != defaultIdleTimeout {
+		t.Errorf("nil config idleTimeout() = %v, want %v", got, defaultIdleTimeout)
+	}
+	if got := (&Config{}).idleTimeout(); got != defaultIdleTimeout {
+		t.Errorf("unset idleTimeout() = %v, want %v", got, defaultIdleTimeout)
+	}
+	if got := (&Config{IdleTimeoutMinutes: minutes(5)}).idleTimeout(); got != 5*time.Minute {
+		t.Errorf("idleTimeout() = %v, want 5m", got)
+	}
+	if got := (&Config{IdleTimeoutMinutes: minutes(0)}).idleTimeout(); got != 0 {
+		t.Errorf("idleTimeout() with 0 = %v, want disabled", got)
+	}
+
+	// An explicit 0 must survive a save/load round trip
+	var loaded Config
+	data, _ := json.Marshal(&Config{IdleTimeoutMinutes: minutes(0)})
+	json.Unmarshal(data, &loaded)
+	if loaded.idleTimeout() != 0 {
+		t.Errorf("idle_timeout_minutes 0 lost in JSON: %s", data)
+	}
+
+	if got := idleTimeoutMessage(45 * time.Minute); got != "⏱️ Session timed out (45min idle)" {
+		t.Errorf("idleTimeoutMessage() = %q", got)
+	}
+}
+
+// TestValidateBotToken verifies well-formed tokens pass (trimmed of paste
+// debris) and malformed ones fail before contacting Telegram
+func TestValidateBotToken(t *testing.T) {
+	const valid = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw_"
+
+	for _, input := range []string{valid, "  " + valid + "\n", `"` + valid + `"`} {
+		got, err := validateBotToken(input)
+		if err != nil {
+			t.Errorf("validateBotToken(%q) error: %v", input, err)
+		}
+		if got != valid {
+			t.Errorf("validateBotToken(%q) = %q, want %q", input, got, valid)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", "   "},
+		{"no colon", "123456789AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"},
+		{"non-numeric id", "bot123:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"},
+		{"missing id", ":AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"},
+		{"bad secret chars", "123456789:AAHdqTcvCH1vGWJxf SeofSAs0K5PALDsaw"},
+		{"truncated secret", "123456789:AAHdqTcv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateBotToken(tt.token); err == nil {
+				t.Errorf("validateBotToken(%q) should fail", tt.token)
+			}
+		})
+	}
+}
+
+// TestNewTelegramBridgesMultiBot verifies each entry in Config.Bots gets its
+// own bridge, whitelist and session index, sharing the other settings
+func TestNewTelegramBridgesMultiBot(t *testing.T) {
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() { configPathOverride = "" })
+
+	secret := strings.Repeat("a", 35)
+	config := &Config{
+		RateLimitPerMinute: 5,
+		Bots: []BotConfig{
+			{BotToken: "111:" + secret, AllowedUsers: []int64{1}},
+			{BotToken: " 222:" + secret + " ", AllowedUsers: []int64{2}},
+		},
+	}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig() error: %v", err)
+	}
+	var connected []string
+	connect := func(token string) (*tgbotapi.BotAPI, error) {
+		connected = append(connected, token)
+		return nil, nil
+	}
+
+	bridges, err := newTelegramBridges(config, connect)
+	if err != nil {
+		t.Fatalf("newTelegramBridges() error: %v", err)
+	}
+	if len(bridges) != 2 || len(connected) != 2 || connected[1] != "222:"+secret {
+		t.Fatalf("got %d bridges, connected %q; want 2 with trimmed tokens", len(bridges), connected)
+	}
+	first, second := bridges[0], bridges[1]
+	if !first.isAllowed(1) || first.isAllowed(2) || !second.isAllowed(2) || second.isAllowed(1) {
+		t.Error("each bridge should only allow its own bot's users")
+	}
+	if second.config.rateLimitPerMinute() != 5 {
+		t.Error("shared settings not passed to every bridge")
+	}
+	if first.sessionIndexPath() == second.sessionIndexPath() {
+		t.Errorf("bridges share session index %s", first.sessionIndexPath())
+	}
+
+	// Approving a user on one bot writes only that bot's whitelist back
+	second.mu.Lock()
+	second.config.AllowedUsers = append(second.config.AllowedUsers, 3)
+	second.mu.Unlock()
+	if err := second.persistConfig(); err != nil {
+		t.Fatalf("persistConfig() error: %v", err)
+	}
+	saved, _ := loadConfig()
+	if len(saved.Bots) != 2 || len(saved.Bots[0].AllowedUsers) != 1 || len(saved.Bots[1].AllowedUsers) != 2 || saved.BotToken != "" {
+		t.Errorf("saved config = %+v, want user 3 added to bot 2 only", saved)
+	}
+
+	config.Bots[1].BotToken = config.Bots[0].BotToken
+	if _, err := newTelegramBridges(config, connect); err == nil || !strings.Contains(err.Error(), "bot 2") {
+		t.Errorf("duplicate token error = %v, want one naming bot 2", err)
+	}
+}
+
+// TestNewTelegramBridgesSingleToken verifies a config without Config.Bots
+// still runs its top-level bot_token as before
+func TestNewTelegramBridgesSingleToken(t *testing.T) {
+	config := &Config{BotToken: "111:" + strings.Repeat("a", 35), AllowedUsers: []int64{1}}
+	bridges, err := newTelegramBridges(config, func(string) (*tgbotapi.BotAPI, error) { return nil, nil })
+	if err != nil || len(bridges) != 1 {
+		t.Fatalf("newTelegramBridges() = %d bridges, %v; want 1", len(bridges), err)
+	}
+	if tb := bridges[0]; tb.botIndex != -1 || !tb.isAllowed(1) || filepath.Base(tb.sessionIndexPath()) != "telegram-sessions.json" {
+		t.Errorf("single-token bridge = index %d, index file %s", tb.botIndex, tb.sessionIndexPath())
+	}
+
+	if _, err := newTelegramBridges(&Config{}, nil); err == nil || strings.Contains(err.Error(), "bot 1") {
+		t.Errorf("missing token error = %v, want one without a bot number", err)
+	}
+}
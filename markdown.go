@@ -14,6 +14,7 @@ var (
 	reInlineCode    = regexp.MustCompile("`([^`\\n]+)`")
 	reHeader        = regexp.MustCompile("(?m)^#{1,6}\\s+(.+)$")
 	reBullet        = regexp.MustCompile("(?m)^(\\s*)[-*]\\s+")
+	reNumbered      = regexp.MustCompile("(?m)^(\\s*)(\\d+)[.)]\\s+")
 	reLink          = regexp.MustCompile("\\[([^\\]]+)\\]\\(([^)]+)\\)")
 	reBold          = regexp.MustCompile("\\*\\*(.+?)\\*\\*")
 	reStrikethrough = regexp.MustCompile("~~(.+?)~~")
@@ -126,7 +127,7 @@ func extractInlineCode(input string) (string, []string) {
 }
 
 // convertMarkdownPatterns converts markdown syntax in HTML-escaped text.
-// Order matters: headers and bullets (line-based) before inline patterns,
+// Order matters: headers and list items (line-based) before inline patterns,
 // bold before italic to avoid ** vs * conflicts.
 func convertMarkdownPatterns(text string) string {
 	// Headers: # Header → <b>Header</b>
@@ -135,6 +136,9 @@ func convertMarkdownPatterns(text string) string {
 	// Bullets: - item or * item → • item
 	text = reBullet.ReplaceAllString(text, "${1}• ")
 
+	// Numbered lists: 1. item or 1) item → 1. item (numbers and indent kept)
+	text = reNumbered.ReplaceAllString(text, "${1}${2}. ")
+
 	// Links: [text](url) → <a href="url">text</a>
 	text = convertLinks(text)
 
@@ -197,18 +201,21 @@ func convertItalic(text string) string {
 func restoreCodeBlocks(text string, blocks []codeBlock) string {
 	for i, block := range blocks {
 		placeholder := fmt.Sprintf("%sCODEBLOCK%d%s", placeholderPrefix, i, placeholderPrefix)
-		escaped := html.EscapeString(block.code)
-		var replacement string
-		if block.language != "" {
-			replacement = fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>", block.language, escaped)
-		} else {
-			replacement = fmt.Sprintf("<pre><code>%s</code></pre>", escaped)
-		}
-		text = strings.Replace(text, placeholder, replacement, 1)
+		text = strings.Replace(text, placeholder, codeBlockHTML(block), 1)
 	}
 	return text
 }
 
+// codeBlockHTML renders a fenced code block as <pre><code>, with a
+// language-* class Telegram uses for highlighting when one was given.
+func codeBlockHTML(block codeBlock) string {
+	escaped := html.EscapeString(block.code)
+	if block.language != "" {
+		return fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>", block.language, escaped)
+	}
+	return fmt.Sprintf("<pre><code>%s</code></pre>", escaped)
+}
+
 // restoreInlineCode replaces placeholder tokens with HTML <code> tags.
 func restoreInlineCode(text string, codes []string) string {
 	for i, code := range codes {
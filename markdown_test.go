@@ -91,6 +91,11 @@ func TestFormatMarkdownToTelegramHTML(t *testing.T) {
 			input: "* item one\n* item two",
 			want:  "• item one\n• item two",
 		},
+		{
+			name:  "numbered_list_with_bold",
+			input: "1. **first**\n2. second",
+			want:  "1. <b>first</b>\n2. second",
+		},
 		{
 			name:  "code_block_with_language",
 			input: "```go\nfmt.Println(\"hello\")\n```",
@@ -263,6 +268,26 @@ func TestConvertMarkdownPatterns(t *testing.T) {
 			input: "  - nested item",
 			want:  "  • nested item",
 		},
+		{
+			name:  "numbered_list",
+			input: "1. first\n2. second\n10. tenth",
+			want:  "1. first\n2. second\n10. tenth",
+		},
+		{
+			name:  "numbered_list_paren_style",
+			input: "1) first\n2)  second",
+			want:  "1. first\n2. second",
+		},
+		{
+			name:  "numbered_and_bullets_nested",
+			input: "1. setup\n   - install go\n   - clone repo\n2. build\n   1. go vet\n   2. go test",
+			want:  "1. setup\n   • install go\n   • clone repo\n2. build\n   1. go vet\n   2. go test",
+		},
+		{
+			name:  "number_mid_line_untouched",
+			input: "version 1. beta",
+			want:  "version 1. beta",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// monitorInterval is how long /monitor samples CPU use for.
+	monitorInterval = 2 * time.Second
+
+	// monitorTopN is how many of the busiest processes /monitor lists.
+	monitorTopN = 3
+)
+
+// procUsage is one process's share of the CPU over the sample.
+type procUsage struct {
+	pid  int
+	name string
+	cpu  float64 // Percent of one core
+}
+
+// monitorReport is the summary /monitor sends.
+type monitorReport struct {
+	load      string  // The 1, 5 and 15 minute load averages
+	cpu       float64 // Percent of the whole machine
+	cores     int
+	hasCPU    bool // Whether cpu was sampled (Linux only)
+	memFree   uint64
+	memTotal  uint64
+	diskDir   string
+	diskUsed  uint64
+	diskTotal uint64
+	top       []procUsage
+}
+
+// sampleMonitor gathers a monitorReport, with disk usage for dir (the
+// bridge's directory if empty). On Linux it reads /proc, sampling CPU use
+// over interval; on macOS it asks sysctl, vm_stat and ps.
+func sampleMonitor(dir string, interval time.Duration) (*monitorReport, error) {
+	var r *monitorReport
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		r, err = sampleProc("/proc", interval)
+	case "darwin":
+		r, err = sampleDarwin()
+	default:
+		return nil, fmt.Errorf("/monitor is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	r.diskDir = dir
+	if out, err := runPipeline(dir, "df -Pk ."); err == nil {
+		r.diskUsed, r.diskTotal, _ = parseDF(out)
+	}
+	return r, nil
+}
+
+// sampleProc reads load, memory and CPU use from a /proc tree at root.
+// CPU use is the change in tick counts over interval, so a process's share
+// is comparable to top's: 100% is one core kept busy.
+func sampleProc(root string, interval time.Duration) (*monitorReport, error) {
+	r := &monitorReport{}
+	loadavg, err := os.ReadFile(filepath.Join(root, "loadavg"))
+	if err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(string(loadavg)); len(fields) >= 3 {
+		r.load = strings.Join(fields[:3], " ")
+	}
+	meminfo, err := os.ReadFile(filepath.Join(root, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	r.memFree, r.memTotal = parseMeminfo(string(meminfo))
+
+	before, err := readCPUStat(root)
+	if err != nil {
+		return nil, err
+	}
+	procsBefore := readProcTicks(root)
+	time.Sleep(interval)
+	after, err := readCPUStat(root)
+	if err != nil {
+		return nil, err
+	}
+	procsAfter := readProcTicks(root)
+
+	r.cores = after.cores
+	total := after.total - before.total
+	if total == 0 {
+		return r, nil
+	}
+	r.hasCPU = true
+	r.cpu = 100 * float64(total-(after.idle-before.idle)) / float64(total)
+
+	for pid, p := range procsAfter {
+		prev, ok := procsBefore[pid]
+		if !ok || p.ticks <= prev.ticks {
+			continue
+		}
+		share := 100 * float64(p.ticks-prev.ticks) / float64(total) * float64(max(r.cores, 1))
+		r.top = append(r.top, procUsage{pid: pid, name: p.name, cpu: share})
+	}
+	sort.Slice(r.top, func(i, j int) bool {
+		if r.top[i].cpu != r.top[j].cpu {
+			return r.top[i].cpu > r.top[j].cpu
+		}
+		return r.top[i].pid < r.top[j].pid
+	})
+	if len(r.top) > monitorTopN {
+		r.top = r.top[:monitorTopN]
+	}
+	return r, nil
+}
+
+// cpuStat is the aggregate line of /proc/stat: ticks spent in total and
+// idle (iowait included), summed over every core.
+type cpuStat struct {
+	total, idle uint64
+	cores       int
+}
+
+// readCPUStat reads the aggregate CPU ticks and the core count from
+// root/stat.
+func readCPUStat(root string) (cpuStat, error) {
+	data, err := os.ReadFile(filepath.Join(root, "stat"))
+	if err != nil {
+		return cpuStat{}, err
+	}
+	return parseCPUStat(string(data))
+}
+
+// parseCPUStat parses the contents of /proc/stat.
+func parseCPUStat(data string) (cpuStat, error) {
+	var s cpuStat
+	found := false
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			s.cores++
+			continue
+		}
+		// Guest time, after the first eight counts, is already in user and nice
+		for i, field := range fields[1:min(len(fields), 9)] {
+			n, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return s, fmt.Errorf("bad cpu line in stat: %q", line)
+			}
+			s.total += n
+			if i == 3 || i == 4 { // idle, iowait
+				s.idle += n
+			}
+		}
+		found = true
+	}
+	if !found {
+		return s, fmt.Errorf("no cpu line in stat")
+	}
+	return s, nil
+}
+
+// procTicks is a process's name and CPU ticks (user plus system) so far.
+type procTicks struct {
+	name  string
+	ticks uint64
+}
+
+// readProcTicks reads the CPU ticks of every process under root. Processes
+// that exit while being read are skipped.
+func readProcTicks(root string) map[int]procTicks {
+	entries, _ := os.ReadDir(root)
+	procs := make(map[int]procTicks)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if p, ok := parseProcStat(string(data)); ok {
+			procs[pid] = p
+		}
+	}
+	return procs
+}
+
+// parseProcStat parses /proc/<pid>/stat. The name is in parentheses and
+// may itself hold spaces or parentheses, so the fields are counted from
+// the last ")".
+func parseProcStat(data string) (procTicks, bool) {
+	open, end := strings.Index(data, "("), strings.LastIndex(data, ")")
+	if open < 0 || end < open {
+		return procTicks{}, false
+	}
+	// After the name: state, ppid, ... utime is the 12th, stime the 13th
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 13 {
+		return procTicks{}, false
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return procTicks{}, false
+	}
+	return procTicks{name: data[open+1 : end], ticks: utime + stime}, true
+}
+
+// parseMeminfo returns the available and total memory in /proc/meminfo,
+// in bytes.
+func parseMeminfo(data string) (free, total uint64) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			free = kb * 1024
+		}
+	}
+	return free, total
+}
+
+// sampleDarwin gathers what macOS offers without /proc. CPU use comes from
+// ps, which averages it over the recent past rather than sampling.
+func sampleDarwin() (*monitorReport, error) {
+	r := &monitorReport{cores: runtime.NumCPU()}
+	if out, err := runPipeline("", "sysctl -n vm.loadavg"); err == nil {
+		r.load = strings.Join(strings.Fields(strings.Trim(out, "{} \n")), " ")
+	}
+	if out, err := runPipeline("", "sysctl -n hw.memsize"); err == nil {
+		r.memTotal, _ = strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+	}
+	if out, err := runPipeline("", "vm_stat"); err == nil {
+		r.memFree = parseVMStat(out)
+	}
+	out, err := runPipeline("", fmt.Sprintf("ps -Aceo pid=,pcpu=,comm= -r | head -n %d", monitorTopN))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[0])
+		cpu, _ := strconv.ParseFloat(fields[1], 64)
+		r.top = append(r.top, procUsage{pid: pid, name: strings.Join(fields[2:], " "), cpu: cpu})
+	}
+	return r, nil
+}
+
+// parseVMStat returns the memory vm_stat reports as free, inactive or
+// speculative, which macOS hands out before swapping, in bytes.
+func parseVMStat(data string) uint64 {
+	pageSize := uint64(4096)
+	var pages uint64
+	for _, line := range strings.Split(data, "\n") {
+		if strings.Contains(line, "page size of") {
+			fields := strings.Fields(line)
+			for i, field := range fields {
+				if field == "of" && i+1 < len(fields) {
+					if n, err := strconv.ParseUint(fields[i+1], 10, 64); err == nil {
+						pageSize = n
+					}
+				}
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "Pages free", "Pages inactive", "Pages speculative":
+			n, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), "."), 10, 64)
+			pages += n
+		}
+	}
+	return pages * pageSize
+}
+
+// parseDF returns the used and total bytes of the filesystem in the output
+// of df -Pk.
+func parseDF(out string) (used, total uint64, err error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected df output")
+	}
+	// The filesystem name may contain spaces; count from the end
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 6 {
+		return 0, 0, fmt.Errorf("unexpected df output")
+	}
+	n := len(fields)
+	total, err1 := strconv.ParseUint(fields[n-5], 10, 64)
+	used, err2 := strconv.ParseUint(fields[n-4], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("unexpected df output")
+	}
+	return used * 1024, total * 1024, nil
+}
+
+// formatBytes formats n bytes in binary units, e.g. "1.5 GiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// format renders the report as the plain text of a /monitor reply.
+func (r *monitorReport) format() string {
+	var b strings.Builder
+	load := r.load
+	if load == "" {
+		load = "unknown"
+	}
+	fmt.Fprintf(&b, "Load:   %s", load)
+	if r.cores > 0 {
+		fmt.Fprintf(&b, " (%d cores)", r.cores)
+	}
+	b.WriteString("\n")
+	if r.hasCPU {
+		fmt.Fprintf(&b, "CPU:    %.0f%% busy\n", r.cpu)
+	}
+	if r.memTotal > 0 {
+		fmt.Fprintf(&b, "Memory: %s free of %s\n", formatBytes(r.memFree), formatBytes(r.memTotal))
+	}
+	if r.diskTotal > 0 {
+		fmt.Fprintf(&b, "Disk:   %s used of %s (%.0f%%) at %s\n", formatBytes(r.diskUsed), formatBytes(r.diskTotal),
+			100*float64(r.diskUsed)/float64(r.diskTotal), r.diskDir)
+	}
+	if len(r.top) > 0 {
+		b.WriteString("\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PID\tCPU\tCOMMAND")
+		for _, p := range r.top {
+			fmt.Fprintf(w, "%d\t%.1f%%\t%s\n", p.pid, p.cpu, p.name)
+		}
+		w.Flush()
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sendMonitor handles /monitor: a snapshot of load, the busiest processes,
+// memory and the disk holding the session's directory, so checking on the
+// machine doesn't need an interactive htop.
+func (tb *TelegramBridge) sendMonitor(chatID int64, username string) {
+	fmt.Printf("📱 @%s → [monitor]\n\n", username)
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	report, err := sampleMonitor(tb.sessionDir(chatID), monitorInterval)
+	stopTyping()
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(report.format())+"</pre>", "pre", 4000)
+}
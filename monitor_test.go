@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCPUStat(t *testing.T) {
+	data := "cpu  100 5 50 800 40 0 5 0 30 0\ncpu0 50 2 25 400 20 0 3 0 15 0\ncpu1 50 3 25 400 20 0 2 0 15 0\nintr 12345\n"
+	got, err := parseCPUStat(data)
+	if err != nil {
+		t.Fatalf("parseCPUStat() error: %v", err)
+	}
+	// Guest time (30) is left out of the total
+	if got.total != 1000 || got.idle != 840 || got.cores != 2 {
+		t.Errorf("parseCPUStat() = %+v, want total 1000, idle 840, 2 cores", got)
+	}
+	if _, err := parseCPUStat("intr 1\n"); err == nil {
+		t.Error("parseCPUStat() without a cpu line succeeded")
+	}
+}
+
+// TestParseProcStat verifies names with spaces and parentheses don't
+// shift the fields
+func TestParseProcStat(t *testing.T) {
+	got, ok := parseProcStat("42 (my (weird) prog) S 1 42 42 0 -1 4194304 100 0 0 0 250 75 0 0 20 0 1 0 100 0 0")
+	if !ok || got.name != "my (weird) prog" || got.ticks != 325 {
+		t.Errorf("parseProcStat() = %+v, %v", got, ok)
+	}
+	if _, ok := parseProcStat("42 (short) S 1"); ok {
+		t.Error("parseProcStat() of a truncated line succeeded")
+	}
+}
+
+func TestParseMemoryAndDisk(t *testing.T) {
+	free, total := parseMeminfo("MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    8000000 kB\n")
+	if free != 8000000*1024 || total != 16000000*1024 {
+		t.Errorf("parseMeminfo() = %d, %d", free, total)
+	}
+
+	vmstat := "Mach Virtual Memory Statistics: (page size of 16384 bytes)\nPages free:      100.\nPages active:    500.\nPages inactive:  200.\nPages speculative:  50.\n"
+	if got := parseVMStat(vmstat); got != 350*16384 {
+		t.Errorf("parseVMStat() = %d, want %d", got, 350*16384)
+	}
+
+	df := "Filesystem     1024-blocks     Used Available Capacity Mounted on\nmy disk          1000000   250000    750000      25% /home\n"
+	used, size, err := parseDF(df)
+	if err != nil || used != 250000*1024 || size != 1000000*1024 {
+		t.Errorf("parseDF() = %d, %d, %v", used, size, err)
+	}
+}
+
+func TestMonitorReportFormat(t *testing.T) {
+	r := &monitorReport{
+		load: "0.50 0.40 0.30", cores: 4, hasCPU: true, cpu: 12.4,
+		memFree: 3 << 30, memTotal: 16 << 30,
+		diskDir: "/home/me", diskUsed: 40 << 30, diskTotal: 100 << 30,
+		top: []procUsage{{pid: 1234, name: "go", cpu: 98.5}, {pid: 7, name: "sshd", cpu: 0.5}},
+	}
+	got := r.format()
+	for _, want := range []string{"0.50 0.40 0.30 (4 cores)", "12% busy", "3.0 GiB free of 16.0 GiB", "40.0 GiB used of 100.0 GiB (40%) at /home/me", "1234  98.5%  go"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("format() = %q, missing %q", got, want)
+		}
+	}
+}
+
+// TestSampleMonitor verifies a live sample on the test machine
+func TestSampleMonitor(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	r, err := sampleMonitor(t.TempDir(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("sampleMonitor() error: %v", err)
+	}
+	if r.load == "" || r.memTotal == 0 || r.cores == 0 || !r.hasCPU || r.diskTotal == 0 {
+		t.Errorf("sampleMonitor() = %+v, missing fields", r)
+	}
+	if len(r.top) > monitorTopN {
+		t.Errorf("sampleMonitor() listed %d processes, want at most %d", len(r.top), monitorTopN)
+	}
+}
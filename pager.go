@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxPagedOutput caps how much output /page keeps; the rest is dropped
+	// with a note on the last page.
+	maxPagedOutput = 1 << 20
+
+	// pageLimit is the most HTML-escaped text a page holds, leaving room
+	// under Telegram's 4096-character limit for the <pre> and footer.
+	pageLimit = 3500
+)
+
+// Callback data of the /page buttons.
+const (
+	pagePrevCallback = "page_prev"
+	pageNextCallback = "page_next"
+)
+
+// pager is /page output being browsed in a chat.
+type pager struct {
+	command   string
+	pages     []string
+	page      int // Index of the page shown
+	messageID int // Message showing the pages, whose buttons turn them
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.max - c.buf.Len(); len(p) > room {
+		c.buf.Write(p[:max(room, 0)])
+		c.truncated = true
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// runPagedCommand runs command with sh in dir and returns its stdout and
+// stderr interleaved, up to maxPagedOutput bytes. It gives up after
+// timeout.
+func runPagedCommand(dir, command string, timeout time.Duration) (output string, truncated bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out := &cappedBuffer{max: maxPagedOutput}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %s", timeout.Round(time.Second))
+	}
+	return tidyPagedOutput(out.buf.String()), out.truncated, err
+}
+
+// tidyPagedOutput strips escape sequences and carriage returns from output
+// for display as plain text. Spacing is kept so columns still line up.
+func tidyPagedOutput(output string) string {
+	return strings.TrimRight(strings.ToValidUTF8(stripEscapes(output), "�"), "\n")
+}
+
+// paginate splits text into pages of whole lines whose HTML-escaped size
+// is at most limit. Lines too long for a page of their own are split.
+func paginate(text string, limit int) []string {
+	var pages []string
+	var cur []string
+	size := 0
+	for _, line := range strings.Split(text, "\n") {
+		for _, piece := range splitLongLine(line, limit) {
+			n := len(html.EscapeString(piece)) + 1 // Plus its newline
+			if len(cur) > 0 && size+n > limit {
+				pages = append(pages, strings.Join(cur, "\n"))
+				cur, size = nil, 0
+			}
+			cur = append(cur, piece)
+			size += n
+		}
+	}
+	if len(cur) > 0 {
+		pages = append(pages, strings.Join(cur, "\n"))
+	}
+	return pages
+}
+
+// splitLongLine splits line into pieces whose escaped size, newline
+// included, fits in limit, without breaking a rune.
+func splitLongLine(line string, limit int) []string {
+	if len(html.EscapeString(line)) < limit {
+		return []string{line}
+	}
+	var pieces []string
+	start, size := 0, 0
+	for i, r := range line {
+		n := len(html.EscapeString(string(r)))
+		if size+n >= limit {
+			pieces = append(pieces, line[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(pieces, line[start:])
+}
+
+// render returns the current page as Telegram HTML, and the buttons to
+// turn it.
+func (p *pager) render() (string, tgbotapi.InlineKeyboardMarkup) {
+	text := fmt.Sprintf("<pre>%s</pre>\n📄 Page %d/%d · <code>%s</code>",
+		html.EscapeString(p.pages[p.page]), p.page+1, len(p.pages), html.EscapeString(p.command))
+
+	var row []tgbotapi.InlineKeyboardButton
+	if p.page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀ Prev", pagePrevCallback))
+	}
+	if p.page < len(p.pages)-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Next ▶", pageNextCallback))
+	}
+	return text, tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// sendPaged handles /page <cmd>: it runs cmd to completion from the
+// session's directory and sends its output a page at a time, with
+// buttons to move between pages.
+func (tb *TelegramBridge) sendPaged(chatID int64, username, command string) {
+	if command == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /page <cmd>"))
+		return
+	}
+	config := tb.currentConfig()
+	if !commandPermitted(command, config) {
+		fmt.Printf("📱 @%s → [blocked] /page %s\n\n", username, command)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "🚫 command not permitted"))
+		return
+	}
+	if isInteractiveCommand(command, config) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ /page runs a command to completion; send interactive programs without it"))
+		return
+	}
+	fmt.Printf("📱 @%s → [page] %s\n\n", username, command)
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	output, truncated, err := runPagedCommand(tb.sessionDir(chatID), command, config.commandTimeout())
+	stopTyping()
+	if err != nil {
+		output = strings.TrimLeft(output+"\n", "\n") + "❌ " + err.Error()
+	}
+	if truncated {
+		output += fmt.Sprintf("\n✂️ Output truncated at %d bytes", maxPagedOutput)
+	}
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+
+	p := &pager{command: command, pages: paginate(output, pageLimit)}
+	text, markup := p.render()
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	if len(p.pages) > 1 {
+		msg.ReplyMarkup = markup
+	}
+	sent, err := tb.bot.Send(msg)
+	if err != nil {
+		log.Printf("❌ /page failed to send: %v\n", err)
+		return
+	}
+	if len(p.pages) > 1 {
+		p.messageID = sent.MessageID
+		tb.mu.Lock()
+		tb.pagers[chatID] = p
+		tb.mu.Unlock()
+	}
+}
+
+// dropPager forgets the chat's /page output, once another command runs,
+// and removes the buttons from its message.
+func (tb *TelegramBridge) dropPager(chatID int64) {
+	tb.mu.Lock()
+	p := tb.pagers[chatID]
+	delete(tb.pagers, chatID)
+	tb.mu.Unlock()
+	if p == nil {
+		return
+	}
+	noButtons := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	tb.bot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, p.messageID, noButtons))
+}
+
+// turnPage handles a press of a /page Prev or Next button by editing the
+// message to show the neighbouring page.
+func (tb *TelegramBridge) turnPage(query *tgbotapi.CallbackQuery) {
+	chatID, messageID := query.Message.Chat.ID, query.Message.MessageID
+
+	tb.mu.Lock()
+	p := tb.pagers[chatID]
+	if p == nil || p.messageID != messageID {
+		tb.mu.Unlock()
+		tb.bot.Request(tgbotapi.NewCallback(query.ID, "This output is no longer available"))
+		noButtons := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		tb.bot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, noButtons))
+		return
+	}
+	if query.Data == pageNextCallback && p.page < len(p.pages)-1 {
+		p.page++
+	} else if query.Data == pagePrevCallback && p.page > 0 {
+		p.page--
+	}
+	text, markup := p.render()
+	tb.mu.Unlock()
+
+	tb.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	edit.ParseMode = "HTML"
+	if _, err := tb.bot.Send(edit); err != nil {
+		log.Printf("❌ /page failed to turn the page: %v\n", err)
+	}
+}
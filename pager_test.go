@@ -0,0 +1,96 @@
+package main
+
+import (
+	"html"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPaginate verifies pages stay under the limit once escaped, keep
+// whole lines where they can, and lose nothing
+func TestPaginate(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, strings.Repeat("<x>", 10))
+	}
+	lines = append(lines, strings.Repeat("é", 500))
+	text := strings.Join(lines, "\n")
+
+	pages := paginate(text, 200)
+	if len(pages) < 2 {
+		t.Fatalf("paginate() = %d pages, want several", len(pages))
+	}
+	for i, page := range pages {
+		if n := len(html.EscapeString(page)); n > 200 {
+			t.Errorf("page %d is %d bytes escaped, over the limit", i, n)
+		}
+		if !strings.HasPrefix(page, "<x>") && !strings.HasPrefix(page, "é") {
+			t.Errorf("page %d starts mid-line: %q", i, page[:10])
+		}
+	}
+	joined := strings.ReplaceAll(strings.Join(pages, "\n"), "\n", "")
+	if joined != strings.ReplaceAll(text, "\n", "") {
+		t.Error("paginated text differs from the input")
+	}
+
+	if got := paginate("one\ntwo", pageLimit); len(got) != 1 || got[0] != "one\ntwo" {
+		t.Errorf("paginate(short) = %q", got)
+	}
+}
+
+func TestTidyPagedOutput(t *testing.T) {
+	got := tidyPagedOutput("\x1b[1mname\x1b[0m   size\r\nfile\x1b]8;;http://x\x1b\\   10\n\n")
+	if want := "name   size\nfile   10"; got != want {
+		t.Errorf("tidyPagedOutput() = %q, want %q", got, want)
+	}
+}
+
+func TestRunPagedCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	output, truncated, err := runPagedCommand(t.TempDir(), "echo out; echo err >&2; exit 3", 5*time.Second)
+	if err == nil || truncated || output != "out\nerr" {
+		t.Errorf("runPagedCommand() = %q, %v, %v", output, truncated, err)
+	}
+
+	output, truncated, err = runPagedCommand("", "head -c 2000000 /dev/zero | tr '\\0' a", 5*time.Second)
+	if err != nil || !truncated || len(output) != maxPagedOutput {
+		t.Errorf("large output: %d bytes, truncated %v, err %v", len(output), truncated, err)
+	}
+
+	if _, _, err := runPagedCommand("", "sleep 5", 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow command error = %v", err)
+	}
+}
+
+// TestPagerRender verifies only the buttons that lead somewhere are shown
+func TestPagerRender(t *testing.T) {
+	p := &pager{command: "ls <dir>", pages: []string{"a & b", "c", "d"}}
+	buttons := func() []string {
+		_, markup := p.render()
+		var labels []string
+		for _, b := range markup.InlineKeyboard[0] {
+			labels = append(labels, *b.CallbackData)
+		}
+		return labels
+	}
+
+	text, _ := p.render()
+	if !strings.Contains(text, "<pre>a &amp; b</pre>") || !strings.Contains(text, "Page 1/3") || !strings.Contains(text, "ls &lt;dir&gt;") {
+		t.Errorf("render() = %q", text)
+	}
+	if got := buttons(); len(got) != 1 || got[0] != pageNextCallback {
+		t.Errorf("first page buttons = %v", got)
+	}
+	p.page = 1
+	if got := buttons(); len(got) != 2 {
+		t.Errorf("middle page buttons = %v", got)
+	}
+	p.page = 2
+	if got := buttons(); len(got) != 1 || got[0] != pagePrevCallback {
+		t.Errorf("last page buttons = %v", got)
+	}
+}
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
 	"strings"
+	"sync"
 
+	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/x/vt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
 )
 
 // ScreenReader wraps a virtual terminal emulator to interpret ANSI escape
@@ -14,33 +25,131 @@ import (
 type ScreenReader struct {
 	emu        *vt.SafeEmulator
 	lastScreen string // Track last-sent screen for diffing
+
+	// Alternate screen state, updated by the emulator during Write
+	inAltScreen bool   // A TUI currently owns the alternate screen
+	leftAlt     bool   // The last Write switched back to the main screen
+	restored    string // Main screen as restored by the last switch back
+
+	sawLink bool // Output has contained an OSC 8 hyperlink
 }
 
 // NewScreenReader creates a virtual terminal with the given dimensions.
 // Dimensions should match the PTY size for correct cursor positioning.
 func NewScreenReader(cols, rows int) *ScreenReader {
-	return &ScreenReader{
+	sr := &ScreenReader{
 		emu: vt.NewSafeEmulator(cols, rows),
 	}
+	sr.emu.SetCallbacks(vt.Callbacks{AltScreen: sr.onAltScreen})
+	return sr
+}
+
+// onAltScreen runs inside Write when a TUI enters or leaves the alternate
+// screen. On leaving, it snapshots the restored main screen before the
+// rest of the write draws over it.
+func (sr *ScreenReader) onAltScreen(on bool) {
+	sr.inAltScreen = on
+	if !on {
+		sr.leftAlt = true
+		sr.restored = trimScreen(sr.emu.String())
+	}
 }
 
 // Write feeds raw PTY output into the virtual terminal.
 // The VTE processes ANSI sequences (cursor moves, colors, clears)
 // and updates its internal screen buffer.
 func (sr *ScreenReader) Write(data []byte) (int, error) {
+	sr.leftAlt = false
+	// Matching without the ESC still catches a link split across writes
+	if !sr.sawLink && bytes.Contains(data, []byte("]8;")) {
+		sr.sawLink = true
+	}
 	return sr.emu.Write(data)
 }
 
 // WriteString feeds a string of raw PTY output into the virtual terminal.
 func (sr *ScreenReader) WriteString(s string) (int, error) {
-	return sr.emu.Write([]byte(s))
+	return sr.Write([]byte(s))
+}
+
+// LeftAltScreen reports whether the last Write ended a TUI by switching
+// from the alternate screen back to the main one, and returns the main
+// screen as it was restored, before any output that followed the switch.
+func (sr *ScreenReader) LeftAltScreen() (restored string, ok bool) {
+	if !sr.leftAlt || sr.inAltScreen {
+		return "", false
+	}
+	return sr.restored, true
 }
 
 // Screen returns the current screen content as plain text.
 // Trailing whitespace is trimmed from each line and trailing empty lines
-// are removed. This is what a human would see on a terminal.
+// are removed. This is what a human would see on a terminal, except that
+// hyperlinks are followed by their URL (see rowCells).
 func (sr *ScreenReader) Screen() string {
-	raw := sr.emu.String()
+	if !sr.sawLink {
+		return trimScreen(sr.emu.String())
+	}
+	lines := make([]string, sr.emu.Height())
+	for y := range lines {
+		contents, _ := sr.rowCells(y)
+		lines[y] = strings.Join(contents, "")
+	}
+	return trimScreen(strings.Join(lines, "\n"))
+}
+
+// rowCells returns the contents of row y's cells, with blanks as spaces
+// and the placeholder cells after wide characters skipped, alongside the
+// cells themselves. The last cell of an OSC 8 hyperlink gets the link's
+// URL appended in parentheses, unless the link's text already is the URL,
+// since chats can't show the link itself.
+func (sr *ScreenReader) rowCells(y int) ([]string, []*uv.Cell) {
+	var contents []string
+	var cells []*uv.Cell
+	for x := 0; x < sr.emu.Width(); x++ {
+		cell := sr.emu.CellAt(x, y)
+		content := " "
+		if cell != nil {
+			if cell.Width == 0 && cell.Content == "" {
+				continue
+			}
+			if cell.Content != "" {
+				content = cell.Content
+			}
+		}
+		contents = append(contents, content)
+		cells = append(cells, cell)
+	}
+
+	start := 0
+	for i, cell := range cells {
+		url := linkURL(cell)
+		if i == 0 || url != linkURL(cells[i-1]) {
+			start = i
+		}
+		if url == "" || (i+1 < len(cells) && linkURL(cells[i+1]) == url) {
+			continue
+		}
+		if strings.TrimSpace(strings.Join(contents[start:i+1], "")) != url {
+			contents[i] += " (" + url + ")"
+		}
+	}
+	return contents, cells
+}
+
+// linkURL returns the URL of the hyperlink cell belongs to, or "".
+func linkURL(cell *uv.Cell) string {
+	if cell == nil {
+		return ""
+	}
+	// vt splits "8;params;url" one field off, so Link.URL holds the
+	// params and Link.Params the URL
+	return cell.Link.Params
+}
+
+// trimScreen trims trailing whitespace from each line of a raw VTE screen
+// and drops trailing empty lines.
+func trimScreen(raw string) string {
 
 	// The VTE buffer includes all rows (even empty ones).
 	// Trim trailing empty lines to get just the visible content.
@@ -66,6 +175,207 @@ func (sr *ScreenReader) Screen() string {
 	return strings.Join(trimmed, "\n")
 }
 
+// cellFormat is the Telegram formatting a styled cell maps to.
+type cellFormat int
+
+const (
+	formatNone cellFormat = iota
+	formatBold            // <b>: bold text without color
+	formatCode            // <code>: colored or reverse-video text
+)
+
+// cellFormatOf maps a cell's VTE style onto Telegram's limited formatting.
+// Any color (foreground or background) or reverse video becomes <code>, since
+// Telegram has no colors; plain bold becomes <b>.
+func cellFormatOf(cell *uv.Cell) cellFormat {
+	if cell == nil {
+		return formatNone
+	}
+	st := cell.Style
+	if st.Fg != nil || st.Bg != nil || st.Attrs&uv.AttrReverse != 0 {
+		return formatCode
+	}
+	if st.Attrs&uv.AttrBold != 0 {
+		return formatBold
+	}
+	return formatNone
+}
+
+// ScreenColored returns the current screen as Telegram HTML, with colored
+// runs wrapped in <code> and bold runs in <b>. Text is HTML-escaped. Lines
+// correspond one-to-one with Screen(), with the same trailing whitespace and
+// trailing empty lines removed.
+func (sr *ScreenReader) ScreenColored() string {
+	height := sr.emu.Height()
+
+	type run struct {
+		format cellFormat
+		text   strings.Builder
+	}
+
+	lines := make([]string, 0, height)
+	for y := 0; y < height; y++ {
+		contents, cells := sr.rowCells(y)
+		formats := make([]cellFormat, len(cells))
+		for i, cell := range cells {
+			formats[i] = cellFormatOf(cell)
+		}
+
+		// Trim trailing whitespace, matching Screen()
+		end := len(contents)
+		for end > 0 && strings.TrimSpace(contents[end-1]) == "" {
+			end--
+		}
+
+		var b strings.Builder
+		var cur *run
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			text := html.EscapeString(cur.text.String())
+			switch {
+			case strings.TrimSpace(text) == "" || cur.format == formatNone:
+				b.WriteString(text)
+			case cur.format == formatBold:
+				b.WriteString("<b>" + text + "</b>")
+			case cur.format == formatCode:
+				b.WriteString("<code>" + text + "</code>")
+			}
+			cur = nil
+		}
+		for i := 0; i < end; i++ {
+			if cur == nil || cur.format != formats[i] {
+				flush()
+				cur = &run{format: formats[i]}
+			}
+			cur.text.WriteString(contents[i])
+		}
+		flush()
+		lines = append(lines, b.String())
+	}
+
+	// Drop trailing empty lines
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// screenshotFontSize and screenshotPadding size the cells and border of Image.
+const (
+	screenshotFontSize = 14
+	screenshotPadding  = 8
+)
+
+// Colors for cells without their own, matching a dark terminal.
+var (
+	screenshotFg = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+	screenshotBg = color.RGBA{0x1e, 0x1e, 0x1e, 0xff}
+)
+
+var (
+	monoFontOnce sync.Once
+	monoFont     *opentype.Font
+	monoFontErr  error
+)
+
+// newScreenshotFace returns a face of the embedded Go Mono font, which
+// covers box-drawing characters. Faces aren't safe for concurrent use, so
+// each Image call makes its own from the shared parsed font.
+func newScreenshotFace() (font.Face, error) {
+	monoFontOnce.Do(func() {
+		monoFont, monoFontErr = opentype.Parse(gomono.TTF)
+	})
+	if monoFontErr != nil {
+		return nil, monoFontErr
+	}
+	return opentype.NewFace(monoFont, &opentype.FaceOptions{
+		Size:    screenshotFontSize,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// Image renders the current screen cell by cell in a monospace font, with
+// its colors. Unlike Screen() text sent to a chat, the grid keeps TUI
+// layouts and box drawing aligned. Rows below the last non-empty line of
+// Screen() are cropped.
+func (sr *ScreenReader) Image() (image.Image, error) {
+	face, err := newScreenshotFace()
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	advance, _ := face.GlyphAdvance('M')
+	cellW, cellH, ascent := advance.Ceil(), metrics.Height.Ceil(), metrics.Ascent.Ceil()
+
+	width := sr.emu.Width()
+	rows := strings.Count(sr.Screen(), "\n") + 1
+	img := image.NewRGBA(image.Rect(0, 0, width*cellW+2*screenshotPadding, rows*cellH+2*screenshotPadding))
+	draw.Draw(img, img.Bounds(), image.NewUniform(screenshotBg), image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: img, Face: face}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < width; x++ {
+			cell := sr.emu.CellAt(x, y)
+			if cell == nil || cell.Width == 0 && cell.Content == "" {
+				continue // Blank, or the placeholder after a wide character
+			}
+
+			var fg, bg color.Color = screenshotFg, screenshotBg
+			if cell.Style.Fg != nil {
+				fg = cell.Style.Fg
+			}
+			if cell.Style.Bg != nil {
+				bg = cell.Style.Bg
+			}
+			if cell.Style.Attrs&uv.AttrReverse != 0 {
+				fg, bg = bg, fg
+			}
+
+			left, top := screenshotPadding+x*cellW, screenshotPadding+y*cellH
+			cellRect := image.Rect(left, top, left+max(cell.Width, 1)*cellW, top+cellH)
+			draw.Draw(img, cellRect, image.NewUniform(bg), image.Point{}, draw.Src)
+			if strings.TrimSpace(cell.Content) == "" {
+				continue
+			}
+			d.Src = image.NewUniform(fg)
+			d.Dot = fixed.P(left, top+ascent)
+			d.DrawString(cell.Content)
+		}
+	}
+	return img, nil
+}
+
+// colorizeContent returns content as Telegram HTML, replacing each line with
+// its colored counterpart from a ScreenColored() snapshot taken alongside the
+// Screen() snapshot plain. Lines with no counterpart (e.g., rewritten by
+// cleanTUIChrome) are escaped as-is.
+func colorizeContent(content, plain, colored string) string {
+	plainLines := strings.Split(plain, "\n")
+	coloredLines := strings.Split(colored, "\n")
+
+	byText := make(map[string]string, len(plainLines))
+	for i, line := range plainLines {
+		if i < len(coloredLines) {
+			byText[strings.TrimSpace(line)] = coloredLines[i]
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if c, ok := byText[strings.TrimSpace(line)]; ok && strings.TrimSpace(line) != "" {
+			lines[i] = c
+		} else {
+			lines[i] = html.EscapeString(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Diff returns only the new content since the last call to Diff.
 // On first call, returns the full screen.
 // Returns empty string if nothing changed.
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"image/color"
 	"strings"
 	"testing"
 	"time"
@@ -140,6 +141,43 @@ func TestScreenReaderAlternateScreenBuffer(t *testing.T) {
 	}
 }
 
+// TestScreenReaderLeftAltScreen verifies the last write reports leaving the
+// alt screen, with the main screen as restored before later output
+func TestScreenReaderLeftAltScreen(t *testing.T) {
+	sr := NewScreenReader(80, 24)
+	sr.WriteString("$ vim notes.txt\r\n")
+	if _, ok := sr.LeftAltScreen(); ok {
+		t.Error("plain output reported leaving the alt screen")
+	}
+
+	sr.WriteString("\x1b[?1049h~ notes ~")
+	if _, ok := sr.LeftAltScreen(); ok {
+		t.Error("entering the alt screen reported leaving it")
+	}
+
+	sr.WriteString("\x1b[?1049lsaved\r\n")
+	restored, ok := sr.LeftAltScreen()
+	if !ok {
+		t.Fatal("leaving the alt screen not reported")
+	}
+	if restored != "$ vim notes.txt" {
+		t.Errorf("restored = %q, want the main screen before later output", restored)
+	}
+	if !strings.Contains(sr.Screen(), "saved") {
+		t.Errorf("output after leaving not on screen: %q", sr.Screen())
+	}
+
+	sr.WriteString("more\r\n")
+	if _, ok := sr.LeftAltScreen(); ok {
+		t.Error("LeftAltScreen should only report the write that left")
+	}
+
+	sr.WriteString("\x1b[?1049l\x1b[?1049h")
+	if _, ok := sr.LeftAltScreen(); ok {
+		t.Error("a write ending back in the alt screen should not report leaving")
+	}
+}
+
 // --- Diff Tests ---
 
 // TestScreenReaderDiffFirstCall verifies first diff returns full content
@@ -220,7 +258,7 @@ func TestScreenReaderResize(t *testing.T) {
 // TestScreenReaderWithRealPTY verifies VTE correctly reads real terminal output
 func TestScreenReaderWithRealPTY(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -258,7 +296,7 @@ drain:
 // TestScreenReaderWithColoredPTYOutput verifies colored ls output renders correctly
 func TestScreenReaderWithColoredPTYOutput(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -337,7 +375,7 @@ func TestDiffScreensChangedLine(t *testing.T) {
 // TestOneShotCommandVTE verifies one-shot commands work with VTE
 func TestOneShotCommandVTE(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -355,3 +393,132 @@ func TestOneShotCommandVTE(t *testing.T) {
 		t.Error("Raw ANSI codes in one-shot output — VTE should have interpreted them")
 	}
 }
+
+// --- Colored Screen Tests ---
+
+// TestScreenColoredWrapsColorAndBold verifies colored runs become <code>
+// and bold runs become <b>
+func TestScreenColoredWrapsColorAndBold(t *testing.T) {
+	sr := NewScreenReader(80, 24)
+	sr.WriteString("plain \x1b[31mred\x1b[0m and \x1b[1mbold\x1b[0m\r\n")
+	sr.WriteString("\x1b[32m+added line\x1b[0m")
+
+	got := sr.ScreenColored()
+	want := "plain <code>red</code> and <b>bold</b>\n<code>+added line</code>"
+	if got != want {
+		t.Errorf("ScreenColored() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+// TestScreenReaderHyperlink verifies OSC 8 link text survives the VTE,
+// followed by the URL unless the text already is the URL
+func TestScreenReaderHyperlink(t *testing.T) {
+	sr := NewScreenReader(80, 24)
+	sr.WriteString("see \x1b]8;;https://example.com/docs\x1b\\the docs\x1b]8;;\x1b\\ now\r\n")
+	sr.WriteString("\x1b]8;id=1;https://example.com\x07https://example.com\x1b]8;;\x07\r\n")
+	sr.WriteString("\x1b]8;;https://example.com/a\x1b\\\x1b[34mblue\x1b[0m\x1b]8;;\x1b\\")
+
+	want := "see the docs (https://example.com/docs) now\nhttps://example.com\nblue (https://example.com/a)"
+	if got := sr.Screen(); got != want {
+		t.Errorf("Screen() =\n%q\nwant\n%q", got, want)
+	}
+	wantColored := "see the docs (https://example.com/docs) now\nhttps://example.com\n<code>blue (https://example.com/a)</code>"
+	if got := sr.ScreenColored(); got != wantColored {
+		t.Errorf("ScreenColored() =\n%q\nwant\n%q", got, wantColored)
+	}
+}
+
+// TestScreenColoredEscapesHTML verifies text is escaped inside and outside runs
+func TestScreenColoredEscapesHTML(t *testing.T) {
+	sr := NewScreenReader(80, 24)
+	sr.WriteString("a<b \x1b[34m<dir>&\x1b[0m")
+
+	got := sr.ScreenColored()
+	want := "a&lt;b <code>&lt;dir&gt;&amp;</code>"
+	if got != want {
+		t.Errorf("ScreenColored() = %q, want %q", got, want)
+	}
+}
+
+// TestScreenColoredMatchesScreenLines verifies line structure matches Screen()
+func TestScreenColoredMatchesScreenLines(t *testing.T) {
+	sr := NewScreenReader(80, 24)
+	sr.WriteString("one\r\n\r\n\x1b[33mthree\x1b[0m   \r\n\r\n")
+
+	plain := strings.Split(sr.Screen(), "\n")
+	colored := strings.Split(sr.ScreenColored(), "\n")
+	if len(plain) != len(colored) {
+		t.Fatalf("line counts differ: plain %d, colored %d", len(plain), len(colored))
+	}
+}
+
+// TestColorizeContent verifies new content lines are mapped to their colored versions
+func TestColorizeContent(t *testing.T) {
+	plain := "old line\nM file.go\n?? new.go"
+	colored := "old line\n<code>M</code> file.go\n<code>??</code> new.go"
+
+	got := colorizeContent("M file.go\n?? new.go\nunmatched <x>", plain, colored)
+	want := "<code>M</code> file.go\n<code>??</code> new.go\nunmatched &lt;x&gt;"
+	if got != want {
+		t.Errorf("colorizeContent() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+// --- Image Tests ---
+
+// TestScreenReaderImage verifies the screen renders on a cell grid sized to
+// the terminal width and the rows in use, with cell colors applied
+func TestScreenReaderImage(t *testing.T) {
+	sr := NewScreenReader(20, 10)
+	sr.WriteString("┌──┐\r\n│\x1b[41mok\x1b[0m│\r\n└──┘")
+
+	img, err := sr.Image()
+	if err != nil {
+		t.Fatalf("Image() error: %v", err)
+	}
+
+	face, err := newScreenshotFace()
+	if err != nil {
+		t.Fatalf("newScreenshotFace() error: %v", err)
+	}
+	defer face.Close()
+	advance, _ := face.GlyphAdvance('M')
+	cellW, cellH := advance.Ceil(), face.Metrics().Height.Ceil()
+
+	bounds := img.Bounds()
+	if bounds.Dx() != 20*cellW+2*screenshotPadding || bounds.Dy() != 3*cellH+2*screenshotPadding {
+		t.Errorf("image is %dx%d, want 20x3 cells of %dx%d plus padding", bounds.Dx(), bounds.Dy(), cellW, cellH)
+	}
+
+	// The top-left corner of the red cell ("o" at column 1, row 1)
+	r, g, b, _ := img.At(screenshotPadding+cellW, screenshotPadding+cellH).RGBA()
+	if r>>8 < 0x80 || g>>8 > 0x40 || b>>8 > 0x40 {
+		t.Errorf("red background cell rendered as %02x%02x%02x", r>>8, g>>8, b>>8)
+	}
+
+	// The box's corner glyph is drawn in the foreground color
+	drawn := false
+	for y := screenshotPadding; y < screenshotPadding+cellH && !drawn; y++ {
+		for x := screenshotPadding; x < screenshotPadding+cellW; x++ {
+			if img.At(x, y) != color.Color(screenshotBg) {
+				drawn = true
+				break
+			}
+		}
+	}
+	if !drawn {
+		t.Error("box-drawing corner not rendered")
+	}
+}
+
+// TestScreenReaderImageEmpty verifies an empty screen renders as one blank row
+func TestScreenReaderImageEmpty(t *testing.T) {
+	sr := NewScreenReader(10, 5)
+	img, err := sr.Image()
+	if err != nil {
+		t.Fatalf("Image() error: %v", err)
+	}
+	if img.Bounds().Dy() <= 2*screenshotPadding {
+		t.Errorf("empty screen image height = %d", img.Bounds().Dy())
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretEchoWindow is how long after a secret is typed its echo is looked
+// for. Programs reading a password turn echo off, so usually none comes.
+const secretEchoWindow = 5 * time.Second
+
+// secretHint warns a Telegram user that the session is asking for a
+// password, which as an ordinary message would stay in the chat.
+const secretHint = "🔑 This looks like a password prompt. Send it with /secret <password> — " +
+	"your message is deleted, and the password is kept out of the output, logs and /history."
+
+// sendSecret types secret into the session followed by Enter, and hides
+// it from the output should the PTY echo it back.
+func (s *Session) sendSecret(secret string) {
+	s.secretMu.Lock()
+	s.pendingEcho = secret
+	s.echoUntil = time.Now().Add(secretEchoWindow)
+	s.secretMu.Unlock()
+	s.Terminal.SendRawInput(secret + "\r")
+}
+
+// hideSecretEcho removes the echo of a secret just sent with sendSecret
+// from output. A secret is only looked for once, in the first output that
+// contains it.
+func (s *Session) hideSecretEcho(output string) string {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	if s.pendingEcho == "" {
+		return output
+	}
+	if time.Now().After(s.echoUntil) {
+		s.pendingEcho = ""
+		return output
+	}
+	if !strings.Contains(output, s.pendingEcho) {
+		return output
+	}
+	output = strings.ReplaceAll(output, s.pendingEcho, "")
+	s.pendingEcho = ""
+	return output
+}
+
+// noteSecretHint reports whether output leaves an ordinary session at a
+// new password prompt, so the user can be pointed to /secret once per
+// prompt. Managed /ssh sessions ask for the reply themselves.
+func (s *Session) noteSecretHint(output string) bool {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	if s.watchSecrets || strings.TrimSpace(cleanANSI(output)) == "" {
+		return false
+	}
+	wasAtPrompt := s.atSecretPrompt
+	s.atSecretPrompt = isSecretPrompt(output)
+	return s.atSecretPrompt && !wasAtPrompt
+}
+
+// sendSecretInput handles /secret <text>: it types text into the chat's
+// session without it showing up anywhere. The message is deleted, and the
+// text is never logged, recorded in /history or audited.
+func (tb *TelegramBridge) sendSecretInput(message *tgbotapi.Message, username, text string) {
+	chatID := message.Chat.ID
+	if text != "" {
+		tb.deleteSecretMessage(chatID, message.MessageID)
+	}
+
+	tb.mu.RLock()
+	session, hasSession := tb.sessions[chatID]
+	tb.mu.RUnlock()
+	if !hasSession || !session.Active {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ No active session to type into"))
+		return
+	}
+	if text == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /secret <text>"))
+		return
+	}
+
+	fmt.Printf("📱 @%s → [secret]\n\n", username)
+	session.takeSecretPrompt()
+	session.sendSecret(text)
+	tb.bot.Send(tgbotapi.NewMessage(chatID, "🔑 Sent"))
+}
+
+// handleSecretInput types a "secret" WebMessage into the tab's session
+// like input, but never logs it and hides its echo.
+func (s *WebUIServer) handleSecretInput(chatID int64, secret string) {
+	s.mu.Lock()
+	session, hasSession := s.sessions[chatID]
+	s.mu.Unlock()
+
+	if !hasSession || !session.Active || secret == "" {
+		return
+	}
+	log.Printf("[WebUI-%d] → [secret]\n", chatID)
+	session.sendSecret(secret)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHideSecretEcho verifies a secret is removed from the first output
+// echoing it, and not looked for once its window has passed
+func TestHideSecretEcho(t *testing.T) {
+	session := &Session{pendingEcho: "hunter2", echoUntil: time.Now().Add(time.Minute)}
+	if got := session.hideSecretEcho("\r\n"); got != "\r\n" {
+		t.Errorf("hideSecretEcho(no echo) = %q", got)
+	}
+	if got := session.hideSecretEcho("hunter2\r\n$ "); got != "\r\n$ " {
+		t.Errorf("hideSecretEcho(echo) = %q", got)
+	}
+	if got := session.hideSecretEcho("echo hunter2"); got != "echo hunter2" {
+		t.Errorf("secret still hidden after its echo: %q", got)
+	}
+
+	session = &Session{pendingEcho: "hunter2", echoUntil: time.Now().Add(-time.Second)}
+	if got := session.hideSecretEcho("hunter2"); got != "hunter2" {
+		t.Errorf("secret hidden after the echo window: %q", got)
+	}
+}
+
+// TestSessionSecretHint verifies ordinary sessions are warned once per
+// password prompt, and managed /ssh sessions not at all
+func TestSessionSecretHint(t *testing.T) {
+	session := &Session{}
+	if session.noteSecretHint("$ ") {
+		t.Error("shell prompt mistaken for a password prompt")
+	}
+	if !session.noteSecretHint("[sudo] password for alice: ") {
+		t.Fatal("password prompt not detected")
+	}
+	if session.noteSecretHint("\x1b[0m") || session.noteSecretHint("[sudo] password for alice: ") {
+		t.Error("same prompt warned about twice")
+	}
+	session.noteSecretHint("\r\nSorry, try again.\r\n")
+	if !session.noteSecretHint("[sudo] password for alice: ") {
+		t.Error("new prompt not detected after other output")
+	}
+
+	if (&Session{watchSecrets: true}).noteSecretHint("alice@host's password: ") {
+		t.Error("managed session warned about its own prompts")
+	}
+}
+
+// TestWebUISecretInput verifies a "secret" message reaches the program
+// while its echo never reaches the tab
+func TestWebUISecretInput(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+	readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" })
+
+	if err := conn.WriteJSON(WebMessage{Type: "command", Content: "stty echo; read -r v; echo \"len=${#v}\""}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// Let read start before typing
+	time.Sleep(500 * time.Millisecond)
+	if err := conn.WriteJSON(WebMessage{Type: "secret", Content: "hunter2-secret"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	leaked := false
+	readUntil(t, conn, func(m WebMessage) bool {
+		leaked = leaked || strings.Contains(m.Content, "hunter2")
+		return strings.Contains(m.Content, "len=14")
+	})
+	if leaked {
+		t.Error("secret echoed to the tab")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sessionIndexEntry records an active Telegram session. PTYs can't survive
+// a restart, but the index lets the next process tell users their session
+// is gone instead of losing it silently.
+type sessionIndexEntry struct {
+	ChatID    int64     `json:"chat_id"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// sessionIndexPath returns the file holding the bridge's active session
+// index. Each bot has its own, so their notices go through the right bot.
+func (tb *TelegramBridge) sessionIndexPath() string {
+	return filepath.Join(getConfigDir(), tb.sessionIndexFile)
+}
+
+// loadSessionIndex reads a session index, sorted by chat ID.
+// A missing or corrupt file yields an empty index.
+func loadSessionIndex(path string) []sessionIndexEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entries []sessionIndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Warning: ignoring invalid session index: %v", err)
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ChatID < entries[j].ChatID })
+	return entries
+}
+
+// saveSessionIndexLocked persists the active sessions with 0600 permissions,
+// removing the file when there are none. Caller must hold tb.mu.
+func (tb *TelegramBridge) saveSessionIndexLocked() {
+	var entries []sessionIndexEntry
+	for chatID, session := range tb.sessions {
+		if session.Active {
+			entries = append(entries, sessionIndexEntry{ChatID: chatID, Command: session.Command, StartedAt: session.StartedAt})
+		}
+	}
+	if len(entries) == 0 {
+		if err := os.Remove(tb.sessionIndexPath()); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: could not remove session index: %v", err)
+		}
+		return
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		log.Printf("Warning: could not encode session index: %v", err)
+		return
+	}
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		log.Printf("Warning: could not create config dir: %v", err)
+		return
+	}
+	if err := os.WriteFile(tb.sessionIndexPath(), data, 0600); err != nil {
+		log.Printf("Warning: could not save session index: %v", err)
+	}
+}
+
+// formatInterruptedSession is the notice sent for a session lost to a restart.
+func formatInterruptedSession(e sessionIndexEntry, now time.Time) string {
+	return fmt.Sprintf("⚠️ Your previous session (%s, started %s ago) ended when remote-terminal restarted.\n"+
+		"Send a command to start a new one.", e.Command, now.Sub(e.StartedAt).Round(time.Second))
+}
+
+// notifyInterruptedSessions tells each chat in the index left behind by a
+// previous process that its session ended, then clears the index.
+// Called once at startup, before any new session is created.
+func (tb *TelegramBridge) notifyInterruptedSessions() {
+	now := time.Now()
+	for _, entry := range loadSessionIndex(tb.sessionIndexPath()) {
+		log.Printf("Notifying chat %d of session lost to restart: %s\n", entry.ChatID, entry.Command)
+		tb.bot.Send(tgbotapi.NewMessage(entry.ChatID, formatInterruptedSession(entry, now)))
+	}
+	if err := os.Remove(tb.sessionIndexPath()); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: could not remove session index: %v", err)
+	}
+}
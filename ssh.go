@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultSSHKeyName is the file /upload-key writes to when no name is
+// given. /ssh passes it to ssh with -i when it exists.
+const defaultSSHKeyName = "id_remote_term"
+
+// pendingKeyTTL is how long /upload-key waits for the key message.
+const pendingKeyTTL = 5 * time.Minute
+
+// pendingKey is an /upload-key waiting for the user to paste the key.
+type pendingKey struct {
+	userID    int64
+	name      string
+	expiresAt time.Time
+}
+
+var (
+	// sshHostPattern accepts [user@]host, where host is a name or address.
+	// Nothing that could be read as an ssh option or shell syntax.
+	sshHostPattern = regexp.MustCompile(`^([A-Za-z0-9._-]+@)?[A-Za-z0-9._:-]+$`)
+
+	// sshKeyNamePattern keeps /upload-key names to plain files in ~/.ssh.
+	sshKeyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+	// secretPromptPattern matches a password or passphrase prompt waiting
+	// on the last line of output, e.g. "user@host's password: ".
+	secretPromptPattern = regexp.MustCompile(`(?i)(password|passphrase)[^\n]*:\s*$`)
+)
+
+var errSSHKeyExists = errors.New("a key with that name already exists")
+
+// sshDir returns the user's ~/.ssh directory.
+func sshDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".ssh"
+	}
+	return filepath.Join(home, ".ssh")
+}
+
+// sshCommand builds the ssh invocation for /ssh <host> [port]. keyPath,
+// if non-empty, is passed with -i.
+func sshCommand(args []string, keyPath string) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", errors.New("usage: /ssh [user@]host [port]")
+	}
+	host := args[0]
+	if strings.HasPrefix(host, "-") || !sshHostPattern.MatchString(host) {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	cmd := "ssh"
+	if len(args) == 2 {
+		port, err := strconv.Atoi(args[1])
+		if err != nil || port < 1 || port > 65535 {
+			return "", fmt.Errorf("invalid port %q", args[1])
+		}
+		cmd += " -p " + strconv.Itoa(port)
+	}
+	if keyPath != "" {
+		cmd += " -i " + shellQuote(keyPath)
+	}
+	return cmd + " " + shellQuote(host), nil
+}
+
+// isSecretPrompt reports whether output ends with a password or
+// passphrase prompt.
+func isSecretPrompt(output string) bool {
+	return secretPromptPattern.MatchString(cleanANSI(output))
+}
+
+// noteSecretPrompt marks a managed /ssh session as waiting for a secret
+// when output ends with a prompt for one. It returns true only for a new
+// prompt, so the user is asked once per prompt.
+func (s *Session) noteSecretPrompt(output string) bool {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	if !s.watchSecrets || s.awaitingSecret || !isSecretPrompt(output) {
+		return false
+	}
+	s.awaitingSecret = true
+	return true
+}
+
+// takeSecretPrompt clears a pending secret prompt, returning whether
+// there was one.
+func (s *Session) takeSecretPrompt() bool {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	awaiting := s.awaitingSecret
+	s.awaitingSecret = false
+	return awaiting
+}
+
+// isValidPrivateKey reports whether key looks like a PEM or OpenSSH
+// private key.
+func isValidPrivateKey(key string) bool {
+	key = strings.TrimSpace(key)
+	return strings.HasPrefix(key, "-----BEGIN ") &&
+		strings.Contains(key, "PRIVATE KEY-----") &&
+		strings.HasSuffix(key, "-----")
+}
+
+// saveSSHKey writes a private key to dir/name with 0600 permissions,
+// creating dir with 0700 if needed. Existing files are never overwritten.
+func saveSSHKey(dir, name, key string) (string, error) {
+	if !sshKeyNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid key name %q", name)
+	}
+	if !isValidPrivateKey(key) {
+		return "", errors.New("that doesn't look like a private key")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return "", errSSHKeyExists
+	}
+	if err != nil {
+		return "", err
+	}
+	// ssh rejects keys without a trailing newline
+	_, err = f.WriteString(strings.TrimSpace(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// OpenFile's mode is subject to umask; make it exact
+		err = os.Chmod(path, 0600)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// deleteSecretMessage removes a message carrying a password or key from
+// the chat, warning the user when the bot isn't allowed to.
+func (tb *TelegramBridge) deleteSecretMessage(chatID int64, messageID int) {
+	if _, err := tb.bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+		log.Printf("Warning: failed to delete secret message in chat %d: %v\n", chatID, err)
+		msg := tgbotapi.NewMessage(chatID, "⚠️ Couldn't delete your message — delete it yourself")
+		tb.bot.Send(msg)
+	}
+}
+
+// startSSH handles /ssh: it starts ssh as a managed session whose password
+// and passphrase prompts are answered by the user's next message.
+func (tb *TelegramBridge) startSSH(chatID, userID int64, username, arg string) {
+	if tb.hasActiveSession(chatID) {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ A session is already running — /stop it first")
+		tb.bot.Send(msg)
+		return
+	}
+	keyPath := filepath.Join(sshDir(), defaultSSHKeyName)
+	if _, err := os.Stat(keyPath); err != nil {
+		keyPath = ""
+	}
+	command, err := sshCommand(strings.Fields(arg), keyPath)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+		tb.bot.Send(msg)
+		return
+	}
+	if !commandPermitted(command, tb.currentConfig()) {
+		fmt.Printf("📱 @%s → [blocked] %s\n\n", username, command)
+		msg := tgbotapi.NewMessage(chatID, "🚫 command not permitted")
+		tb.bot.Send(msg)
+		return
+	}
+
+	tb.startManagedSession(chatID, userID, username, command, sessionOptions{watchSecrets: true})
+}
+
+// promptForSecret tells the user a managed session is waiting for a
+// password or passphrase.
+func (tb *TelegramBridge) promptForSecret(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "🔑 ssh is asking for a password or passphrase. "+
+		"Reply with it — your message is deleted and never echoed.")
+	tb.bot.Send(msg)
+}
+
+// isUploadKeyCommand reports whether text is /upload-key (or the menu's
+// /upload_key), possibly followed by a name and the key itself.
+func isUploadKeyCommand(text string) bool {
+	fields := strings.Fields(text)
+	return len(fields) > 0 && (fields[0] == "/upload-key" || fields[0] == "/upload_key")
+}
+
+// handleUploadKey handles /upload-key [name]. The key can follow the
+// command on the next lines, or come as the next message.
+func (tb *TelegramBridge) handleUploadKey(message *tgbotapi.Message, username, text string) {
+	chatID := message.Chat.ID
+	// Whatever happens next, a pasted key doesn't stay in the chat
+	if strings.Contains(text, "-----BEGIN") {
+		tb.deleteSecretMessage(chatID, message.MessageID)
+	}
+	first, key, _ := strings.Cut(text, "\n")
+	name := defaultSSHKeyName
+	if fields := strings.Fields(first); len(fields) > 1 {
+		name = fields[1]
+	}
+	if !sshKeyNamePattern.MatchString(name) {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ invalid key name %q", name))
+		tb.bot.Send(msg)
+		return
+	}
+
+	if strings.TrimSpace(key) == "" {
+		tb.mu.Lock()
+		tb.pendingKeys[chatID] = pendingKey{userID: message.From.ID, name: name, expiresAt: time.Now().Add(pendingKeyTTL)}
+		tb.mu.Unlock()
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔑 Send the private key as your next message. "+
+			"It's saved to ~/.ssh/%s (0600) and your message is deleted.", name))
+		tb.bot.Send(msg)
+		return
+	}
+	tb.saveUploadedKey(chatID, username, name, key)
+}
+
+// saveUploadedKey saves a key from /upload-key and reports the result
+// without ever echoing the key.
+func (tb *TelegramBridge) saveUploadedKey(chatID int64, username, name, key string) {
+	fmt.Printf("📱 @%s → [upload-key] %s\n\n", username, name)
+	path, err := saveSSHKey(sshDir(), name, key)
+	if err != nil {
+		log.Printf("Error saving SSH key %s: %v\n", name, err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Key not saved: "+err.Error())
+		tb.bot.Send(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔑 Saved %s (0600)", path))
+	tb.bot.Send(msg)
+}
+
+// takeSecretReply consumes a message answering a pending /upload-key or
+// ssh password prompt. Such messages are deleted from the chat and never
+// logged, recorded in /history or audited. Commands are never consumed,
+// so /stop still works while a prompt is pending.
+func (tb *TelegramBridge) takeSecretReply(message *tgbotapi.Message, username string) bool {
+	text := message.Text
+	if text == "" || strings.HasPrefix(text, "/") {
+		return false
+	}
+	chatID, userID := message.Chat.ID, message.From.ID
+
+	tb.mu.Lock()
+	pending, ok := tb.pendingKeys[chatID]
+	if ok && (pending.userID != userID || time.Now().After(pending.expiresAt)) {
+		ok = false
+	}
+	if ok {
+		delete(tb.pendingKeys, chatID)
+	}
+	session, hasSession := tb.sessions[chatID]
+	tb.mu.Unlock()
+
+	if ok {
+		tb.deleteSecretMessage(chatID, message.MessageID)
+		tb.saveUploadedKey(chatID, username, pending.name, text)
+		return true
+	}
+
+	// Only the user who started the session answers its prompts
+	if !hasSession || !session.Active || session.UserID != userID || !session.takeSecretPrompt() {
+		return false
+	}
+	fmt.Printf("📱 @%s → [ssh secret]\n\n", username)
+	tb.deleteSecretMessage(chatID, message.MessageID)
+	session.sendSecret(text)
+	msg := tgbotapi.NewMessage(chatID, "🔑 Sent")
+	tb.bot.Send(msg)
+	return true
+}
@@ -3,16 +3,21 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
+// standaloneExitSentinel leaves interactive pass-through mode.
+const standaloneExitSentinel = "/exit"
+
 // RunStandalone runs terminal in standalone mode (no Telegram)
 func RunStandalone() {
 	fmt.Println("Terminal Standalone Mode")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("Commands:")
 	fmt.Println("  Type any shell command")
+	fmt.Println("  Interactive programs (python3, node, ...) run until /exit")
 	fmt.Println("  'exit' to quit")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
@@ -20,13 +25,16 @@ func RunStandalone() {
 	// Create console sink
 	sink := &ConsoleSink{}
 
+	config, _ := loadConfig() // nil-safe: standalone works without setup
+
 	// Create terminal
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, config, nil)
 	if err != nil {
 		fmt.Printf("Error creating terminal: %v\n", err)
 		return
 	}
-	defer term.Close()
+	defer func() { term.Close() }()
+	term.SetCommandTimeout(config.commandTimeout())
 
 	// Read commands from stdin
 	scanner := bufio.NewScanner(os.Stdin)
@@ -44,12 +52,31 @@ func RunStandalone() {
 			continue
 		}
 
-		// Send command
-		fmt.Printf("\n→ Executing: %s\n\n", command)
-		term.SendCommand(command)
+		if isInteractiveCommand(command, config) {
+			// REPLs never go quiet long enough for StreamOutput to return,
+			// so pass input and output straight through until /exit
+			fmt.Printf("\n→ Interactive: %s (type %s to leave)\n\n", command, standaloneExitSentinel)
+			term.SendCommand(command)
+			runInteractive(term, scanner, os.Stdout)
+		} else {
+			// Send command
+			fmt.Printf("\n→ Executing: %s\n\n", command)
+			term.RunCommand(command)
 
-		// Stream output
-		term.StreamOutput()
+			// Stream output
+			term.StreamOutput()
+		}
+
+		// A timed-out command or a finished interactive program closes
+		// the terminal — start a fresh shell
+		if term.isClosed() {
+			term, err = NewTerminal(sink, config, nil)
+			if err != nil {
+				fmt.Printf("Error creating terminal: %v\n", err)
+				return
+			}
+			term.SetCommandTimeout(config.commandTimeout())
+		}
 
 		fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		fmt.Print("$ ")
@@ -57,3 +84,29 @@ func RunStandalone() {
 
 	fmt.Println("\nGoodbye!")
 }
+
+// runInteractive forwards input lines to the terminal with SendRawInput and
+// copies its output to out as it arrives, until the user types the exit
+// sentinel or input ends. The terminal is closed on return, since the
+// interactive program is still attached to it.
+func runInteractive(term *Terminal, input *bufio.Scanner, out io.Writer) {
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for output := range term.outputChan {
+			fmt.Fprint(out, output)
+		}
+	}()
+
+	for input.Scan() {
+		line := input.Text()
+		if strings.TrimSpace(line) == standaloneExitSentinel {
+			break
+		}
+		term.SendRawInput(line + "\r")
+	}
+
+	term.Close()
+	<-printed
+	fmt.Fprintln(out)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -310,3 +312,217 @@ func BenchmarkStreamOutput(b *testing.B) {
 		streamOutputMock(outputChan, mockSend)
 	}
 }
+
+func TestOutputLimiterCapsAndCounts(t *testing.T) {
+	l := newOutputLimiter(10)
+
+	if got := l.take("hello "); got != "hello " {
+		t.Errorf("first chunk = %q, want it unchanged", got)
+	}
+	if got := l.notice(); got != "" {
+		t.Errorf("notice before truncation = %q, want empty", got)
+	}
+	if got := l.take("world!!"); got != "worl" {
+		t.Errorf("chunk crossing the cap = %q, want %q", got, "worl")
+	}
+	if got := l.take("more"); got != "" {
+		t.Errorf("chunk after cap = %q, want empty", got)
+	}
+
+	notice := l.notice()
+	if !strings.Contains(notice, "output truncated (17 bytes total)") {
+		t.Errorf("notice = %q, want total of 17 bytes", notice)
+	}
+	if got := l.notice(); got != "" {
+		t.Errorf("notice should only be sent once, got %q", got)
+	}
+
+	// A new command gets a fresh budget
+	l.reset()
+	if got := l.take("next"); got != "next" {
+		t.Errorf("after reset = %q, want %q", got, "next")
+	}
+}
+
+func TestOutputLimiterKeepsRunesWhole(t *testing.T) {
+	l := newOutputLimiter(4)
+	if got := l.take("ab✓"); got != "ab" {
+		t.Errorf("take = %q, want %q (no partial rune)", got, "ab")
+	}
+}
+
+func TestOutputLimiterDisabled(t *testing.T) {
+	var nilLimiter *outputLimiter
+	if got := nilLimiter.take("abc"); got != "abc" {
+		t.Errorf("nil limiter take = %q", got)
+	}
+	l := newOutputLimiter((&Config{MaxOutputBytes: -1}).maxOutputBytes())
+	big := strings.Repeat("x", 1<<20)
+	if got := l.take(big); got != big {
+		t.Error("disabled limiter should pass everything through")
+	}
+	if got := (&Config{}).maxOutputBytes(); got != defaultMaxOutputBytes {
+		t.Errorf("default cap = %d, want %d", got, defaultMaxOutputBytes)
+	}
+}
+
+// TestSplitAtClearScreen verifies output is split at its last clear sequence
+func TestSplitAtClearScreen(t *testing.T) {
+	before, after, cleared := splitAtClearScreen("old\r\n\x1b[H\x1b[2Jnew")
+	if !cleared || before != "old\r\n\x1b[H" || after != "\x1b[2Jnew" {
+		t.Errorf("splitAtClearScreen() = %q, %q, %v", before, after, cleared)
+	}
+	if _, after, cleared := splitAtClearScreen("a\x1b[2Jb\x1bcc"); !cleared || after != "\x1bcc" {
+		t.Errorf("expected split at last clear (RIS), got %q", after)
+	}
+	if _, _, cleared := splitAtClearScreen("plain output"); cleared {
+		t.Error("plain output reported as cleared")
+	}
+}
+
+// TestScreenDeduperResetOnClear feeds content, a clear, then new content
+// through the virtual screen and verifies no stale lines reappear, while
+// lines repeated after the clear are still sent
+func TestScreenDeduperResetOnClear(t *testing.T) {
+	screen := NewScreenReader(120, 50)
+	dedup := newScreenDeduper()
+	flush := func() string { return dedup.next(cleanTUIChrome(screen.Screen())) }
+
+	screen.WriteString("stale one\r\nstale two\r\nshared line\r\n")
+	if got := flush(); !strings.Contains(got, "stale one") {
+		t.Fatalf("first flush = %q", got)
+	}
+
+	before, after, cleared := splitAtClearScreen("\x1b[H\x1b[2Jfresh output\r\nshared line\r\n")
+	if !cleared {
+		t.Fatal("clear sequence not detected")
+	}
+	screen.WriteString(before)
+	dedup.reset()
+	screen.WriteString(after)
+
+	got := flush()
+	if strings.Contains(got, "stale") {
+		t.Errorf("stale lines reappeared after clear: %q", got)
+	}
+	if !strings.Contains(got, "fresh output") || !strings.Contains(got, "shared line") {
+		t.Errorf("new page not sent in full after clear: %q", got)
+	}
+}
+
+// TestCleanTUIChromePreservesCode verifies code samples keep box and
+// bullet characters that would otherwise be stripped as TUI chrome
+func TestCleanTUIChromePreservesCode(t *testing.T) {
+	screen := strings.Join([]string{
+		"● Here is a table printer:",
+		"",
+		"```python",
+		"print('│ name │ size │')",
+		"● not a bullet",
+		"────────────",
+		"```",
+		"",
+		"    for row in rows:",
+		"        print('│', row)",
+		"    ● done",
+		"",
+		"● That prints a table.",
+		"────────────────────",
+		"❯ ",
+	}, "\n")
+
+	want := strings.Join([]string{
+		"Here is a table printer:",
+		"",
+		"```python",
+		"print('│ name │ size │')",
+		"● not a bullet",
+		"────────────",
+		"```",
+		"",
+		"    for row in rows:",
+		"        print('│', row)",
+		"    ● done",
+		"",
+		"That prints a table.",
+	}, "\n")
+
+	if got := cleanTUIChrome(screen); got != want {
+		t.Errorf("cleanTUIChrome() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestCleanTUIChromeIndentedChromeNotCode verifies indentation alone
+// (without a preceding blank line) doesn't exempt TUI chrome
+func TestCleanTUIChromeIndentedChromeNotCode(t *testing.T) {
+	screen := "● Working on it\n    ⎿  Running tests\n    ✻ Thinking…"
+	if got := cleanTUIChrome(screen); got != "Working on it" {
+		t.Errorf("cleanTUIChrome() = %q, want %q", got, "Working on it")
+	}
+}
+
+// TestScreenDeduperResetOnAltScreenExit runs a script that prints a line on
+// the alternate screen, leaves it, and prints the same line on the main
+// screen, processing PTY output the way streamSessionOutput does. The
+// repeated line must be sent, and the restored main screen must not be.
+func TestScreenDeduperResetOnAltScreenExit(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "tui.sh")
+	body := "printf '\\033[?1049htui row\\n'\nsleep 1\nprintf '\\033[?1049l'\necho tui row\necho after tui\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+
+	screen := NewScreenReader(120, 50)
+	dedup := newScreenDeduper()
+	flush := func() string { return dedup.next(cleanTUIChrome(screen.Screen())) }
+
+	// feedUntil processes PTY output until a screen line reads want
+	hasLine := func(want string) bool {
+		for _, line := range strings.Split(screen.Screen(), "\n") {
+			if line == want {
+				return true
+			}
+		}
+		return false
+	}
+	feedUntil := func(want string) {
+		t.Helper()
+		deadline := time.After(10 * time.Second)
+		for !hasLine(want) {
+			select {
+			case output := <-term.outputChan:
+				screen.WriteString(output)
+				if restored, left := screen.LeftAltScreen(); left {
+					dedup.resetTo(cleanTUIChrome(restored))
+				}
+			case <-deadline:
+				t.Fatalf("timed out waiting for %q, screen: %q", want, screen.Screen())
+			}
+		}
+	}
+
+	term.SendCommand("echo main before")
+	feedUntil("main before")
+	flush()
+
+	term.SendCommand("sh " + script)
+	feedUntil("tui row")
+	if got := flush(); !strings.Contains(got, "tui row") {
+		t.Fatalf("alt screen flush = %q", got)
+	}
+
+	feedUntil("after tui")
+	got := flush()
+	if !strings.Contains(got, "tui row") || !strings.Contains(got, "after tui") {
+		t.Errorf("output after leaving the alt screen = %q, want tui row and after tui", got)
+	}
+	if strings.Contains(got, "main before") {
+		t.Errorf("restored main screen was re-sent: %q", got)
+	}
+}
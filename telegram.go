@@ -1,23 +1,166 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"html"
+	"image/png"
+	"io"
+	"io/fs"
 	"log"
+	"math"
+	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// botSender is the part of the Bot API a TelegramSink needs, so tests can
+// substitute a mock.
+type botSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// telegramBot is the part of the Bot API the bridge uses, so tests can
+// substitute a mock. *tgbotapi.BotAPI implements it.
+type telegramBot interface {
+	botSender
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	GetFileDirectURL(fileID string) (string, error)
+}
+
 // TelegramSink sends output to Telegram
 type TelegramSink struct {
-	bot    *tgbotapi.BotAPI
+	bot             botSender
+	chatID          int64
+	preserveColors  bool // Config.PreserveColors: send colored runs via SendColoredOutput
+	attachThreshold int  // Output longer than this is sent as a .txt file (0 = never)
+	binaryThreshold int  // Percent of non-printable characters that marks output as binary (0 = never)
+
+	chunkDelay time.Duration       // Pause between chunks of a long message (Config.ChunkDelayMs)
+	sleep      func(time.Duration) // Waits out chunkDelay and 429 backoffs; nil means time.Sleep (tests inject their own)
+
+	editInPlace bool         // Append output to the last message by editing it (session output)
+	liveMu      sync.Mutex   // Guards live
+	live        *liveMessage // Message later output is appended to; nil starts a new one
+}
+
+// maxMessageLen is the most text sent in one message, under Telegram's
+// 4096-character limit.
+const maxMessageLen = 4000
+
+// liveMessage is the last output message a session sink sent. Output that
+// follows is appended to it by editing, so a long response grows one
+// message instead of filling the chat.
+type liveMessage struct {
+	id      int
+	text    string    // Output shown, before formatting
+	colored bool      // text is colored HTML from SendColoredOutput
+	updated time.Time // When the message was sent or last edited
+}
+
+// liveMessageWindow is how long after its last update output is still
+// appended to a message. Edits don't notify, so output arriving after a
+// pause (a background job finishing) gets a message of its own.
+const liveMessageWindow = 30 * time.Second
+
+// typingInterval is how often "typing..." is re-sent while a command
+// runs; Telegram shows the action for 5 seconds.
+const typingInterval = 4 * time.Second
+
+// typingIndicator keeps a chat's "typing..." action alive.
+type typingIndicator struct {
+	bot    botSender
 	chatID int64
+	last   time.Time // When the action was last sent
+}
+
+// send shows "typing..." now.
+func (ti *typingIndicator) send() {
+	ti.bot.Send(tgbotapi.NewChatAction(ti.chatID, tgbotapi.ChatTyping))
+	ti.last = time.Now()
+}
+
+// refresh re-sends "typing..." if it is about to expire.
+func (ti *typingIndicator) refresh() {
+	if time.Since(ti.last) > typingInterval {
+		ti.send()
+	}
+}
+
+// keepTyping shows "typing..." in a chat until the returned stop function
+// is called, re-sending it every interval so one-shot commands that run
+// longer than the action lasts (/find, /split...) still show activity.
+func keepTyping(bot botSender, chatID int64, interval time.Duration) (stop func()) {
+	ti := &typingIndicator{bot: bot, chatID: chatID}
+	ti.send()
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ti.send()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// maxSendRetries bounds how many times a message refused with 429 Too Many
+// Requests is retried before it is dropped.
+const maxSendRetries = 3
+
+// send sends c, waiting out Telegram's retry_after and retrying when it is
+// refused for flooding.
+func (t *TelegramSink) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		msg, err := t.bot.Send(c)
+		var apiErr *tgbotapi.Error
+		if err == nil || attempt == maxSendRetries || !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+			return msg, err
+		}
+		log.Printf("⏳ Telegram rate limit, retrying in %ds\n", apiErr.RetryAfter)
+		t.wait(time.Duration(apiErr.RetryAfter) * time.Second)
+	}
+}
+
+// wait pauses the sink for d.
+func (t *TelegramSink) wait(d time.Duration) {
+	if t.sleep != nil {
+		t.sleep(d)
+		return
+	}
+	time.Sleep(d)
 }
 
 func (t *TelegramSink) SendOutput(output string) {
@@ -28,29 +171,195 @@ func (t *TelegramSink) SendOutput(output string) {
 		return
 	}
 
-	// Choose format based on content:
-	// - ASCII art → <pre> (monospace, preserves alignment)
-	// - Markdown content → HTML formatting in <blockquote>
-	// - Plain text → send as-is, no wrapping
-	maxLen := 4000
+	// Raw bytes (e.g. cat on an executable) render as garbage or get rejected
+	if looksBinary(output, t.binaryThreshold) {
+		t.startNewMessage()
+		msg := tgbotapi.NewMessage(t.chatID,
+			fmt.Sprintf("⚠️ output appears to be binary (%d bytes), use /get to download", len(output)))
+		t.send(msg)
+		return
+	}
+
+	// Long output as one file instead of a flood of chunked messages
+	if t.shouldAttach(output) {
+		t.startNewMessage()
+		t.sendAttachment(output)
+		return
+	}
+
+	if t.appendToLive(output, false) {
+		return
+	}
+
+	formatted, openTag := formatOutput(output)
+	switch {
+	case len(formatted) <= maxMessageLen && t.editInPlace:
+		t.sendLive(output, formatted, openTag != "", false)
+	case openTag != "":
+		t.sendHTML(formatted, openTag, maxMessageLen)
+	default:
+		t.sendPlain(output, maxMessageLen)
+	}
+}
 
+// formatOutput formats output for Telegram based on its content, returning
+// the tag it is wrapped in, or "" for plain text:
+// - ASCII art → <pre> (monospace, preserves alignment)
+// - Markdown content → HTML formatting in <blockquote>
+// - Plain text → sent as-is, no wrapping
+func formatOutput(output string) (formatted, openTag string) {
 	if needsMonospace(output) {
-		// ASCII art: wrap in <pre>
-		formatted := "<pre>" + html.EscapeString(output) + "</pre>"
-		t.sendHTML(formatted, "pre", maxLen)
-	} else if hasMarkdown(output) {
-		// Markdown: convert and wrap in blockquote
-		formatted := formatMarkdownToTelegramHTML(output)
+		return "<pre>" + html.EscapeString(output) + "</pre>", "pre"
+	}
+	if hasMarkdown(output) {
+		openTag = "blockquote"
 		if len(output) > 500 {
-			formatted = "<blockquote expandable>" + formatted + "</blockquote>"
-			t.sendHTML(formatted, "blockquote expandable", maxLen)
-		} else {
-			formatted = "<blockquote>" + formatted + "</blockquote>"
-			t.sendHTML(formatted, "blockquote", maxLen)
+			openTag = "blockquote expandable"
 		}
-	} else {
-		// Plain text: send without formatting
-		t.sendPlain(output, maxLen)
+		return "<" + openTag + ">" + formatMarkdownToTelegramHTML(output) + "</blockquote>", openTag
+	}
+	return output, ""
+}
+
+// SendColoredOutput sends output whose colored/bold runs have been rendered
+// as Telegram HTML (see ScreenReader.ScreenColored). Falls back to SendOutput
+// when colors are disabled or the output has no styling, so markdown and
+// ASCII-art detection still apply to uncolored text.
+func (t *TelegramSink) SendColoredOutput(plain, colored string) {
+	colored = strings.TrimSpace(colored)
+	trimmed := strings.TrimSpace(plain)
+	if !t.preserveColors || !strings.Contains(colored, "<") || t.shouldAttach(trimmed) || looksBinary(trimmed, t.binaryThreshold) {
+		t.SendOutput(plain)
+		return
+	}
+	if t.appendToLive(colored, true) {
+		return
+	}
+	formatted := "<blockquote>" + colored + "</blockquote>"
+	if len(formatted) <= maxMessageLen && t.editInPlace {
+		t.sendLive(colored, formatted, true, true)
+		return
+	}
+	t.sendHTML(formatted, "blockquote", maxMessageLen)
+}
+
+// sendLive sends output as one message and, if it went through, makes it
+// the message later output is appended to. text is the output before
+// formatting; isHTML says formatted is HTML.
+func (t *TelegramSink) sendLive(text, formatted string, isHTML, colored bool) {
+	msg := tgbotapi.NewMessage(t.chatID, formatted)
+	if isHTML {
+		msg.ParseMode = "HTML"
+	}
+	sent, err := t.send(msg)
+
+	t.liveMu.Lock()
+	defer t.liveMu.Unlock()
+	t.live = nil
+	if err != nil {
+		log.Printf("❌ Failed to send message: %v\n", err)
+		return
+	}
+	t.live = &liveMessage{id: sent.MessageID, text: text, colored: colored, updated: time.Now()}
+}
+
+// appendToLive edits the live message to show output after what it
+// already shows, reporting whether it did. It doesn't once the result would
+// no longer fit in one message, or if the edit fails; the caller then sends
+// output as a new message.
+func (t *TelegramSink) appendToLive(output string, colored bool) bool {
+	t.liveMu.Lock()
+	live := t.live
+	t.live = nil // Restored below once the edit succeeds
+	t.liveMu.Unlock()
+	if live == nil || live.colored != colored || time.Since(live.updated) > liveMessageWindow {
+		return false
+	}
+
+	text := live.text + "\n" + output
+	formatted, isHTML := "<blockquote>"+text+"</blockquote>", true
+	if !colored {
+		var openTag string
+		formatted, openTag = formatOutput(text)
+		isHTML = openTag != ""
+	}
+	if len(formatted) > maxMessageLen {
+		return false
+	}
+
+	edit := tgbotapi.NewEditMessageText(t.chatID, live.id, formatted)
+	if isHTML {
+		edit.ParseMode = "HTML"
+	}
+	if _, err := t.send(edit); err != nil {
+		log.Printf("⚠️  Failed to edit message, sending a new one: %v\n", err)
+		return false
+	}
+
+	t.liveMu.Lock()
+	t.live = &liveMessage{id: live.id, text: text, colored: colored, updated: time.Now()}
+	t.liveMu.Unlock()
+	return true
+}
+
+// startNewMessage makes the next output go to a new message rather than
+// be appended to the last one, e.g. once the user has sent a message that
+// the output would otherwise be hidden above.
+func (t *TelegramSink) startNewMessage() {
+	t.liveMu.Lock()
+	defer t.liveMu.Unlock()
+	t.live = nil
+}
+
+// looksBinary reports whether more than percent% of output's characters are
+// non-printable: control characters other than whitespace and ESC, invalid
+// UTF-8, or the replacement characters a terminal leaves for raw bytes.
+// A percent of zero disables the check.
+func looksBinary(output string, percent int) bool {
+	if percent <= 0 || output == "" {
+		return false
+	}
+	total, bad := 0, 0
+	for _, r := range output {
+		total++
+		switch {
+		case r == '\n' || r == '\r' || r == '\t' || r == '\x1b':
+		case r == utf8.RuneError || unicode.IsControl(r):
+			bad++
+		}
+	}
+	return bad*100 > total*percent
+}
+
+// shouldAttach reports whether output is over the attachment threshold.
+func (t *TelegramSink) shouldAttach(output string) bool {
+	return t.attachThreshold > 0 && len(output) > t.attachThreshold
+}
+
+// sendAttachment uploads output as a plain-text .txt document, falling back
+// to chunked messages if the upload fails.
+func (t *TelegramSink) sendAttachment(output string) {
+	text := cleanANSI(output)
+	doc := tgbotapi.NewDocument(t.chatID, tgbotapi.FileBytes{
+		Name:  attachmentName(time.Now()),
+		Bytes: []byte(text + "\n"),
+	})
+	doc.Caption = fmt.Sprintf("📎 Output (%d bytes)", len(text))
+	if _, err := t.send(doc); err != nil {
+		log.Printf("❌ Failed to send output file: %v\n", err)
+		t.sendPlain(text, 4000)
+	}
+}
+
+// attachmentName returns the file name for output sent as a document.
+func attachmentName(now time.Time) string {
+	return "output-" + now.Format("20060102-150405") + ".txt"
+}
+
+// pauseBetweenChunks waits chunkDelay before the next chunk is sent.
+func (t *TelegramSink) pauseBetweenChunks() {
+	if t.chunkDelay > 0 {
+		t.wait(t.chunkDelay)
 	}
 }
 
@@ -59,7 +368,7 @@ func (t *TelegramSink) SendOutput(output string) {
 func (t *TelegramSink) sendPlain(text string, maxLen int) {
 	if len(text) <= maxLen {
 		msg := tgbotapi.NewMessage(t.chatID, text)
-		_, err := t.bot.Send(msg)
+		_, err := t.send(msg)
 		if err != nil {
 			log.Printf("❌ Failed to send message: %v\n", err)
 		}
@@ -76,11 +385,11 @@ func (t *TelegramSink) sendPlain(text string, maxLen int) {
 			continue
 		}
 		msg := tgbotapi.NewMessage(t.chatID, chunk)
-		_, err := t.bot.Send(msg)
+		_, err := t.send(msg)
 		if err != nil {
 			log.Printf("❌ Failed to send chunk: %v\n", err)
 		}
-		time.Sleep(100 * time.Millisecond)
+		t.pauseBetweenChunks()
 	}
 }
 
@@ -92,7 +401,7 @@ func (t *TelegramSink) sendHTML(formatted string, openTag string, maxLen int) {
 	if len(formatted) <= maxLen {
 		msg := tgbotapi.NewMessage(t.chatID, formatted)
 		msg.ParseMode = "HTML"
-		_, err := t.bot.Send(msg)
+		_, err := t.send(msg)
 		if err != nil {
 			log.Printf("❌ Failed to send message: %v\n", err)
 		}
@@ -127,11 +436,11 @@ func (t *TelegramSink) sendHTML(formatted string, openTag string, maxLen int) {
 		chunkFormatted := "<" + openTag + ">" + chunk + "</" + closeTag + ">"
 		msg := tgbotapi.NewMessage(t.chatID, chunkFormatted)
 		msg.ParseMode = "HTML"
-		_, err := t.bot.Send(msg)
+		_, err := t.send(msg)
 		if err != nil {
 			log.Printf("❌ Failed to send chunk: %v\n", err)
 		}
-		time.Sleep(100 * time.Millisecond)
+		t.pauseBetweenChunks()
 	}
 }
 
@@ -150,13 +459,73 @@ func needsMonospace(s string) bool {
 // Session represents a persistent terminal session
 type Session struct {
 	Terminal   *Terminal
-	Sink       OutputSink
 	Active     bool
 	Command    string
+	UserID     int64 // Telegram user who started the session (0 for WebUI)
 	StartedAt  time.Time
 	done       chan struct{} // Signal to stop streaming goroutine
 	doneClosed bool         // Tracks whether done channel has been closed
 	closeMu    sync.Mutex   // Protects doneClosed and close(done)
+
+	sinkMu sync.Mutex   // Protects Sinks; streaming and /attach run concurrently
+	Sinks  []OutputSink // Where output goes: the session's own chat or tab, plus /attach'ed chats
+
+	stopMessageID int            // "Session started" message carrying the Stop button, guarded by TelegramBridge.mu
+	clearReq      chan struct{}  // Asks the streaming goroutine to forget sent output (/clear)
+	screenshotReq chan struct{}  // Asks the streaming goroutine to send the screen as an image (/screenshot)
+	grepReq       chan grepQuery // Asks the streaming goroutine to search its scrollback (/grep)
+	resizeReq     chan termSize  // Asks the streaming goroutine to resize its virtual screen (/resize)
+	scroll        *scrollback    // Cleaned output sent so far, for /grep and /save
+
+	auditMu      sync.Mutex  // Protects pendingAudit
+	pendingAudit *auditEntry // Last command, logged once its output arrives
+
+	muteMu sync.Mutex // Protects muted
+	muted  bool       // Skip periodic flushes; send only once output settles (/mute)
+
+	commands   chan queuedCommand // Commands waiting to be written to the PTY, one at a time
+	queueMu    sync.Mutex         // Protects queued and lastOutput
+	queued     int                // Commands enqueued and not yet settled, including the running one
+	lastOutput time.Time          // When the PTY last produced output
+
+	secretMu       sync.Mutex // Protects awaitingSecret, atSecretPrompt, pendingEcho and echoUntil
+	watchSecrets   bool       // Managed /ssh session: ask the user to answer password prompts
+	awaitingSecret bool       // A password prompt is waiting for the user's reply
+	atSecretPrompt bool       // Output last ended with a password prompt, warned about once
+	pendingEcho    string     // Secret just typed, removed from output if the PTY echoes it
+	echoUntil      time.Time  // When to stop looking for pendingEcho's echo
+
+	noIdleTimeout bool   // Exempt from the idle timeout, e.g. /tail on a quiet log
+	lastDir       string // Shell's working directory when output last arrived, for /reconnect
+
+	// WebUI reconnect state, guarded by WebUIServer.mu
+	resumeToken    string      // Lets a reconnecting WebSocket reattach
+	backlog        string      // Output buffered while no sink is attached
+	graceTimer     *time.Timer // Cleans up a disconnected session unless resumed
+	bracketedPaste bool        // The program last turned bracketed paste on, restored on attach
+
+	// WebUI read-only viewers, guarded by WebUIServer.mu
+	viewers map[*WebSocketSink]bool // Connections watching output without input
+	recent  string                  // Latest output, replayed to viewers as they join
+
+	// WebUI download log, guarded by WebUIServer.mu
+	outputLog string // Raw output sent so far (last 1 MB), served by /session-log
+}
+
+// sessionLimitReached reports whether sessions already holds max active
+// sessions. The caller holds the lock guarding sessions; zero max means
+// unlimited.
+func sessionLimitReached(sessions map[int64]*Session, max int) bool {
+	if max <= 0 {
+		return false
+	}
+	active := 0
+	for _, s := range sessions {
+		if s.Active {
+			active++
+		}
+	}
+	return active >= max
 }
 
 // safeCloseDone closes the done channel exactly once, preventing double-close panics.
@@ -169,20 +538,324 @@ func (s *Session) safeCloseDone() {
 	}
 }
 
+// sinkList snapshots the session's sinks so they can be written to
+// without holding sinkMu.
+func (s *Session) sinkList() []OutputSink {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	return append([]OutputSink(nil), s.Sinks...)
+}
+
+// addSink subscribes sink to the session's output.
+func (s *Session) addSink(sink OutputSink) {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	s.Sinks = append(s.Sinks, sink)
+}
+
+// removeSink unsubscribes sink, reporting whether it was subscribed.
+func (s *Session) removeSink(sink OutputSink) bool {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	for i, existing := range s.Sinks {
+		if existing == sink {
+			s.Sinks = append(s.Sinks[:i:i], s.Sinks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// setSink makes sink the session's only sink, or detaches all with nil.
+func (s *Session) setSink(sink OutputSink) {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	s.Sinks = nil
+	if sink != nil {
+		s.Sinks = []OutputSink{sink}
+	}
+}
+
+// hasSink reports whether sink receives the session's output.
+func (s *Session) hasSink(sink OutputSink) bool {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	for _, existing := range s.Sinks {
+		if existing == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// attached reports whether anything receives the session's output.
+func (s *Session) attached() bool {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	return len(s.Sinks) > 0
+}
+
+// telegramSinkFor returns the session's sink delivering to chatID, if any.
+func (s *Session) telegramSinkFor(chatID int64) (*TelegramSink, bool) {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	for _, sink := range s.Sinks {
+		if ts, ok := sink.(*TelegramSink); ok && ts.chatID == chatID {
+			return ts, true
+		}
+	}
+	return nil, false
+}
+
+// startNewMessages makes each Telegram sink send the next output as a new
+// message, as a new command starts a new response.
+func (s *Session) startNewMessages() {
+	for _, sink := range s.sinkList() {
+		if ts, ok := sink.(*TelegramSink); ok {
+			ts.startNewMessage()
+		}
+	}
+}
+
+// setMuted turns /mute on or off.
+func (s *Session) setMuted(muted bool) {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+	s.muted = muted
+}
+
+// isMuted reports whether intermediate output is suppressed.
+func (s *Session) isMuted() bool {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+	return s.muted
+}
+
+// requestClear asks the streaming goroutine to reset its screen and dedup
+// state. It never blocks; a request already pending covers this one.
+// Returns false if the session has no streaming goroutine to signal.
+func (s *Session) requestClear() bool {
+	if s.clearReq == nil {
+		return false
+	}
+	select {
+	case s.clearReq <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// requestScreenshot asks the streaming goroutine, which owns the virtual
+// screen, to send it as an image. Like requestClear it never blocks and
+// returns false without a streaming goroutine.
+func (s *Session) requestScreenshot() bool {
+	if s.screenshotReq == nil {
+		return false
+	}
+	select {
+	case s.screenshotReq <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// requestGrep asks the streaming goroutine, which owns the scrollback, to
+// search it and reply. Unlike requestScreenshot a pending search isn't
+// replaced, so it returns false when one is already queued too.
+func (s *Session) requestGrep(query grepQuery) bool {
+	if s.grepReq == nil {
+		return false
+	}
+	select {
+	case s.grepReq <- query:
+		return true
+	default:
+		return false
+	}
+}
+
+// termSize is a terminal size in rows and columns.
+type termSize struct {
+	rows, cols int
+}
+
+// requestResize asks the streaming goroutine, which owns the virtual
+// screen, to resize it. It never blocks; a size still pending is replaced,
+// so the latest request wins. Returns false without a streaming goroutine.
+func (s *Session) requestResize(size termSize) bool {
+	if s.resizeReq == nil {
+		return false
+	}
+	for {
+		select {
+		case s.resizeReq <- size:
+			return true
+		default:
+		}
+		select {
+		case <-s.resizeReq:
+		default:
+		}
+	}
+}
+
+// sendDelay is how long session output must be quiet before it is sent
+// to the chat, and before the next queued command is written.
+const sendDelay = 1500 * time.Millisecond
+
+// maxQueuedCommands bounds how many commands can wait behind a busy
+// session; maxCommandSettle bounds how long one command holds the queue,
+// so a command that streams forever doesn't block the rest.
+const (
+	maxQueuedCommands = 16
+	maxCommandSettle  = 30 * time.Second
+)
+
+// queuedCommand is a command waiting its turn to be written to a session.
+type queuedCommand struct {
+	username string
+	text     string
+}
+
+// markOutput records that the session's PTY just produced output.
+func (s *Session) markOutput() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.lastOutput = time.Now()
+}
+
+// sinceOutput reports how long the session's PTY has been quiet.
+func (s *Session) sinceOutput() time.Duration {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.lastOutput.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Since(s.lastOutput)
+}
+
+// enqueueCommand adds cmd to the session's command queue and returns how
+// many commands are ahead of it. It never blocks; ok is false when the
+// queue is full.
+func (s *Session) enqueueCommand(cmd queuedCommand) (ahead int, ok bool) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	select {
+	case s.commands <- cmd:
+		ahead = s.queued
+		s.queued++
+		return ahead, true
+	default:
+		return s.queued, false
+	}
+}
+
+// runCommandQueue writes queued commands to the PTY one at a time until
+// the session ends. After each command it waits for the output to settle
+// so rapid messages don't interleave: no output for settle, or maxWait at
+// most.
+func (s *Session) runCommandQueue(settle, maxWait time.Duration, send func(queuedCommand)) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case cmd := <-s.commands:
+			send(cmd)
+			s.waitForSettle(settle, maxWait)
+			s.queueMu.Lock()
+			s.queued--
+			s.queueMu.Unlock()
+		}
+	}
+}
+
+// waitForSettle blocks until the PTY has been quiet for settle since the
+// last command was written, maxWait has passed, or the session ends.
+func (s *Session) waitForSettle(settle, maxWait time.Duration) {
+	start := time.Now()
+	for {
+		quiet := min(time.Since(start), s.sinceOutput())
+		if quiet >= settle || time.Since(start) >= maxWait {
+			return
+		}
+		timer := time.NewTimer(settle - quiet)
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// approvalCodeTTL and maxApprovalAttempts mirror the first-time setup flow
+// in setupWithApproval: codes expire after 15 minutes and lock after 5 misses.
+const (
+	approvalCodeTTL     = 15 * time.Minute
+	maxApprovalAttempts = 5
+)
+
+// pendingApproval tracks an outstanding /approve code issued by an
+// authorized user so a new teammate can join the whitelist.
+type pendingApproval struct {
+	code      string
+	issuedBy  int64
+	expiresAt time.Time
+	attempts  map[int64]int // Failed guesses per sender
+}
+
 // TelegramBridge manages Telegram bot and terminal
 type TelegramBridge struct {
-	bot         *tgbotapi.BotAPI
+	bot         telegramBot
+	botName     string // The bot's @username, for the startup banner
 	config      *Config
 	mu          sync.RWMutex
-	sessions    map[int64]*Session // chatID -> active session
-	cleanupHook func()            // Called during signal-based shutdown (e.g., remove PID file)
+	sessions    map[int64]*Session          // chatID -> active session
+	approval    *pendingApproval            // Outstanding /approve code (nil if none)
+	rateLimits  map[int64][]time.Time       // userID -> command times in the last minute
+	history     map[int64][]string          // chatID -> recent commands, oldest first
+	env         map[int64]map[string]string // chatID -> /env overrides for new sessions
+	seenChats   map[int64]bool              // Chats authorized users have messaged from, for /announce
+	pendingKeys map[int64]pendingKey        // chatID -> /upload-key waiting for the key message
+	lastOutput  map[int64]*scrollback       // chatID -> output of the chat's most recent session, for /save
+	lastDirs    map[int64]string            // chatID -> last working directory of the chat's ended session, for /reconnect
+	pagers      map[int64]*pager            // chatID -> /page output being browsed
+	idleTimeout time.Duration               // Stop sessions without output for this long (0 = never)
+	location    *time.Location              // Config.Timezone, for times shown in chats
+	startedAt   time.Time                   // When the bridge was created, for /uptime
+
+	seenUpdates   map[int]bool // Update IDs already handled, to skip redelivered ones
+	seenUpdateIDs []int        // The keys of seenUpdates, oldest first
+
+	botIndex         int    // Index into Config.Bots, or -1 for the top-level bot_token
+	sessionIndexFile string // Session index file name in the config dir (one per bot)
 }
 
-func NewTelegramBridge(bot *tgbotapi.BotAPI, config *Config) (*TelegramBridge, error) {
+func NewTelegramBridge(bot telegramBot, config *Config) (*TelegramBridge, error) {
+	var botName string
+	if api, ok := bot.(*tgbotapi.BotAPI); ok && api != nil {
+		botName = api.Self.UserName
+	}
 	return &TelegramBridge{
-		bot:      bot,
-		config:   config,
-		sessions: make(map[int64]*Session),
+		bot:         bot,
+		botName:     botName,
+		config:      config,
+		sessions:    make(map[int64]*Session),
+		rateLimits:  make(map[int64][]time.Time),
+		history:     make(map[int64][]string),
+		env:         make(map[int64]map[string]string),
+		seenChats:   make(map[int64]bool),
+		pendingKeys: make(map[int64]pendingKey),
+		lastOutput:  make(map[int64]*scrollback),
+		lastDirs:    make(map[int64]string),
+		pagers:      make(map[int64]*pager),
+		idleTimeout: config.idleTimeout(),
+		location:    config.location(),
+		startedAt:   time.Now(),
+		seenUpdates: make(map[int]bool),
+
+		botIndex:         -1,
+		sessionIndexFile: "telegram-sessions.json",
 	}, nil
 }
 
@@ -191,8 +864,46 @@ func (tb *TelegramBridge) registerCommands() {
 	commands := tgbotapi.NewSetMyCommands(
 		tgbotapi.BotCommand{Command: "start", Description: "Connect to terminal"},
 		tgbotapi.BotCommand{Command: "stop", Description: "End current session"},
+		tgbotapi.BotCommand{Command: "kill", Description: "Send Ctrl-C to the running program"},
+		tgbotapi.BotCommand{Command: "secret", Description: "Type a password without it being echoed: /secret <text>"},
+		tgbotapi.BotCommand{Command: "clear", Description: "Reset output tracking and re-send the screen"},
+		tgbotapi.BotCommand{Command: "resize", Description: "Change the terminal size: /resize <rows> <cols>"},
+		tgbotapi.BotCommand{Command: "screenshot", Description: "Send the current screen as an image"},
+		tgbotapi.BotCommand{Command: "grep", Description: "Search session output: /grep [-i] [-m N] <pattern>"},
+		tgbotapi.BotCommand{Command: "attach", Description: "Mirror another chat's session here: /attach [id]"},
+		tgbotapi.BotCommand{Command: "detach", Description: "Stop mirroring attached sessions"},
+		tgbotapi.BotCommand{Command: "mute", Description: "Only send output once the program goes quiet"},
+		tgbotapi.BotCommand{Command: "unmute", Description: "Stream output while the program runs"},
 		tgbotapi.BotCommand{Command: "status", Description: "Show session info"},
 		tgbotapi.BotCommand{Command: "restart", Description: "Restart shell session"},
+		tgbotapi.BotCommand{Command: "reconnect", Description: "Start a new shell where a dead one left off"},
+		tgbotapi.BotCommand{Command: "approve", Description: "Generate a code to add a user"},
+		tgbotapi.BotCommand{Command: "get", Description: "Download a file: /get <path>"},
+		tgbotapi.BotCommand{Command: "view", Description: "Show a file as highlighted code: /view <path>"},
+		tgbotapi.BotCommand{Command: "zip", Description: "Download a directory as zip: /zip <dir>"},
+		tgbotapi.BotCommand{Command: "find", Description: "Find files by name: /find <pattern>"},
+		tgbotapi.BotCommand{Command: "split", Description: "Show each pipeline stage's output: /split a | b"},
+		tgbotapi.BotCommand{Command: "diff", Description: "Compare two files: /diff <a> <b>"},
+		tgbotapi.BotCommand{Command: "page", Description: "Show long output a page at a time: /page <cmd>"},
+		tgbotapi.BotCommand{Command: "cp", Description: "Copy a file: /cp <src> <dst>"},
+		tgbotapi.BotCommand{Command: "mv", Description: "Move a file: /mv <src> <dst>"},
+		tgbotapi.BotCommand{Command: "upload", Description: "Get a one-time WebUI upload link"},
+		tgbotapi.BotCommand{Command: "save", Description: "Save the session's output to a file: /save <path>"},
+		tgbotapi.BotCommand{Command: "tail", Description: "Follow a file like tail -f: /tail <path>"},
+		tgbotapi.BotCommand{Command: "ssh", Description: "SSH to a host: /ssh [user@]host [port]"},
+		tgbotapi.BotCommand{Command: "upload_key", Description: "Save a private key to ~/.ssh: /upload_key [name]"},
+		tgbotapi.BotCommand{Command: "ps", Description: "Show top processes by CPU"},
+		tgbotapi.BotCommand{Command: "free", Description: "Show memory usage"},
+		tgbotapi.BotCommand{Command: "monitor", Description: "Show load, busiest processes, memory and disk"},
+		tgbotapi.BotCommand{Command: "wait", Description: "Get notified when a process exits: /wait <pid>"},
+		tgbotapi.BotCommand{Command: "history", Description: "List recent commands (/!N re-runs one)"},
+		tgbotapi.BotCommand{Command: "retry", Description: "Re-run the last command"},
+		tgbotapi.BotCommand{Command: "env", Description: "Set or list env vars: /env KEY=VALUE"},
+		tgbotapi.BotCommand{Command: "unenv", Description: "Remove an env var: /unenv KEY"},
+		tgbotapi.BotCommand{Command: "whoami", Description: "Show your Telegram user ID"},
+		tgbotapi.BotCommand{Command: "uptime", Description: "Show bot uptime and sessions"},
+		tgbotapi.BotCommand{Command: "announce", Description: "Owner: message all chats: /announce <text>"},
+		tgbotapi.BotCommand{Command: "sessions", Description: "Owner: list every chat's session"},
 		tgbotapi.BotCommand{Command: "help", Description: "Show available commands"},
 	)
 	if _, err := tb.bot.Request(commands); err != nil {
@@ -208,229 +919,2518 @@ func (tb *TelegramBridge) Listen() {
 	u.Timeout = 60
 	updates := tb.bot.GetUpdatesChan(u)
 
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
-	go func() {
-		<-sigChan
-		log.Println("\n🛑 Shutting down gracefully...")
-		tb.CleanupAllSessions()
-		if tb.cleanupHook != nil {
-			tb.cleanupHook()
-		}
-		os.Exit(0)
-	}()
+	// SIGHUP (from --reload) re-reads the config without dropping sessions
+	stopReload := tb.watchReloadSignal(nil)
+	defer stopReload()
 
 	for update := range updates {
-		if update.Message == nil {
-			continue
+		tb.handleUpdate(update)
+	}
+}
+
+// handleUpdate routes one update from Telegram. An update already handled
+// (Telegram can redeliver updates after a reconnect) is skipped, so a
+// command never runs twice.
+func (tb *TelegramBridge) handleUpdate(update tgbotapi.Update) {
+	if !tb.markUpdateSeen(update.UpdateID) {
+		log.Printf("Skipping duplicate update %d\n", update.UpdateID)
+		return
+	}
+	config := tb.currentConfig()
+	if update.CallbackQuery != nil {
+		tb.handleCallback(update.CallbackQuery)
+		return
+	}
+	if update.InlineQuery != nil {
+		tb.handleInlineQuery(update.InlineQuery)
+		return
+	}
+	if update.Message == nil {
+		return
+	}
+
+	userID := update.Message.From.ID
+	username := update.Message.From.UserName
+	text := update.Message.Text
+	chatID := update.Message.Chat.ID
+
+	// Check whitelist
+	if !tb.isAllowed(userID) {
+		// A pending /approve code lets a new user join the whitelist
+		if reply, handled := tb.tryApprove(userID, username, strings.TrimSpace(text)); handled {
+			msg := tgbotapi.NewMessage(chatID, reply)
+			tb.bot.Send(msg)
+			return
+		}
+		log.Printf("⚠️  Unauthorized: @%s (ID: %d)\n", username, userID)
+		msg := tgbotapi.NewMessage(chatID, "❌ Unauthorized")
+		tb.bot.Send(msg)
+		return
+	}
+	tb.markChatSeen(chatID)
+
+	// Replies to this message come after it, so output still streaming
+	// into an earlier message continues in a new one below
+	tb.startNewMessages(chatID)
+
+	// Handle document uploads - save to the server
+	if update.Message.Document != nil {
+		tb.receiveFile(chatID, username, update.Message.Document)
+		return
+	}
+
+	// An ssh password or /upload-key reply is a secret, not a command
+	if tb.takeSecretReply(update.Message, username) {
+		return
+	}
+
+	// Handle secret - typed into the session, kept out of logs and
+	// /history; only the separating space is trimmed from the text
+	if text == "/secret" || strings.HasPrefix(text, "/secret ") {
+		tb.sendSecretInput(update.Message, username, strings.TrimPrefix(strings.TrimPrefix(text, "/secret"), " "))
+		return
+	}
+
+	// With a command prefix, ordinary (group) chatter is not a command
+	text, ok := applyCommandPrefix(text, config.CommandPrefix)
+	if !ok {
+		return
+	}
+
+	// Handle /start
+	if text == "/start" {
+		msg := tgbotapi.NewMessage(chatID, welcomeHTML(config))
+		msg.ParseMode = "HTML"
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Handle exit/stop - end session
+	if text == "/exit" || text == "/stop" {
+		tb.stopSession(chatID, username)
+		return
+	}
+
+	// Handle kill - interrupt the running program but keep the session
+	if text == "/kill" {
+		tb.interruptSession(chatID, username)
+		return
+	}
+
+	// Handle clear - re-send the current screen from scratch
+	if text == "/clear" {
+		tb.clearSession(chatID, username)
+		return
+	}
+
+	// Handle attach/detach - mirror another chat's session output here
+	if text == "/attach" || strings.HasPrefix(text, "/attach ") {
+		tb.attachSession(chatID, userID, username, strings.TrimSpace(strings.TrimPrefix(text, "/attach")))
+		return
+	}
+	if text == "/detach" {
+		tb.detachSession(chatID, username)
+		return
+	}
+
+	// Handle resize - change the session's terminal size
+	if text == "/resize" || strings.HasPrefix(text, "/resize ") {
+		tb.resizeSession(chatID, username, strings.TrimPrefix(text, "/resize"))
+		return
+	}
+
+	// Handle screenshot - the current screen as an image, for TUI layouts
+	if text == "/screenshot" {
+		tb.screenshotSession(chatID, username)
+		return
+	}
+
+	// Handle grep - search the session's recent output
+	if text == "/grep" || strings.HasPrefix(text, "/grep ") {
+		tb.grepSession(chatID, username, strings.TrimPrefix(text, "/grep"))
+		return
+	}
+
+	// Handle mute/unmute - suppress intermediate output of long responses
+	if text == "/mute" || text == "/unmute" {
+		tb.muteSession(chatID, username, text == "/mute")
+		return
+	}
+
+	// Handle status
+	if text == "/status" {
+		tb.showStatus(chatID)
+		return
+	}
+
+	// Handle restart daemon - owner-only restart of the whole process
+	if text == "/restart daemon" {
+		tb.restartDaemon(chatID, userID, username, update.UpdateID)
+		return
+	}
+
+	// Handle restart - stop current session, next command starts fresh
+	if text == "/restart" {
+		tb.mu.RLock()
+		_, hasSession := tb.sessions[chatID]
+		tb.mu.RUnlock()
+		if hasSession {
+			tb.stopSession(chatID, username)
+		}
+		msg := tgbotapi.NewMessage(chatID, "🔄 Session restarted. Send any command to begin.")
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Handle reconnect - a fresh shell where a dead one left off
+	if text == "/reconnect" {
+		tb.reconnectSession(chatID, userID, username)
+		return
+	}
+
+	// Handle approve - issue a one-time code for a new user
+	if text == "/approve" {
+		code, err := tb.issueApprovalCode(userID)
+		if err != nil {
+			log.Printf("Error generating approval code: %v\n", err)
+			msg := tgbotapi.NewMessage(chatID, "❌ Error generating approval code")
+			tb.bot.Send(msg)
+			return
+		}
+		fmt.Printf("📱 @%s → [approve] code issued\n\n", username)
+		msg := tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("🔐 Approval code: %s\n\n"+
+				"Ask the new user to message this bot with the code.\n"+
+				"Expires in %d minutes.", code, int(approvalCodeTTL.Minutes())))
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Handle upload - single-use WebUI link for files too big for Telegram
+	if text == "/upload" {
+		tb.sendUploadLink(chatID, username)
+		return
+	}
+
+	// Handle save - write the session's output to a file
+	if text == "/save" || strings.HasPrefix(text, "/save ") {
+		tb.saveOutput(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/save")))
+		return
+	}
+
+	// Handle tail - follow a log file until /stop
+	if text == "/tail" || strings.HasPrefix(text, "/tail ") {
+		tb.startTail(chatID, userID, username, strings.TrimSpace(strings.TrimPrefix(text, "/tail")))
+		return
+	}
+
+	// Handle ssh/upload-key - managed ssh sessions and key setup. Telegram
+	// menu commands can't contain "-", so /upload_key works too
+	if text == "/ssh" || strings.HasPrefix(text, "/ssh ") {
+		tb.startSSH(chatID, userID, username, strings.TrimPrefix(text, "/ssh"))
+		return
+	}
+	if isUploadKeyCommand(text) {
+		tb.handleUploadKey(update.Message, username, text)
+		return
+	}
+
+	// Handle env - per-chat environment overrides for new sessions
+	if text == "/env" || strings.HasPrefix(text, "/env ") {
+		tb.handleEnv(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/env")))
+		return
+	}
+	if text == "/unenv" || strings.HasPrefix(text, "/unenv ") {
+		tb.handleUnenv(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/unenv")))
+		return
+	}
+
+	// Handle whoami/uptime - diagnostics answered by the bridge itself
+	if text == "/whoami" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, tb.formatWhoami(userID, username)))
+		return
+	}
+	if text == "/uptime" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, tb.formatUptime(time.Now())))
+		return
+	}
+
+	// Handle sessions - owner-only overview of every chat's session
+	if text == "/sessions" {
+		tb.showAllSessions(chatID, userID)
+		return
+	}
+
+	// Handle announce - owner-only broadcast to every known chat
+	if text == "/announce" || strings.HasPrefix(text, "/announce ") {
+		tb.announce(chatID, userID, username, strings.TrimSpace(strings.TrimPrefix(text, "/announce")))
+		return
+	}
+
+	// Handle help
+	if text == "/help" {
+		msg := tgbotapi.NewMessage(chatID,
+			"📖 Commands:\n\n"+
+				"/stop — End current session\n"+
+				"/kill — Send Ctrl-C (keeps session)\n"+
+				"/secret <text> — Type a password; deleted, never echoed or logged\n"+
+				"/clear — Re-send the current screen\n"+
+				"/resize <rows> <cols> — Change the terminal size\n"+
+				"/screenshot — Current screen as an image\n"+
+				"/grep [-i] [-m N] <pattern> — Search the session's recent output\n"+
+				"/attach [id] — Mirror another chat's session output here\n"+
+				"/detach — Stop mirroring\n"+
+				"/mute, /unmute — Hide or show output while it streams\n"+
+				"/restart — Restart shell (fresh cwd)\n"+
+				"/restart daemon — Restart remote-term itself (owner only)\n"+
+				"/reconnect — Replace a dead shell, keeping its cwd and /env\n"+
+				"/status — Show session info\n"+
+				"/get <path> — Download a file\n"+
+				"/view <path> — Show a file as highlighted code\n"+
+				"/zip <dir> — Download a directory as a zip\n"+
+				"/find <pattern> — Find files by name\n"+
+				"/split <a | b> — Show each pipeline stage's output\n"+
+				"/diff <a> <b> — Compare two files (unified diff)\n"+
+				"/page <cmd> — Run a command and browse its output page by page\n"+
+				"/cp <src> <dst>, /mv <src> <dst> — Copy or move a file\n"+
+				"/upload — Get a WebUI link for large uploads\n"+
+				"/save <path> — Save the session's output to a file\n"+
+				"/tail <path> — Follow a file (tail -f) until /stop\n"+
+				"/ssh [user@]host [port] — SSH session; password prompts come to you\n"+
+				"/upload-key [name] — Save a private key to ~/.ssh\n"+
+				"/ps — Top processes by CPU\n"+
+				"/free — Memory usage\n"+
+				"/monitor — Load, top processes, memory and disk at a glance\n"+
+				"/wait <pid> — Tell me when a background process finishes\n"+
+				"/history — List recent commands\n"+
+				"/!N — Re-run command N from /history\n"+
+				"/retry — Re-run the last command\n"+
+				"/env [KEY=VALUE] — Set or list env vars for new sessions\n"+
+				"/unenv KEY — Remove an env var\n"+
+				"/approve — Generate a code to add a user\n"+
+				"/whoami — Show your Telegram identity\n"+
+				"/announce <text> — Message all chats (owner only)\n"+
+				"/sessions — List every chat's session (owner only)\n"+
+				"/uptime — Show bot uptime and active sessions\n"+
+				"/help — This message\n\n"+
+				"All commands run in a persistent shell.\n"+
+				"cd, env vars, etc. persist across messages.")
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Drop commands from users flooding the bot
+	if !tb.allowCommand(userID, time.Now()) {
+		log.Printf("⚠️  Rate limited: @%s (ID: %d)\n", username, userID)
+		msg := tgbotapi.NewMessage(chatID, "⚠️ rate limit exceeded, slow down")
+		tb.bot.Send(msg)
+		return
+	}
+
+	if text == "/retry" {
+		tb.retryLastCommand(config, chatID, userID, username)
+		return
+	}
+
+	// Handle all other commands
+	tb.handleCommand(config, chatID, userID, username, text)
+}
+
+// maxSeenUpdates bounds how many update IDs are remembered for
+// de-duplication; redeliveries come shortly after the original.
+const maxSeenUpdates = 1000
+
+// markUpdateSeen records an update ID, returning false if it was seen
+// before. The oldest IDs are forgotten past maxSeenUpdates. Only the
+// Listen loop calls it, so it needs no lock.
+func (tb *TelegramBridge) markUpdateSeen(id int) bool {
+	if tb.seenUpdates[id] {
+		return false
+	}
+	tb.seenUpdates[id] = true
+	tb.seenUpdateIDs = append(tb.seenUpdateIDs, id)
+	if len(tb.seenUpdateIDs) > maxSeenUpdates {
+		delete(tb.seenUpdates, tb.seenUpdateIDs[0])
+		tb.seenUpdateIDs = tb.seenUpdateIDs[1:]
+	}
+	return true
+}
+
+// applyCommandPrefix strips Config.CommandPrefix from a message, returning
+// false for messages that don't start with it and should be ignored.
+// Bot /commands need no prefix, and an empty prefix accepts everything.
+func applyCommandPrefix(text, prefix string) (string, bool) {
+	if prefix == "" || strings.HasPrefix(text, "/") {
+		return text, true
+	}
+	if !strings.HasPrefix(text, prefix) {
+		return "", false
+	}
+	command := strings.TrimSpace(strings.TrimPrefix(text, prefix))
+	return command, command != ""
+}
+
+// expandAlias replaces the first word of a command with its Config.Aliases
+// expansion, keeping the rest of the line ("ll foo" → "ls -la foo"). The
+// expansion is not itself expanded again, so aliases can't loop.
+func expandAlias(text string, aliases map[string]string) string {
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	expansion, ok := aliases[text[:end]]
+	if !ok {
+		return text
+	}
+	return expansion + text[end:]
+}
+
+// isOwner reports whether a user is the config owner: the first allowed
+// user, who completed the initial setup.
+func (tb *TelegramBridge) isOwner(userID int64) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return len(tb.config.AllowedUsers) > 0 && tb.config.AllowedUsers[0] == userID
+}
+
+// formatWhoami describes the sender for /whoami.
+func (tb *TelegramBridge) formatWhoami(userID int64, username string) string {
+	name := "(no username)"
+	if username != "" {
+		name = "@" + username
+	}
+	role := "allowed user"
+	if tb.isOwner(userID) {
+		role = "owner"
+	}
+	return fmt.Sprintf("👤 %s\nID: %d\nRole: %s", name, userID, role)
+}
+
+// formatUptime reports how long the bridge has run and its active sessions.
+func (tb *TelegramBridge) formatUptime(now time.Time) string {
+	tb.mu.RLock()
+	active := 0
+	for _, session := range tb.sessions {
+		if session.Active {
+			active++
+		}
+	}
+	tb.mu.RUnlock()
+
+	return fmt.Sprintf("⏱️ Uptime: %s\nActive sessions: %d",
+		now.Sub(tb.startedAt).Round(time.Second), active)
+}
+
+// defaultWelcomeMessage is the /start reply when Config.WelcomeMessage is unset.
+const defaultWelcomeMessage = "✅ Connected!\n\n" +
+	"Just send commands — a persistent shell session\n" +
+	"starts automatically. cd, env vars, etc. persist.\n\n" +
+	"• /exit or /stop → end session\n" +
+	"• /status → show session info"
+
+// welcomeHTML renders the /start reply: Config.WelcomeMessage converted
+// from Markdown, or the default. config may be nil.
+func welcomeHTML(config *Config) string {
+	message := defaultWelcomeMessage
+	if config != nil && config.WelcomeMessage != "" {
+		message = config.WelcomeMessage
+	}
+	return formatMarkdownToTelegramHTML(message)
+}
+
+// startNewMessages makes session output streaming to chatID, its own or
+// from /attach, continue in a new message.
+func (tb *TelegramBridge) startNewMessages(chatID int64) {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	for _, session := range tb.sessions {
+		if ts, ok := session.telegramSinkFor(chatID); ok {
+			ts.startNewMessage()
+		}
+	}
+}
+
+// markChatSeen records a chat an authorized user has written from.
+func (tb *TelegramBridge) markChatSeen(chatID int64) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.seenChats[chatID] = true
+}
+
+// announceTargets returns every known chat, seen or with an active
+// session, sorted by ID.
+func (tb *TelegramBridge) announceTargets() []int64 {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	targets := make(map[int64]bool, len(tb.seenChats))
+	for chatID := range tb.seenChats {
+		targets[chatID] = true
+	}
+	for chatID, session := range tb.sessions {
+		if session.Active {
+			targets[chatID] = true
+		}
+	}
+	list := make([]int64, 0, len(targets))
+	for chatID := range targets {
+		list = append(list, chatID)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// formatAnnouncement marks a broadcast so it stands out from command output.
+func formatAnnouncement(text string) string {
+	return "📢 Announcement\n\n" + text
+}
+
+// announce sends an owner's message to every known chat. Chats are only
+// known once someone has messaged the bot from them since it started.
+func (tb *TelegramBridge) announce(chatID, userID int64, username, text string) {
+	if !tb.isOwner(userID) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Only the owner can send announcements"))
+		return
+	}
+	if text == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /announce <text>"))
+		return
+	}
+
+	fmt.Printf("📱 @%s → [announce] %s\n\n", username, text)
+	sent := 0
+	for _, target := range tb.announceTargets() {
+		if _, err := tb.bot.Send(tgbotapi.NewMessage(target, formatAnnouncement(text))); err != nil {
+			log.Printf("❌ Announcement to chat %d failed: %v\n", target, err)
+			continue
+		}
+		sent++
+	}
+	tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Announcement sent to %d chat(s)", sent)))
+}
+
+// reloadConfig re-reads the config file and swaps it in, so whitelist and
+// limit changes apply without restarting. Active sessions are untouched; an
+// unreadable config leaves the current one in place.
+func (tb *TelegramBridge) reloadConfig() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if tb.botIndex >= 0 {
+		if tb.botIndex >= len(config.Bots) {
+			return fmt.Errorf("bot %d is no longer in the config", tb.botIndex+1)
+		}
+		// The token can't change without reconnecting; keep the live one
+		bot := config.Bots[tb.botIndex]
+		tb.mu.RLock()
+		bot.BotToken = tb.config.BotToken
+		tb.mu.RUnlock()
+		config = config.forBot(bot)
+	}
+	tb.mu.Lock()
+	tb.config = config
+	tb.idleTimeout = config.idleTimeout()
+	tb.location = config.location()
+	tb.mu.Unlock()
+	return nil
+}
+
+// watchReloadSignal reloads the config each time the process gets SIGHUP.
+// After each attempt reloaded, if not nil, is called with its result. The
+// returned stop func stops watching and waits for the watcher to exit.
+func (tb *TelegramBridge) watchReloadSignal(reloaded func(error)) (stop func()) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-quit:
+				return
+			case <-hupChan:
+			}
+			err := tb.reloadConfig()
+			if err != nil {
+				log.Printf("⚠️  Config reload failed, keeping current config: %v\n", err)
+			} else {
+				log.Printf("🔄 Config reloaded from %s\n", getConfigPath())
+			}
+			if reloaded != nil {
+				reloaded(err)
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hupChan)
+		close(quit)
+		<-exited
+	}
+}
+
+// persistConfig writes the bridge's whitelist back to the config file,
+// leaving every other setting as it is on disk. With several bots it goes
+// into this bot's Config.Bots entry. The whitelist is read under
+// configSaveMu, so the last save always writes the latest one; callers must
+// not hold tb.mu, so a slow disk doesn't stall other updates.
+func (tb *TelegramBridge) persistConfig() error {
+	return updateConfig(tb.currentConfig(), func(config *Config) error {
+		allowed := tb.currentConfig().AllowedUsers
+		if tb.botIndex < 0 {
+			config.AllowedUsers = allowed
+			return nil
+		}
+		if tb.botIndex >= len(config.Bots) {
+			return fmt.Errorf("bot %d is no longer in the config", tb.botIndex+1)
+		}
+		config.Bots[tb.botIndex].AllowedUsers = allowed
+		return nil
+	})
+}
+
+// restartRequests asks the shutdown handler to re-exec the process once it
+// has cleaned up, rather than exit (/restart daemon).
+var restartRequests = make(chan struct{}, 1)
+
+// shutdownOnSignal stops every bridge's sessions on SIGINT or SIGTERM, runs
+// cleanup (control socket, PID file) and exits. One handler covers all
+// bridges so the process can't exit while another is still cleaning up.
+// A restart request goes through the same cleanup, then starts the process
+// again in place.
+func shutdownOnSignal(bridges []*TelegramBridge, cleanup func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		restart := false
+		select {
+		case <-sigChan:
+			log.Println("\n🛑 Shutting down gracefully...")
+		case <-restartRequests:
+			log.Println("\n🔄 Restarting...")
+			restart = true
+		}
+		for _, tb := range bridges {
+			tb.CleanupAllSessions()
+		}
+		if cleanup != nil {
+			cleanup()
+		}
+		if restart {
+			err := reexecSelf()
+			log.Printf("❌ Restart failed, exiting: %v\n", err)
+		}
+		os.Exit(0)
+	}()
+}
+
+// restartDaemon handles /restart daemon: the owner's request to restart
+// remote-term itself, e.g. after upgrading the binary. Every session is
+// stopped, as on shutdown, and the process is started again with the same
+// arguments and config.
+func (tb *TelegramBridge) restartDaemon(chatID, userID int64, username string, updateID int) {
+	if !tb.isOwner(userID) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Only the owner can restart remote-term"))
+		return
+	}
+	if !selfRestartSupported {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Restarting remote-term is not supported on "+runtime.GOOS))
+		return
+	}
+
+	fmt.Printf("📱 @%s → [restart daemon]\n\n", username)
+	tb.bot.Send(tgbotapi.NewMessage(chatID, "🔄 Restarting remote-term — sessions are stopped, back in a few seconds"))
+
+	// Confirm the update now; the new process would otherwise fetch this
+	// message again and restart once more
+	tb.bot.Request(tgbotapi.UpdateConfig{Offset: updateID + 1, Limit: 1})
+
+	select {
+	case restartRequests <- struct{}{}:
+	default: // A restart is already under way
+	}
+}
+
+// isAllowed reports whether a Telegram user ID is on the whitelist.
+// currentConfig returns the bridge's config. A reload swaps in a new
+// *Config instead of changing the one in use, so the result is a
+// consistent snapshot that can be read without holding tb.mu. Take one per
+// update and pass it down, so a reload mid-command can't mix settings.
+func (tb *TelegramBridge) currentConfig() *Config {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.config
+}
+
+func (tb *TelegramBridge) isAllowed(userID int64) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	for _, allowedID := range tb.config.AllowedUsers {
+		if userID == allowedID {
+			return true
+		}
+	}
+	return false
+}
+
+// issueApprovalCode generates a fresh approval code, replacing any code that
+// is still pending. Only whitelisted users reach this (checked in Listen).
+func (tb *TelegramBridge) issueApprovalCode(issuedBy int64) (string, error) {
+	code, err := generateCode()
+	if err != nil {
+		return "", err
+	}
+	tb.mu.Lock()
+	tb.approval = &pendingApproval{
+		code:      code,
+		issuedBy:  issuedBy,
+		expiresAt: time.Now().Add(approvalCodeTTL),
+		attempts:  make(map[int64]int),
+	}
+	tb.mu.Unlock()
+	return code, nil
+}
+
+// tryApprove checks a message from a non-whitelisted user against the pending
+// approval code. On a match the user is appended to AllowedUsers and the config
+// is saved. Failed guesses are counted per sender, so other strangers writing
+// to the bot can't use up the new user's attempts; a sender out of attempts
+// is rejected as usual until a new code is issued. Returns the reply to send
+// and whether the message was handled; handled is false when no code is
+// pending for the sender, so the caller rejects as usual.
+func (tb *TelegramBridge) tryApprove(userID int64, username, text string) (string, bool) {
+	tb.mu.Lock()
+
+	if tb.approval == nil || tb.approval.attempts[userID] >= maxApprovalAttempts {
+		tb.mu.Unlock()
+		return "", false
+	}
+
+	// Check expiration
+	if time.Now().After(tb.approval.expiresAt) {
+		tb.approval = nil
+		tb.mu.Unlock()
+		return "❌ Approval code expired. Ask an authorized user to run /approve again.", true
+	}
+
+	if subtle.ConstantTimeCompare([]byte(text), []byte(tb.approval.code)) != 1 {
+		tb.approval.attempts[userID]++
+		remaining := maxApprovalAttempts - tb.approval.attempts[userID]
+		tb.mu.Unlock()
+		if remaining <= 0 {
+			log.Printf("⚠️  Approval locked for @%s (ID: %d) after failed attempts\n", username, userID)
+			return "❌ Too many failed attempts. Ask an authorized user to run /approve again.", true
+		}
+		return fmt.Sprintf("❌ Invalid approval code. %d attempts remaining.", remaining), true
+	}
+
+	tb.approval = nil
+	// Copy on write: callers may hold a snapshot of the current config
+	config := *tb.config
+	config.AllowedUsers = append(slices.Clone(tb.config.AllowedUsers), userID)
+	tb.config = &config
+	tb.mu.Unlock()
+
+	if err := tb.persistConfig(); err != nil {
+		log.Printf("Warning: could not save config: %v", err)
+	}
+	log.Printf("✅ User approved: @%s (ID: %d)\n", username, userID)
+
+	return fmt.Sprintf("✅ Approved!\n\n"+
+		"User: @%s (ID: %d)\n\n"+
+		"You can now send commands.\n"+
+		"Try: ls", username, userID), true
+}
+
+// interactiveCommands are REPLs and TUI tools that need a persistent session.
+// Config.InteractiveCommands extends this list.
+var interactiveCommands = []string{
+	"claude", "claude-code", "aider", // AI assistants
+	"python", "python3", "ipython",
+	"node", "deno", "bun",
+	"irb", "ruby",
+	"ghci", "stack",
+	"lua",
+	"psql", "mysql", "redis-cli",
+	"vim", "nvim", "emacs", "nano",
+	"less", "more",
+	"top", "htop", "btop",
+	"watch",
+	"ssh", "telnet",
+}
+
+// rateLimitWindow is the sliding window for per-user rate limiting.
+const rateLimitWindow = time.Minute
+
+// allowCommand records a command from userID and reports whether it is within
+// Config.RateLimitPerMinute over a sliding one-minute window. Users with no
+// commands inside the window are dropped from the map to keep it small.
+func (tb *TelegramBridge) allowCommand(userID int64, now time.Time) bool {
+	limit := tb.currentConfig().rateLimitPerMinute()
+	if limit == 0 {
+		return true
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	cutoff := now.Add(-rateLimitWindow)
+
+	// Prune expired timestamps for every user; forget idle users entirely
+	for id, times := range tb.rateLimits {
+		kept := times[:0]
+		for _, ts := range times {
+			if ts.After(cutoff) {
+				kept = append(kept, ts)
+			}
+		}
+		if len(kept) == 0 {
+			delete(tb.rateLimits, id)
+		} else {
+			tb.rateLimits[id] = kept
+		}
+	}
+
+	if len(tb.rateLimits[userID]) >= limit {
+		return false
+	}
+	tb.rateLimits[userID] = append(tb.rateLimits[userID], now)
+	return true
+}
+
+// maxHistory is how many commands are remembered per chat for /history.
+const maxHistory = 50
+
+// recordHistory appends a command to the chat's history, dropping the
+// oldest entry once maxHistory is reached.
+func (tb *TelegramBridge) recordHistory(chatID int64, command string) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	h := append(tb.history[chatID], command)
+	if len(h) > maxHistory {
+		h = h[len(h)-maxHistory:]
+	}
+	tb.history[chatID] = h
+}
+
+// commandHistory returns a copy of the chat's history, oldest first.
+func (tb *TelegramBridge) commandHistory(chatID int64) []string {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return append([]string(nil), tb.history[chatID]...)
+}
+
+// historyEntry returns the Nth (1-based, as numbered by /history) command.
+func (tb *TelegramBridge) historyEntry(chatID int64, n int) (string, bool) {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	h := tb.history[chatID]
+	if n < 1 || n > len(h) {
+		return "", false
+	}
+	return h[n-1], true
+}
+
+// lastCommand returns the most recent command run in a chat.
+func (tb *TelegramBridge) lastCommand(chatID int64) (string, bool) {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	h := tb.history[chatID]
+	if len(h) == 0 {
+		return "", false
+	}
+	return h[len(h)-1], true
+}
+
+// retryLastCommand re-dispatches the chat's last command. It is refused while
+// an interactive program owns the session, since the text would be typed
+// into that program rather than re-run by the shell.
+func (tb *TelegramBridge) retryLastCommand(config *Config, chatID, userID int64, username string) {
+	tb.mu.RLock()
+	session, hasSession := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
+	if hasSession && session.Active && isInteractiveCommand(session.Command, config) {
+		msg := tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("⚠️ %s is running — send input to it directly, or /stop first.", session.Command))
+		tb.bot.Send(msg)
+		return
+	}
+
+	command, ok := tb.lastCommand(chatID)
+	if !ok {
+		msg := tgbotapi.NewMessage(chatID, "🤷 Nothing to retry yet — send a command first.")
+		tb.bot.Send(msg)
+		return
+	}
+
+	fmt.Printf("📱 @%s → [retry] %s\n", username, command)
+	tb.handleCommand(config, chatID, userID, username, command)
+}
+
+// envKeyPattern matches valid environment variable names.
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnvAssignment splits "KEY=VALUE", rejecting input without "=" or
+// with an invalid variable name. Errors are user-facing.
+func parseEnvAssignment(arg string) (string, string, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected KEY=VALUE")
+	}
+	key = strings.TrimSpace(key)
+	if !envKeyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("invalid variable name %q", key)
+	}
+	return key, value, nil
+}
+
+// setEnv stores an environment override for the chat's next session.
+func (tb *TelegramBridge) setEnv(chatID int64, key, value string) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.env[chatID] == nil {
+		tb.env[chatID] = make(map[string]string)
+	}
+	tb.env[chatID][key] = value
+}
+
+// unsetEnv removes an override, reporting whether it existed.
+func (tb *TelegramBridge) unsetEnv(chatID int64, key string) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if _, ok := tb.env[chatID][key]; !ok {
+		return false
+	}
+	delete(tb.env[chatID], key)
+	if len(tb.env[chatID]) == 0 {
+		delete(tb.env, chatID)
+	}
+	return true
+}
+
+// sessionEnv returns a copy of the chat's overrides for NewTerminal.
+func (tb *TelegramBridge) sessionEnv(chatID int64) map[string]string {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	if len(tb.env[chatID]) == 0 {
+		return nil
+	}
+	env := make(map[string]string, len(tb.env[chatID]))
+	for k, v := range tb.env[chatID] {
+		env[k] = v
+	}
+	return env
+}
+
+// formatEnvOverrides renders the /env listing. Only the names are shown:
+// values are often API keys and shouldn't sit in the chat history.
+func formatEnvOverrides(env map[string]string) string {
+	if len(env) == 0 {
+		return "🌱 No environment overrides. Set one with /env KEY=VALUE"
+	}
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return "🌱 Environment overrides (applied to new sessions, values hidden):\n\n" + strings.Join(names, "\n")
+}
+
+// handleEnv lists overrides (no argument) or sets one from "KEY=VALUE".
+// Values are never logged or echoed since they are often API keys.
+func (tb *TelegramBridge) handleEnv(chatID int64, username, arg string) {
+	if arg == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, formatEnvOverrides(tb.sessionEnv(chatID))))
+		return
+	}
+
+	key, value, err := parseEnvAssignment(arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %v. Usage: /env KEY=VALUE", err)))
+		return
+	}
+	tb.setEnv(chatID, key, value)
+	fmt.Printf("📱 @%s → [env] set %s\n\n", username, key)
+	tb.bot.Send(tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("✅ %s set. Applies to the next session — /restart to use it now.", key)))
+}
+
+// handleUnenv removes an override set with /env.
+func (tb *TelegramBridge) handleUnenv(chatID int64, username, key string) {
+	if key == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /unenv KEY"))
+		return
+	}
+	if !tb.unsetEnv(chatID, key) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ %s is not set", key)))
+		return
+	}
+	fmt.Printf("📱 @%s → [env] unset %s\n\n", username, key)
+	tb.bot.Send(tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("✅ %s removed. Applies to the next session — /restart to use it now.", key)))
+}
+
+// formatHistory renders the numbered list shown by /history.
+func formatHistory(entries []string) string {
+	if len(entries) == 0 {
+		return "📜 No commands yet."
+	}
+	var b strings.Builder
+	b.WriteString("📜 History (re-run with /!N):\n\n")
+	for i, cmd := range entries {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, cmd)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// isInteractiveCommand checks if a command needs a persistent session.
+// Commands listed in config.InteractiveCommands are matched in addition
+// to the built-in list. config may be nil.
+func isInteractiveCommand(cmd string, config *Config) bool {
+	// Get first word of command
+	parts := strings.Fields(cmd)
+	if len(parts) == 0 {
+		return false
+	}
+	
+	firstWord := parts[0]
+	
+	for _, cmd := range interactiveCommands {
+		if firstWord == cmd {
+			return true
+		}
+	}
+
+	if config != nil {
+		for _, cmd := range config.InteractiveCommands {
+			if firstWord == cmd {
+				return true
+			}
+		}
+	}
+	
+	return false
+}
+
+// commandPermitted checks a command's first word against Config.DeniedCommands
+// and, when set, Config.AllowedCommands (strict mode). Patterns use path.Match
+// globs and match the word as typed or its base name, so "rm*" also blocks
+// "/bin/rm". Denied patterns win over allowed ones. config may be nil.
+func commandPermitted(command string, config *Config) bool {
+	parts := strings.Fields(command)
+	if config == nil || len(parts) == 0 {
+		return true
+	}
+
+	word := parts[0]
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, word); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(word)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if matches(config.DeniedCommands) {
+		return false
+	}
+	if len(config.AllowedCommands) > 0 {
+		return matches(config.AllowedCommands)
+	}
+	return true
+}
+
+// handleCommand routes all commands to a persistent session.
+// If no session exists, one is auto-started so that state (cwd, env vars)
+// persists across commands. userID picks the profile a new session uses.
+func (tb *TelegramBridge) handleCommand(config *Config, chatID, userID int64, username, text string) {
+	// History recall is resolved before recording so the re-run command,
+	// not "/!N", lands in the history
+	if text == "/history" {
+		msg := tgbotapi.NewMessage(chatID, formatHistory(tb.commandHistory(chatID)))
+		tb.bot.Send(msg)
+		return
+	}
+	if strings.HasPrefix(text, "/!") {
+		n, err := strconv.Atoi(strings.TrimPrefix(text, "/!"))
+		entry, ok := tb.historyEntry(chatID, n)
+		if err != nil || !ok {
+			msg := tgbotapi.NewMessage(chatID, "❌ No such history entry. Send /history to list commands.")
+			tb.bot.Send(msg)
+			return
+		}
+		fmt.Printf("📱 @%s → [history !%d] %s\n", username, n, entry)
+		tb.handleCommand(config, chatID, userID, username, entry)
+		return
+	}
+	tb.recordHistory(chatID, text)
+	// Pages of an earlier /page are dropped once anything else runs
+	tb.dropPager(chatID)
+
+	// Aliases are recorded as typed and expanded before routing
+	text = expandAlias(text, config.Aliases)
+
+	// Built-in file transfer (handled by the bridge, never sent to the PTY)
+	if strings.HasPrefix(text, "/get ") {
+		tb.sendFile(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/get ")))
+		return
+	}
+	if text == "/view" || strings.HasPrefix(text, "/view ") {
+		tb.viewFile(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/view")))
+		return
+	}
+	if text == "/zip" || strings.HasPrefix(text, "/zip ") {
+		tb.sendDirectory(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/zip")))
+		return
+	}
+
+	// Built-in system info shortcuts (run outside the session)
+	if _, ok := systemInfoCommands[text]; ok {
+		tb.sendSystemInfo(chatID, username, text)
+		return
+	}
+	if text == "/monitor" {
+		tb.sendMonitor(chatID, username)
+		return
+	}
+	if text == "/wait" || strings.HasPrefix(text, "/wait ") {
+		tb.waitProcess(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/wait")))
+		return
+	}
+	if text == "/find" || strings.HasPrefix(text, "/find ") {
+		tb.sendFindResults(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/find")))
+		return
+	}
+	if text == "/split" || strings.HasPrefix(text, "/split ") {
+		tb.sendSplitResults(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/split")))
+		return
+	}
+	if text == "/page" || strings.HasPrefix(text, "/page ") {
+		tb.sendPaged(chatID, username, strings.TrimSpace(strings.TrimPrefix(text, "/page")))
+		return
+	}
+	if text == "/diff" || strings.HasPrefix(text, "/diff ") {
+		tb.sendDiff(chatID, username, strings.Fields(strings.TrimPrefix(text, "/diff")))
+		return
+	}
+	for _, name := range []string{"cp", "mv"} {
+		if text == "/"+name || strings.HasPrefix(text, "/"+name+" ") {
+			tb.fileOp(chatID, username, name, strings.Fields(strings.TrimPrefix(text, "/"+name)))
+			return
+		}
+	}
+
+	// Admin allow/deny lists: blocked commands never reach the PTY
+	if !commandPermitted(text, config) {
+		fmt.Printf("📱 @%s → [blocked] %s\n\n", username, text)
+		if config != nil && config.AuditLog {
+			tb.writeAudit(auditEntry{Time: time.Now(), ChatID: chatID, Username: username, Command: text}, "command not permitted")
+		}
+		msg := tgbotapi.NewMessage(chatID, "🚫 command not permitted")
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Check if session exists
+	tb.mu.RLock()
+	session, hasSession := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
+	// If session exists and active, send to it
+	if hasSession && session.Active {
+		fmt.Printf("📱 @%s → [session] %s\n\n", username, text)
+		// Show "typing..." while waiting for response
+		typing := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
+		tb.bot.Send(typing)
+		if session.commands == nil {
+			tb.auditCommand(session, chatID, username, text)
+			session.startNewMessages()
+			session.Terminal.SendCommand(text)
+			return
+		}
+		// Commands run one at a time so rapid messages don't interleave
+		ahead, ok := session.enqueueCommand(queuedCommand{username: username, text: text})
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ %d commands already queued — wait for them to finish", ahead))
+			tb.bot.Send(msg)
+		} else if ahead > 0 {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Queued behind %d command(s)", ahead))
+			tb.bot.Send(msg)
+		}
+		return
+	}
+
+	// No session — auto-start persistent shell session
+	tb.startSession(chatID, userID, username, text)
+}
+
+// auditCommand records a command sent to the session's PTY when
+// Config.AuditLog is set. The entry is written once the command's first
+// output is flushed; a previous command still waiting is written without
+// a result.
+func (tb *TelegramBridge) auditCommand(session *Session, chatID int64, username, command string) {
+	if config := tb.currentConfig(); config == nil || !config.AuditLog {
+		return
+	}
+	session.auditMu.Lock()
+	prev := session.pendingAudit
+	session.pendingAudit = &auditEntry{Time: time.Now(), ChatID: chatID, Username: username, Command: command}
+	session.auditMu.Unlock()
+
+	if prev != nil {
+		tb.writeAudit(*prev, "")
+	}
+}
+
+// flushAudit writes the session's pending audit entry, if any, using the
+// first line of output as its result.
+func (tb *TelegramBridge) flushAudit(session *Session, output string) {
+	session.auditMu.Lock()
+	entry := session.pendingAudit
+	session.pendingAudit = nil
+	session.auditMu.Unlock()
+
+	if entry != nil {
+		tb.writeAudit(*entry, firstResultLine(output, entry.Command))
+	}
+}
+
+// writeAudit appends to the audit log, logging rather than surfacing failures.
+func (tb *TelegramBridge) writeAudit(entry auditEntry, result string) {
+	if err := appendAuditLog(entry, result); err != nil {
+		log.Printf("❌ Failed to write audit log: %v\n", err)
+	}
+}
+
+// systemInfoCommands maps the /ps and /free shortcuts to shell pipelines,
+// tried in order until one produces output. The fallbacks cover systems
+// without procps, such as macOS.
+var systemInfoCommands = map[string][]string{
+	"/ps":   {"ps aux --sort=-%cpu | head -n 11", "ps aux -r | head -n 11"},
+	"/free": {"free -h", "vm_stat"},
+}
+
+// systemInfoTimeout bounds a /ps or /free pipeline.
+const systemInfoTimeout = 10 * time.Second
+
+// runFirstAvailable runs each pipeline with sh until one exits cleanly with
+// output, returning that output.
+func runFirstAvailable(pipelines []string) (string, error) {
+	lastErr := fmt.Errorf("no command available")
+	for _, pipeline := range pipelines {
+		output, err := runPipeline("", pipeline)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if output != "" {
+			return output, nil
+		}
+	}
+	return "", lastErr
+}
+
+// runPipeline runs a pipeline with sh in dir (the bridge's directory if
+// empty), bounded by systemInfoTimeout. WaitDelay stops a killed shell's
+// children from holding the output pipe open past the deadline.
+func runPipeline(dir, pipeline string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), systemInfoTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", pipeline)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// maxFindResults and maxFindDepth bound /find so it can't flood the chat
+// or crawl a huge tree.
+const (
+	maxFindResults = 50
+	maxFindDepth   = 4
+)
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// findPipeline builds the bounded, case-insensitive name search run by /find.
+func findPipeline(pattern string) string {
+	return fmt.Sprintf("find . -maxdepth %d -iname %s 2>/dev/null | head -n %d",
+		maxFindDepth, shellQuote("*"+pattern+"*"), maxFindResults)
+}
+
+// sessionDir returns the working directory of the chat's session shell,
+// or "" (the bridge's directory) without a session or where it can't be read.
+func (tb *TelegramBridge) sessionDir(chatID int64) string {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	if session, ok := tb.sessions[chatID]; ok && session.Active {
+		return processDir(session.Terminal.PID())
+	}
+	return ""
+}
+
+// splitPipeline splits a shell command at its top-level pipes. It returns
+// each stage's own text and the cumulative pipelines ("a", "a | b", ...),
+// the latter sliced from the original so spacing and |& are kept. Pipes
+// inside quotes, after a backslash, or nested in $(...), (...), {...} or
+// backticks don't split, and neither does ||.
+func splitPipeline(command string) (stages, prefixes []string) {
+	depth := 0 // Nesting of (), $() and {}
+	quote := byte(0)
+	escaped := false
+	start := 0
+
+	cut := func(end, next int) {
+		stages = append(stages, strings.TrimSpace(command[start:end]))
+		prefixes = append(prefixes, strings.TrimSpace(command[:end]))
+		start = next
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case quote != 0: // Inside "..." or `...`
+			if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			escaped = true
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(' || c == '{':
+			depth++
+		case c == ')' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+		case c == '|' && depth == 0:
+			switch {
+			case i+1 < len(command) && command[i+1] == '|':
+				i++ // || is a logical OR, not a pipe
+			case i > 0 && command[i-1] == '>':
+				// >| is a redirection
+			case i+1 < len(command) && command[i+1] == '&':
+				cut(i, i+2) // |& pipes stderr too
+				i++
+			default:
+				cut(i, i+1)
+			}
+		}
+	}
+	cut(len(command), len(command))
+	return stages, prefixes
+}
+
+// maxSplitStageLines caps the output shown per /split stage.
+const maxSplitStageLines = 20
+
+// formatSplitStage labels one /split stage's output, trimmed to
+// maxSplitStageLines.
+func formatSplitStage(n int, pipeline, output string, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "▶ [%d] %s\n", n, pipeline)
+	lines := strings.Split(output, "\n")
+	switch {
+	case output == "":
+		b.WriteString("(no output)\n")
+	case len(lines) > maxSplitStageLines:
+		b.WriteString(strings.Join(lines[:maxSplitStageLines], "\n"))
+		fmt.Fprintf(&b, "\n… %d more lines\n", len(lines)-maxSplitStageLines)
+	default:
+		b.WriteString(output + "\n")
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "(%v)\n", err)
+	}
+	return b.String()
+}
+
+// sendSplitResults runs /split: each cumulative stage of a pipeline from
+// the session's working directory, replying with every stage's output.
+// Each stage is checked against the command allow/deny lists, since it
+// runs outside the session.
+func (tb *TelegramBridge) sendSplitResults(chatID int64, username, command string) {
+	stages, prefixes := splitPipeline(command)
+	if command == "" || len(stages) < 2 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /split <cmd1> | <cmd2> [| ...]"))
+		return
+	}
+	for _, stage := range stages {
+		if stage == "" {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Empty pipeline stage"))
+			return
+		}
+		if !commandPermitted(stage, tb.currentConfig()) {
+			fmt.Printf("📱 @%s → [blocked] /split %s\n\n", username, command)
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "🚫 command not permitted"))
+			return
+		}
+	}
+	fmt.Printf("📱 @%s → [split] %s\n\n", username, command)
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	dir := tb.sessionDir(chatID)
+	var b strings.Builder
+	for i, prefix := range prefixes {
+		output, err := runPipeline(dir, prefix)
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(formatSplitStage(i+1, prefix, output, err))
+	}
+	stopTyping()
+
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(strings.TrimRight(b.String(), "\n"))+"</pre>", "pre", 4000)
+}
+
+// sendFindResults runs /find from the session's working directory (or the
+// bridge's, without a session) and replies with matches as a monospace block.
+func (tb *TelegramBridge) sendFindResults(chatID int64, username, pattern string) {
+	if pattern == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /find <pattern>"))
+		return
+	}
+	fmt.Printf("📱 @%s → [find] %s\n\n", username, pattern)
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	output, err := runPipeline(tb.sessionDir(chatID), findPipeline(pattern))
+	stopTyping()
+	if err != nil && output == "" {
+		log.Printf("❌ /find failed: %v\n", err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Search failed or timed out"))
+		return
+	}
+	if output == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔍 No files matching %q", pattern)))
+		return
+	}
+
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(output)+"</pre>", "pre", 4000)
+}
+
+// diffPath resolves a /diff argument: ~ is expanded and relative paths are
+// taken from dir (the session's working directory, if known). Errors are
+// user-facing.
+func diffPath(dir, arg string) (string, error) {
+	return resolveFileArg(dir, arg, "/diff compares two files")
+}
+
+// tailPath resolves a /tail argument like diffPath.
+func tailPath(dir, arg string) (string, error) {
+	return resolveFileArg(dir, arg, "/tail follows a file")
+}
+
+// resolveFileArg resolves a path argument that must name an existing
+// file; dirHint explains why a directory was refused.
+func resolveFileArg(dir, arg, dirHint string) (string, error) {
+	path := expandPath(arg)
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file not found: %s", arg)
+		}
+		return "", fmt.Errorf("cannot access %s: %w", arg, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory — %s", arg, dirHint)
+	}
+	return path, nil
+}
+
+// runDiff runs a unified diff of two files, with git's diff where diff
+// isn't installed. identical is true when the files match.
+func runDiff(a, b string) (output string, identical bool, err error) {
+	args := shellQuote(a) + " " + shellQuote(b)
+	for _, pipeline := range []string{"diff -u -- " + args, "git diff --no-index --no-color -- " + args} {
+		output, err := runPipeline("", pipeline)
+		var exitErr *exec.ExitError
+		if err == nil {
+			return "", true, nil
+		}
+		if !errors.As(err, &exitErr) {
+			return "", false, err
+		}
+		switch exitErr.ExitCode() {
+		case 1: // Files differ
+			return output, false, nil
+		case 127: // Not installed, try the next tool
+			continue
+		}
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return "", false, errors.New(msg)
+		}
+		return "", false, err
+	}
+	return "", false, errors.New("neither diff nor git is installed")
+}
+
+// diffStats counts the added and removed lines in a unified diff, skipping
+// the ---/+++ file headers.
+func diffStats(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// sendDiff runs /diff on two files and replies with the unified diff in a
+// monospace block, so +/- columns stay aligned, chunked if it is long.
+func (tb *TelegramBridge) sendDiff(chatID int64, username string, args []string) {
+	if len(args) != 2 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /diff <file1> <file2>"))
+		return
+	}
+	fmt.Printf("📱 @%s → [diff] %s %s\n\n", username, args[0], args[1])
+
+	dir := tb.sessionDir(chatID)
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		path, err := diffPath(dir, arg)
+		if err != nil {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+			return
+		}
+		paths[i] = path
+	}
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	output, identical, err := runDiff(paths[0], paths[1])
+	stopTyping()
+	switch {
+	case err != nil:
+		log.Printf("❌ /diff failed: %v\n", err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ diff failed: "+err.Error()))
+		return
+	case identical:
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %s and %s are identical", args[0], args[1])))
+		return
+	}
+
+	added, removed := diffStats(output)
+	header := tgbotapi.NewMessage(chatID, fmt.Sprintf("<b>%s → %s</b> (+%d −%d)",
+		html.EscapeString(args[0]), html.EscapeString(args[1]), added, removed))
+	header.ParseMode = "HTML"
+	tb.bot.Send(header)
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(output)+"</pre>", "pre", 4000)
+}
+
+// startTail handles /tail <path>: it follows the file with tail -f as a
+// session, so new lines stream to the chat until /stop.
+func (tb *TelegramBridge) startTail(chatID, userID int64, username, arg string) {
+	if arg == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /tail <path>"))
+		return
+	}
+	if tb.hasActiveSession(chatID) {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ A session is already running — /stop it first")
+		tb.bot.Send(msg)
+		return
+	}
+	path, err := tailPath(tb.sessionDir(chatID), arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	command := "tail -f " + shellQuote(path)
+	if !commandPermitted(command, tb.currentConfig()) {
+		fmt.Printf("📱 @%s → [blocked] %s\n\n", username, command)
+		msg := tgbotapi.NewMessage(chatID, "🚫 command not permitted")
+		tb.bot.Send(msg)
+		return
+	}
+	tb.startManagedSession(chatID, userID, username, command, sessionOptions{noIdleTimeout: true})
+}
+
+// savePath resolves a /save argument to the file to create: ~ is expanded,
+// relative paths are taken from dir, and the file must be inside root
+// (Config.FileRoot, or home). Errors are user-facing.
+func savePath(root, dir, arg string) (string, error) {
+	path := expandPath(arg)
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(path)
+	if base == string(filepath.Separator) || base == "." {
+		return "", fmt.Errorf("invalid file name: %s", arg)
+	}
+	parent, err := resolveInRoot(root, filepath.Dir(path))
+	switch {
+	case errors.Is(err, errOutsideFileRoot):
+		return "", fmt.Errorf("%s is outside %s", arg, root)
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("directory not found: %s", filepath.Dir(arg))
+	case err != nil:
+		return "", fmt.Errorf("cannot access %s: %w", arg, err)
+	}
+	return filepath.Join(parent, base), nil
+}
+
+// writeNewFile writes content to path, refusing to overwrite an existing
+// file, and returns the number of bytes written.
+func writeNewFile(path, content string) (int, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return 0, fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return n, nil
+}
+
+// saveOutput handles /save <path>: it writes the output of the chat's
+// current or most recent session, as sent to the chat, to a new file.
+func (tb *TelegramBridge) saveOutput(chatID int64, username, arg string) {
+	if arg == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /save <path>"))
+		return
+	}
+
+	tb.mu.RLock()
+	scroll := tb.lastOutput[chatID]
+	if session, ok := tb.sessions[chatID]; ok && session.Active && session.scroll != nil {
+		scroll = session.scroll
+	}
+	root := tb.config.fileRoot()
+	tb.mu.RUnlock()
+
+	var lines []string
+	if scroll != nil {
+		lines = scroll.lines()
+	}
+	if len(lines) == 0 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "⚠️ No session output to save"))
+		return
+	}
+
+	path, err := savePath(root, tb.sessionDir(chatID), arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	fmt.Printf("📱 @%s → [save] %s\n\n", username, path)
+	n, err := writeNewFile(path, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		log.Printf("❌ /save failed: %v\n", err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("💾 Saved %d bytes to %s", n, path)))
+}
+
+// sendSystemInfo runs a /ps or /free shortcut and replies with its output
+// as a monospace block.
+func (tb *TelegramBridge) sendSystemInfo(chatID int64, username, command string) {
+	fmt.Printf("📱 @%s → [system info] %s\n\n", username, command)
+
+	stopTyping := keepTyping(tb.bot, chatID, typingInterval)
+	output, err := runFirstAvailable(systemInfoCommands[command])
+	stopTyping()
+	if err != nil {
+		log.Printf("❌ %s failed: %v\n", command, err)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %s is not available on this system", command))
+		tb.bot.Send(msg)
+		return
+	}
+
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(output)+"</pre>", "pre", 4000)
+}
+
+// maxTelegramFileSize is the Bot API upload limit for documents.
+const maxTelegramFileSize = 50 * 1024 * 1024
+
+// expandPath expands a leading ~ to the user's home directory and cleans the path.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// checkSendableFile verifies a path names a regular file small enough for
+// Telegram. Errors are user-facing.
+func checkSendableFile(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		return nil, fmt.Errorf("cannot access %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory — /get only sends single files", path)
+	}
+	if info.Size() > maxTelegramFileSize {
+		return nil, fmt.Errorf("%s is %d MB — Telegram's limit is 50 MB", path, info.Size()/(1024*1024))
+	}
+	return info, nil
+}
+
+// sendFile uploads a file from the server to the chat as a document.
+func (tb *TelegramBridge) sendFile(chatID int64, username, path string) {
+	if path == "" {
+		msg := tgbotapi.NewMessage(chatID, "Usage: /get <path>")
+		tb.bot.Send(msg)
+		return
+	}
+
+	path = expandPath(path)
+	fmt.Printf("📱 @%s → [get] %s\n\n", username, path)
+
+	if _, err := checkSendableFile(path); err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+		tb.bot.Send(msg)
+		return
+	}
+
+	upload := tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument)
+	tb.bot.Send(upload)
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(path))
+	if _, err := tb.bot.Send(doc); err != nil {
+		log.Printf("❌ Failed to send file %s: %v\n", path, err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Failed to send file: "+err.Error())
+		tb.bot.Send(msg)
+	}
+}
+
+// maxZipInputSize and maxZipFiles bound the trees /zip will archive, so a
+// stray /zip ~ doesn't spend minutes compressing gigabytes.
+const (
+	maxZipInputSize = 200 * 1024 * 1024
+	maxZipFiles     = 10000
+)
+
+// zipDirectory writes a zip archive of the regular files under dir to w,
+// with paths relative to dir. The tree is measured first and refused if it
+// exceeds maxBytes or maxFiles; files are then streamed into the archive
+// one at a time. Symlinks and special files are skipped. Errors are
+// user-facing. Returns the number of files archived.
+func zipDirectory(dir string, w io.Writer, maxBytes int64, maxFiles int) (int, error) {
+	var files []string
+	var total int64
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		files = append(files, p)
+		if len(files) > maxFiles {
+			return fmt.Errorf("%s has more than %d files — too many to zip", dir, maxFiles)
+		}
+		if total > maxBytes {
+			return fmt.Errorf("%s is over %d MB — too large to zip", dir, maxBytes/(1024*1024))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, p := range files {
+		if err := addZipFile(zw, dir, p); err != nil {
+			return 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return len(files), nil
+}
+
+// addZipFile streams one file into the archive under its path relative to dir.
+func addZipFile(zw *zip.Writer, dir, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", p, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", p, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(rel)
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := io.Copy(entry, f); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", p, err)
+	}
+	return nil
+}
+
+// sendDirectory zips a directory into a temp file and uploads it to the
+// chat as a document, removing the temp file afterwards.
+func (tb *TelegramBridge) sendDirectory(chatID int64, username, dir string) {
+	reply := func(text string) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, text))
+	}
+	if dir == "" {
+		reply("Usage: /zip <dir>")
+		return
+	}
+
+	dir = expandPath(dir)
+	fmt.Printf("📱 @%s → [zip] %s\n\n", username, dir)
+
+	if info, err := os.Stat(dir); err != nil {
+		reply("❌ cannot access " + dir)
+		return
+	} else if !info.IsDir() {
+		reply(fmt.Sprintf("❌ %s is not a directory — use /get for single files", dir))
+		return
+	}
+
+	tmp, err := os.CreateTemp("", "remote-term-*.zip")
+	if err != nil {
+		log.Printf("❌ Failed to create zip: %v\n", err)
+		reply("❌ Failed to create archive")
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	upload := tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadDocument)
+	tb.bot.Send(upload)
+
+	count, err := zipDirectory(dir, tmp, maxZipInputSize, maxZipFiles)
+	if err != nil {
+		reply("❌ " + err.Error())
+		return
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		reply("❌ Failed to create archive")
+		return
+	}
+	if size > maxTelegramFileSize {
+		reply(fmt.Sprintf("❌ Archive is %d MB — Telegram's limit is 50 MB", size/(1024*1024)))
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		reply("❌ Failed to create archive")
+		return
+	}
+
+	name := filepath.Base(dir) + ".zip"
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: name, Reader: tmp})
+	doc.Caption = fmt.Sprintf("📦 %s — %d files, %d KB compressed", name, count, (size+1023)/1024)
+	if _, err := tb.bot.Send(doc); err != nil {
+		log.Printf("❌ Failed to send archive %s: %v\n", name, err)
+		reply("❌ Failed to send archive: " + err.Error())
+	}
+}
+
+// receiveFile downloads a document sent to the bot and saves it in the
+// working directory (or the temp dir if the cwd is unavailable).
+func (tb *TelegramBridge) receiveFile(chatID int64, username string, doc *tgbotapi.Document) {
+	maxSize := tb.currentConfig().maxUploadSize()
+	fmt.Printf("📱 @%s → [upload] %s (%d bytes)\n\n", username, doc.FileName, doc.FileSize)
+
+	if int64(doc.FileSize) > maxSize {
+		msg := tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("❌ File too large (%d bytes, limit %d bytes)", doc.FileSize, maxSize))
+		tb.bot.Send(msg)
+		return
+	}
+
+	url, err := tb.bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		log.Printf("❌ Failed to get file URL: %v\n", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Failed to download file from Telegram")
+		tb.bot.Send(msg)
+		return
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Printf("❌ Failed to download file: %v\n", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Failed to download file from Telegram")
+		tb.bot.Send(msg)
+		return
+	}
+	defer resp.Body.Close()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	name := doc.FileName
+	if name == "" {
+		name = doc.FileUniqueID
+	}
+
+	path, size, err := saveUpload(dir, name, resp.Body, maxSize)
+	if err != nil {
+		log.Printf("❌ Failed to save upload: %v\n", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+		tb.bot.Send(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Saved %s (%d bytes)", path, size))
+	tb.bot.Send(msg)
+}
+
+// sendUploadLink replies with a single-use WebUI upload link, for files
+// above the Bot API size limit.
+func (tb *TelegramBridge) sendUploadLink(chatID int64, username string) {
+	link, err := issueUploadLink()
+	if err == errWebUINotRunning {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ WebUI is not running. Start it with --web to use /upload.")
+		tb.bot.Send(msg)
+		return
+	}
+	if err != nil {
+		log.Printf("Error issuing upload link: %v\n", err)
+		msg := tgbotapi.NewMessage(chatID, "❌ Error creating upload link")
+		tb.bot.Send(msg)
+		return
+	}
+
+	fmt.Printf("📱 @%s → [upload link] issued\n\n", username)
+	msg := tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("📤 Upload link (single use, expires in %d minutes):\n\n%s\n\n"+
+			"Files are saved to the WebUI's working directory.",
+			int(uploadTokenTTL.Minutes()), link))
+	tb.bot.Send(msg)
+}
+
+// saveUpload writes r into dir under the base name of name, never
+// overwriting an existing file (a numeric suffix is added instead).
+// Reads at most maxSize bytes; larger content is rejected and removed.
+func saveUpload(dir, name string, r io.Reader, maxSize int64) (string, int64, error) {
+	// Strip any directory components a client might smuggle in the file name
+	base := filepath.Base(filepath.Clean("/" + name))
+	if base == "/" || base == "." {
+		return "", 0, fmt.Errorf("invalid file name: %q", name)
+	}
+
+	path := filepath.Join(dir, base)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return "", 0, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if n > maxSize {
+		os.Remove(path)
+		return "", 0, fmt.Errorf("file too large (limit %d bytes)", maxSize)
+	}
+
+	return path, n, nil
+}
+
+// newSessionSink creates the sink that streams session output to chatID.
+func (tb *TelegramBridge) newSessionSink(chatID int64) *TelegramSink {
+	config := tb.currentConfig()
+	return &TelegramSink{
+		bot:             tb.bot,
+		chatID:          chatID,
+		preserveColors:  config != nil && config.PreserveColors,
+		attachThreshold: config.fileAttachThreshold(),
+		binaryThreshold: config.binaryThresholdPercent(),
+		chunkDelay:      config.chunkDelay(),
+		editInPlace:     true,
+	}
+}
+
+// replySink returns a sink for one-off command replies (/diff, /grep...)
+// that only need chunking, not a session's output handling.
+func (tb *TelegramBridge) replySink(chatID int64) *TelegramSink {
+	return &TelegramSink{bot: tb.bot, chatID: chatID, chunkDelay: tb.currentConfig().chunkDelay()}
+}
+
+// sessionOptions adjust how a managed session (/ssh, /tail) behaves.
+type sessionOptions struct {
+	watchSecrets  bool   // Ask the user to answer password prompts (/ssh)
+	noIdleTimeout bool   // Keep the session however long it is quiet (/tail)
+	dir           string // Start here instead of the configured directory (/reconnect)
+}
+
+// startSession starts a persistent interactive session
+func (tb *TelegramBridge) startSession(chatID, userID int64, username, command string) {
+	tb.startManagedSession(chatID, userID, username, command, sessionOptions{})
+}
+
+// startManagedSession starts a session like startSession, with opts. An
+// empty command starts a bare shell.
+func (tb *TelegramBridge) startManagedSession(chatID, userID int64, username, command string, opts sessionOptions) {
+	fmt.Printf("📱 @%s → [new session] %s\n\n", username, command)
+
+	// The requesting user's profile, if any, overrides shell, directory and env
+	tb.mu.RLock()
+	config := tb.config.forUser(userID)
+	full := sessionLimitReached(tb.sessions, tb.config.maxSessions())
+	tb.mu.RUnlock()
+	if opts.dir != "" && config != nil {
+		cfg := *config
+		cfg.WorkingDir = opts.dir
+		config = &cfg
+	}
+	if full {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ too many active sessions")
+		tb.bot.Send(msg)
+		return
+	}
+
+	// Create persistent terminal
+	sink := tb.newSessionSink(chatID)
+	env := config.withProfileEnv(userID, tb.sessionEnv(chatID))
+
+	terminal, err := NewSessionTerminal(sink, config, env, command)
+	if err != nil {
+		log.Printf("Error creating terminal: %v\n", err)
+		msg := tgbotapi.NewMessage(chatID, terminalErrorText("❌ Error creating session", err))
+		tb.bot.Send(msg)
+		return
+	}
+
+	label := command
+	if label == "" {
+		label = "shell"
+	}
+	session := &Session{
+		Terminal:      terminal,
+		Sinks:         []OutputSink{sink},
+		Active:        true,
+		Command:       label,
+		UserID:        userID,
+		StartedAt:     time.Now(),
+		done:          make(chan struct{}),
+		clearReq:      make(chan struct{}, 1),
+		screenshotReq: make(chan struct{}, 1),
+		grepReq:       make(chan grepQuery, 1),
+		resizeReq:     make(chan termSize, 1),
+		scroll:        newScrollback(scrollbackLines),
+		commands:      make(chan queuedCommand, maxQueuedCommands),
+		watchSecrets:  opts.watchSecrets,
+		noIdleTimeout: opts.noIdleTimeout,
+	}
+	tb.mu.Lock()
+	tb.sessions[chatID] = session
+	tb.saveSessionIndexLocked()
+	tb.mu.Unlock()
+
+	// Confirm right away — slow-starting tools may not print for a while.
+	// The Stop button gives a one-tap way to end the session on mobile.
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🟢 Session started: %s (PID %d)", label, terminal.PID()))
+	msg.ReplyMarkup = stopButtonMarkup()
+	if sent, err := tb.bot.Send(msg); err == nil {
+		tb.mu.Lock()
+		session.stopMessageID = sent.MessageID
+		tb.mu.Unlock()
+	}
+
+	// Show "typing..." while session starts up
+	typing := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
+	tb.bot.Send(typing)
+
+	// Send initial command
+	if command != "" {
+		tb.auditCommand(session, chatID, username, command)
+		terminal.Launch(command)
+	}
+
+	// Stream output in background, and write later commands one at a time
+	go tb.streamSessionOutput(chatID)
+	go session.runCommandQueue(sendDelay, maxCommandSettle, func(cmd queuedCommand) {
+		tb.auditCommand(session, chatID, cmd.username, cmd.text)
+		session.startNewMessages()
+		session.Terminal.SendCommand(cmd.text)
+	})
+}
+
+// reconnectSession handles /reconnect: when the chat's shell has died, it
+// starts a new one in the directory the old one was last in. /env
+// overrides apply as for any new session.
+func (tb *TelegramBridge) reconnectSession(chatID, userID int64, username string) {
+	if tb.hasActiveSession(chatID) {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ The session is still running — /restart replaces it")
+		tb.bot.Send(msg)
+		return
+	}
+	tb.mu.RLock()
+	dir := tb.lastDirs[chatID]
+	tb.mu.RUnlock()
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = ""
+	}
+
+	fmt.Printf("📱 @%s → [reconnect] %s\n\n", username, dir)
+	reply := "🔄 Shell restarted"
+	if dir != "" {
+		reply += " in " + dir
+	}
+	msg := tgbotapi.NewMessage(chatID, reply)
+	tb.bot.Send(msg)
+	tb.startManagedSession(chatID, userID, username, "", sessionOptions{dir: dir})
+}
+
+// hasActiveSession reports whether the chat has a running session.
+func (tb *TelegramBridge) hasActiveSession(chatID int64) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	session, ok := tb.sessions[chatID]
+	return ok && session.Active
+}
+
+// stopSession ends the active session
+func (tb *TelegramBridge) stopSession(chatID int64, username string) {
+	tb.mu.Lock()
+	session, exists := tb.sessions[chatID]
+	if !exists || !session.Active {
+		tb.mu.Unlock()
+		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
+		tb.bot.Send(msg)
+		return
+	}
+	session.Active = false
+	delete(tb.sessions, chatID)
+	tb.saveSessionIndexLocked()
+	tb.mu.Unlock()
+
+	fmt.Printf("📱 @%s → [stop session]\n\n", username)
+	session.safeCloseDone() // Signal goroutine to stop
+	session.Terminal.Close()
+
+	msg := tgbotapi.NewMessage(chatID, "✅ Session ended")
+	tb.bot.Send(msg)
+}
+
+// interruptSession sends Ctrl-C to the session's PTY without closing it,
+// so a runaway command can be stopped while keeping the shell or REPL.
+func (tb *TelegramBridge) interruptSession(chatID int64, username string) {
+	tb.mu.RLock()
+	session, exists := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
+	if !exists || !session.Active {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
+		tb.bot.Send(msg)
+		return
+	}
+
+	fmt.Printf("📱 @%s → [interrupt session]\n\n", username)
+	session.Terminal.SendRawInput("\x03")
+
+	msg := tgbotapi.NewMessage(chatID, "🛑 Sent Ctrl-C (session still active)")
+	tb.bot.Send(msg)
+}
+
+// stopSessionCallback is the callback data of the inline Stop button.
+const stopSessionCallback = "stop_session"
+
+// stopButtonMarkup returns the inline keyboard attached to "Session started".
+func stopButtonMarkup() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏹ Stop", stopSessionCallback),
+		),
+	)
+}
+
+// stopButtonIsCurrent reports whether messageID carries the Stop button of
+// the chat's active session, so a stale button from an earlier session
+// can't end a newer one.
+func (tb *TelegramBridge) stopButtonIsCurrent(chatID int64, messageID int) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	session, exists := tb.sessions[chatID]
+	return exists && session.Active && session.stopMessageID == messageID
+}
+
+// handleCallback handles inline keyboard presses. Every callback is
+// answered so the button's loading spinner clears.
+func (tb *TelegramBridge) handleCallback(query *tgbotapi.CallbackQuery) {
+	if !tb.isAllowed(query.From.ID) {
+		log.Printf("⚠️  Unauthorized callback: @%s (ID: %d)\n", query.From.UserName, query.From.ID)
+		tb.bot.Request(tgbotapi.NewCallback(query.ID, "❌ Unauthorized"))
+		return
+	}
+	if query.Message != nil && (query.Data == pagePrevCallback || query.Data == pageNextCallback) {
+		tb.turnPage(query)
+		return
+	}
+	if query.Message == nil || query.Data != stopSessionCallback {
+		tb.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	if tb.stopButtonIsCurrent(chatID, query.Message.MessageID) {
+		tb.bot.Request(tgbotapi.NewCallback(query.ID, "Stopping session"))
+		tb.stopSession(chatID, query.From.UserName)
+	} else {
+		tb.bot.Request(tgbotapi.NewCallback(query.ID, "Session already ended"))
+	}
+
+	// Remove the button so it can't be pressed again
+	noButtons := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	tb.bot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, noButtons))
+}
+
+// clearSession resets the session's output tracking, so the next flush
+// sends the whole current screen instead of only lines not yet seen. This
+// recovers long sessions whose dedup state has drifted from the screen.
+func (tb *TelegramBridge) clearSession(chatID int64, username string) {
+	tb.mu.RLock()
+	session, exists := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
+	if !exists || !session.Active || !session.requestClear() {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
+		tb.bot.Send(msg)
+		return
+	}
+
+	fmt.Printf("📱 @%s → [clear session]\n\n", username)
+	msg := tgbotapi.NewMessage(chatID,
+		"🧹 Output tracking reset. The current screen will be re-sent in full; "+
+			"lines already sent may appear again.")
+	tb.bot.Send(msg)
+}
+
+// Bounds for /resize, wide enough for a phone in portrait and a large
+// monitor.
+const (
+	minResizeRows = 5
+	maxResizeRows = 300
+	minResizeCols = 20
+	maxResizeCols = 500
+)
+
+// parseResize parses the /resize arguments "<rows> <cols>". Errors are
+// user-facing.
+func parseResize(arg string) (termSize, error) {
+	fields := strings.Fields(arg)
+	if len(fields) != 2 {
+		return termSize{}, fmt.Errorf("usage: /resize <rows> <cols>")
+	}
+	rows, err := strconv.Atoi(fields[0])
+	if err != nil || rows < minResizeRows || rows > maxResizeRows {
+		return termSize{}, fmt.Errorf("rows must be a number from %d to %d", minResizeRows, maxResizeRows)
+	}
+	cols, err := strconv.Atoi(fields[1])
+	if err != nil || cols < minResizeCols || cols > maxResizeCols {
+		return termSize{}, fmt.Errorf("columns must be a number from %d to %d", minResizeCols, maxResizeCols)
+	}
+	return termSize{rows: rows, cols: cols}, nil
+}
+
+// resizeSession handles /resize <rows> <cols>: the PTY gets the new size,
+// so TUI programs redraw for it, and so does the streaming goroutine's
+// virtual screen, which the chat's output is read from.
+func (tb *TelegramBridge) resizeSession(chatID int64, username, arg string) {
+	size, err := parseResize(arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	tb.mu.RLock()
+	session, exists := tb.sessions[chatID]
+	tb.mu.RUnlock()
+	if !exists || !session.Active || !session.requestResize(size) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "⚠️ No active session"))
+		return
+	}
+	fmt.Printf("📱 @%s → [resize] %dx%d\n\n", username, size.rows, size.cols)
+	if err := session.Terminal.Resize(size.rows, size.cols); err != nil {
+		log.Printf("Error resizing terminal for chat %d: %v\n", chatID, err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to resize the terminal: "+err.Error()))
+		return
+	}
+	tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📐 Terminal resized to %d rows × %d columns", size.rows, size.cols)))
+}
+
+// attachCandidates returns the active sessions, other than chatID's own,
+// whose output userID may subscribe to: ones the user started, or any for
+// the owner. Sorted by chat ID.
+func (tb *TelegramBridge) attachCandidates(chatID, userID int64) []int64 {
+	owner := tb.isOwner(userID)
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	var ids []int64
+	for id, session := range tb.sessions {
+		if id != chatID && session.Active && (session.UserID == userID || owner) {
+			ids = append(ids, id)
 		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
 
-		userID := update.Message.From.ID
-		username := update.Message.From.UserName
-		text := update.Message.Text
-		chatID := update.Message.Chat.ID
+// attachSession subscribes chatID to the output of another chat's session,
+// e.g. to follow a DM session from a group. arg is the session's chat ID,
+// optional when the user has exactly one other session. Input from this
+// chat still goes to its own session.
+func (tb *TelegramBridge) attachSession(chatID, userID int64, username, arg string) {
+	candidates := tb.attachCandidates(chatID, userID)
 
-		// Check whitelist
-		allowed := false
-		for _, allowedID := range tb.config.AllowedUsers {
-			if userID == allowedID {
-				allowed = true
-				break
-			}
+	var target int64
+	if arg == "" {
+		if len(candidates) != 1 {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, formatAttachUsage(candidates)))
+			return
 		}
-
-		if !allowed {
-			log.Printf("⚠️  Unauthorized: @%s (ID: %d)\n", username, userID)
-			msg := tgbotapi.NewMessage(chatID, "❌ Unauthorized")
-			tb.bot.Send(msg)
-			continue
+		target = candidates[0]
+	} else {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || !slices.Contains(candidates, id) {
+			tb.bot.Send(tgbotapi.NewMessage(chatID, formatAttachUsage(candidates)))
+			return
 		}
+		target = id
+	}
 
-		// Handle /start
-		if text == "/start" {
-			msg := tgbotapi.NewMessage(chatID,
-				"✅ Connected!\n\n"+
-					"Just send commands — a persistent shell session\n"+
-					"starts automatically. cd, env vars, etc. persist.\n\n"+
-					"• /exit or /stop → end session\n"+
-					"• /status → show session info")
-			tb.bot.Send(msg)
-			continue
-		}
+	tb.mu.RLock()
+	session, exists := tb.sessions[target]
+	tb.mu.RUnlock()
+	if !exists || !session.Active {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ No active session %d", target)))
+		return
+	}
+	if _, ok := session.telegramSinkFor(chatID); ok {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("ℹ️ Already attached to session %d", target)))
+		return
+	}
 
-		// Handle exit/stop - end session
-		if text == "/exit" || text == "/stop" {
-			tb.stopSession(chatID, username)
-			continue
-		}
+	session.addSink(tb.newSessionSink(chatID))
+	fmt.Printf("📱 @%s → [attach] chat %d to session %d\n\n", username, chatID, target)
+	tb.bot.Send(tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("🔗 Attached to session %d (%s). New output will appear here; /detach to stop.", target, session.Command)))
+}
 
-		// Handle status
-		if text == "/status" {
-			tb.showStatus(chatID)
-			continue
-		}
+// formatAttachUsage explains /attach, listing the sessions it can target.
+func formatAttachUsage(candidates []int64) string {
+	if len(candidates) == 0 {
+		return "⚠️ No other session to attach to. Start one in another chat first."
+	}
+	ids := make([]string, len(candidates))
+	for i, id := range candidates {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return "Usage: /attach <session id>\nYour sessions: " + strings.Join(ids, ", ")
+}
 
-		// Handle restart - stop current session, next command starts fresh
-		if text == "/restart" {
-			tb.mu.RLock()
-			_, hasSession := tb.sessions[chatID]
-			tb.mu.RUnlock()
-			if hasSession {
-				tb.stopSession(chatID, username)
-			}
-			msg := tgbotapi.NewMessage(chatID, "🔄 Session restarted. Send any command to begin.")
-			tb.bot.Send(msg)
-			continue
+// detachSession unsubscribes chatID from every session it attached to.
+func (tb *TelegramBridge) detachSession(chatID int64, username string) {
+	tb.mu.RLock()
+	var detached []int64
+	for id, session := range tb.sessions {
+		if id == chatID {
+			continue // A chat's own session isn't an attachment
 		}
-
-		// Handle help
-		if text == "/help" {
-			msg := tgbotapi.NewMessage(chatID,
-				"📖 Commands:\n\n"+
-					"/stop — End current session\n"+
-					"/restart — Restart shell (fresh cwd)\n"+
-					"/status — Show session info\n"+
-					"/help — This message\n\n"+
-					"All commands run in a persistent shell.\n"+
-					"cd, env vars, etc. persist across messages.")
-			tb.bot.Send(msg)
-			continue
+		if sink, ok := session.telegramSinkFor(chatID); ok && session.removeSink(sink) {
+			detached = append(detached, id)
 		}
+	}
+	tb.mu.RUnlock()
 
-		// Handle all other commands
-		tb.handleCommand(chatID, username, text)
+	if len(detached) == 0 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Not attached to any session"))
+		return
 	}
+	fmt.Printf("📱 @%s → [detach] chat %d\n\n", username, chatID)
+	tb.bot.Send(tgbotapi.NewMessage(chatID, "🔌 Detached"))
 }
 
-// isInteractiveCommand checks if a command needs a persistent session
-func isInteractiveCommand(cmd string) bool {
-	// Get first word of command
-	parts := strings.Fields(cmd)
-	if len(parts) == 0 {
-		return false
-	}
-	
-	firstWord := parts[0]
-	
-	// Interactive REPLs and tools
-	interactive := []string{
-		"claude", "claude-code", "aider", // AI assistants
-		"python", "python3", "ipython",
-		"node", "deno", "bun",
-		"irb", "ruby",
-		"ghci", "stack",
-		"lua",
-		"psql", "mysql", "redis-cli",
-		"vim", "nvim", "emacs", "nano",
-		"less", "more",
-		"top", "htop", "btop",
-		"watch",
-		"ssh", "telnet",
-	}
-	
-	for _, cmd := range interactive {
-		if firstWord == cmd {
-			return true
+// notifyAttached tells the chats attached to chatID's session that it ended.
+func (tb *TelegramBridge) notifyAttached(chatID int64, session *Session) {
+	for _, sink := range session.sinkList() {
+		if ts, ok := sink.(*TelegramSink); ok && ts.chatID != chatID {
+			tb.bot.Send(tgbotapi.NewMessage(ts.chatID, fmt.Sprintf("🔌 Session %d ended", chatID)))
 		}
 	}
-	
-	return false
 }
 
-// handleCommand routes all commands to a persistent session.
-// If no session exists, one is auto-started so that state (cwd, env vars)
-// persists across commands.
-func (tb *TelegramBridge) handleCommand(chatID int64, username, text string) {
-	// Check if session exists
+// screenshotSession asks the session's streaming goroutine to send the
+// current screen as an image.
+func (tb *TelegramBridge) screenshotSession(chatID int64, username string) {
 	tb.mu.RLock()
-	session, hasSession := tb.sessions[chatID]
+	session, exists := tb.sessions[chatID]
 	tb.mu.RUnlock()
 
-	// If session exists and active, send to it
-	if hasSession && session.Active {
-		fmt.Printf("📱 @%s → [session] %s\n\n", username, text)
-		// Show "typing..." while waiting for response
-		typing := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
-		tb.bot.Send(typing)
-		session.Terminal.SendCommand(text)
+	if !exists || !session.Active || !session.requestScreenshot() {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
+		tb.bot.Send(msg)
 		return
 	}
-
-	// No session — auto-start persistent shell session
-	tb.startSession(chatID, username, text)
+	fmt.Printf("📱 @%s → [screenshot]\n\n", username)
 }
 
-// startSession starts a persistent interactive session
-func (tb *TelegramBridge) startSession(chatID int64, username, command string) {
-	fmt.Printf("📱 @%s → [new session] %s\n\n", username, command)
-
-	// Create persistent terminal
-	sink := &TelegramSink{
-		bot:    tb.bot,
-		chatID: chatID,
+// grepSession asks the session's streaming goroutine to search its
+// scrollback for /grep.
+func (tb *TelegramBridge) grepSession(chatID int64, username, arg string) {
+	query, err := parseGrepArgs(arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
 	}
 
-	terminal, err := NewTerminal(sink)
-	if err != nil {
-		log.Printf("Error creating terminal: %v\n", err)
-		msg := tgbotapi.NewMessage(chatID, "❌ Error creating session")
+	tb.mu.RLock()
+	session, exists := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
+	if !exists || !session.Active {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
 		tb.bot.Send(msg)
 		return
 	}
-
-	session := &Session{
-		Terminal:  terminal,
-		Sink:      sink,
-		Active:    true,
-		Command:   command,
-		StartedAt: time.Now(),
-		done:      make(chan struct{}),
+	if !session.requestGrep(query) {
+		msg := tgbotapi.NewMessage(chatID, "⚠️ A search is already running")
+		tb.bot.Send(msg)
+		return
 	}
-	tb.mu.Lock()
-	tb.sessions[chatID] = session
-	tb.mu.Unlock()
-
-	// Show "typing..." while session starts up
-	typing := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
-	tb.bot.Send(typing)
+	fmt.Printf("📱 @%s → [grep] %s\n\n", username, query.pattern)
+}
 
-	// Send initial command
-	terminal.SendCommand(command)
+// sendGrepResults replies to /grep with the matching lines as a
+// monospace block.
+func (tb *TelegramBridge) sendGrepResults(chatID int64, query grepQuery, matches []string, total int) {
+	if total == 0 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔍 No matches for %s", query.pattern)))
+		return
+	}
+	summary := fmt.Sprintf("🔍 %d matches for <b>%s</b>", total, html.EscapeString(query.pattern))
+	if total > len(matches) {
+		summary += fmt.Sprintf(" (last %d shown)", len(matches))
+	}
+	header := tgbotapi.NewMessage(chatID, summary)
+	header.ParseMode = "HTML"
+	tb.bot.Send(header)
+	sink := tb.replySink(chatID)
+	sink.sendHTML("<pre>"+html.EscapeString(strings.Join(matches, "\n"))+"</pre>", "pre", 4000)
+}
 
-	// Stream output in background
-	go tb.streamSessionOutput(chatID)
+// sendScreenshot renders screen as a PNG and sends it as a photo.
+func (tb *TelegramBridge) sendScreenshot(chatID int64, screen *ScreenReader) {
+	img, err := screen.Image()
+	var buf bytes.Buffer
+	if err == nil {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		log.Printf("Error rendering screenshot for chat %d: %v\n", chatID, err)
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Could not render the screen"))
+		return
+	}
 
-	// Don't send "session started" message - just let output flow
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "screen.png", Bytes: buf.Bytes()})
+	if _, err := tb.bot.Send(photo); err != nil {
+		log.Printf("Error sending screenshot for chat %d: %v\n", chatID, err)
+	}
 }
 
-// stopSession ends the active session
-func (tb *TelegramBridge) stopSession(chatID int64, username string) {
-	tb.mu.Lock()
+// muteSession toggles whether the session streams intermediate output.
+// A muted session still sends everything, but only after output settles,
+// so long responses arrive as one final answer instead of many updates.
+func (tb *TelegramBridge) muteSession(chatID int64, username string, muted bool) {
+	tb.mu.RLock()
 	session, exists := tb.sessions[chatID]
+	tb.mu.RUnlock()
+
 	if !exists || !session.Active {
-		tb.mu.Unlock()
 		msg := tgbotapi.NewMessage(chatID, "⚠️ No active session")
 		tb.bot.Send(msg)
 		return
 	}
-	session.Active = false
-	delete(tb.sessions, chatID)
-	tb.mu.Unlock()
-
-	fmt.Printf("📱 @%s → [stop session]\n\n", username)
-	session.safeCloseDone() // Signal goroutine to stop
-	session.Terminal.Close()
 
-	msg := tgbotapi.NewMessage(chatID, "✅ Session ended")
-	tb.bot.Send(msg)
+	session.setMuted(muted)
+	reply := "🔊 Unmuted: output is sent while the program runs"
+	if muted {
+		reply = "🔇 Muted: output is sent only once the program goes quiet. /unmute to stream again"
+	}
+	fmt.Printf("📱 @%s → [mute %t]\n\n", username, muted)
+	tb.bot.Send(tgbotapi.NewMessage(chatID, reply))
 }
 
 // showStatus shows current session info
 func (tb *TelegramBridge) showStatus(chatID int64) {
 	tb.mu.RLock()
 	session, exists := tb.sessions[chatID]
+	loc := tb.location
 	tb.mu.RUnlock()
 
 	if !exists || !session.Active {
@@ -442,16 +3442,99 @@ func (tb *TelegramBridge) showStatus(chatID int64) {
 	duration := time.Since(session.StartedAt).Round(time.Second)
 	status := fmt.Sprintf("📊 Active Session\n\n"+
 		"Command: %s\n"+
+		"PID: %d\n"+
 		"Duration: %s\n"+
 		"Started: %s",
 		session.Command,
+		session.Terminal.PID(),
 		duration,
-		session.StartedAt.Format("15:04:05"))
+		session.StartedAt.In(loc).Format("15:04:05 MST"))
 
 	msg := tgbotapi.NewMessage(chatID, status)
 	tb.bot.Send(msg)
 }
 
+// listSessions returns active sessions ordered by chat ID, for the daemon
+// control socket.
+func (tb *TelegramBridge) listSessions() []sessionInfo {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	list := make([]sessionInfo, 0, len(tb.sessions))
+	for chatID, session := range tb.sessions {
+		if !session.Active {
+			continue
+		}
+		list = append(list, sessionInfo{
+			ID:        chatID,
+			Command:   session.Command,
+			PID:       session.Terminal.PID(),
+			StartedAt: session.StartedAt,
+			Duration:  time.Since(session.StartedAt).Round(time.Second).String(),
+			Attached:  session.attached(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// formatSessionList renders sessions as an aligned table for --list-sessions.
+func formatSessionList(sessions []sessionInfo) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAT ID\tPID\tDURATION\tCOMMAND")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", s.ID, s.PID, s.Duration, s.Command)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// formatAllSessions renders every session the bridge holds, across chats
+// and including ones that have ended but not yet been removed, as an
+// aligned table ordered by chat ID.
+func (tb *TelegramBridge) formatAllSessions(now time.Time) string {
+	tb.mu.RLock()
+	chatIDs := make([]int64, 0, len(tb.sessions))
+	for chatID := range tb.sessions {
+		chatIDs = append(chatIDs, chatID)
+	}
+	slices.Sort(chatIDs)
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAT ID\tSTATE\tDURATION\tCOMMAND")
+	for _, chatID := range chatIDs {
+		session := tb.sessions[chatID]
+		state := "active"
+		if !session.Active {
+			state = "ended"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", chatID, state, now.Sub(session.StartedAt).Round(time.Second), session.Command)
+	}
+	tb.mu.RUnlock()
+	w.Flush()
+	return b.String()
+}
+
+// showAllSessions handles /sessions: the owner's view of every chat's
+// session, to spot stuck ones. /status shows only the current chat's.
+func (tb *TelegramBridge) showAllSessions(chatID, userID int64) {
+	if !tb.isOwner(userID) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Only the owner can list every session"))
+		return
+	}
+	tb.mu.RLock()
+	empty := len(tb.sessions) == 0
+	tb.mu.RUnlock()
+	if empty {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "No sessions"))
+		return
+	}
+	table := tb.formatAllSessions(time.Now())
+	tb.replySink(chatID).sendHTML("<pre>"+html.EscapeString(strings.TrimRight(table, "\n"))+"</pre>", "pre", maxMessageLen)
+}
+
 func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 	tb.mu.RLock()
 	session, exists := tb.sessions[chatID]
@@ -464,6 +3547,10 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 
 	log.Printf("Session streaming started for chat %d\n", chatID)
 
+	config := tb.currentConfig()
+	record := startTranscript(config, chatID)
+	defer record.close()
+
 	defer func() {
 		log.Printf("Session streaming ended for chat %d\n", chatID)
 		// Cleanup on exit
@@ -471,15 +3558,29 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 		if session.Active {
 			session.Active = false
 			delete(tb.sessions, chatID)
+			tb.saveSessionIndexLocked()
+		}
+		// /save still works once the session is gone
+		tb.lastOutput[chatID] = session.scroll
+		// /reconnect picks up where the shell left off
+		if dir := processDir(session.Terminal.PID()); dir != "" {
+			session.lastDir = dir
+		}
+		if session.lastDir != "" {
+			tb.lastDirs[chatID] = session.lastDir
 		}
 		tb.mu.Unlock()
 		// Close terminal WITHOUT holding the lock (blocking operation)
 		session.Terminal.Close()
+		// A command that never printed anything is still audited
+		tb.flushAudit(session, "")
+		tb.notifyAttached(chatID, session)
 	}()
 
 	// Virtual terminal emulator — interprets ANSI cursor positioning
 	// so TUI apps like Claude Code render correctly as text
-	screen := NewScreenReader(120, 50)
+	rows, cols := config.terminalSize()
+	screen := NewScreenReader(cols, rows)
 
 	ticker := time.NewTicker(200 * time.Millisecond) // Check every 200ms
 	defer ticker.Stop()
@@ -487,13 +3588,10 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 	hasNewData := false
 	lastOutput := time.Now()
 	lastSend := time.Now()
-	lastTyping := time.Now()                           // Track last "typing..." action sent
-	lastCleanedScreen := ""                            // Track cleaned content already sent
-	sentLines := make(map[string]bool)                 // Track all lines ever sent (dedup fallback)
-	maxIdleTime := 30 * time.Minute                   // Auto-stop after 30 min idle
-	sendDelay := 1500 * time.Millisecond              // Wait 1.5s after last output before sending
-	maxSendInterval := 5 * time.Second                // Force send every 5s during continuous streaming
-	typingInterval := 4 * time.Second                  // Refresh typing indicator every 4s (expires at 5s)
+	typing := &typingIndicator{bot: tb.bot, chatID: chatID, last: time.Now()}
+	dedup := newScreenDeduper()        // Track content already sent
+	scroll := session.scroll           // Cleaned output kept for /grep and /save
+	maxSendInterval := 5 * time.Second // Force send every 5s during continuous streaming
 
 	// flushNewContent cleans the current screen and sends only new content
 	flushNewContent := func() {
@@ -502,45 +3600,32 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 		if cleaned == "" {
 			return
 		}
-		newContent := findNewContent(lastCleanedScreen, cleaned)
+		newContent := dedup.next(cleaned)
 		if newContent == "" {
 			return
 		}
-
-		// If suffix matching failed (returned entire screen), apply line-level
-		// dedup against previously sent content. This handles TUI full-redraws
-		// where old content is collapsed/summarized by Claude Code.
-		if newContent == cleaned && lastCleanedScreen != "" {
-			log.Printf("[DEDUP] suffix match failed, applying line dedup (tracked=%d lines)", len(sentLines))
-			lines := strings.Split(newContent, "\n")
-			var unsent []string
-			for _, line := range lines {
-				key := strings.TrimSpace(line)
-				if key == "" {
-					continue
-				}
-				if !sentLines[key] {
-					unsent = append(unsent, line)
-				}
-			}
-			if len(unsent) > 0 {
-				newContent = strings.TrimSpace(strings.Join(unsent, "\n"))
-			} else {
-				newContent = ""
-			}
+		scroll.add(newContent)
+		tb.flushAudit(session, newContent)
+		// The shell can't be asked once it has died, so remember it now
+		if dir := processDir(session.Terminal.PID()); dir != "" {
+			session.lastDir = dir
 		}
 
-		if newContent != "" {
-			// Track sent lines for future dedup
-			for _, line := range strings.Split(newContent, "\n") {
-				key := strings.TrimSpace(line)
-				if key != "" {
-					sentLines[key] = true
+		// Drop output past the per-command cap (reset by SendCommand)
+		// Every subscribed chat gets the same content (see /attach)
+		if capped := session.Terminal.limiter.take(newContent); capped != "" {
+			colored := ""
+			for _, sink := range session.sinkList() {
+				if ts, ok := sink.(*TelegramSink); ok && ts.preserveColors {
+					if colored == "" {
+						colored = colorizeContent(capped, rawScreen, screen.ScreenColored())
+					}
+					ts.SendColoredOutput(capped, colored)
+				} else {
+					sink.SendOutput(capped)
 				}
 			}
-			session.Sink.SendOutput(newContent)
 		}
-		lastCleanedScreen = cleaned
 	}
 
 	for {
@@ -553,6 +3638,34 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 			}
 			return
 
+		case <-session.clearReq:
+			// /clear: forget what was sent and re-send the current screen
+			screen.Reset()
+			dedup.reset()
+			session.startNewMessages()
+			flushNewContent()
+			hasNewData = false
+			lastSend = time.Now()
+
+		case <-session.screenshotReq:
+			tb.sendScreenshot(chatID, screen)
+
+		case size := <-session.resizeReq:
+			// /resize: send what was drawn at the old size first
+			if hasNewData {
+				flushNewContent()
+				hasNewData = false
+				lastSend = time.Now()
+			}
+			screen.Resize(size.cols, size.rows)
+
+		case query := <-session.grepReq:
+			// Output not flushed yet is still on screen
+			lines := scroll.lines()
+			lines = append(lines, unseenLines(lines, cleanTUIChrome(screen.Screen()))...)
+			matches, total := grepLines(lines, query.re, query.limit)
+			tb.sendGrepResults(chatID, query, matches, total)
+
 		case output, ok := <-session.Terminal.outputChan:
 			if !ok {
 				// Channel closed, terminal died (command exited)
@@ -564,35 +3677,71 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 				tb.bot.Send(msg)
 				return
 			}
+			output = session.hideSecretEcho(output)
+			record.write(output)
+			session.markOutput()
+			if session.noteSecretPrompt(output) {
+				session.startNewMessages()
+				tb.promptForSecret(chatID)
+			} else if session.noteSecretHint(output) {
+				session.startNewMessages()
+				tb.bot.Send(tgbotapi.NewMessage(chatID, secretHint))
+			}
+			// A clear-screen starts a fresh page: send what was drawn before
+			// it, then forget what was sent so the new page isn't diffed
+			// against (or deduped by) content that is no longer on screen
+			if before, after, cleared := splitAtClearScreen(output); cleared {
+				screen.Write([]byte(before))
+				if hasNewData || before != "" {
+					flushNewContent()
+				}
+				dedup.reset()
+				output = after
+			}
+
 			// Feed raw output into virtual terminal
 			screen.Write([]byte(output))
 			hasNewData = true
+
+			// A TUI exited and restored the main screen: forget the lines it
+			// drew, so they aren't suppressed if printed again, but count
+			// the restored screen as sent since the chat saw it before
+			if restored, left := screen.LeftAltScreen(); left {
+				dedup.resetTo(cleanTUIChrome(restored))
+			}
 			lastOutput = time.Now()
 
 		case <-ticker.C:
 			// Keep "typing..." indicator alive while accumulating output
-			if hasNewData && time.Since(lastTyping) > typingInterval {
-				typing := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
-				tb.bot.Send(typing)
-				lastTyping = time.Now()
+			if hasNewData {
+				typing.refresh()
 			}
 
 			// Send new content when output settles OR on a regular interval.
 			// - sendDelay: send after 1.5s of silence (quick for short responses)
 			// - maxSendInterval: force send every 5s during continuous streaming
-			//   (so the user sees partial progress for long responses)
+			//   (so the user sees partial progress for long responses;
+			//   skipped while muted, so only the settled result is sent)
 			settled := hasNewData && time.Since(lastOutput) > sendDelay
-			forceSend := hasNewData && time.Since(lastSend) > maxSendInterval
+			forceSend := hasNewData && !session.isMuted() && time.Since(lastSend) > maxSendInterval
 			if settled || forceSend {
 				flushNewContent()
 				hasNewData = false
 				lastSend = time.Now()
 			}
 
+			// Once output settles, report a truncated command with its full size
+			if time.Since(lastOutput) > sendDelay {
+				if notice := session.Terminal.limiter.notice(); notice != "" {
+					tb.bot.Send(tgbotapi.NewMessage(chatID, notice))
+				}
+			}
+
 			// Auto-timeout after long idle (no new output)
-			if time.Since(lastOutput) > maxIdleTime {
+			// (not for /tail, where a quiet log is normal)
+			if tb.idleTimeout > 0 && !session.noIdleTimeout && time.Since(lastOutput) > tb.idleTimeout {
 				log.Printf("Session idle timeout for chat %d\n", chatID)
-				msg := tgbotapi.NewMessage(chatID, "⏱️ Session timed out (30min idle)")
+				msg := tgbotapi.NewMessage(chatID, idleTimeoutMessage(tb.idleTimeout))
 				tb.bot.Send(msg)
 				return
 			}
@@ -603,6 +3752,8 @@ func (tb *TelegramBridge) streamSessionOutput(chatID int64) {
 
 // CleanupAllSessions stops all active sessions and cleans up resources
 func (tb *TelegramBridge) CleanupAllSessions() {
+	// The session index is deliberately kept, so after a restart the next
+	// process tells these chats their session ended
 	tb.mu.Lock()
 	log.Printf("Cleaning up %d active sessions...\n", len(tb.sessions))
 	// Copy sessions to local slice and clear the map while holding the lock
@@ -628,13 +3779,38 @@ func (tb *TelegramBridge) CleanupAllSessions() {
 // cleanTUIChrome removes terminal UI chrome from VTE screen output.
 // Strips separator lines, status bars, empty prompts, and duplicated
 // status text that are part of TUI layout but noise in Telegram messages.
+// Code is passed through verbatim: lines inside ``` fences, and indented
+// blocks (4+ spaces or a tab) that start after a blank line, since code
+// samples legitimately contain │, ●, and similar characters.
 func cleanTUIChrome(output string) string {
 	lines := strings.Split(output, "\n")
 	var cleaned []string
+	inFence := false    // Between ``` lines
+	inIndented := false // Within an indented code block
+	prevBlank := true   // Previous line was blank (or start of screen)
 
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			cleaned = append(cleaned, line)
+			prevBlank = false
+			continue
+		}
+		if inFence {
+			cleaned = append(cleaned, line)
+			continue
+		}
+		if trimmed != "" {
+			inIndented = isIndentedCode(line) && (inIndented || prevBlank)
+		}
+		prevBlank = trimmed == ""
+		if inIndented {
+			cleaned = append(cleaned, line)
+			continue
+		}
+
 		// Skip lines that are only or mostly box-drawing separator characters.
 		// "Only" catches pure separators like ─────────────────
 		// "Mostly" catches prompt bars like ────what─is─2+2 ────────────
@@ -737,6 +3913,12 @@ func cleanTUIChrome(output string) string {
 	return result
 }
 
+// isIndentedCode reports whether a non-blank line is indented like a
+// Markdown code block: a leading tab or at least four spaces.
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ")
+}
+
 // isOnlySeparators returns true if the string contains only box-drawing
 // separator characters (─, ━, ═) and spaces.
 func isOnlySeparators(s string) bool {
@@ -772,6 +3954,204 @@ func isMostlySeparators(s string) bool {
 	return float64(sepCount)/float64(totalCount) > 0.6
 }
 
+// screenDeduper tracks what a Telegram session has already sent, so each
+// flush of the virtual screen only sends content the chat hasn't seen.
+type screenDeduper struct {
+	lastCleaned string          // Cleaned screen as of the last flush
+	sentLines   map[string]bool // All lines ever sent (dedup fallback)
+}
+
+func newScreenDeduper() *screenDeduper {
+	return &screenDeduper{sentLines: make(map[string]bool)}
+}
+
+// next returns the part of a cleaned screen not sent yet and records it.
+func (d *screenDeduper) next(cleaned string) string {
+	newContent := findNewContent(d.lastCleaned, cleaned)
+	if newContent == "" {
+		return ""
+	}
+
+	// If suffix matching failed (returned entire screen), apply line-level
+	// dedup against previously sent content. This handles TUI full-redraws
+	// where old content is collapsed/summarized by Claude Code.
+	if newContent == cleaned && d.lastCleaned != "" {
+		log.Printf("[DEDUP] suffix match failed, applying line dedup (tracked=%d lines)", len(d.sentLines))
+		var unsent []string
+		for _, line := range strings.Split(newContent, "\n") {
+			key := strings.TrimSpace(line)
+			if key == "" {
+				continue
+			}
+			if !d.sentLines[key] {
+				unsent = append(unsent, line)
+			}
+		}
+		newContent = strings.TrimSpace(strings.Join(unsent, "\n"))
+	}
+
+	// Track sent lines for future dedup
+	for _, line := range strings.Split(newContent, "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			d.sentLines[key] = true
+		}
+	}
+	d.lastCleaned = cleaned
+	return newContent
+}
+
+// reset forgets everything sent, so the next screen is treated as fresh.
+func (d *screenDeduper) reset() {
+	d.lastCleaned = ""
+	d.sentLines = make(map[string]bool)
+}
+
+// resetTo forgets everything sent, then records cleaned as already sent,
+// so the next screen is diffed against it.
+func (d *screenDeduper) resetTo(cleaned string) {
+	d.reset()
+	d.next(cleaned)
+}
+
+// scrollbackLines bounds the cleaned output a session keeps for /grep.
+const scrollbackLines = 5000
+
+// scrollback is a ring buffer of the most recent output lines, kept beyond
+// what fits on the virtual screen. The streaming goroutine adds to it while
+// /save reads it, so it is safe for concurrent use.
+type scrollback struct {
+	mu   sync.Mutex
+	buf  []string
+	next int  // Index the next line is written to
+	full bool // Whether buf has wrapped, so next is also the oldest line
+}
+
+func newScrollback(size int) *scrollback {
+	return &scrollback{buf: make([]string, size)}
+}
+
+// add appends each line of text, dropping the oldest lines once full.
+func (s *scrollback) add(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range strings.Split(text, "\n") {
+		s.buf[s.next] = line
+		s.next = (s.next + 1) % len(s.buf)
+		if s.next == 0 {
+			s.full = true
+		}
+	}
+}
+
+// lines returns the buffered lines, oldest first.
+func (s *scrollback) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full {
+		return append([]string(nil), s.buf[:s.next]...)
+	}
+	return append(append([]string(nil), s.buf[s.next:]...), s.buf[:s.next]...)
+}
+
+// unseenLines returns the lines of screen not already in lines, so /grep
+// also finds output that hasn't been flushed to the scrollback yet.
+func unseenLines(lines []string, screen string) []string {
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		seen[line] = true
+	}
+	var unseen []string
+	for _, line := range strings.Split(screen, "\n") {
+		if line != "" && !seen[line] {
+			unseen = append(unseen, line)
+		}
+	}
+	return unseen
+}
+
+// grepQuery is a parsed /grep request.
+type grepQuery struct {
+	pattern string
+	re      *regexp.Regexp
+	limit   int // Most matching lines to return
+}
+
+// defaultGrepResults is how many matching lines /grep returns without -m.
+const defaultGrepResults = 50
+
+// parseGrepArgs parses /grep's arguments: [-i] [-m N] <pattern>, where
+// the pattern is a regular expression and may contain spaces.
+func parseGrepArgs(arg string) (grepQuery, error) {
+	query := grepQuery{limit: defaultGrepResults}
+	ignoreCase := false
+	for {
+		arg = strings.TrimSpace(arg)
+		flag, rest, _ := strings.Cut(arg, " ")
+		switch flag {
+		case "-i":
+			ignoreCase = true
+			arg = rest
+			continue
+		case "-m":
+			n, rest, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			limit, err := strconv.Atoi(n)
+			if err != nil || limit < 1 {
+				return query, fmt.Errorf("invalid -m %q", n)
+			}
+			query.limit = limit
+			arg = rest
+			continue
+		}
+		break
+	}
+	if arg == "" {
+		return query, errors.New("usage: /grep [-i] [-m N] <pattern>")
+	}
+	expr := arg
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return query, fmt.Errorf("invalid pattern: %v", err)
+	}
+	query.pattern, query.re = arg, re
+	return query, nil
+}
+
+// grepLines returns up to limit lines matching re, the most recent ones
+// when there are more, along with the total number of matches.
+func grepLines(lines []string, re *regexp.Regexp, limit int) (matches []string, total int) {
+	for _, line := range lines {
+		if re.MatchString(line) {
+			matches = append(matches, line)
+		}
+	}
+	total = len(matches)
+	if total > limit {
+		matches = matches[total-limit:]
+	}
+	return matches, total
+}
+
+// clearScreenSequences erase the whole display: ED 2 (clear) and RIS (full reset).
+var clearScreenSequences = []string{"\x1b[2J", "\x1bc"}
+
+// splitAtClearScreen splits output at its last clear-screen sequence,
+// returning the output before it and from it onwards.
+func splitAtClearScreen(output string) (before, after string, cleared bool) {
+	idx := -1
+	for _, seq := range clearScreenSequences {
+		if i := strings.LastIndex(output, seq); i > idx {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return "", output, false
+	}
+	return output[:idx], output[idx:], true
+}
+
 // findNewContent extracts only new content from a cleaned screen by finding
 // where old content ends and returning everything after it.
 // Uses suffix matching to handle terminal scrolling — old content may have
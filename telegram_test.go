@@ -1,8 +1,21 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // TestSessionSafeCloseDone verifies that safeCloseDone() doesn't panic when called twice
@@ -125,10 +138,1746 @@ func TestIsInteractiveCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.cmd, func(t *testing.T) {
-			got := isInteractiveCommand(tt.cmd)
+			got := isInteractiveCommand(tt.cmd, nil)
 			if got != tt.want {
 				t.Errorf("isInteractiveCommand(%q) = %v, want %v", tt.cmd, got, tt.want)
 			}
 		})
 	}
 }
+
+// TestIsInteractiveCommandConfigured verifies Config.InteractiveCommands extends the built-in list
+func TestIsInteractiveCommandConfigured(t *testing.T) {
+	config := &Config{InteractiveCommands: []string{"sqlite3", "gdb"}}
+
+	if isInteractiveCommand("sqlite3 app.db", nil) {
+		t.Error("sqlite3 should not be interactive without configuration")
+	}
+	if !isInteractiveCommand("sqlite3 app.db", config) {
+		t.Error("sqlite3 should be interactive when configured")
+	}
+	if !isInteractiveCommand("gdb ./a.out", config) {
+		t.Error("gdb should be interactive when configured")
+	}
+	// Built-in list still applies alongside configured commands
+	if !isInteractiveCommand("python3", config) {
+		t.Error("built-in python3 should remain interactive")
+	}
+	if isInteractiveCommand("ls", config) {
+		t.Error("ls should not be interactive")
+	}
+}
+
+// newTestBridge creates a TelegramBridge with no bot connection and redirects
+// config writes to a temp directory.
+func newTestBridge(t *testing.T, config *Config) *TelegramBridge {
+	t.Helper()
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() {
+		configPathOverride = ""
+	})
+	tb, err := NewTelegramBridge(nil, config)
+	if err != nil {
+		t.Fatalf("NewTelegramBridge() error: %v", err)
+	}
+	return tb
+}
+
+// TestApproveAddsUser verifies a correct approval code whitelists the user and saves config
+func TestApproveAddsUser(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111}})
+
+	code, err := tb.issueApprovalCode(111)
+	if err != nil {
+		t.Fatalf("issueApprovalCode() error: %v", err)
+	}
+
+	reply, handled := tb.tryApprove(222, "newuser", code)
+	if !handled {
+		t.Fatal("tryApprove() not handled with pending code")
+	}
+	if !strings.Contains(reply, "Approved") {
+		t.Errorf("reply = %q, want approval confirmation", reply)
+	}
+	if !tb.isAllowed(222) {
+		t.Error("user 222 should be allowed after approval")
+	}
+
+	// Config should be persisted with the new user
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if len(loaded.AllowedUsers) != 2 || loaded.AllowedUsers[1] != 222 {
+		t.Errorf("saved AllowedUsers = %v, want [111 222]", loaded.AllowedUsers)
+	}
+
+	// Code is single-use
+	if _, handled := tb.tryApprove(333, "other", code); handled {
+		t.Error("approval code should not be reusable")
+	}
+}
+
+// TestApproveKeepsOtherSavedSettings verifies an approval only writes the
+// whitelist, keeping what another process (the WebUI) saved in the meantime
+func TestApproveKeepsOtherSavedSettings(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111}})
+	if err := saveConfig(&Config{BotToken: "token", AllowedUsers: []int64{111}, WebUIPasswordHash: "hash", WebUITheme: "light"}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := tb.issueApprovalCode(111)
+	if reply, _ := tb.tryApprove(222, "newuser", code); !strings.Contains(reply, "Approved") {
+		t.Fatalf("reply = %q, want approval", reply)
+	}
+
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if saved.WebUIPasswordHash != "hash" || saved.WebUITheme != "light" {
+		t.Errorf("saved config = %+v, want the WebUI settings kept", saved)
+	}
+	if len(saved.AllowedUsers) != 2 || saved.AllowedUsers[1] != 222 {
+		t.Errorf("saved AllowedUsers = %v, want [111 222]", saved.AllowedUsers)
+	}
+}
+
+// TestApproveNoPendingCode verifies unauthorized users are rejected when no code is pending
+func TestApproveNoPendingCode(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{111}})
+
+	if _, handled := tb.tryApprove(222, "stranger", "12345678"); handled {
+		t.Error("tryApprove() should not handle messages when no code is pending")
+	}
+	if tb.isAllowed(222) {
+		t.Error("user 222 should not be allowed")
+	}
+}
+
+// TestApproveExpiredCode verifies expired codes are rejected and cleared
+func TestApproveExpiredCode(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{111}})
+
+	code, _ := tb.issueApprovalCode(111)
+	tb.mu.Lock()
+	tb.approval.expiresAt = time.Now().Add(-1 * time.Minute)
+	tb.mu.Unlock()
+
+	reply, handled := tb.tryApprove(222, "late", code)
+	if !handled || !strings.Contains(reply, "expired") {
+		t.Errorf("tryApprove() = (%q, %v), want expired reply", reply, handled)
+	}
+	if tb.isAllowed(222) {
+		t.Error("expired code should not whitelist user")
+	}
+	if tb.approval != nil {
+		t.Error("expired approval should be cleared")
+	}
+}
+
+// TestApproveMaxAttempts verifies a sender is locked out after too many
+// wrong guesses, without using up anyone else's attempts
+func TestApproveMaxAttempts(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{111}})
+
+	code, _ := tb.issueApprovalCode(111)
+
+	// Other strangers' messages don't count against the new user
+	for i := 0; i < 2*maxApprovalAttempts; i++ {
+		tb.tryApprove(333, "spammer", "hello")
+	}
+	if reply, _ := tb.tryApprove(222, "guesser", "00000000x"); !strings.Contains(reply, fmt.Sprintf("%d attempts remaining", maxApprovalAttempts-1)) {
+		t.Errorf("first guess reply = %q, want %d attempts remaining", reply, maxApprovalAttempts-1)
+	}
+
+	var reply string
+	for i := 1; i < maxApprovalAttempts; i++ {
+		reply, _ = tb.tryApprove(222, "guesser", "00000000x")
+	}
+	if !strings.Contains(reply, "Too many failed attempts") {
+		t.Errorf("final reply = %q, want lockout message", reply)
+	}
+
+	// Correct code no longer works for the locked-out sender
+	if _, handled := tb.tryApprove(222, "guesser", code); handled {
+		t.Error("approval should be locked after max attempts")
+	}
+	if tb.isAllowed(222) {
+		t.Error("user should not be allowed after lockout")
+	}
+
+	// ...but still works for someone else
+	if reply, _ := tb.tryApprove(444, "newuser", code); !strings.Contains(reply, "Approved") {
+		t.Errorf("reply = %q, want approval for another sender", reply)
+	}
+}
+
+// TestCheckSendableFile verifies /get path validation
+func TestCheckSendableFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"regular_file", file, ""},
+		{"directory", dir, "is a directory"},
+		{"missing", filepath.Join(dir, "nope.txt"), "file not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkSendableFile(tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkSendableFile(%q) error: %v", tt.path, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkSendableFile(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExpandPath verifies ~ expansion and cleaning
+func TestExpandPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	if got := expandPath("~/notes.txt"); got != filepath.Join(home, "notes.txt") {
+		t.Errorf("expandPath(~/notes.txt) = %q", got)
+	}
+	if got := expandPath("/tmp/../tmp/x"); got != "/tmp/x" {
+		t.Errorf("expandPath(/tmp/../tmp/x) = %q, want /tmp/x", got)
+	}
+}
+
+// TestSaveUpload verifies uploads are written without clobbering existing files
+func TestSaveUpload(t *testing.T) {
+	dir := t.TempDir()
+
+	path, size, err := saveUpload(dir, "report.txt", strings.NewReader("hello"), 1024)
+	if err != nil {
+		t.Fatalf("saveUpload() error: %v", err)
+	}
+	if path != filepath.Join(dir, "report.txt") || size != 5 {
+		t.Errorf("saveUpload() = (%q, %d), want report.txt, 5", path, size)
+	}
+
+	// Second upload with the same name gets a suffix
+	path2, _, err := saveUpload(dir, "report.txt", strings.NewReader("again"), 1024)
+	if err != nil {
+		t.Fatalf("saveUpload() second error: %v", err)
+	}
+	if path2 != filepath.Join(dir, "report (1).txt") {
+		t.Errorf("second path = %q, want suffixed name", path2)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "hello" {
+		t.Errorf("original file overwritten: %q", data)
+	}
+}
+
+// TestSaveUploadRejectsTraversal verifies directory components in names are stripped
+func TestSaveUploadRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	path, _, err := saveUpload(dir, "../../etc/passwd", strings.NewReader("x"), 1024)
+	if err != nil {
+		t.Fatalf("saveUpload() error: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("upload escaped target dir: %q", path)
+	}
+}
+
+// TestSaveUploadTooLarge verifies content beyond the limit is rejected and removed
+func TestSaveUploadTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := saveUpload(dir, "big.bin", strings.NewReader(strings.Repeat("x", 100)), 10)
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("saveUpload() error = %v, want too large", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "big.bin")); !os.IsNotExist(err) {
+		t.Error("oversized upload should be removed")
+	}
+}
+
+// TestAllowCommandRateLimit verifies the sliding window limit per user
+func TestAllowCommandRateLimit(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{1, 2}, RateLimitPerMinute: 3})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !tb.allowCommand(1, now.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("command %d should be allowed", i+1)
+		}
+	}
+	if tb.allowCommand(1, now.Add(3*time.Second)) {
+		t.Error("4th command within a minute should be rate limited")
+	}
+
+	// Limits are per user
+	if !tb.allowCommand(2, now.Add(3*time.Second)) {
+		t.Error("other user should not be affected by user 1's limit")
+	}
+
+	// Window slides: after a minute the oldest entries expire
+	if !tb.allowCommand(1, now.Add(61*time.Second)) {
+		t.Error("command should be allowed after the window slides")
+	}
+}
+
+// TestAllowCommandCleansIdleUsers verifies idle users are dropped from the limiter map
+func TestAllowCommandCleansIdleUsers(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{1, 2}})
+	now := time.Now()
+
+	tb.allowCommand(1, now)
+	tb.allowCommand(2, now.Add(2*time.Minute))
+
+	tb.mu.RLock()
+	_, stale := tb.rateLimits[1]
+	tb.mu.RUnlock()
+	if stale {
+		t.Error("idle user should be removed from rate limiter state")
+	}
+}
+
+// TestAllowCommandDisabled verifies a negative limit disables rate limiting
+func TestAllowCommandDisabled(t *testing.T) {
+	tb := newTestBridge(t, &Config{AllowedUsers: []int64{1}, RateLimitPerMinute: -1})
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if !tb.allowCommand(1, now) {
+			t.Fatal("rate limiting should be disabled")
+		}
+	}
+}
+
+func TestRecordHistoryBounded(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+
+	for i := 1; i <= maxHistory+5; i++ {
+		tb.recordHistory(1, fmt.Sprintf("echo %d", i))
+	}
+	tb.recordHistory(2, "pwd")
+
+	h := tb.commandHistory(1)
+	if len(h) != maxHistory {
+		t.Fatalf("history length = %d, want %d", len(h), maxHistory)
+	}
+	if h[0] != "echo 6" || h[len(h)-1] != fmt.Sprintf("echo %d", maxHistory+5) {
+		t.Errorf("history should keep the newest entries, got first=%q last=%q", h[0], h[len(h)-1])
+	}
+	if got := tb.commandHistory(2); len(got) != 1 || got[0] != "pwd" {
+		t.Errorf("chat 2 history = %v, want [pwd]", got)
+	}
+}
+
+func TestHistoryEntry(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+	tb.recordHistory(1, "ls")
+	tb.recordHistory(1, "cd /tmp")
+
+	if got, ok := tb.historyEntry(1, 2); !ok || got != "cd /tmp" {
+		t.Errorf("historyEntry(2) = %q, %v; want cd /tmp", got, ok)
+	}
+	for _, n := range []int{0, 3, -1} {
+		if _, ok := tb.historyEntry(1, n); ok {
+			t.Errorf("historyEntry(%d) should be out of range", n)
+		}
+	}
+	if _, ok := tb.historyEntry(99, 1); ok {
+		t.Error("historyEntry on unknown chat should fail")
+	}
+}
+
+func TestFormatHistory(t *testing.T) {
+	if got := formatHistory(nil); !strings.Contains(got, "No commands") {
+		t.Errorf("empty history = %q", got)
+	}
+	got := formatHistory([]string{"ls", "pwd"})
+	if !strings.Contains(got, "1. ls\n2. pwd") {
+		t.Errorf("formatHistory = %q, want numbered list", got)
+	}
+}
+
+func TestLastCommand(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+
+	if _, ok := tb.lastCommand(1); ok {
+		t.Error("lastCommand with no history should report false")
+	}
+
+	tb.recordHistory(1, "make build")
+	tb.recordHistory(1, "make test")
+	if got, ok := tb.lastCommand(1); !ok || got != "make test" {
+		t.Errorf("lastCommand = %q, %v; want make test", got, ok)
+	}
+}
+
+func TestParseEnvAssignment(t *testing.T) {
+	tests := []struct {
+		arg       string
+		key, val  string
+		wantError bool
+	}{
+		{"FOO=bar", "FOO", "bar", false},
+		{"ANTHROPIC_API_KEY=sk-ant=x", "ANTHROPIC_API_KEY", "sk-ant=x", false},
+		{"EMPTY=", "EMPTY", "", false},
+		{"FOO", "", "", true},
+		{"=bar", "", "", true},
+		{"1FOO=bar", "", "", true},
+		{"FOO BAR=baz", "", "", true},
+	}
+
+	for _, tt := range tests {
+		key, val, err := parseEnvAssignment(tt.arg)
+		if (err != nil) != tt.wantError {
+			t.Errorf("parseEnvAssignment(%q) error = %v, wantError %v", tt.arg, err, tt.wantError)
+			continue
+		}
+		if key != tt.key || val != tt.val {
+			t.Errorf("parseEnvAssignment(%q) = %q, %q; want %q, %q", tt.arg, key, val, tt.key, tt.val)
+		}
+	}
+}
+
+func TestSessionEnvSetUnset(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+
+	if env := tb.sessionEnv(1); env != nil {
+		t.Errorf("sessionEnv with no overrides = %v, want nil", env)
+	}
+
+	tb.setEnv(1, "FOO", "bar")
+	tb.setEnv(1, "FOO", "baz")
+	tb.setEnv(2, "OTHER", "x")
+
+	env := tb.sessionEnv(1)
+	if len(env) != 1 || env["FOO"] != "baz" {
+		t.Errorf("chat 1 env = %v, want FOO=baz", env)
+	}
+
+	// The returned map is a copy
+	env["FOO"] = "mutated"
+	if tb.sessionEnv(1)["FOO"] != "baz" {
+		t.Error("sessionEnv should return a copy")
+	}
+
+	if !tb.unsetEnv(1, "FOO") {
+		t.Error("unsetEnv(FOO) = false, want true")
+	}
+	if tb.unsetEnv(1, "FOO") {
+		t.Error("unsetEnv of missing key = true, want false")
+	}
+	if tb.sessionEnv(2)["OTHER"] != "x" {
+		t.Error("unsetEnv affected another chat")
+	}
+}
+
+// TestEnvCommandsHideValues verifies /env lists override names without
+// their values, and /unenv is only taken as the exact command
+func TestEnvCommandsHideValues(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111}})
+	bot := &mockBot{}
+	tb.bot = bot
+	tb.setEnv(111, "API_KEY", "s3cret")
+
+	send := func(id int, text string) string {
+		tb.handleUpdate(tgbotapi.Update{
+			UpdateID: id,
+			Message: &tgbotapi.Message{
+				From: &tgbotapi.User{ID: 111, UserName: "alice"},
+				Chat: &tgbotapi.Chat{ID: 111},
+				Text: text,
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if reply := send(1, "/env"); !strings.Contains(reply, "API_KEY") || strings.Contains(reply, "s3cret") {
+		t.Errorf("/env reply = %q, want the name without the value", reply)
+	}
+	if reply := send(2, "/unenv MISSING"); reply != "⚠️ MISSING is not set" {
+		t.Errorf("/unenv reply = %q", reply)
+	}
+	if reply := send(3, "/unenv"); reply != "Usage: /unenv KEY" {
+		t.Errorf("bare /unenv reply = %q", reply)
+	}
+}
+
+// TestRunFirstAvailableFallsBack verifies failing or silent pipelines are
+// skipped in favor of the next one
+func TestRunFirstAvailableFallsBack(t *testing.T) {
+	got, err := runFirstAvailable([]string{"exit 1", "true", "echo fallback"})
+	if err != nil {
+		t.Fatalf("runFirstAvailable() error: %v", err)
+	}
+	if got != "fallback" {
+		t.Errorf("runFirstAvailable() = %q, want %q", got, "fallback")
+	}
+
+	if _, err := runFirstAvailable([]string{"exit 1", "true"}); err == nil {
+		t.Error("expected error when no pipeline produces output")
+	}
+}
+
+// TestSystemInfoPS verifies the /ps shortcut produces a process table
+func TestSystemInfoPS(t *testing.T) {
+	got, err := runFirstAvailable(systemInfoCommands["/ps"])
+	if err != nil {
+		t.Fatalf("/ps error: %v", err)
+	}
+	if !strings.Contains(got, "PID") {
+		t.Errorf("/ps output missing header: %q", got)
+	}
+	if lines := strings.Count(got, "\n") + 1; lines > 11 {
+		t.Errorf("/ps returned %d lines, want at most 11", lines)
+	}
+}
+
+// TestShouldAttach verifies only output over the threshold becomes a file
+func TestShouldAttach(t *testing.T) {
+	sink := &TelegramSink{attachThreshold: (&Config{}).fileAttachThreshold()}
+	if sink.shouldAttach(strings.Repeat("x", defaultFileAttachThreshold)) {
+		t.Error("output at the threshold should stay inline")
+	}
+	if !sink.shouldAttach(strings.Repeat("x", defaultFileAttachThreshold+1)) {
+		t.Error("output over the threshold should be attached")
+	}
+
+	disabled := &TelegramSink{attachThreshold: (&Config{FileAttachThresholdBytes: -1}).fileAttachThreshold()}
+	if disabled.shouldAttach(strings.Repeat("x", 1<<20)) {
+		t.Error("negative threshold should disable attachments")
+	}
+}
+
+// TestTelegramSinkChunkDelay verifies long messages pause for the
+// configured delay between chunks, in both plain and HTML form
+func TestTelegramSinkChunkDelay(t *testing.T) {
+	if got := (&Config{}).chunkDelay(); got != 100*time.Millisecond {
+		t.Errorf("default chunkDelay() = %v, want 100ms", got)
+	}
+	if got := (&Config{ChunkDelayMs: -1}).chunkDelay(); got != 0 {
+		t.Errorf("negative chunkDelay() = %v, want 0", got)
+	}
+
+	bot := &mockBot{}
+	var slept []time.Duration
+	sink := &TelegramSink{
+		bot:        bot,
+		chatID:     1,
+		chunkDelay: (&Config{ChunkDelayMs: 250}).chunkDelay(),
+		sleep:      func(d time.Duration) { slept = append(slept, d) },
+	}
+
+	sink.sendPlain(strings.Repeat("x", 2500), 1000)
+	if len(bot.sent) != 3 || len(slept) != 3 {
+		t.Fatalf("sendPlain: %d chunks, %d pauses; want 3 and 3", len(bot.sent), len(slept))
+	}
+	for _, d := range slept {
+		if d != 250*time.Millisecond {
+			t.Errorf("paused %v between chunks, want 250ms", d)
+		}
+	}
+
+	slept = nil
+	sink.sendHTML("<pre>"+strings.Repeat("line\n", 500)+"</pre>", "pre", 1000)
+	if len(slept) < 2 {
+		t.Errorf("sendHTML paused %d times, want one per chunk", len(slept))
+	}
+
+	// A disabled delay never sleeps
+	sink.chunkDelay = (&Config{ChunkDelayMs: -1}).chunkDelay()
+	slept = nil
+	sink.sendPlain(strings.Repeat("x", 2500), 1000)
+	if len(slept) != 0 {
+		t.Errorf("disabled delay still paused %d times", len(slept))
+	}
+}
+
+// mockBot is a botSender that fails with the queued errors before
+// succeeding, recording every message it is asked to send.
+type mockBot struct {
+	errs []error
+	sent []tgbotapi.Chattable
+}
+
+func (m *mockBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	m.sent = append(m.sent, c)
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return tgbotapi.Message{}, err
+	}
+	return tgbotapi.Message{MessageID: len(m.sent)}, nil
+}
+
+// Request, GetUpdatesChan and GetFileDirectURL make mockBot a telegramBot,
+// so a bridge can run against it.
+func (m *mockBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (m *mockBot) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return make(chan tgbotapi.Update)
+}
+
+func (m *mockBot) GetFileDirectURL(fileID string) (string, error) {
+	return "", errors.New("mockBot has no files")
+}
+
+// TestHandleUpdateSkipsDuplicates verifies an update Telegram delivers
+// twice is only handled once
+func TestHandleUpdateSkipsDuplicates(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111}})
+	bot := &mockBot{}
+	tb.bot = bot
+
+	update := tgbotapi.Update{
+		UpdateID: 42,
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: 111, UserName: "alice"},
+			Chat: &tgbotapi.Chat{ID: 111},
+			Text: "/whoami",
+		},
+	}
+	tb.handleUpdate(update)
+	tb.handleUpdate(update)
+	if len(bot.sent) != 1 {
+		t.Fatalf("duplicate update sent %d replies, want 1", len(bot.sent))
+	}
+
+	update.UpdateID = 43
+	tb.handleUpdate(update)
+	if len(bot.sent) != 2 {
+		t.Errorf("new update sent %d replies in total, want 2", len(bot.sent))
+	}
+}
+
+// TestMarkUpdateSeenBounded verifies old update IDs are forgotten past
+// maxSeenUpdates
+func TestMarkUpdateSeenBounded(t *testing.T) {
+	tb := newTestBridge(t, &Config{})
+	for id := 1; id <= maxSeenUpdates+10; id++ {
+		if !tb.markUpdateSeen(id) {
+			t.Fatalf("markUpdateSeen(%d) = false for a new ID", id)
+		}
+	}
+	if len(tb.seenUpdates) != maxSeenUpdates || len(tb.seenUpdateIDs) != maxSeenUpdates {
+		t.Errorf("remembered %d/%d IDs, want %d", len(tb.seenUpdates), len(tb.seenUpdateIDs), maxSeenUpdates)
+	}
+	if tb.markUpdateSeen(maxSeenUpdates + 10) {
+		t.Error("markUpdateSeen() = true for a recent duplicate")
+	}
+	if !tb.markUpdateSeen(1) {
+		t.Error("markUpdateSeen() = false for an ID past the window")
+	}
+}
+
+// TestTelegramSinkRetriesRateLimit verifies a 429 is retried after its
+// retry_after, other errors are not, and retries are bounded
+func TestTelegramSinkRetriesRateLimit(t *testing.T) {
+	tooMany := func(after int) error {
+		return &tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: after}}
+	}
+	var slept []time.Duration
+	sleep := func(d time.Duration) { slept = append(slept, d) }
+
+	bot := &mockBot{errs: []error{tooMany(3)}}
+	sink := &TelegramSink{bot: bot, chatID: 1, sleep: sleep}
+	sink.SendOutput("hello")
+	if len(bot.sent) != 2 {
+		t.Errorf("SendOutput made %d attempts, want 2", len(bot.sent))
+	}
+	if len(slept) != 1 || slept[0] != 3*time.Second {
+		t.Errorf("slept %v, want [3s]", slept)
+	}
+
+	// Each chunk of a long message is retried on its own
+	bot = &mockBot{errs: []error{tooMany(1)}}
+	sink = &TelegramSink{bot: bot, chatID: 1, sleep: sleep}
+	sink.sendHTML("<pre>"+strings.Repeat("line\n", 500)+"</pre>", "pre", 1000)
+	first := bot.sent[0].(tgbotapi.MessageConfig).Text
+	if second := bot.sent[1].(tgbotapi.MessageConfig).Text; second != first {
+		t.Error("the rate-limited chunk should be resent before the next one")
+	}
+
+	// Other errors are not retried
+	bot = &mockBot{errs: []error{errors.New("Bad Request: chat not found")}}
+	sink = &TelegramSink{bot: bot, chatID: 1, sleep: sleep}
+	sink.sendPlain("hello", 4000)
+	if len(bot.sent) != 1 {
+		t.Errorf("non-429 error retried: %d attempts", len(bot.sent))
+	}
+
+	// A persistent 429 gives up after maxSendRetries retries
+	bot = &mockBot{errs: []error{tooMany(1), tooMany(1), tooMany(1), tooMany(1), tooMany(1)}}
+	sink = &TelegramSink{bot: bot, chatID: 1, sleep: sleep}
+	sink.sendPlain("hello", 4000)
+	if len(bot.sent) != maxSendRetries+1 {
+		t.Errorf("persistent 429: %d attempts, want %d", len(bot.sent), maxSendRetries+1)
+	}
+}
+
+// TestTelegramSinkEditsInPlace verifies session output grows one message
+// by editing it, and a new message is started when asked, when the text
+// outgrows a message, when the last one is stale, or when an edit fails
+func TestTelegramSinkEditsInPlace(t *testing.T) {
+	bot := &mockBot{}
+	sink := &TelegramSink{bot: bot, chatID: 1, editInPlace: true}
+	lastSent := func() tgbotapi.Chattable { return bot.sent[len(bot.sent)-1] }
+
+	sink.SendOutput("line one")
+	sink.SendOutput("line two")
+	edit, ok := lastSent().(tgbotapi.EditMessageTextConfig)
+	if len(bot.sent) != 2 || !ok || edit.MessageID != 1 || edit.Text != "line one\nline two" {
+		t.Fatalf("second output sent %#v, want an edit of message 1", lastSent())
+	}
+
+	sink.startNewMessage()
+	sink.SendOutput("line three")
+	if _, ok := lastSent().(tgbotapi.MessageConfig); !ok {
+		t.Errorf("output after startNewMessage sent %T, want a new message", lastSent())
+	}
+
+	sink.SendOutput(strings.Repeat("x", maxMessageLen))
+	if _, ok := lastSent().(tgbotapi.MessageConfig); !ok {
+		t.Errorf("output past the message limit sent %T, want a new message", lastSent())
+	}
+
+	sink.SendOutput("fresh")
+	sink.live.updated = time.Now().Add(-liveMessageWindow - time.Second)
+	sink.SendOutput("after a pause")
+	if _, ok := lastSent().(tgbotapi.MessageConfig); !ok {
+		t.Errorf("output after a pause sent %T, want a new message", lastSent())
+	}
+
+	bot.errs = []error{errors.New("Bad Request: message to edit not found")}
+	sent := len(bot.sent)
+	sink.SendOutput("after a failed edit")
+	if len(bot.sent) != sent+2 {
+		t.Fatalf("failed edit: %d sends, want the edit and a new message", len(bot.sent)-sent)
+	}
+	if msg, ok := lastSent().(tgbotapi.MessageConfig); !ok || msg.Text != "after a failed edit" {
+		t.Errorf("failed edit fell back to %#v, want a new message", lastSent())
+	}
+
+	// Replies (editInPlace unset) always send new messages
+	bot = &mockBot{}
+	sink = &TelegramSink{bot: bot, chatID: 1}
+	sink.SendOutput("one")
+	sink.SendOutput("two")
+	for _, c := range bot.sent {
+		if _, ok := c.(tgbotapi.MessageConfig); !ok {
+			t.Errorf("reply sink sent %T, want new messages only", c)
+		}
+	}
+}
+
+// TestKeepTyping verifies one-shot commands keep re-sending "typing..."
+// until they finish, and nothing is sent once stopped
+func TestKeepTyping(t *testing.T) {
+	bot := &mockBot{}
+	stop := keepTyping(bot, 1, 20*time.Millisecond)
+	time.Sleep(110 * time.Millisecond)
+	stop()
+	sent := len(bot.sent)
+	if sent < 3 {
+		t.Fatalf("sent %d typing actions, want at least 3", sent)
+	}
+	for _, c := range bot.sent {
+		if action, ok := c.(tgbotapi.ChatActionConfig); !ok || action.Action != tgbotapi.ChatTyping {
+			t.Fatalf("sent %#v, want a typing action", c)
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if len(bot.sent) != sent {
+		t.Errorf("typing continued after stop: %d actions, want %d", len(bot.sent), sent)
+	}
+}
+
+// TestTypingIndicatorRefresh verifies the streaming loop's indicator is
+// only re-sent once it is about to expire
+func TestTypingIndicatorRefresh(t *testing.T) {
+	bot := &mockBot{}
+	typing := &typingIndicator{bot: bot, chatID: 1, last: time.Now()}
+	typing.refresh()
+	if len(bot.sent) != 0 {
+		t.Errorf("fresh indicator re-sent %d times", len(bot.sent))
+	}
+	typing.last = time.Now().Add(-typingInterval - time.Second)
+	typing.refresh()
+	typing.refresh()
+	if len(bot.sent) != 1 {
+		t.Errorf("stale indicator sent %d times, want 1", len(bot.sent))
+	}
+}
+
+// TestLooksBinary verifies UTF-8 text is kept while NUL-laden or undecodable
+// output is detected as binary, and that the threshold is configurable
+func TestLooksBinary(t *testing.T) {
+	threshold := (&Config{}).binaryThresholdPercent()
+	binary := "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00>\x00\x01\x00\x00\x00\x10k"
+
+	kept := []string{
+		"hello world",
+		"héllo wörld — 日本語 ✓ 🚀",
+		"\x1b[31mred\x1b[0m\ttab\r\nnext line",
+		"┌──┐\n│ok│\n└──┘",
+	}
+	for _, s := range kept {
+		if looksBinary(s, threshold) {
+			t.Errorf("looksBinary(%q) = true, want text kept", s)
+		}
+	}
+
+	suppressed := []string{
+		binary,
+		strings.Repeat("\x00", 64),
+		"\xff\xfe\xfd\xfc abc \xc3\x28\xa0\xa1",
+		"ELF��������>����k",
+	}
+	for _, s := range suppressed {
+		if !looksBinary(s, threshold) {
+			t.Errorf("looksBinary(%q) = false, want binary suppressed", s)
+		}
+	}
+
+	if looksBinary(binary, (&Config{BinaryThresholdPercent: -1}).binaryThresholdPercent()) {
+		t.Error("negative threshold should disable detection")
+	}
+	if looksBinary("log line\x00", (&Config{BinaryThresholdPercent: 50}).binaryThresholdPercent()) {
+		t.Error("a stray NUL should stay under a 50% threshold")
+	}
+}
+
+// TestAttachmentName verifies attachments get a timestamped .txt name
+func TestAttachmentName(t *testing.T) {
+	got := attachmentName(time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC))
+	if got != "output-20250304-050607.txt" {
+		t.Errorf("attachmentName() = %q", got)
+	}
+}
+
+// TestFormatWhoami verifies the owner is the first allowed user
+func TestFormatWhoami(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111, 222}})
+
+	if got := tb.formatWhoami(111, "alice"); !strings.Contains(got, "@alice") ||
+		!strings.Contains(got, "ID: 111") || !strings.Contains(got, "Role: owner") {
+		t.Errorf("owner whoami = %q", got)
+	}
+	if got := tb.formatWhoami(222, ""); !strings.Contains(got, "(no username)") ||
+		!strings.Contains(got, "Role: allowed user") {
+		t.Errorf("second user whoami = %q", got)
+	}
+}
+
+// TestFormatUptime verifies uptime is measured from bridge creation and
+// only active sessions are counted
+func TestFormatUptime(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	tb.sessions[1] = &Session{Active: true}
+	tb.sessions[2] = &Session{Active: false}
+
+	got := tb.formatUptime(tb.startedAt.Add(90 * time.Minute))
+	if !strings.Contains(got, "Uptime: 1h30m0s") || !strings.Contains(got, "Active sessions: 1") {
+		t.Errorf("formatUptime() = %q", got)
+	}
+}
+
+// TestShowStatusTimezone verifies Config.Timezone sets the zone of the
+// start time in /status, and an unknown zone falls back to server time
+func TestShowStatusTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	status := func(config *Config) string {
+		tb := newTestBridge(t, config)
+		bot := &mockBot{}
+		tb.bot = bot
+		tb.sessions[1] = &Session{Active: true, Command: "shell", StartedAt: started}
+		tb.showStatus(1)
+		return bot.sent[0].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := status(&Config{Timezone: "Asia/Tokyo"}); !strings.Contains(got, "Started: 12:04:05 JST") {
+		t.Errorf("status = %q, want the start time in Tokyo", got)
+	}
+	want := "Started: " + started.In(time.Local).Format("15:04:05 MST")
+	for _, tz := range []string{"", "Not/AZone"} {
+		if got := status(&Config{Timezone: tz}); !strings.Contains(got, want) {
+			t.Errorf("timezone %q: status = %q, want %q", tz, got, want)
+		}
+	}
+}
+
+// TestFormatAllSessions verifies /sessions lists every chat's session in
+// chat ID order with its state, duration and command
+func TestFormatAllSessions(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	now := time.Now()
+	tb.sessions[-1002] = &Session{Active: true, Command: "htop", StartedAt: now.Add(-90 * time.Second)}
+	tb.sessions[42] = &Session{Active: false, Command: "claude", StartedAt: now.Add(-time.Hour)}
+
+	lines := strings.Split(strings.TrimSpace(tb.formatAllSessions(now)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("formatAllSessions() = %q, want a header and two sessions", lines)
+	}
+	for i, want := range [][]string{
+		{"CHAT ID", "STATE", "DURATION", "COMMAND"},
+		{"-1002", "active", "1m30s", "htop"},
+		{"42", "ended", "1h0m0s", "claude"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i], field) {
+				t.Errorf("line %d = %q, missing %q", i, lines[i], field)
+			}
+		}
+	}
+	if strings.Index(lines[1], "active") != strings.Index(lines[2], "ended") {
+		t.Errorf("columns not aligned:\n%s\n%s", lines[1], lines[2])
+	}
+}
+
+// TestCommandPermittedDenylist verifies denylist globs block matching first
+// words (including by base name) and let everything else through
+func TestCommandPermittedDenylist(t *testing.T) {
+	config := &Config{DeniedCommands: []string{"rm*", "shutdown"}}
+
+	for _, cmd := range []string{"rm -rf /tmp/x", "rmdir foo", "/bin/rm file", "  shutdown -h now"} {
+		if commandPermitted(cmd, config) {
+			t.Errorf("%q should be denied", cmd)
+		}
+	}
+	for _, cmd := range []string{"ls -la", "echo rm", "mkdir rm", ""} {
+		if !commandPermitted(cmd, config) {
+			t.Errorf("%q should be permitted", cmd)
+		}
+	}
+	if !commandPermitted("rm -rf /", nil) {
+		t.Error("nil config should permit everything")
+	}
+}
+
+// TestCommandPermittedAllowlist verifies strict mode only runs allowed
+// commands and that the denylist still takes precedence
+func TestCommandPermittedAllowlist(t *testing.T) {
+	config := &Config{
+		AllowedCommands: []string{"ls", "git", "docker*"},
+		DeniedCommands:  []string{"docker-compose"},
+	}
+
+	for _, cmd := range []string{"ls", "git status", "docker ps"} {
+		if !commandPermitted(cmd, config) {
+			t.Errorf("%q should be allowed", cmd)
+		}
+	}
+	for _, cmd := range []string{"cat /etc/passwd", "docker-compose down", "lsof"} {
+		if commandPermitted(cmd, config) {
+			t.Errorf("%q should be refused in strict mode", cmd)
+		}
+	}
+}
+
+// TestZipDirectory verifies archives contain relative paths and file contents
+func TestZipDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("beta"), 0644)
+
+	var buf bytes.Buffer
+	count, err := zipDirectory(dir, &buf, maxZipInputSize, maxZipFiles)
+	if err != nil {
+		t.Fatalf("zipDirectory() error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid archive: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+	}
+	if got["a.txt"] != "alpha" || got["sub/b.txt"] != "beta" || len(got) != 2 {
+		t.Errorf("archive contents = %v", got)
+	}
+}
+
+// TestZipDirectoryLimits verifies oversized or crowded trees are refused
+func TestZipDirectoryLimits(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", i)), []byte("0123456789"), 0644)
+	}
+
+	if _, err := zipDirectory(dir, io.Discard, 25, maxZipFiles); err == nil {
+		t.Error("expected size limit error")
+	}
+	if _, err := zipDirectory(dir, io.Discard, maxZipInputSize, 2); err == nil {
+		t.Error("expected file count error")
+	}
+}
+
+// TestAuditLogWritesEntry verifies an audited command is logged with its
+// first output line once output is flushed, in a 0600 file
+func TestAuditLogWritesEntry(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AuditLog: true})
+	session := &Session{}
+
+	tb.auditCommand(session, 42, "alice", "ls -la")
+	tb.flushAudit(session, "ls -la\n\ntotal 8\ndrwxr-xr-x  2 alice\n")
+	tb.flushAudit(session, "more output") // Already written, must not log again
+
+	data, err := os.ReadFile(auditLogPath())
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 audit line, got %d: %q", len(lines), data)
+	}
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) != 5 {
+		t.Fatalf("expected 5 fields, got %q", lines[0])
+	}
+	if _, err := time.Parse(time.RFC3339, fields[0]); err != nil {
+		t.Errorf("bad timestamp %q: %v", fields[0], err)
+	}
+	want := []string{"chat=42", "user=@alice", `cmd="ls -la"`, `result="total 8"`}
+	for i, w := range want {
+		if fields[i+1] != w {
+			t.Errorf("field %d = %q, want %q", i+1, fields[i+1], w)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		info, _ := os.Stat(auditLogPath())
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("audit log mode = %o, want 600", perm)
+		}
+	}
+}
+
+// TestAuditLogDisabled verifies nothing is written unless AuditLog is set
+func TestAuditLogDisabled(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	session := &Session{}
+
+	tb.auditCommand(session, 42, "alice", "ls")
+	tb.flushAudit(session, "file.txt")
+
+	if _, err := os.Stat(auditLogPath()); !os.IsNotExist(err) {
+		t.Errorf("audit log should not exist, stat err = %v", err)
+	}
+}
+
+// TestStopButtonIsCurrent verifies only the active session's own Stop
+// button can end it
+func TestStopButtonIsCurrent(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	tb.sessions[42] = &Session{Active: true, stopMessageID: 7, done: make(chan struct{})}
+
+	if !tb.stopButtonIsCurrent(42, 7) {
+		t.Error("button on the session's start message should be current")
+	}
+	if tb.stopButtonIsCurrent(42, 6) {
+		t.Error("button from an earlier session should be stale")
+	}
+	if tb.stopButtonIsCurrent(43, 7) {
+		t.Error("button in a chat without a session should be stale")
+	}
+
+	markup := stopButtonMarkup()
+	if data := markup.InlineKeyboard[0][0].CallbackData; data == nil || *data != stopSessionCallback {
+		t.Errorf("Stop button callback data = %v, want %q", data, stopSessionCallback)
+	}
+}
+
+// TestSessionRequestClear verifies /clear signals never block and are
+// refused for sessions without a streaming goroutine
+func TestSessionRequestClear(t *testing.T) {
+	session := &Session{clearReq: make(chan struct{}, 1)}
+	if !session.requestClear() || !session.requestClear() {
+		t.Fatal("requestClear() should succeed and not block when a request is pending")
+	}
+	if len(session.clearReq) != 1 {
+		t.Errorf("expected 1 pending clear request, got %d", len(session.clearReq))
+	}
+
+	if (&Session{}).requestClear() {
+		t.Error("requestClear() should fail without a clear channel")
+	}
+}
+
+// TestSessionIndexRoundTrip verifies active sessions are persisted, and
+// the file is removed once none remain
+func TestSessionIndexRoundTrip(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token"})
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tb.mu.Lock()
+	tb.sessions[42] = &Session{Active: true, Command: "claude", StartedAt: started}
+	tb.sessions[7] = &Session{Active: true, Command: "htop", StartedAt: started}
+	tb.sessions[9] = &Session{Active: false, Command: "gone"}
+	tb.saveSessionIndexLocked()
+	tb.mu.Unlock()
+
+	entries := loadSessionIndex(tb.sessionIndexPath())
+	if len(entries) != 2 || entries[0].ChatID != 7 || entries[1].Command != "claude" || !entries[1].StartedAt.Equal(started) {
+		t.Fatalf("loadSessionIndex() = %+v", entries)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(tb.sessionIndexPath()); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("session index should be 0600 (err %v)", err)
+		}
+	}
+
+	tb.mu.Lock()
+	tb.sessions = make(map[int64]*Session)
+	tb.saveSessionIndexLocked()
+	tb.mu.Unlock()
+	if _, err := os.Stat(tb.sessionIndexPath()); !os.IsNotExist(err) {
+		t.Errorf("empty index should remove the file, stat err = %v", err)
+	}
+}
+
+// TestFormatInterruptedSession verifies the restart notice names the session
+func TestFormatInterruptedSession(t *testing.T) {
+	started := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	got := formatInterruptedSession(sessionIndexEntry{ChatID: 1, Command: "claude", StartedAt: started}, started.Add(90*time.Minute))
+	if !strings.Contains(got, "claude, started 1h30m0s ago") || !strings.Contains(got, "restarted") {
+		t.Errorf("formatInterruptedSession() = %q", got)
+	}
+}
+
+// TestSessionMuteToggle verifies /mute state is per session and reversible
+func TestSessionMuteToggle(t *testing.T) {
+	session := &Session{}
+	if session.isMuted() {
+		t.Fatal("new sessions should not be muted")
+	}
+	session.setMuted(true)
+	if !session.isMuted() {
+		t.Error("setMuted(true) should mute")
+	}
+	if (&Session{}).isMuted() {
+		t.Error("mute must not leak to other sessions")
+	}
+	session.setMuted(false)
+	if session.isMuted() {
+		t.Error("setMuted(false) should unmute")
+	}
+}
+
+// TestFindPipeline verifies /find quotes the pattern and bounds the search
+func TestFindPipeline(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "b", "c", "d", "e"), 0755)
+	os.WriteFile(filepath.Join(dir, "Report.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "a", "b", "old report.md"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "a", "b", "c", "d", "e", "deep-report"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+
+	got, err := runPipeline(dir, findPipeline("report"))
+	if err != nil {
+		t.Fatalf("runPipeline() error: %v", err)
+	}
+	lines := strings.Split(got, "\n")
+	sort.Strings(lines)
+	want := []string{"./Report.txt", "./a/b/old report.md"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("find results = %q, want %q", lines, want)
+	}
+
+	// Quotes in the pattern must not break out of the find argument
+	if got, _ := runPipeline(dir, findPipeline("'; echo injected; '")); strings.Contains(got, "injected") {
+		t.Errorf("pattern was interpreted by the shell: %q", got)
+	}
+}
+
+// TestWelcomeHTML verifies a configured welcome is used after Markdown
+// conversion, and the default is used otherwise
+func TestWelcomeHTML(t *testing.T) {
+	if got := welcomeHTML(nil); got != formatMarkdownToTelegramHTML(defaultWelcomeMessage) || !strings.Contains(got, "Connected!") {
+		t.Errorf("default welcome = %q", got)
+	}
+	if got := welcomeHTML(&Config{}); !strings.Contains(got, "Connected!") {
+		t.Errorf("empty welcome_message should use the default, got %q", got)
+	}
+
+	custom := "# Team bot\n\nUse **git** only & be nice: `git status`"
+	got := welcomeHTML(&Config{WelcomeMessage: custom})
+	if got != formatMarkdownToTelegramHTML(custom) {
+		t.Errorf("welcomeHTML() = %q, want markdown conversion of the configured message", got)
+	}
+	for _, want := range []string{"<b>Team bot</b>", "<b>git</b>", "&amp;", "<code>git status</code>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("welcomeHTML() = %q, missing %q", got, want)
+		}
+	}
+}
+
+// TestAnnounceTargets verifies announcements reach seen chats and chats
+// with active sessions, once each
+func TestAnnounceTargets(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{111, 222}})
+	tb.markChatSeen(30)
+	tb.markChatSeen(10)
+	tb.markChatSeen(30)
+	tb.sessions[20] = &Session{Active: true, done: make(chan struct{})}
+	tb.sessions[10] = &Session{Active: true, done: make(chan struct{})}
+	tb.sessions[40] = &Session{Active: false, done: make(chan struct{})}
+
+	if got := fmt.Sprint(tb.announceTargets()); got != "[10 20 30]" {
+		t.Errorf("announceTargets() = %s, want [10 20 30]", got)
+	}
+	if !tb.isOwner(111) || tb.isOwner(222) {
+		t.Error("only the first allowed user may announce")
+	}
+	if got := formatAnnouncement("maintenance at 5pm"); got != "📢 Announcement\n\nmaintenance at 5pm" {
+		t.Errorf("formatAnnouncement() = %q", got)
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		stages   []string
+		prefixes []string
+	}{
+		{
+			name:     "simple",
+			command:  "cat log | grep err | wc -l",
+			stages:   []string{"cat log", "grep err", "wc -l"},
+			prefixes: []string{"cat log", "cat log | grep err", "cat log | grep err | wc -l"},
+		},
+		{
+			name:     "quoted pipes",
+			command:  `echo 'a|b' | grep "x|y"`,
+			stages:   []string{`echo 'a|b'`, `grep "x|y"`},
+			prefixes: []string{`echo 'a|b'`, `echo 'a|b' | grep "x|y"`},
+		},
+		{
+			name:     "escaped pipe",
+			command:  `echo a\|b | cat`,
+			stages:   []string{`echo a\|b`, "cat"},
+			prefixes: []string{`echo a\|b`, `echo a\|b | cat`},
+		},
+		{
+			name:     "command substitution",
+			command:  "echo $(ls | head -1) | wc -c",
+			stages:   []string{"echo $(ls | head -1)", "wc -c"},
+			prefixes: []string{"echo $(ls | head -1)", "echo $(ls | head -1) | wc -c"},
+		},
+		{
+			name:     "backticks",
+			command:  "echo `ls | head -1` | wc -c",
+			stages:   []string{"echo `ls | head -1`", "wc -c"},
+			prefixes: []string{"echo `ls | head -1`", "echo `ls | head -1` | wc -c"},
+		},
+		{
+			name:     "group and subshell",
+			command:  "{ a | b; } | (c | d) | e",
+			stages:   []string{"{ a | b; }", "(c | d)", "e"},
+			prefixes: []string{"{ a | b; }", "{ a | b; } | (c | d)", "{ a | b; } | (c | d) | e"},
+		},
+		{
+			name:     "logical or and clobber",
+			command:  "false || echo x >| out",
+			stages:   []string{"false || echo x >| out"},
+			prefixes: []string{"false || echo x >| out"},
+		},
+		{
+			name:     "stderr pipe",
+			command:  "make |& grep error",
+			stages:   []string{"make", "grep error"},
+			prefixes: []string{"make", "make |& grep error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, prefixes := splitPipeline(tt.command)
+			if strings.Join(stages, "\x00") != strings.Join(tt.stages, "\x00") {
+				t.Errorf("stages = %q, want %q", stages, tt.stages)
+			}
+			if strings.Join(prefixes, "\x00") != strings.Join(tt.prefixes, "\x00") {
+				t.Errorf("prefixes = %q, want %q", prefixes, tt.prefixes)
+			}
+		})
+	}
+}
+
+func TestFormatSplitStage(t *testing.T) {
+	got := formatSplitStage(2, "ls | grep go", "", nil)
+	if got != "▶ [2] ls | grep go\n(no output)\n" {
+		t.Errorf("empty stage = %q", got)
+	}
+
+	var lines []string
+	for i := 0; i < maxSplitStageLines+5; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	got = formatSplitStage(1, "seq", strings.Join(lines, "\n"), nil)
+	if !strings.Contains(got, "… 5 more lines") || strings.Contains(got, fmt.Sprintf("line %d", maxSplitStageLines)) {
+		t.Errorf("long stage not truncated: %q", got)
+	}
+
+	got = formatSplitStage(1, "false", "", errors.New("exit status 1"))
+	if !strings.Contains(got, "(exit status 1)") {
+		t.Errorf("error not shown: %q", got)
+	}
+}
+
+// TestDiffPath verifies /diff arguments resolve against the session's
+// directory and missing files or directories are reported by name
+func TestDiffPath(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644)
+
+	if got, err := diffPath(dir, "a.txt"); err != nil || got != filepath.Join(dir, "a.txt") {
+		t.Errorf("diffPath(relative) = %q, %v", got, err)
+	}
+	if _, err := diffPath(dir, "missing.txt"); err == nil || err.Error() != "file not found: missing.txt" {
+		t.Errorf("missing file error = %v", err)
+	}
+	if _, err := diffPath("", dir); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("directory error = %v", err)
+	}
+}
+
+// TestTailPath verifies /tail only starts on a file that exists, and says
+// why a directory is refused
+func TestTailPath(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.log"), []byte("started\n"), 0644)
+
+	if got, err := tailPath(dir, "app.log"); err != nil || got != filepath.Join(dir, "app.log") {
+		t.Errorf("tailPath(relative) = %q, %v", got, err)
+	}
+	if _, err := tailPath(dir, "missing.log"); err == nil || err.Error() != "file not found: missing.log" {
+		t.Errorf("missing file error = %v", err)
+	}
+	if _, err := tailPath("", dir); err == nil || !strings.Contains(err.Error(), "/tail follows a file") {
+		t.Errorf("directory error = %v", err)
+	}
+}
+
+// TestRunDiff verifies identical files are reported as such and differing
+// files produce a unified diff with the right line counts
+func TestRunDiff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh and diff")
+	}
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b's copy.txt")
+	os.WriteFile(a, []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(b, []byte("one\ntwo\nthree\n"), 0644)
+
+	if output, identical, err := runDiff(a, b); err != nil || !identical || output != "" {
+		t.Errorf("runDiff(identical) = %q, %v, %v", output, identical, err)
+	}
+
+	os.WriteFile(b, []byte("one\n2\nthree\nfour\n"), 0644)
+	output, identical, err := runDiff(a, b)
+	if err != nil || identical {
+		t.Fatalf("runDiff(different) identical=%v err=%v", identical, err)
+	}
+	if !strings.Contains(output, "-two\n+2") || !strings.Contains(output, "+four") {
+		t.Errorf("runDiff output = %q, want a unified diff", output)
+	}
+	if added, removed := diffStats(output); added != 2 || removed != 1 {
+		t.Errorf("diffStats() = +%d -%d, want +2 -1", added, removed)
+	}
+}
+
+// TestSessionSinks verifies sinks can be added and listed concurrently,
+// as /attach and the streaming goroutine do, and removed again
+func TestSessionSinks(t *testing.T) {
+	own := &MockSink{}
+	session := &Session{Sinks: []OutputSink{own}}
+
+	var wg sync.WaitGroup
+	extra := make([]*MockSink, 20)
+	for i := range extra {
+		extra[i] = &MockSink{}
+		wg.Add(2)
+		go func(sink *MockSink) {
+			defer wg.Done()
+			session.addSink(sink)
+		}(extra[i])
+		go func() {
+			defer wg.Done()
+			if sinks := session.sinkList(); len(sinks) == 0 || sinks[0] != OutputSink(own) {
+				t.Error("sinkList() lost the session's own sink")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(session.sinkList()); got != 21 {
+		t.Fatalf("got %d sinks, want 21", got)
+	}
+	for _, sink := range extra {
+		if !session.removeSink(sink) {
+			t.Errorf("removeSink(%p) = false", sink)
+		}
+	}
+	if session.removeSink(extra[0]) {
+		t.Error("removing a sink twice should report false")
+	}
+	if !session.hasSink(own) || len(session.sinkList()) != 1 {
+		t.Errorf("own sink lost: %v", session.sinkList())
+	}
+
+	session.setSink(nil)
+	if session.attached() {
+		t.Error("setSink(nil) should detach all sinks")
+	}
+}
+
+// TestAttachCandidates verifies users can attach only to their own sessions
+// in other chats, while the owner can attach to any
+func TestAttachCandidates(t *testing.T) {
+	tb := newTestBridge(t, &Config{BotToken: "token", AllowedUsers: []int64{1, 2}})
+	tb.sessions[1] = &Session{Active: true, UserID: 1}
+	tb.sessions[-100] = &Session{Active: true, UserID: 2}
+	tb.sessions[2] = &Session{Active: true, UserID: 2}
+	tb.sessions[-200] = &Session{Active: false, UserID: 2}
+
+	if got := tb.attachCandidates(2, 2); len(got) != 1 || got[0] != -100 {
+		t.Errorf("user 2 candidates = %v, want [-100]", got)
+	}
+	if got := tb.attachCandidates(1, 1); len(got) != 2 || got[0] != -100 || got[1] != 2 {
+		t.Errorf("owner candidates = %v, want [-100 2]", got)
+	}
+
+	session := tb.sessions[2]
+	session.addSink(&TelegramSink{chatID: -100})
+	if _, ok := session.telegramSinkFor(-100); !ok {
+		t.Error("telegramSinkFor(-100) did not find the attached chat")
+	}
+	if _, ok := session.telegramSinkFor(-300); ok {
+		t.Error("telegramSinkFor(-300) found a chat that never attached")
+	}
+
+	if usage := formatAttachUsage([]int64{-100, 2}); !strings.Contains(usage, "-100, 2") {
+		t.Errorf("formatAttachUsage() = %q", usage)
+	}
+}
+
+// TestApplyCommandPrefix verifies a configured prefix is required and
+// stripped for shell commands, while /commands pass through unchanged
+func TestExpandAlias(t *testing.T) {
+	aliases := map[string]string{
+		"ll":   "ls -la",
+		"gs":   "git status",
+		"ls":   "ls --color=never",
+		"loop": "loop again",
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ll", "ls -la"},
+		{"gs", "git status"},
+		{"ll foo bar", "ls -la foo bar"},
+		{"ll\t/tmp", "ls -la\t/tmp"},
+		{"lll", "lll"},
+		{"echo ll", "echo ll"},
+		{"git status", "git status"},
+		{"", ""},
+		// The expansion's own first word is not expanded again
+		{"loop", "loop again"},
+	}
+	for _, tt := range tests {
+		if got := expandAlias(tt.text, aliases); got != tt.want {
+			t.Errorf("expandAlias(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	if got := expandAlias("ll", nil); got != "ll" {
+		t.Errorf("expandAlias with no aliases = %q, want unchanged", got)
+	}
+}
+
+func TestApplyCommandPrefix(t *testing.T) {
+	tests := []struct {
+		text   string
+		prefix string
+		want   string
+		ok     bool
+	}{
+		{"ls -la", "", "ls -la", true},
+		{"!ls -la", "!", "ls -la", true},
+		{"! uptime", "!", "uptime", true},
+		{"good morning everyone", "!", "", false},
+		{"!", "!", "", false},
+		{"/status", "!", "/status", true},
+		{"/get notes.txt", "!", "/get notes.txt", true},
+		{"$ df -h", "$ ", "df -h", true},
+		{"df -h", "$ ", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := applyCommandPrefix(tt.text, tt.prefix)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("applyCommandPrefix(%q, %q) = %q, %v; want %q, %v", tt.text, tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestSessionCommandQueue verifies queued commands are written one at a
+// time, each only after the previous command's output has settled
+func TestSessionCommandQueue(t *testing.T) {
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	defer term.Close()
+	session := &Session{
+		Terminal: term,
+		done:     make(chan struct{}),
+		commands: make(chan queuedCommand, maxQueuedCommands),
+	}
+	defer session.safeCloseDone()
+
+	// Stand in for the streaming goroutine: collect output and mark it
+	var mu sync.Mutex
+	var output strings.Builder
+	go func() {
+		for {
+			select {
+			case <-session.done:
+				return
+			case chunk, ok := <-term.outputChan:
+				if !ok {
+					return
+				}
+				session.markOutput()
+				mu.Lock()
+				output.WriteString(chunk)
+				mu.Unlock()
+			}
+		}
+	}()
+	go session.runCommandQueue(time.Second, 10*time.Second, func(cmd queuedCommand) {
+		term.SendCommand(cmd.text)
+	})
+
+	// The echoed input says "q-$((n))" and only the output says "q-n", so
+	// interleaved commands would show up as input echoed before output
+	for i := 1; i <= 3; i++ {
+		ahead, ok := session.enqueueCommand(queuedCommand{username: "alice", text: fmt.Sprintf("sleep 0.3; echo q-$((%d))", i)})
+		if !ok || ahead != i-1 {
+			t.Fatalf("enqueueCommand(%d) = %d, %v; want %d, true", i, ahead, ok, i-1)
+		}
+	}
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		mu.Lock()
+		got := output.String()
+		mu.Unlock()
+		if strings.Contains(got, "q-3\r\n") || strings.Contains(got, "q-3\n") {
+			order := []string{"q-$((1))", "q-1", "q-$((2))", "q-2", "q-$((3))", "q-3"}
+			last := -1
+			for _, marker := range order {
+				idx := strings.Index(got[last+1:], marker)
+				if idx < 0 {
+					t.Fatalf("%q missing or out of order in output:\n%s", marker, got)
+				}
+				last += 1 + idx
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for queued commands, output:\n%s", got)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestScrollback verifies the ring buffer keeps the most recent lines in
+// order once it wraps
+func TestScrollback(t *testing.T) {
+	s := newScrollback(4)
+	s.add("one\ntwo")
+	if got := strings.Join(s.lines(), ","); got != "one,two" {
+		t.Errorf("lines() = %q, want %q", got, "one,two")
+	}
+	s.add("three\nfour\nfive")
+	if got := strings.Join(s.lines(), ","); got != "two,three,four,five" {
+		t.Errorf("lines() after wrap = %q, want %q", got, "two,three,four,five")
+	}
+	s.add("six\nseven\neight\nnine\nten")
+	if got := strings.Join(s.lines(), ","); got != "seven,eight,nine,ten" {
+		t.Errorf("lines() after overflow = %q, want %q", got, "seven,eight,nine,ten")
+	}
+}
+
+func TestParseGrepArgs(t *testing.T) {
+	tests := []struct {
+		arg     string
+		pattern string
+		limit   int
+		matches string
+		wantErr bool
+	}{
+		{arg: " error", pattern: "error", limit: defaultGrepResults, matches: "error"},
+		{arg: " -i error", pattern: "error", limit: defaultGrepResults, matches: "ERROR"},
+		{arg: " -m 5 -i exit code", pattern: "exit code", limit: 5, matches: "EXIT CODE 1"},
+		{arg: "", wantErr: true},
+		{arg: " -i", wantErr: true},
+		{arg: " -m x foo", wantErr: true},
+		{arg: " -m 0 foo", wantErr: true},
+		{arg: " (unclosed", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, err := parseGrepArgs(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGrepArgs(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if query.pattern != tt.pattern || query.limit != tt.limit {
+			t.Errorf("parseGrepArgs(%q) = %q, %d; want %q, %d", tt.arg, query.pattern, query.limit, tt.pattern, tt.limit)
+		}
+		if !query.re.MatchString(tt.matches) {
+			t.Errorf("parseGrepArgs(%q) pattern doesn't match %q", tt.arg, tt.matches)
+		}
+	}
+}
+
+// TestGrepScrollback searches a synthetic session buffer, including output
+// still on screen, and checks the result cap keeps the latest matches
+func TestGrepScrollback(t *testing.T) {
+	s := newScrollback(scrollbackLines)
+	for i := 1; i <= 100; i++ {
+		s.add(fmt.Sprintf("line %d ok", i))
+		if i%10 == 0 {
+			s.add(fmt.Sprintf("Error: step %d failed", i))
+		}
+	}
+	lines := s.lines()
+	lines = append(lines, unseenLines(lines, "line 100 ok\nerror: not flushed yet\n")...)
+
+	query, err := parseGrepArgs("-i -m 3 ^error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, total := grepLines(lines, query.re, query.limit)
+	if total != 11 {
+		t.Errorf("total = %d, want 11", total)
+	}
+	want := []string{"Error: step 90 failed", "Error: step 100 failed", "error: not flushed yet"}
+	if strings.Join(matches, "|") != strings.Join(want, "|") {
+		t.Errorf("matches = %q, want %q", matches, want)
+	}
+
+	// Without -i the unflushed lowercase line doesn't match
+	query, _ = parseGrepArgs("^error")
+	if _, total := grepLines(lines, query.re, query.limit); total != 1 {
+		t.Errorf("case-sensitive total = %d, want 1", total)
+	}
+}
+
+// TestSavePath verifies /save paths resolve against the session directory
+// and can't leave the file root
+func TestSavePath(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(root, "notes"), 0755)
+
+	if got, err := savePath(root, filepath.Join(root, "notes"), "answer.md"); err != nil || got != filepath.Join(root, "notes", "answer.md") {
+		t.Errorf("savePath(relative) = %q, %v", got, err)
+	}
+	if got, err := savePath(root, "", filepath.Join(root, "out.txt")); err != nil || got != filepath.Join(root, "out.txt") {
+		t.Errorf("savePath(absolute) = %q, %v", got, err)
+	}
+	if _, err := savePath(root, root, "../escape.txt"); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("escaping path error = %v", err)
+	}
+	if _, err := savePath(root, root, "missing/answer.md"); err == nil || !strings.Contains(err.Error(), "directory not found") {
+		t.Errorf("missing directory error = %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		outside := t.TempDir()
+		os.Symlink(outside, filepath.Join(root, "link"))
+		if _, err := savePath(root, root, "link/answer.md"); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("symlinked directory error = %v", err)
+		}
+	}
+}
+
+// TestWriteNewFile verifies /save reports the bytes written and never
+// overwrites an existing file
+func TestWriteNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	s := newScrollback(scrollbackLines)
+	s.add("first answer\nsecond line")
+
+	n, err := writeNewFile(path, strings.Join(s.lines(), "\n")+"\n")
+	if err != nil || n != len("first answer\nsecond line\n") {
+		t.Fatalf("writeNewFile() = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first answer\nsecond line\n" {
+		t.Errorf("saved %q", data)
+	}
+	if _, err := writeNewFile(path, "other"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("overwrite error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first answer\nsecond line\n" {
+		t.Errorf("existing file changed to %q", data)
+	}
+}
+
+// TestSessionLimitReached verifies only active sessions count toward
+// max_sessions and that a stopped session frees its slot
+func TestSessionLimitReached(t *testing.T) {
+	max := (&Config{MaxSessions: 2}).maxSessions()
+	sessions := map[int64]*Session{
+		1: {Active: true},
+		2: {Active: false},
+	}
+	if sessionLimitReached(sessions, max) {
+		t.Error("limit reached with 1 of 2 sessions active")
+	}
+	sessions[3] = &Session{Active: true}
+	if !sessionLimitReached(sessions, max) {
+		t.Error("third session allowed with max_sessions 2")
+	}
+	sessions[1].Active = false
+	if sessionLimitReached(sessions, max) {
+		t.Error("stopped session still counts toward the limit")
+	}
+	if sessionLimitReached(sessions, (&Config{MaxSessions: -1}).maxSessions()) {
+		t.Error("limit applied with max_sessions disabled")
+	}
+	if got := (*Config)(nil).maxSessions(); got != defaultMaxSessions {
+		t.Errorf("default maxSessions() = %d, want %d", got, defaultMaxSessions)
+	}
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/creack/pty"
 )
@@ -16,13 +26,110 @@ type OutputSink interface {
 	SendOutput(output string)
 }
 
+// statusSink is implemented by sinks that render status lines distinctly
+// from program output (e.g., WebSocketSink shows them in yellow).
+type statusSink interface {
+	SendStatus(status string)
+}
+
+// sendStatus delivers a status line, falling back to SendOutput for sinks
+// that don't distinguish status from output.
+func sendStatus(sink OutputSink, status string) {
+	if s, ok := sink.(statusSink); ok {
+		s.SendStatus(status)
+		return
+	}
+	sink.SendOutput(status)
+}
+
 // Terminal manages the PTY and streaming
 type Terminal struct {
-	ptmx       *os.File
-	cmd        *exec.Cmd
-	outputChan chan string
-	sink       OutputSink
-	done       chan struct{} // Signal to stop reading
+	ptmx        *os.File
+	cmd         *exec.Cmd
+	outputChan  chan string
+	sink        OutputSink
+	done        chan struct{}  // Signal to stop reading
+	maxWaitTime time.Duration  // StreamOutput gives up after this long
+	limiter     *outputLimiter // Caps output per command (reset by SendCommand)
+	rows, cols  int            // Initial window size, mirrored by StreamOutput's screen
+	direct      bool           // PTY child is the session command itself, not a shell
+	awaitExit   bool           // RunCommand appended an exit-code marker
+	outputMode  string         // How StreamOutput processes output (Config.OutputMode)
+
+	skippingInit atomic.Bool   // Output is discarded until the init command is done (runInit)
+	initDone     chan struct{} // Closed by readOutput once initDoneMarker has been read
+	initOutput   string        // Tail of discarded output, to find a marker split across reads
+}
+
+// outputLimiter caps how many bytes of output a single command may send,
+// so something like `cat bigfile` doesn't flood the sink. A nil limiter or
+// a zero max disables the cap.
+type outputLimiter struct {
+	mu        sync.Mutex
+	max       int
+	sent      int  // Bytes passed through to the sink
+	total     int  // Bytes produced, including suppressed output
+	truncated bool // Cap reached for the current command
+	notified  bool // Truncation notice already sent
+}
+
+func newOutputLimiter(max int) *outputLimiter {
+	return &outputLimiter{max: max}
+}
+
+// take returns the part of chunk that still fits under the cap, or "" once
+// the cap has been reached. Suppressed bytes still count toward the total.
+func (l *outputLimiter) take(chunk string) string {
+	if l == nil {
+		return chunk
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total += len(chunk)
+	if l.max <= 0 {
+		return chunk
+	}
+	if l.truncated {
+		return ""
+	}
+	if room := l.max - l.sent; len(chunk) > room {
+		// Don't split a multi-byte character
+		for room > 0 && !utf8.RuneStart(chunk[room]) {
+			room--
+		}
+		chunk = chunk[:room]
+		l.truncated = true
+	}
+	l.sent += len(chunk)
+	return chunk
+}
+
+// notice returns the truncation notice the first time it is called after
+// the cap was hit for the current command, and "" otherwise.
+func (l *outputLimiter) notice() string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.truncated || l.notified {
+		return ""
+	}
+	l.notified = true
+	return fmt.Sprintf("... output truncated (%d bytes total). Redirect to a file and use /get for the full output.", l.total)
+}
+
+// reset starts counting afresh for a new command.
+func (l *outputLimiter) reset() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sent, l.total = 0, 0
+	l.truncated, l.notified = false, false
 }
 
 // getCleanEnvironment returns environment variables filtered for clean terminal sessions
@@ -46,14 +153,248 @@ func getCleanEnvironment() []string {
 	return cleaned
 }
 
-// NewTerminal creates a new terminal instance
-func NewTerminal(sink OutputSink) (*Terminal, error) {
-	// Determine shell (platform-specific)
-	shellCmd, shellArgs := getShell()
+// resolveShell returns Config.Shell when it names an existing file,
+// otherwise the platform default from getShell. With Config.LoadShellRC
+// the shell starts as a login shell that loads the user's rc files.
+// config may be nil.
+func resolveShell(config *Config) (string, []string) {
+	loadRC := config != nil && config.LoadShellRC
+	if config != nil && config.Shell != "" {
+		if _, err := os.Stat(config.Shell); err == nil {
+			if loadRC {
+				return config.Shell, rcShellArgs()
+			}
+			return config.Shell, nil
+		}
+		log.Printf("Warning: configured shell %s not found, using default\n", config.Shell)
+	}
+	return getShell(loadRC)
+}
+
+// shellNotFoundError reports that no shell a session could run exists,
+// e.g. in a minimal container without bash or sh.
+type shellNotFoundError struct {
+	tried []string // Config.Shell, if set, then defaultShells
+}
+
+func newShellNotFoundError(config *Config) *shellNotFoundError {
+	var tried []string
+	if config != nil && config.Shell != "" {
+		tried = append(tried, config.Shell)
+	}
+	for _, sh := range defaultShells {
+		if len(tried) == 0 || sh != tried[0] {
+			tried = append(tried, sh)
+		}
+	}
+	return &shellNotFoundError{tried: tried}
+}
+
+func (e *shellNotFoundError) Error() string {
+	return fmt.Sprintf("no shell found (tried %s) — set \"shell\" in the config to an installed shell",
+		strings.Join(e.tried, ", "))
+}
+
+// terminalErrorText is the user-facing message for a terminal that could
+// not be created: text alone, plus the reason when no shell was found,
+// since that's something the user can fix.
+func terminalErrorText(text string, err error) string {
+	var notFound *shellNotFoundError
+	if errors.As(err, &notFound) {
+		return text + ": " + notFound.Error()
+	}
+	return text
+}
+
+// envList renders environment overrides as sorted KEY=VALUE entries.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// NewTerminal creates a new terminal instance. config may be nil
+// (standalone mode, tests, WebUI before setup). env holds per-session
+// overrides (e.g., from /env) and may be nil.
+func NewTerminal(sink OutputSink, config *Config, env map[string]string) (*Terminal, error) {
+	// Determine shell (Config.Shell, else platform-specific default)
+	shellCmd, shellArgs := resolveShell(config)
+	if _, err := exec.LookPath(shellCmd); err != nil {
+		return nil, newShellNotFoundError(config)
+	}
+	term, err := startTerminal(sink, config, env, exec.Command(shellCmd, shellArgs...))
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.SessionInitCommand != "" {
+		if isPOSIXShell(shellCmd) {
+			term.runInit(config.SessionInitCommand, sessionInitTimeout)
+		} else {
+			log.Printf("Warning: session_init_command needs a POSIX shell, not %s; skipped\n", shellCmd)
+		}
+	}
+	return term, nil
+}
+
+// sessionInitTimeout bounds how long a new shell waits for
+// Config.SessionInitCommand to finish before it is used anyway.
+const sessionInitTimeout = 10 * time.Second
+
+// initDoneCommand prints initDoneMarker once the init command has run.
+// printf builds the marker so the shell's echo of this line lacks it.
+const (
+	initDoneCommand = `printf '__INIT_%s__\n' DONE`
+	initDoneMarker  = "__INIT_DONE__"
+)
+
+// runInit types Config.SessionInitCommand into a new shell and waits for
+// it to finish. Its output, the shell's echo of it included, is discarded
+// so the first response only shows the user's command. After timeout the
+// terminal is handed over anyway, with whatever the command prints next.
+func (t *Terminal) runInit(command string, timeout time.Duration) {
+	t.initDone = make(chan struct{})
+	t.skippingInit.Store(true)
+	// On its own line, so a trailing ";" or "&" in command still parses
+	t.SendCommand(command + "\n" + initDoneCommand)
+
+	select {
+	case <-t.initDone:
+	case <-time.After(timeout):
+		t.skippingInit.Store(false)
+		log.Printf("Warning: session_init_command still running after %s, starting anyway\n", timeout)
+	}
+}
+
+// skipInit discards output read while the init command runs. Once
+// initDoneMarker arrives it returns what follows it, and output flows
+// normally again.
+func (t *Terminal) skipInit(output string) string {
+	t.initOutput += output
+	i := strings.Index(t.initOutput, initDoneMarker)
+	if i < 0 {
+		if keep := len(initDoneMarker); len(t.initOutput) > keep {
+			t.initOutput = t.initOutput[len(t.initOutput)-keep:]
+		}
+		return ""
+	}
+	rest := strings.TrimLeft(t.initOutput[i+len(initDoneMarker):], "\r\n")
+	t.initOutput = ""
+	t.skippingInit.Store(false)
+	close(t.initDone)
+	return rest
+}
+
+// NewDirectTerminal creates a terminal whose PTY child is argv itself
+// rather than a shell, for programs that expect a clean launch. The
+// terminal closes when the program exits.
+func NewDirectTerminal(sink OutputSink, config *Config, env map[string]string, argv []string) (*Terminal, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+	term, err := startTerminal(sink, config, env, exec.Command(argv[0], argv[1:]...))
+	if err != nil {
+		return nil, err
+	}
+	term.direct = true
+	return term, nil
+}
+
+// NewSessionTerminal creates the terminal for a session that starts with
+// command; Launch then runs it. With Config.DirectExec, an interactive
+// command that needs no shell features (pipes, variables, globs...) and
+// is found on PATH is exec'd directly; otherwise a shell is started.
+func NewSessionTerminal(sink OutputSink, config *Config, env map[string]string, command string) (*Terminal, error) {
+	if config != nil && config.DirectExec && isInteractiveCommand(command, config) {
+		if argv, ok := splitCommandLine(command); ok {
+			if _, err := exec.LookPath(argv[0]); err == nil {
+				return NewDirectTerminal(sink, config, env, argv)
+			}
+		}
+	}
+	return NewTerminal(sink, config, env)
+}
+
+// Launch runs a session's initial command: typed into the shell, or
+// nothing for a direct terminal, whose child already is the command.
+func (t *Terminal) Launch(command string) {
+	if !t.direct {
+		t.SendCommand(command)
+	}
+}
+
+// shellMetachars need a shell to interpret when they appear unquoted.
+const shellMetachars = "|&;<>()$`*?[]{}~"
+
+// splitCommandLine splits a command line into arguments, honoring single
+// and double quotes and backslash escapes. It returns false for lines
+// that need a real shell: unquoted metacharacters, variable expansion,
+// leading VAR=value assignments, or unbalanced quotes.
+func splitCommandLine(line string) ([]string, bool) {
+	var args []string
+	var cur strings.Builder
+	inArg := false // cur holds an argument, possibly empty ("")
+	escaped := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			case '$', '`':
+				return nil, false
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case strings.ContainsRune(shellMetachars, r):
+			return nil, false
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, false
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 || strings.Contains(args[0], "=") {
+		return nil, false
+	}
+	return args, true
+}
 
-	// Start shell in PTY with full TTY environment
+// startTerminal starts cmd in a PTY with the session environment and
+// begins reading its output.
+func startTerminal(sink OutputSink, config *Config, env map[string]string, cmd *exec.Cmd) (*Terminal, error) {
+	// Start in PTY with full TTY environment
 	// Use cleaned environment to allow independent sessions (e.g., Claude in browser while running in Claude)
-	cmd := exec.Command(shellCmd, shellArgs...)
 	cmd.Env = append(getCleanEnvironment(),
 		// Terminal type and capabilities
 		"TERM=xterm-256color",
@@ -75,22 +416,41 @@ func NewTerminal(sink OutputSink) (*Terminal, error) {
 		"INTERACTIVE=1",
 		"IS_TTY=1",
 	)
-	
+	// Start in Config.WorkingDir if it exists (otherwise inherit ours)
+	if config != nil && config.WorkingDir != "" {
+		if info, err := os.Stat(config.WorkingDir); err == nil && info.IsDir() {
+			cmd.Dir = config.WorkingDir
+		} else {
+			log.Printf("Warning: working directory %s not found, using default\n", config.WorkingDir)
+		}
+	}
+
 	// Set platform-specific process attributes for TTY support
 	setProcAttr(cmd)
 
+	// Drop to Config.RunAsUser (e.g. daemon as root, commands as a restricted user)
+	if config != nil && config.RunAsUser != "" {
+		if err := setRunAsUser(cmd, config.RunAsUser); err != nil {
+			return nil, err
+		}
+	}
+
+	// Overrides go last: exec keeps the last value for duplicate keys
+	cmd.Env = append(cmd.Env, envList(env)...)
+
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set terminal window size - crucial for interactive programs
-	// Using generous dimensions for modern terminal applications
+	// Defaults to generous dimensions for modern terminal applications
+	rows, cols := config.terminalSize()
 	ws := &pty.Winsize{
-		Rows: 50,   // Height - enough for most interactive UIs
-		Cols: 120,  // Width - standard wide terminal
-		X:    0,    // Pixel width (optional)
-		Y:    0,    // Pixel height (optional)
+		Rows: uint16(rows), // Height - enough for most interactive UIs
+		Cols: uint16(cols), // Width - standard wide terminal
+		X:    0,            // Pixel width (optional)
+		Y:    0,            // Pixel height (optional)
 	}
 	if err := pty.Setsize(ptmx, ws); err != nil {
 		log.Printf("Warning: couldn't set terminal size: %v\n", err)
@@ -101,11 +461,16 @@ func NewTerminal(sink OutputSink) (*Terminal, error) {
 	// Note: We don't set the master PTY to raw since we're reading from it
 
 	term := &Terminal{
-		ptmx:       ptmx,
-		cmd:        cmd,
-		outputChan: make(chan string, 100),
-		sink:       sink,
-		done:       make(chan struct{}),
+		ptmx:        ptmx,
+		cmd:         cmd,
+		outputChan:  make(chan string, 100),
+		sink:        sink,
+		done:        make(chan struct{}),
+		maxWaitTime: defaultCommandTimeout,
+		limiter:     newOutputLimiter(config.maxOutputBytes()),
+		rows:        rows,
+		cols:        cols,
+		outputMode:  config.outputMode(),
 	}
 
 	// Start reading output first
@@ -137,7 +502,9 @@ func (t *Terminal) readOutput() {
 			
 			n, err := t.ptmx.Read(buf)
 			if err != nil {
-				if err == io.EOF {
+				// Linux reports EIO rather than EOF once the PTY child has
+				// exited and the slave side is gone
+				if err == io.EOF || errors.Is(err, syscall.EIO) {
 					// Terminal closed cleanly
 					close(t.outputChan)
 					return
@@ -149,6 +516,11 @@ func (t *Terminal) readOutput() {
 
 			if n > 0 {
 				output := string(buf[:n])
+				if t.skippingInit.Load() {
+					if output = t.skipInit(output); output == "" {
+						continue
+					}
+				}
 				select {
 				case t.outputChan <- output:
 					// Sent successfully
@@ -164,6 +536,9 @@ func (t *Terminal) readOutput() {
 
 // SendCommand sends a command to the terminal
 func (t *Terminal) SendCommand(command string) {
+	// Each command gets a fresh output budget
+	t.limiter.reset()
+
 	// Write text and Enter as SEPARATE PTY writes with a small delay.
 	//
 	// Why: TUI apps like Claude Code use Ink (React for CLI), whose input parser
@@ -180,6 +555,93 @@ func (t *Terminal) SendCommand(command string) {
 	t.ptmx.Write([]byte("\r"))
 }
 
+// exitMarkerSuffix is appended to one-shot commands so the shell prints
+// the exit status once the command finishes.
+const exitMarkerSuffix = `; echo "__EXIT_$?__"`
+
+var (
+	// exitMarkerPattern matches the status line exitMarkerSuffix prints.
+	exitMarkerPattern = regexp.MustCompile(`(?m)^__EXIT_(\d+)__[ \t\r]*\n?`)
+
+	// exitEchoPattern matches exitMarkerSuffix in the shell's echo of the
+	// command line, which may wrap anywhere at the terminal width.
+	exitEchoPattern = regexp.MustCompile(wrappedLiteral(exitMarkerSuffix))
+)
+
+// wrappedLiteral returns a pattern matching s with optional line breaks
+// between its characters.
+func wrappedLiteral(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 {
+			b.WriteString(`(?:\r?\n)?`)
+		}
+		b.WriteString(regexp.QuoteMeta(string(r)))
+	}
+	return b.String()
+}
+
+// posixShells can run exitMarkerSuffix and initDoneCommand.
+var posixShells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "mksh": true, "ash": true,
+}
+
+// isPOSIXShell reports whether the shell binary at path is one of posixShells.
+func isPOSIXShell(path string) bool {
+	return posixShells[strings.TrimSuffix(filepath.Base(path), ".exe")]
+}
+
+// withExitMarker appends exitMarkerSuffix to command. A trailing ";" is
+// dropped and a trailing "&" is followed by a space instead, since
+// "cmd &;" and "cmd;;" are syntax errors.
+func withExitMarker(command string) string {
+	command = strings.TrimRight(command, " \t")
+	if trimmed := strings.TrimRight(command, ";"); trimmed != command && !strings.HasSuffix(trimmed, ";") {
+		command = strings.TrimRight(trimmed, " \t")
+	}
+	if strings.HasSuffix(command, "&") && !strings.HasSuffix(command, "&&") {
+		return command + " " + strings.TrimPrefix(exitMarkerSuffix, "; ")
+	}
+	return command + exitMarkerSuffix
+}
+
+// RunCommand sends a one-shot command whose exit status StreamOutput
+// reports once it finishes. Only POSIX shells support this; elsewhere it
+// is the same as SendCommand. Interactive sessions use SendCommand, since
+// their commands don't finish with a status.
+func (t *Terminal) RunCommand(command string) {
+	t.awaitExit = !t.direct && isPOSIXShell(t.cmd.Path)
+	if t.awaitExit {
+		command = withExitMarker(command)
+	}
+	t.SendCommand(command)
+}
+
+// takeExitStatus strips the exit-code marker and its echo from output.
+// It returns the exit code once the marker has been printed.
+func (t *Terminal) takeExitStatus(output string) (string, int, bool) {
+	if !t.awaitExit {
+		return output, 0, false
+	}
+	output = exitEchoPattern.ReplaceAllString(output, "")
+	m := exitMarkerPattern.FindAllStringSubmatch(output, -1)
+	if m == nil {
+		return output, 0, false
+	}
+	output = exitMarkerPattern.ReplaceAllString(output, "")
+	code, _ := strconv.Atoi(m[len(m)-1][1])
+	t.awaitExit = false
+	return output, code, true
+}
+
+// exitStatus is the footer reported for a one-shot command's exit code.
+func exitStatus(code int) string {
+	if code == 0 {
+		return "✅ exit 0"
+	}
+	return fmt.Sprintf("❌ exit %d", code)
+}
+
 // SendRawInput sends raw input to the PTY without adding newline
 // Used for character-by-character input from terminal emulator
 func (t *Terminal) SendRawInput(input string) {
@@ -195,59 +657,174 @@ func (t *Terminal) Resize(rows, cols int) error {
 	return pty.Setsize(t.ptmx, ws)
 }
 
+// PID returns the shell's process ID, or 0 if it isn't running.
+func (t *Terminal) PID() int {
+	if t == nil || t.cmd == nil || t.cmd.Process == nil {
+		return 0
+	}
+	return t.cmd.Process.Pid
+}
+
+// processDir returns the working directory of a process, or "" where it
+// can't be read (non-Linux systems, exited processes).
+func processDir(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// SetCommandTimeout sets how long StreamOutput waits before abandoning a
+// one-shot command. Non-positive values keep the current timeout.
+func (t *Terminal) SetCommandTimeout(d time.Duration) {
+	if d > 0 {
+		t.maxWaitTime = d
+	}
+}
+
+// stripEscapes removes ANSI escape sequences and carriage returns from
+// output, keeping its spacing so columns still line up.
+func stripEscapes(output string) string {
+	var b strings.Builder
+	for i := 0; i < len(output); {
+		if output[i] == '\x1b' {
+			end := escapeEnd(output, i)
+			if end < 0 {
+				break
+			}
+			i = end
+			continue
+		}
+		if output[i] != '\r' {
+			b.WriteByte(output[i])
+		}
+		i++
+	}
+	return b.String()
+}
+
 // StreamOutput streams output to the sink with smart chunking.
-// Uses a virtual terminal emulator to correctly interpret ANSI cursor
-// positioning, so TUI program output renders as readable text.
+// By default it uses a virtual terminal emulator to correctly interpret
+// ANSI cursor positioning, so TUI program output renders as readable
+// text; Config.OutputMode can instead strip escape codes or pass output
+// through untouched.
+// A command sent with RunCommand is waited on until it prints its exit
+// status, however long it stays quiet; other output stops after a few
+// seconds of silence. If the command runs past the command timeout, the
+// terminal is closed.
+// Output beyond Config.MaxOutputBytes is dropped with a truncation notice.
 func (t *Terminal) StreamOutput() {
-	screen := NewScreenReader(120, 50)
+	screen := NewScreenReader(t.cols, t.rows)
+	pending := "" // Output not yet sent, outside vte mode
 	lastOutputTime := time.Now()
 	hasNewData := false
 
+	// next returns the output to send since the last call. Outside vte
+	// mode an escape sequence or rune split across reads is held back
+	// until the rest arrives, or until the last call.
+	next := func(final bool) string {
+		if t.outputMode == outputModeVTE {
+			return screen.Diff()
+		}
+		ready, held := splitIncompleteTail(pending)
+		if final || len(held) > maxHeldOutput {
+			ready, held = pending, ""
+		}
+		pending = held
+		if t.outputMode == outputModeStrip {
+			return stripEscapes(ready)
+		}
+		return ready
+	}
+
 	// Tunable parameters
 	silenceThreshold := 1500 * time.Millisecond  // Send chunk after 1.5s silence
-	finalSilenceThreshold := 3 * time.Second      // Stop after 3s total silence
-	maxWaitTime := 30 * time.Second               // Max 30s total
+	finalSilenceThreshold := 3 * time.Second      // Stop after 3s total silence (no exit marker)
+	maxWaitTime := t.maxWaitTime                  // Max total (Config.CommandTimeout)
 
 	startTime := time.Now()
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 
+	// flush sends the settled screen diff, reporting whether a one-shot
+	// command printed its exit status. The marker is stripped before the
+	// output cap applies, so a truncated command still reports it.
+	flush := func(final bool) bool {
+		diff, code, exited := t.takeExitStatus(next(final))
+		if diff = t.limiter.take(diff); diff != "" {
+			t.sink.SendOutput(diff)
+		}
+		if exited {
+			t.sendTruncationNotice()
+			sendStatus(t.sink, exitStatus(code))
+		}
+		return exited
+	}
+
 	for {
 		select {
-		case output := <-t.outputChan:
-			screen.Write([]byte(output))
+		case output, ok := <-t.outputChan:
+			if !ok {
+				// The shell exited (e.g. `exit`): send what's left and
+				// return now instead of waiting out the silence timers
+				if hasNewData && flush(true) {
+					return
+				}
+				t.sendTruncationNotice()
+				return
+			}
+			if t.outputMode == outputModeVTE {
+				screen.Write([]byte(output))
+			} else {
+				pending += output
+			}
 			hasNewData = true
 			lastOutputTime = time.Now()
 
 		case <-ticker.C:
 			// Send screen diff if output has settled
 			if hasNewData && time.Since(lastOutputTime) > silenceThreshold {
-				diff := screen.Diff()
-				if diff != "" {
-					t.sink.SendOutput(diff)
-				}
 				hasNewData = false
+				if flush(false) {
+					// The command finished — no need to wait out the silence
+					return
+				}
 			}
 
-			// Stop if max total time reached
+			// Stop if max total time reached — the command is still running,
+			// so close the terminal rather than leave it attached to the PTY
 			if time.Since(startTime) > maxWaitTime {
-				if hasNewData {
-					diff := screen.Diff()
-					if diff != "" {
-						t.sink.SendOutput(diff)
-					}
+				if hasNewData && flush(true) {
+					return
 				}
+				t.sendTruncationNotice()
+				sendStatus(t.sink, fmt.Sprintf("⏱️ command timed out after %s", maxWaitTime.Round(time.Second)))
+				t.Close()
 				return
 			}
 
-			// Stop only after long silence with no pending data
-			if !hasNewData && time.Since(lastOutputTime) > finalSilenceThreshold {
+			// Stop only after long silence with no pending data. A one-shot
+			// command is still running until its exit marker arrives, so
+			// only the command timeout bounds it
+			if !t.awaitExit && !hasNewData && time.Since(lastOutputTime) > finalSilenceThreshold {
+				t.sendTruncationNotice()
 				return
 			}
 		}
 	}
 }
 
+// sendTruncationNotice tells the sink the command's output was capped, if it was.
+func (t *Terminal) sendTruncationNotice() {
+	if notice := t.limiter.notice(); notice != "" {
+		sendStatus(t.sink, notice)
+	}
+}
+
 // Close closes the terminal and all child processes
 func (t *Terminal) Close() {
 	// Signal readOutput to stop
@@ -272,6 +849,16 @@ func (t *Terminal) Close() {
 	// outputChan is closed by readOutput() when it exits
 }
 
+// isClosed reports whether Close has been called (e.g., after a command timeout).
+func (t *Terminal) isClosed() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // ConsoleSink writes output to console (for testing)
 type ConsoleSink struct{}
 
@@ -3,21 +3,44 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 )
 
-// getShell returns the shell command and arguments for Unix systems
-func getShell() (string, []string) {
-	shellCmd := "/bin/bash"
-	shellArgs := []string{"--norc", "--noprofile"}
-	if _, err := os.Stat(shellCmd); err != nil {
-		shellCmd = "/bin/sh"
-		shellArgs = []string{}
+// defaultShells are the shells tried, in order, when Config.Shell is
+// unset or missing.
+var defaultShells = []string{"/bin/bash", "/bin/sh"}
+
+// getShell returns the shell command and arguments for Unix systems: the
+// first of defaultShells that exists, or the last one if none do.
+// Unless loadRC is set, bash skips the user's rc and profile files.
+func getShell(loadRC bool) (string, []string) {
+	shellCmd := defaultShells[len(defaultShells)-1]
+	for _, sh := range defaultShells {
+		if _, err := os.Stat(sh); err == nil {
+			shellCmd = sh
+			break
+		}
+	}
+	if loadRC {
+		return shellCmd, rcShellArgs()
 	}
-	return shellCmd, shellArgs
+	if filepath.Base(shellCmd) == "bash" {
+		return shellCmd, []string{"--norc", "--noprofile"}
+	}
+	return shellCmd, []string{}
+}
+
+// rcShellArgs start a shell as an interactive login shell, so it reads
+// the user's profile (and, through it, usually the rc file).
+func rcShellArgs() []string {
+	return []string{"-l", "-i"}
 }
 
 // setProcAttr sets Unix-specific process attributes for TTY support
@@ -28,6 +51,47 @@ func setProcAttr(cmd *exec.Cmd) {
 	}
 }
 
+// setRunAsUser makes cmd run as the named user by adding its UID, GID and
+// supplementary groups to the process credentials, keeping Setsid/Setctty.
+// HOME, USER and LOGNAME are pointed at the target user. Switching to
+// another user requires running as root.
+func setRunAsUser(cmd *exec.Cmd, name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("run_as_user %q: %w", name, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("run_as_user %q: invalid uid %q", name, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("run_as_user %q: invalid gid %q", name, u.Gid)
+	}
+
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if os.Geteuid() == 0 {
+		groupIDs, _ := u.GroupIds()
+		for _, g := range groupIDs {
+			if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+				cred.Groups = append(cred.Groups, uint32(id))
+			}
+		}
+	} else if int(uid) == os.Geteuid() {
+		// Already this user: nothing to switch, and setgroups would fail
+		cred.NoSetGroups = true
+	} else {
+		return fmt.Errorf("run_as_user %q: switching users requires running as root", name)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = cred
+	cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
 // killProcessGroup terminates the process and its children using Unix signals.
 // Since we used Setsid, killing the negative PID targets the entire session group.
 func killProcessGroup(cmd *exec.Cmd) {
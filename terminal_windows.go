@@ -3,25 +3,45 @@
 package main
 
 import (
+	"fmt"
 	"os/exec"
 	"strconv"
 	"time"
 )
 
-// getShell returns the shell command and arguments for Windows
-func getShell() (string, []string) {
+// defaultShells are the shells tried, in order, when Config.Shell is
+// unset or missing.
+var defaultShells = []string{"powershell.exe", "cmd.exe"}
+
+// getShell returns the shell command and arguments for Windows.
+// Unless loadRC is set, PowerShell skips the user's profile.
+func getShell(loadRC bool) (string, []string) {
 	// Prefer PowerShell if available
 	if _, err := exec.LookPath("powershell.exe"); err == nil {
+		if loadRC {
+			return "powershell.exe", []string{"-NoLogo"}
+		}
 		return "powershell.exe", []string{"-NoProfile", "-NoLogo"}
 	}
 	return "cmd.exe", []string{}
 }
 
+// rcShellArgs is empty on Windows: a configured shell loads its profile
+// by default.
+func rcShellArgs() []string {
+	return nil
+}
+
 // setProcAttr is a no-op on Windows — ConPTY handles terminal setup
 func setProcAttr(cmd *exec.Cmd) {
 	// No Unix-specific TTY attributes needed on Windows
 }
 
+// setRunAsUser is unsupported on Windows.
+func setRunAsUser(cmd *exec.Cmd, name string) error {
+	return fmt.Errorf("run_as_user is not supported on Windows")
+}
+
 // killProcessGroup terminates the process tree on Windows using taskkill
 func killProcessGroup(cmd *exec.Cmd) {
 	if cmd.Process == nil {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// transcript records a session's raw PTY output when Config.RecordSessions
+// is set, like script(1) with timing folded in: one line per chunk read,
+// the time followed by the chunk quoted so escape codes and newlines
+// survive intact.
+type transcript struct {
+	f *os.File
+}
+
+// transcriptDir returns where session transcripts are written.
+func transcriptDir() string {
+	return filepath.Join(getConfigDir(), "transcripts")
+}
+
+// transcriptPath returns the transcript file for a session of chatID
+// starting at start.
+func transcriptPath(chatID int64, start time.Time) string {
+	return filepath.Join(transcriptDir(), fmt.Sprintf("%d-%s.log", chatID, start.Format("20060102-150405")))
+}
+
+// openTranscript creates the transcript for a session of chatID with 0600
+// permissions. A session started in the same second appends to the file.
+func openTranscript(chatID int64) (*transcript, error) {
+	if err := os.MkdirAll(transcriptDir(), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(transcriptPath(chatID, time.Now()), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &transcript{f: f}, nil
+}
+
+// startTranscript opens a transcript for chatID's session if config asks
+// for one. It returns nil otherwise, or if the file can't be created.
+func startTranscript(config *Config, chatID int64) *transcript {
+	if config == nil || !config.RecordSessions {
+		return nil
+	}
+	tr, err := openTranscript(chatID)
+	if err != nil {
+		log.Printf("Warning: can't record session %d: %v\n", chatID, err)
+		return nil
+	}
+	return tr
+}
+
+// write appends a chunk of output. A nil transcript records nothing.
+func (tr *transcript) write(output string) {
+	if tr == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(tr.f, "%s %q\n", time.Now().UTC().Format(time.RFC3339Nano), output); err != nil {
+		log.Printf("Warning: transcript write failed: %v\n", err)
+	}
+}
+
+// close closes the transcript file.
+func (tr *transcript) close() {
+	if tr == nil {
+		return
+	}
+	if err := tr.f.Close(); err != nil {
+		log.Printf("Warning: transcript close failed: %v\n", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTranscript verifies a transcript is private and records each chunk
+// of raw output with a timestamp, escape codes included
+func TestTranscript(t *testing.T) {
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = "" }()
+
+	if tr := startTranscript(&Config{}, 42); tr != nil {
+		t.Fatal("transcript started without record_sessions")
+	}
+	tr := startTranscript(&Config{RecordSessions: true}, 42)
+	if tr == nil {
+		t.Fatal("startTranscript() = nil with record_sessions")
+	}
+	tr.write("hello\r\n")
+	tr.write("\x1b[31mred\x1b[0m")
+	tr.close()
+
+	files, _ := filepath.Glob(filepath.Join(transcriptDir(), "42-*.log"))
+	if len(files) != 1 {
+		t.Fatalf("transcript files = %v, want one", files)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("transcript mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(files[0])
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ` "hello\r\n"`) || !strings.HasSuffix(lines[1], ` "\x1b[31mred\x1b[0m"`) {
+		t.Errorf("transcript = %q", data)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, strings.Fields(lines[0])[0]); err != nil {
+		t.Errorf("transcript line without timestamp: %q", lines[0])
+	}
+}
+
+// TestWebUISessionTranscript verifies a WebUI session's output ends up in
+// its transcript once the session ends
+func TestWebUISessionTranscript(t *testing.T) {
+	srv, ts, cleanup := newTestServer(&Config{RecordSessions: true})
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+	id := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+	if err := conn.WriteJSON(WebMessage{Type: "command", Content: "echo transcript-$((6*7))"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readUntil(t, conn, func(m WebMessage) bool { return strings.Contains(m.Content, "transcript-42") })
+	srv.cleanup(id)
+
+	// The transcript is closed by the streaming goroutine as it exits
+	pattern := filepath.Join(transcriptDir(), "*.log")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, _ := filepath.Glob(pattern)
+		if len(files) == 1 {
+			if data, _ := os.ReadFile(files[0]); strings.Contains(string(data), "transcript-42") {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no transcript with the session's output in %s (found %v)", transcriptDir(), files)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
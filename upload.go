@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploadTokenTTL is how long a /upload link stays valid.
+const uploadTokenTTL = 10 * time.Minute
+
+// maxWebUploadSize caps files sent through a WebUI upload link. It is far
+// above the Bot API limit, since large files are what the link is for.
+const maxWebUploadSize = 1 << 30
+
+// errWebUINotRunning is returned when no WebUI has published its address.
+var errWebUINotRunning = errors.New("WebUI is not running")
+
+// uploadStore is the state shared between the Telegram bridge, which issues
+// upload links, and the WebUI server, which redeems them. The two normally
+// run as separate processes, so it is persisted in the config directory.
+type uploadStore struct {
+	URL    string               `json:"url"`    // Base URL of the running WebUI
+	Tokens map[string]time.Time `json:"tokens"` // token → expiry
+}
+
+// uploadStoreMu serializes load-modify-save cycles within a process.
+var uploadStoreMu sync.Mutex
+
+// uploadStorePath returns the file holding the shared upload state.
+func uploadStorePath() string {
+	return filepath.Join(getConfigDir(), "upload-tokens.json")
+}
+
+// loadUploadStore reads the shared upload state, dropping expired tokens.
+// A missing or corrupt file yields an empty store. Caller must hold uploadStoreMu.
+func loadUploadStore() *uploadStore {
+	store := &uploadStore{Tokens: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(uploadStorePath())
+	if err != nil {
+		return store
+	}
+	var stored uploadStore
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return store
+	}
+
+	store.URL = stored.URL
+	now := time.Now()
+	for token, expiry := range stored.Tokens {
+		if now.Before(expiry) {
+			store.Tokens[token] = expiry
+		}
+	}
+	return store
+}
+
+// save persists the store with 0600 permissions. Caller must hold uploadStoreMu.
+func (u *uploadStore) save() error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(uploadStorePath(), data, 0600)
+}
+
+// publishUploadURL records the address the WebUI is reachable at, so the
+// Telegram bridge can build links to it.
+func publishUploadURL(url string) error {
+	uploadStoreMu.Lock()
+	defer uploadStoreMu.Unlock()
+
+	store := loadUploadStore()
+	store.URL = strings.TrimSuffix(url, "/")
+	return store.save()
+}
+
+// issueUploadLink creates a single-use upload token and returns the full
+// WebUI link for it.
+func issueUploadLink() (string, error) {
+	uploadStoreMu.Lock()
+	defer uploadStoreMu.Unlock()
+
+	store := loadUploadStore()
+	if store.URL == "" {
+		return "", errWebUINotRunning
+	}
+	token := generateSessionToken()
+	store.Tokens[token] = time.Now().Add(uploadTokenTTL)
+	if err := store.save(); err != nil {
+		return "", err
+	}
+	return store.URL + "/upload?token=" + token, nil
+}
+
+// uploadTokenValid reports whether token is issued and unexpired, without
+// consuming it.
+func uploadTokenValid(token string) bool {
+	if token == "" {
+		return false
+	}
+	uploadStoreMu.Lock()
+	defer uploadStoreMu.Unlock()
+
+	_, ok := loadUploadStore().Tokens[token]
+	return ok
+}
+
+// redeemUploadToken consumes token, reporting whether it was valid.
+func redeemUploadToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	uploadStoreMu.Lock()
+	defer uploadStoreMu.Unlock()
+
+	store := loadUploadStore()
+	if _, ok := store.Tokens[token]; !ok {
+		return false
+	}
+	delete(store.Tokens, token)
+	return store.save() == nil
+}
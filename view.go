@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxViewLen is the most formatted text /view sends, leaving room in the
+// message for the truncation notice.
+const maxViewLen = 3600
+
+// viewLanguages maps file extensions to the language-* class Telegram
+// highlights code blocks with.
+var viewLanguages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".rs":   "rust",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".java": "java",
+	".kt":   "kotlin",
+	".rb":   "ruby",
+	".php":  "php",
+	".sh":   "bash",
+	".bash": "bash",
+	".zsh":  "bash",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "toml",
+	".xml":  "xml",
+	".html": "html",
+	".css":  "css",
+	".sql":  "sql",
+	".md":   "markdown",
+	".diff": "diff",
+}
+
+// viewFileNames maps extensionless file names to their language.
+var viewFileNames = map[string]string{
+	"Makefile":   "makefile",
+	"Dockerfile": "dockerfile",
+	".bashrc":    "bash",
+	".zshrc":     "bash",
+	".profile":   "bash",
+}
+
+// viewLanguage returns the code block language for path, or "" when the
+// file type isn't known.
+func viewLanguage(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := viewFileNames[base]; ok {
+		return lang
+	}
+	return viewLanguages[strings.ToLower(filepath.Ext(base))]
+}
+
+// truncateForView returns the leading whole lines of content whose HTML
+// escaped form fits in maxLen, and whether anything was cut.
+func truncateForView(content string, maxLen int) (string, bool) {
+	if len(html.EscapeString(content)) <= maxLen {
+		return content, false
+	}
+	size := 0
+	end := 0
+	for end < len(content) {
+		next := strings.IndexByte(content[end:], '\n') + 1
+		if next == 0 {
+			next = len(content) - end
+		}
+		lineLen := len(html.EscapeString(content[end : end+next]))
+		if size+lineLen > maxLen {
+			break
+		}
+		size += lineLen
+		end += next
+	}
+	if end == 0 {
+		// One huge first line: cut it on a rune boundary
+		return strings.ToValidUTF8(content[:min(len(content), maxLen/6)], ""), true
+	}
+	return content[:end], true
+}
+
+// formatView renders a file's content as a code block for /view, with a
+// notice pointing at /get when it had to be cut short. The block is built
+// directly rather than as a ``` fence, so files containing ``` (Markdown)
+// don't end it early.
+func formatView(arg, content string, size int64) string {
+	shown, truncated := truncateForView(content, maxViewLen)
+	if int64(len(content)) < size {
+		truncated = true
+	}
+	text := codeBlockHTML(codeBlock{language: viewLanguage(arg), code: strings.TrimRight(shown, "\n")})
+	if truncated {
+		lines := strings.Count(strings.TrimRight(shown, "\n"), "\n") + 1
+		text += fmt.Sprintf("\n✂️ Showing the first %d lines (%s is %d bytes) — /get %s for the whole file",
+			lines, html.EscapeString(arg), size, html.EscapeString(arg))
+	}
+	return text
+}
+
+// viewFile handles /view <path>: a text file shown in the chat as a
+// highlighted code block. Relative paths are taken from the session's
+// directory.
+func (tb *TelegramBridge) viewFile(chatID int64, username, arg string) {
+	if arg == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /view <path>"))
+		return
+	}
+	path, err := resolveFileArg(tb.sessionDir(chatID), arg, "/view shows a single file")
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	fmt.Printf("📱 @%s → [view] %s\n\n", username, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	// Only the start is ever shown; a long file is read no further
+	data, err := io.ReadAll(io.LimitReader(f, 2*maxViewLen))
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	if len(data) == 0 {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📄 %s is empty", arg)))
+		return
+	}
+	if looksBinary(string(data), tb.currentConfig().binaryThresholdPercent()) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %s looks like a binary file — use /get %s", arg, arg)))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatView(arg, string(data), info.Size()))
+	msg.ParseMode = "HTML"
+	if _, err := tb.bot.Send(msg); err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to show file: "+err.Error()))
+	}
+}
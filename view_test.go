@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewLanguage(t *testing.T) {
+	tests := []struct{ path, want string }{
+		{"main.go", "go"},
+		{"scripts/run.py", "python"},
+		{"/srv/app/index.js", "javascript"},
+		{"README.MD", "markdown"},
+		{"Makefile", "makefile"},
+		{"notes.txt", ""},
+		{"LICENSE", ""},
+	}
+	for _, tt := range tests {
+		if got := viewLanguage(tt.path); got != tt.want {
+			t.Errorf("viewLanguage(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFormatView(t *testing.T) {
+	src := "package main\n\nfunc main() {}\n"
+	got := formatView("main.go", src, int64(len(src)))
+	want := "<pre><code class=\"language-go\">package main\n\nfunc main() {}</code></pre>"
+	if got != want {
+		t.Errorf("formatView = %q, want %q", got, want)
+	}
+
+	// Fences inside the file don't end the block
+	if got := formatView("a.md", "```go\nx < y\n```\n", 17); !strings.Contains(got, "```go\nx &lt; y\n```") {
+		t.Errorf("formatView(markdown) = %q", got)
+	}
+	if got := formatView("notes.txt", "plain\n", 6); !strings.HasPrefix(got, "<pre><code>plain") {
+		t.Errorf("formatView(unknown type) = %q", got)
+	}
+}
+
+func TestFormatViewTruncates(t *testing.T) {
+	content := strings.Repeat("line of text\n", 1000)
+	got := formatView("log.py", content, int64(len(content)))
+	if !strings.Contains(got, "/get log.py for the whole file") {
+		t.Errorf("missing truncation notice: %q", got[len(got)-100:])
+	}
+	if len(got) > maxMessageLen {
+		t.Errorf("formatView length = %d, want <= %d", len(got), maxMessageLen)
+	}
+	if !strings.Contains(got, "line of text</code></pre>") {
+		t.Errorf("truncated mid-line: %q", got[len(got)-150:])
+	}
+
+	// Content read short of the file's size is still reported as cut
+	if got := formatView("big.go", "package big\n", 1<<20); !strings.Contains(got, "Showing the first 1 lines") {
+		t.Errorf("formatView(partial read) = %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// waitPollInterval is how often /wait checks whether the process is gone.
+const waitPollInterval = 500 * time.Millisecond
+
+// waitForExit polls pid every interval until it exits or timeout elapses,
+// and reports whether it exited.
+func waitForExit(pid int, timeout, interval time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for isProcessAlive(pid) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(interval)
+	}
+	return true
+}
+
+// parseWaitPID parses the /wait argument. PID 1 never exits, so it is
+// refused like any other invalid PID. Errors are user-facing.
+func parseWaitPID(arg string) (int, error) {
+	pid, err := strconv.Atoi(arg)
+	if err != nil || pid <= 1 {
+		return 0, fmt.Errorf("invalid PID: %q", arg)
+	}
+	return pid, nil
+}
+
+// waitProcess handles /wait <pid>: the chat is told when a background job
+// (make &) finishes, or that it is still running once the command timeout
+// has passed. The wait runs in the background so the chat stays usable.
+func (tb *TelegramBridge) waitProcess(chatID int64, username, arg string) {
+	if runtime.GOOS == "windows" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ /wait is not supported on "+runtime.GOOS))
+		return
+	}
+	if arg == "" {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "Usage: /wait <pid>"))
+		return
+	}
+	pid, err := parseWaitPID(arg)
+	if err != nil {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	if !isProcessAlive(pid) {
+		tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ No running process with PID %d", pid)))
+		return
+	}
+	tb.mu.RLock()
+	timeout := tb.config.commandTimeout()
+	tb.mu.RUnlock()
+	fmt.Printf("📱 @%s → [wait] %d\n\n", username, pid)
+	tb.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Waiting for PID %d (up to %s)", pid, timeout)))
+
+	go func() {
+		start := time.Now()
+		if waitForExit(pid, timeout, waitPollInterval) {
+			tb.bot.Send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("✅ PID %d finished after %s", pid, time.Since(start).Round(time.Second))))
+			return
+		}
+		tb.bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("⌛ PID %d is still running after %s — /wait %d again to keep waiting", pid, timeout, pid)))
+	}()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseWaitPID(t *testing.T) {
+	if pid, err := parseWaitPID("1234"); err != nil || pid != 1234 {
+		t.Errorf("parseWaitPID(1234) = %d, %v", pid, err)
+	}
+	for _, arg := range []string{"", "abc", "-5", "0", "1"} {
+		if _, err := parseWaitPID(arg); err == nil {
+			t.Errorf("parseWaitPID(%q) succeeded, want error", arg)
+		}
+	}
+}
+
+func TestWaitForExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("isProcessAlive is a stub on Windows")
+	}
+	short := exec.Command("sleep", "0.2")
+	if err := short.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Reap the child, or it lingers as a zombie that still looks alive
+	go short.Wait()
+	if !waitForExit(short.Process.Pid, 5*time.Second, 10*time.Millisecond) {
+		t.Error("waitForExit() = false for a process that exited")
+	}
+
+	long := exec.Command("sleep", "5")
+	if err := long.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		long.Process.Kill()
+		long.Wait()
+	}()
+	if waitForExit(long.Process.Pid, 50*time.Millisecond, 10*time.Millisecond) {
+		t.Error("waitForExit() = true for a process still running")
+	}
+}
@@ -2,12 +2,27 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/bcrypt"
@@ -16,35 +31,207 @@ import (
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
-		return origin == "" || origin == "http://"+r.Host || origin == "https://"+r.Host
+		if origin == "" {
+			return true
+		}
+		// Over TLS the page is served via https:// and connects with wss://,
+		// so only a same-host https origin is legitimate.
+		if r.TLS != nil {
+			return origin == "https://"+r.Host
+		}
+		return origin == "http://"+r.Host || origin == "https://"+r.Host
 	},
 }
 
+// defaultWebUIHost keeps the WebUI reachable only from the local machine
+// unless a host is configured explicitly.
+const defaultWebUIHost = "localhost"
+
 type WebUIServer struct {
 	sessions     map[int64]*Session
-	authSessions map[string]time.Time // auth token → expiry
+	authSessions map[string]*authSession // auth token → login
 	mu           sync.Mutex
 	nextID       int64
 	config       *Config
+	host         string        // Bind address (localhost, 0.0.0.0, or an interface IP/name)
+	port         int           // Listen port, set by Start
+	idleTimeout  time.Duration // Stop sessions without output for this long (0 = never)
+	tlsCert      string        // TLS certificate file (empty = plain HTTP)
+	tlsKey       string        // TLS private key file
 }
 
 func NewWebUIServer(config *Config) *WebUIServer {
-	return &WebUIServer{
+	if config == nil {
+		// First run: the password setup fills this in, so s.config is
+		// never swapped out from under a reader
+		config = &Config{}
+	}
+	s := &WebUIServer{
 		sessions:     make(map[int64]*Session),
-		authSessions: make(map[string]time.Time),
+		authSessions: loadAuthSessions(),
 		nextID:       1,
 		config:       config,
+		host:         defaultWebUIHost,
+		idleTimeout:  config.idleTimeout(),
+	}
+	s.tlsCert = config.TLSCert
+	s.tlsKey = config.TLSKey
+	if config.WebUIHost != "" {
+		s.host = config.WebUIHost
+	}
+	return s
+}
+
+// SetHost overrides the configured bind address (from the --web-host flag).
+func (s *WebUIServer) SetHost(host string) {
+	if host != "" {
+		s.host = host
+	}
+}
+
+// checkOrigin extends the upgrader's same-host check to also accept origins
+// naming the configured bind address (host and port), so a UI bound to a
+// specific LAN address can be reached through it. Wildcard binds add
+// nothing beyond same-host.
+func (s *WebUIServer) checkOrigin(r *http.Request) bool {
+	if upgrader.CheckOrigin(r) {
+		return true
+	}
+	if s.host == "" || s.host == "0.0.0.0" || s.host == "::" {
+		return false
+	}
+	u, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil {
+		return false
+	}
+	if r.TLS != nil && u.Scheme != "https" {
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	return strings.EqualFold(u.Hostname(), strings.Trim(s.host, "[]")) && port == strconv.Itoa(s.port)
+}
+
+// SetTLS overrides the configured TLS certificate and key files
+// (from the --web-cert/--web-key flags).
+func (s *WebUIServer) SetTLS(certFile, keyFile string) {
+	if certFile != "" {
+		s.tlsCert = certFile
+	}
+	if keyFile != "" {
+		s.tlsKey = keyFile
+	}
+}
+
+// authSessionsPath returns the file used to persist WebUI login tokens
+// so a restart doesn't log everyone out.
+func authSessionsPath() string {
+	return filepath.Join(getConfigDir(), "webui-sessions.json")
+}
+
+// authSession is a WebUI login. Expires slides forward with activity by
+// Config.WebUIIdleLogout, but never past Deadline, fixed at login.
+type authSession struct {
+	Expires  time.Time `json:"expires"`
+	Deadline time.Time `json:"deadline"`
+	saved    time.Time // Expires as last persisted, to throttle saves
+}
+
+// authSessionSaveInterval is how far activity must push a login's expiry
+// before it is persisted again, so typing doesn't rewrite the file.
+const authSessionSaveInterval = time.Minute
+
+// newAuthSession starts a login at now.
+func newAuthSession(now time.Time, idle, max time.Duration) *authSession {
+	a := &authSession{Deadline: now.Add(max)}
+	a.extend(now, idle)
+	a.saved = a.Expires
+	return a
+}
+
+// extend pushes the expiry idle past now, capped at the deadline. Without
+// an idle limit the login lasts until the deadline.
+func (a *authSession) extend(now time.Time, idle time.Duration) {
+	a.Expires = a.Deadline
+	if idle > 0 && now.Add(idle).Before(a.Deadline) {
+		a.Expires = now.Add(idle)
+	}
+}
+
+// valid reports whether the login is neither idle-expired nor past its deadline.
+func (a *authSession) valid(now time.Time) bool {
+	return now.Before(a.Expires) && now.Before(a.Deadline)
+}
+
+// loadAuthSessions reads persisted auth tokens, dropping expired ones.
+// A missing or corrupt file yields an empty map.
+func loadAuthSessions() map[string]*authSession {
+	sessions := make(map[string]*authSession)
+
+	data, err := os.ReadFile(authSessionsPath())
+	if err != nil {
+		return sessions
+	}
+
+	var stored map[string]*authSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		// Files written before sliding expiry map each token to one time
+		var legacy map[string]time.Time
+		if json.Unmarshal(data, &legacy) != nil {
+			log.Printf("Warning: ignoring invalid WebUI sessions file: %v", err)
+			return sessions
+		}
+		stored = make(map[string]*authSession, len(legacy))
+		for token, expiry := range legacy {
+			stored[token] = &authSession{Expires: expiry, Deadline: expiry}
+		}
+	}
+
+	now := time.Now()
+	for token, session := range stored {
+		if session != nil && session.valid(now) {
+			session.saved = session.Expires
+			sessions[token] = session
+		}
+	}
+	return sessions
+}
+
+// saveAuthSessionsLocked persists auth tokens with 0600 permissions.
+// Caller must hold s.mu.
+func (s *WebUIServer) saveAuthSessionsLocked() {
+	data, err := json.Marshal(s.authSessions)
+	if err != nil {
+		log.Printf("Warning: could not encode WebUI sessions: %v", err)
+		return
+	}
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		log.Printf("Warning: could not create config dir: %v", err)
+		return
+	}
+	if err := os.WriteFile(authSessionsPath(), data, 0600); err != nil {
+		log.Printf("Warning: could not save WebUI sessions: %v", err)
 	}
 }
 
 type WebMessage struct {
-	Type    string `json:"type"`    // "command", "input", "output", "status", "error", "resize"
-	Content string `json:"content"` // Message content
-	ChatID  int64  `json:"chatId"`  // Session ID
-	Rows    int    `json:"rows"`    // Terminal rows (for resize)
-	Cols    int    `json:"cols"`    // Terminal cols (for resize)
+	Type    string `json:"type"`            // "command", "input", "secret", "output", "status", "error", "resize", "switch", "resume", "heartbeat", "start"
+	Content string `json:"content"`         // Message content (session uptime for heartbeat)
+	ChatID  int64  `json:"chatId"`          // Session ID
+	Rows    int    `json:"rows"`            // Terminal rows (for resize)
+	Cols    int    `json:"cols"`            // Terminal cols (for resize)
+	Alive   bool   `json:"alive,omitempty"` // Whether the session's PTY is running (for heartbeat)
 }
 
+// heartbeatInterval is how often a connected tab is told its session is
+// alive. The page warns after two missed heartbeats.
+const heartbeatInterval = 5 * time.Second
+
 // WebSocketSink sends output to WebSocket
 type WebSocketSink struct {
 	conn   *websocket.Conn
@@ -67,6 +254,22 @@ func (w *WebSocketSink) SendOutput(output string) {
 	}
 }
 
+// send writes an arbitrary message, stamping it with the sink's session ID.
+func (w *WebSocketSink) send(msg WebMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sendLocked(msg)
+}
+
+// sendLocked is send for callers that already hold w.mu, such as a replay
+// that must not interleave with live output. Caller must hold w.mu.
+func (w *WebSocketSink) sendLocked(msg WebMessage) {
+	msg.ChatID = w.chatID
+	if err := w.conn.WriteJSON(msg); err != nil {
+		log.Printf("WebSocket write error: %v\n", err)
+	}
+}
+
 func (w *WebSocketSink) SendStatus(status string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -88,13 +291,25 @@ func (s *WebUIServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	wsUpgrader := upgrader
+	wsUpgrader.CheckOrigin = s.checkOrigin
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v\n", err)
 		return
 	}
 	defer conn.Close()
 
+	// Read-only viewers watch an existing session instead of getting a shell
+	if query := r.URL.Query(); query.Get("mode") == "view" {
+		s.serveViewer(conn, query.Get("session"))
+		return
+	}
+
+	// Messages count as activity for the login; isAuthenticated passed,
+	// so the cookie is present
+	cookie, _ := r.Cookie("session")
+
 	// Assign session ID
 	s.mu.Lock()
 	chatID := s.nextID
@@ -109,8 +324,18 @@ func (s *WebUIServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		chatID: chatID,
 	}
 
-	// Automatically start a shell session for the user
-	s.startShellSession(chatID, sink)
+	// Start a shell session for the user, unless webui_auto_start_shell
+	// is off; then the tab gets one when it asks (Start button)
+	if s.autoStartShell() {
+		s.startShellSession(chatID, sink)
+	} else {
+		sink.SendStatus("💤 No shell running — press Start or type a command")
+	}
+
+	// Heartbeats show the tab the session is alive during quiet periods
+	stopHeartbeats := make(chan struct{})
+	defer close(stopHeartbeats)
+	go s.sendHeartbeats(sink, stopHeartbeats)
 
 	// Handle incoming messages
 	for {
@@ -118,8 +343,22 @@ func (s *WebUIServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		err := conn.ReadJSON(&msg)
 		if err != nil {
 			log.Printf("WebSocket read error: %v\n", err)
-			// Clean up session on disconnect
-			s.cleanup(chatID)
+			// Detach the session this connection is attached to (unless
+			// another connection has since switched to it) and give the
+			// client a grace period to resume before it is cleaned up
+			if s.isAttached(chatID, sink) {
+				s.detachSession(chatID)
+			}
+			break
+		}
+		if !s.touchAuthSession(cookie.Value) {
+			// Logged out by inactivity or the absolute limit: keep the
+			// session resumable after the next login, and send the page there
+			log.Printf("WebUI login expired (session %d)\n", chatID)
+			sink.send(WebMessage{Type: "logout", Content: "🔒 Logged out after inactivity"})
+			if s.isAttached(chatID, sink) {
+				s.detachSession(chatID)
+			}
 			break
 		}
 
@@ -128,19 +367,60 @@ func (s *WebUIServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		} else if msg.Type == "input" {
 			// Handle raw input (character-by-character) for interactive programs
 			s.handleRawInput(chatID, msg.Content, sink)
+		} else if msg.Type == "secret" {
+			// Like input plus Enter, but not logged and not echoed
+			s.handleSecretInput(chatID, msg.Content)
 		} else if msg.Type == "resize" {
 			// Handle terminal resize
 			s.handleResize(chatID, msg)
+		} else if msg.Type == "start" {
+			s.startRequestedShell(chatID, sink)
 		} else if msg.Type == "stop" {
 			s.stopSession(chatID, sink)
 		} else if msg.Type == "status" {
 			s.showStatus(chatID, sink)
+		} else if msg.Type == "switch" {
+			if s.switchSession(chatID, msg.ChatID, sink) {
+				chatID = msg.ChatID
+			}
+		} else if msg.Type == "resume" {
+			if id, ok := s.resumeSession(chatID, msg.Content, sink); ok {
+				chatID = id
+			}
 		}
 	}
 
 	log.Printf("WebUI client disconnected (session %d)\n", chatID)
 }
 
+// sendHeartbeats sends a heartbeat to sink every heartbeatInterval until stop
+// is closed.
+func (s *WebUIServer) sendHeartbeats(sink *WebSocketSink, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sink.send(s.heartbeat(sink))
+		}
+	}
+}
+
+// heartbeat describes the session sink is attached to: its uptime, and
+// whether its PTY is still running.
+func (s *WebUIServer) heartbeat(sink *WebSocketSink) WebMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := WebMessage{Type: "heartbeat"}
+	if session, ok := s.sessions[sink.chatID]; ok && session.Active {
+		msg.Alive = true
+		msg.Content = time.Since(session.StartedAt).Round(time.Second).String()
+	}
+	return msg
+}
+
 func (s *WebUIServer) handleCommand(chatID int64, command string, sink *WebSocketSink) {
 	s.mu.Lock()
 	session, hasSession := s.sessions[chatID]
@@ -154,7 +434,7 @@ func (s *WebUIServer) handleCommand(chatID int64, command string, sink *WebSocke
 	}
 
 	// No session - decide if we need one
-	if isInteractiveCommand(command) {
+	if isInteractiveCommand(command, s.config) {
 		s.startSession(chatID, command, sink)
 	} else {
 		s.executeCommand(chatID, command, sink)
@@ -191,19 +471,32 @@ func (s *WebUIServer) handleResize(chatID int64, msg WebMessage) {
 	}
 }
 
+// sessionLimitReached reports whether Config.MaxSessions sessions are
+// already active.
+func (s *WebUIServer) sessionLimitReached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sessionLimitReached(s.sessions, s.config.maxSessions())
+}
+
 func (s *WebUIServer) startShellSession(chatID int64, sink *WebSocketSink) {
 	log.Printf("[WebUI-%d] → [starting shell session]\n", chatID)
 
-	terminal, err := NewTerminal(sink)
+	if s.sessionLimitReached() {
+		sink.SendStatus("⚠️ too many active sessions")
+		return
+	}
+
+	terminal, err := NewTerminal(sink, s.config, nil)
 	if err != nil {
 		log.Printf("Error creating terminal: %v\n", err)
-		sink.SendStatus("❌ Error creating terminal")
+		sink.SendStatus(terminalErrorText("❌ Error creating terminal", err))
 		return
 	}
 
 	session := &Session{
 		Terminal:  terminal,
-		Sink:      sink,
+		Sinks:     []OutputSink{sink},
 		Active:    true,
 		Command:   "shell",
 		StartedAt: time.Now(),
@@ -213,24 +506,43 @@ func (s *WebUIServer) startShellSession(chatID int64, sink *WebSocketSink) {
 	s.mu.Lock()
 	s.sessions[chatID] = session
 	s.mu.Unlock()
+	s.issueResumeToken(session, sink)
 
 	// Stream output in background (shell is already running)
-	go s.streamSessionOutput(chatID, sink)
+	go s.streamSessionOutput(chatID)
+}
+
+// startRequestedShell handles a "start" message: it starts a shell for a
+// tab without a running session.
+func (s *WebUIServer) startRequestedShell(chatID int64, sink *WebSocketSink) {
+	s.mu.Lock()
+	session, hasSession := s.sessions[chatID]
+	s.mu.Unlock()
+	if hasSession && session.Active {
+		sink.SendStatus("⚠️ A session is already running")
+		return
+	}
+	s.startShellSession(chatID, sink)
 }
 
 func (s *WebUIServer) startSession(chatID int64, command string, sink *WebSocketSink) {
 	log.Printf("[WebUI-%d] → [new session] %s\n", chatID, command)
 
-	terminal, err := NewTerminal(sink)
+	if s.sessionLimitReached() {
+		sink.SendStatus("⚠️ too many active sessions")
+		return
+	}
+
+	terminal, err := NewSessionTerminal(sink, s.config, nil, command)
 	if err != nil {
 		log.Printf("Error creating terminal: %v\n", err)
-		sink.SendStatus("❌ Error creating session")
+		sink.SendStatus(terminalErrorText("❌ Error creating session", err))
 		return
 	}
 
 	session := &Session{
 		Terminal:  terminal,
-		Sink:      sink,
+		Sinks:     []OutputSink{sink},
 		Active:    true,
 		Command:   command,
 		StartedAt: time.Now(),
@@ -240,14 +552,15 @@ func (s *WebUIServer) startSession(chatID int64, command string, sink *WebSocket
 	s.mu.Lock()
 	s.sessions[chatID] = session
 	s.mu.Unlock()
+	s.issueResumeToken(session, sink)
 
 	// Send initial command
-	terminal.SendCommand(command)
+	terminal.Launch(command)
 
 	// Stream output in background
-	go s.streamSessionOutput(chatID, sink)
+	go s.streamSessionOutput(chatID)
 
-	sink.SendStatus(fmt.Sprintf("🔄 Interactive session started: %s", command))
+	sink.SendStatus(fmt.Sprintf("🔄 Interactive session started: %s (PID %d)", command, terminal.PID()))
 }
 
 func (s *WebUIServer) stopSession(chatID int64, sink *WebSocketSink) {
@@ -285,9 +598,11 @@ func (s *WebUIServer) showStatus(chatID int64, sink *WebSocketSink) {
 	duration := time.Since(session.StartedAt).Round(time.Second)
 	status := fmt.Sprintf("📊 Active Session\n\n"+
 		"Command: %s\n"+
+		"PID: %d\n"+
 		"Duration: %s\n"+
 		"Started: %s",
 		session.Command,
+		session.Terminal.PID(),
 		duration,
 		session.StartedAt.Format("15:04:05"))
 
@@ -297,16 +612,17 @@ func (s *WebUIServer) showStatus(chatID int64, sink *WebSocketSink) {
 func (s *WebUIServer) executeCommand(chatID int64, command string, sink *WebSocketSink) {
 	log.Printf("[WebUI-%d] → [one-shot] %s\n", chatID, command)
 
-	terminal, err := NewTerminal(sink)
+	terminal, err := NewTerminal(sink, s.config, nil)
 	if err != nil {
 		log.Printf("Error creating terminal: %v\n", err)
-		sink.SendStatus("❌ Error creating terminal")
+		sink.SendStatus(terminalErrorText("❌ Error creating terminal", err))
 		return
 	}
 	defer terminal.Close()
+	terminal.SetCommandTimeout(s.config.commandTimeout())
 
 	// Send command
-	terminal.SendCommand(command)
+	terminal.RunCommand(command)
 
 	// Stream output
 	terminal.StreamOutput()
@@ -314,7 +630,9 @@ func (s *WebUIServer) executeCommand(chatID int64, command string, sink *WebSock
 	log.Printf("[WebUI-%d] ✓ Complete\n", chatID)
 }
 
-func (s *WebUIServer) streamSessionOutput(chatID int64, sink *WebSocketSink) {
+// streamSessionOutput forwards PTY output to whichever sink is currently
+// attached to the session. Output produced while detached is dropped.
+func (s *WebUIServer) streamSessionOutput(chatID int64) {
 	s.mu.Lock()
 	session, exists := s.sessions[chatID]
 	s.mu.Unlock()
@@ -326,6 +644,12 @@ func (s *WebUIServer) streamSessionOutput(chatID int64, sink *WebSocketSink) {
 
 	log.Printf("Session streaming started for WebUI-%d\n", chatID)
 
+	s.mu.Lock()
+	config := s.config
+	s.mu.Unlock()
+	record := startTranscript(config, chatID)
+	defer record.close()
+
 	defer func() {
 		log.Printf("Session streaming ended for WebUI-%d\n", chatID)
 		// Cleanup on exit
@@ -343,7 +667,6 @@ func (s *WebUIServer) streamSessionOutput(chatID int64, sink *WebSocketSink) {
 
 	var buffer string
 	lastOutput := time.Now()
-	maxIdleTime := 30 * time.Minute
 
 	for {
 		select {
@@ -351,7 +674,7 @@ func (s *WebUIServer) streamSessionOutput(chatID int64, sink *WebSocketSink) {
 			log.Printf("Session manually stopped for WebUI-%d\n", chatID)
 			if buffer != "" {
 				// Send raw output for xterm.js terminal emulator
-				sink.SendOutput(buffer)
+				s.sendOutput(session, buffer)
 			}
 			return
 
@@ -360,175 +683,1151 @@ func (s *WebUIServer) streamSessionOutput(chatID int64, sink *WebSocketSink) {
 				log.Printf("Terminal exited for WebUI-%d\n", chatID)
 				if buffer != "" {
 					// Send raw output for xterm.js terminal emulator
-					sink.SendOutput(buffer)
+					s.sendOutput(session, buffer)
 				}
-				sink.SendStatus("🔴 Session ended (program exited)")
+				s.sendStatus(session, "🔴 Session ended (program exited)")
 				return
 			}
+			output = session.hideSecretEcho(output)
+			record.write(output)
 			buffer += output
 			lastOutput = time.Now()
 
 		case <-ticker.C:
 			if buffer != "" && time.Since(lastOutput) > 1*time.Millisecond {
-				// Send RAW output immediately for instant typing (1ms delay)
-				sink.SendOutput(buffer)
-				buffer = ""
+				// Send RAW output immediately for instant typing (1ms delay),
+				// holding back a split escape sequence or rune for the next read
+				ready, held := splitIncompleteTail(buffer)
+				if len(held) > maxHeldOutput {
+					ready, held = buffer, "" // Not a sequence that will ever end
+				}
+				if ready != "" {
+					s.sendOutput(session, ready)
+				}
+				buffer = held
 			}
 
-			if time.Since(lastOutput) > maxIdleTime {
+			if s.idleTimeout > 0 && time.Since(lastOutput) > s.idleTimeout {
 				log.Printf("Session idle timeout for WebUI-%d\n", chatID)
-				sink.SendStatus("⏱️ Session timed out (30min idle)")
+				s.sendStatus(session, idleTimeoutMessage(s.idleTimeout))
 				return
 			}
 		}
 	}
 }
 
-func (s *WebUIServer) cleanup(chatID int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// maxHeldOutput bounds the incomplete tail held back by
+// splitIncompleteTail, so malformed output can't stall the stream.
+const maxHeldOutput = 4096
+
+// splitIncompleteTail splits buffered PTY output into the part that can be
+// sent now and an incomplete tail — an unterminated ANSI escape sequence or
+// a partial UTF-8 rune — to hold until more output arrives.
+func splitIncompleteTail(buf string) (ready, held string) {
+	for i := 0; i < len(buf); {
+		if buf[i] != 0x1b {
+			i++
+			continue
+		}
+		end := escapeEnd(buf, i)
+		if end < 0 {
+			return buf[:i], buf[i:]
+		}
+		i = end
+	}
 
-	if session, exists := s.sessions[chatID]; exists && session.Active {
-		session.Active = false
-		session.Terminal.Close()
-		delete(s.sessions, chatID)
-		log.Printf("Cleaned up session for WebUI-%d\n", chatID)
+	// Back up to the start of the last rune; hold it if bytes are missing
+	for k := len(buf) - 1; k >= 0 && k >= len(buf)-utf8.UTFMax; k-- {
+		if utf8.RuneStart(buf[k]) {
+			if !utf8.FullRuneInString(buf[k:]) {
+				return buf[:k], buf[k:]
+			}
+			break
+		}
 	}
+	return buf, ""
 }
 
-func (s *WebUIServer) Start(port int) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleRoot)
-	mux.HandleFunc("/setup-password", s.handleSetupPassword)
-	mux.HandleFunc("/login", s.handleLogin)
-	mux.HandleFunc("/logout", s.handleLogout)
-	mux.HandleFunc("/ws", s.handleWebSocket)
+// escapeEnd returns the index just past the escape sequence starting at
+// buf[i], or -1 if buf ends before the sequence does. Malformed sequences
+// end where they stop parsing, leaving the rest to the terminal.
+func escapeEnd(buf string, i int) int {
+	j := i + 1
+	if j >= len(buf) {
+		return -1
+	}
+	switch buf[j] {
+	case '[': // CSI: parameter and intermediate bytes, then a final byte
+		for j++; j < len(buf); j++ {
+			c := buf[j]
+			if c >= 0x40 && c <= 0x7e {
+				return j + 1
+			}
+			if c < 0x20 || c > 0x3f {
+				return j
+			}
+		}
+		return -1
+	case ']', 'P', '_', '^', 'X': // OSC, DCS, APC, PM, SOS: ended by BEL or ESC \
+		for j++; j < len(buf); j++ {
+			switch buf[j] {
+			case 0x07:
+				return j + 1
+			case 0x1b:
+				if j+1 >= len(buf) {
+					return -1
+				}
+				if buf[j+1] == '\\' {
+					return j + 2
+				}
+				return j
+			}
+		}
+		return -1
+	default: // Two-byte escape, or intermediates then a final byte (ESC ( B)
+		for ; j < len(buf) && buf[j] >= 0x20 && buf[j] <= 0x2f; j++ {
+		}
+		if j >= len(buf) {
+			return -1
+		}
+		return j + 1
+	}
+}
 
-	addr := fmt.Sprintf("localhost:%d", port)
-	log.Printf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	log.Printf("🌐 WebUI started: http://%s\n", addr)
-	log.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+// maxResumeBacklog bounds the output buffered for a detached session;
+// only the most recent output is kept.
+const maxResumeBacklog = 256 * 1024
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatalf("WebUI server error: %v\n", err)
+// maxViewerReplay bounds the recent output replayed to a viewer that
+// joins mid-session.
+const maxViewerReplay = 64 * 1024
+
+// maxSessionLog bounds the output kept for a session's downloadable log.
+const maxSessionLog = 1024 * 1024
+
+// appendTail appends output to buf, keeping only the last max bytes.
+func appendTail(buf, output string, max int) string {
+	buf += output
+	if len(buf) > max {
+		buf = buf[len(buf)-max:]
 	}
+	return buf
 }
 
-// handleRoot serves the appropriate page based on auth state
-func (s *WebUIServer) handleRoot(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+// sendOutput delivers output to the session's attached sink, or buffers it
+// for a later resume/switch while the session is detached. Read-only
+// viewers get a copy either way.
+func (s *WebUIServer) sendOutput(session *Session, output string) {
+	s.mu.Lock()
+	session.bracketedPaste = bracketedPasteMode(output, session.bracketedPaste)
+	sinks := session.sinkList()
+	if len(sinks) == 0 {
+		session.backlog = appendTail(session.backlog, output, maxResumeBacklog)
 	}
+	session.recent = appendTail(session.recent, output, maxViewerReplay)
+	session.outputLog = appendTail(session.outputLog, output, maxSessionLog)
+	viewers := viewersLocked(session)
+	s.mu.Unlock()
 
-	w.Header().Set("Content-Type", "text/html")
-
-	// No password set yet → setup page
-	if s.config == nil || s.config.WebUIPasswordHash == "" {
-		fmt.Fprint(w, setupPasswordHTML)
-		return
+	for _, sink := range sinks {
+		sink.SendOutput(output)
+	}
+	for _, viewer := range viewers {
+		viewer.SendOutput(output)
 	}
+}
 
-	// Not authenticated → login page
-	if !s.isAuthenticated(r) {
-		fmt.Fprint(w, loginHTML)
-		return
+// Bracketed paste mode (DECSET 2004): while a program has it on, pasted
+// text reaches it between pasteStart and pasteEnd markers, so a multi-line
+// paste is inserted as a whole instead of each line being run. bash's
+// readline turns it on at every prompt; xterm.js adds the markers.
+const (
+	bracketedPasteOn  = "\x1b[?2004h"
+	bracketedPasteOff = "\x1b[?2004l"
+)
+
+// bracketedPasteMode returns whether bracketed paste is on after output,
+// given whether it was on before: the last of the sequences turning it on
+// or off wins.
+func bracketedPasteMode(output string, on bool) bool {
+	onAt := strings.LastIndex(output, bracketedPasteOn)
+	offAt := strings.LastIndex(output, bracketedPasteOff)
+	if onAt == offAt { // Neither appears
+		return on
 	}
+	return onAt > offAt
+}
 
-	// Authenticated → terminal
-	fmt.Fprint(w, htmlContent)
+// bracketedPasteSequence returns the sequence setting bracketed paste mode.
+func bracketedPasteSequence(on bool) string {
+	if on {
+		return bracketedPasteOn
+	}
+	return bracketedPasteOff
 }
 
-// handleSetupPassword creates the initial password (first-time setup)
-func (s *WebUIServer) handleSetupPassword(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// sendStatus delivers a status line to the session's attached sink, if any,
+// and to its viewers.
+func (s *WebUIServer) sendStatus(session *Session, status string) {
+	s.mu.Lock()
+	sinks := session.sinkList()
+	viewers := viewersLocked(session)
+	s.mu.Unlock()
+
+	for _, sink := range sinks {
+		sendStatus(sink, status)
 	}
+	for _, viewer := range viewers {
+		viewer.SendStatus(status)
+	}
+}
 
-	// Block if password already set
-	if s.config != nil && s.config.WebUIPasswordHash != "" {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
+// viewersLocked snapshots a session's viewers so they can be written to
+// without holding s.mu. Caller must hold s.mu.
+func viewersLocked(session *Session) []*WebSocketSink {
+	viewers := make([]*WebSocketSink, 0, len(session.viewers))
+	for viewer := range session.viewers {
+		viewers = append(viewers, viewer)
 	}
+	return viewers
+}
 
-	password := r.FormValue("password")
-	confirm := r.FormValue("confirm")
+// addViewer attaches a read-only sink to a session and replays its recent
+// output. The sink's write lock is taken before s.mu is released, so live
+// output waits for the replay instead of interleaving with it. Returns
+// false if the session doesn't exist.
+func (s *WebUIServer) addViewer(chatID int64, sink *WebSocketSink) bool {
+	s.mu.Lock()
+	session, exists := s.sessions[chatID]
+	if !exists || !session.Active {
+		s.mu.Unlock()
+		return false
+	}
+	if session.viewers == nil {
+		session.viewers = make(map[*WebSocketSink]bool)
+	}
+	session.viewers[sink] = true
+	command, recent := session.Command, session.recent
+	sink.mu.Lock()
+	s.mu.Unlock()
+	defer sink.mu.Unlock()
 
-	if password == "" {
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprint(w, setupPasswordHTMLWithError("Password cannot be empty"))
-		return
+	sink.sendLocked(WebMessage{Type: "status", Content: fmt.Sprintf("👀 Watching session %d (%s) — read-only", chatID, command)})
+	if recent != "" {
+		sink.sendLocked(WebMessage{Type: "output", Content: recent})
 	}
+	return true
+}
 
-	if password != confirm {
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprint(w, setupPasswordHTMLWithError("Passwords do not match"))
-		return
+// removeViewer detaches a read-only sink from a session, if it still exists.
+func (s *WebUIServer) removeViewer(chatID int64, sink *WebSocketSink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, exists := s.sessions[chatID]; exists {
+		delete(session.viewers, sink)
 	}
+}
 
-	// Hash with bcrypt
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+// serveViewer streams a session's output to a read-only connection
+// (/ws?mode=view&session=ID). Messages from the viewer, including input
+// and commands, are ignored; reading only detects the disconnect.
+func (s *WebUIServer) serveViewer(conn *websocket.Conn, sessionParam string) {
+	chatID, err := strconv.ParseInt(sessionParam, 10, 64)
+	sink := &WebSocketSink{conn: conn, chatID: chatID}
+	if err != nil || !s.addViewer(chatID, sink) {
+		sink.send(WebMessage{Type: "error", Content: fmt.Sprintf("⚠️ No active session %s", sessionParam)})
 		return
 	}
+	defer s.removeViewer(chatID, sink)
 
-	// Initialize config if nil
-	if s.config == nil {
-		s.config = &Config{}
+	log.Printf("[WebUI-%d] viewer connected\n", chatID)
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
 	}
-	s.config.WebUIPasswordHash = string(hash)
+	log.Printf("[WebUI-%d] viewer disconnected\n", chatID)
+}
 
-	// Save to disk
-	if err := saveConfig(s.config); err != nil {
-		log.Printf("Warning: could not save config: %v", err)
-	}
+// isAttached reports whether sink is the one currently receiving a session's output.
+func (s *WebUIServer) isAttached(chatID int64, sink OutputSink) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, exists := s.sessions[chatID]
+	return exists && session.hasSink(sink)
+}
 
-	// Auto-login: create session
-	token := s.createAuthSession()
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+// switchSession attaches sink to the session targetID, detaching it from
+// currentID. The previous session keeps running detached so the user can
+// switch back later. Returns false if the target doesn't exist.
+func (s *WebUIServer) switchSession(currentID, targetID int64, sink *WebSocketSink) bool {
+	s.mu.Lock()
+	target, exists := s.sessions[targetID]
+	if !exists || !target.Active {
+		s.mu.Unlock()
+		sink.SendStatus(fmt.Sprintf("⚠️ No active session %d", targetID))
+		return false
+	}
+	if current, ok := s.sessions[currentID]; ok && currentID != targetID && current.hasSink(sink) {
+		current.setSink(nil)
+	}
+	replay := s.attachLocked(targetID, target, sink)
+	s.mu.Unlock()
+	replay()
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	log.Printf("[WebUI-%d] → [switch to session %d]\n", currentID, targetID)
+	sink.SendStatus(fmt.Sprintf("🔀 Attached to session %d (%s)", targetID, target.Command))
+	return true
 }
 
-// handleLogin validates password and creates a session
-func (s *WebUIServer) handleLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// attachLocked points a session's output at sink and returns a replay
+// that sends anything buffered while it was detached plus the client's
+// resume token. The caller runs the replay after releasing s.mu; the sink's
+// write lock is held until it finishes, so live output can't interleave
+// with it. Caller must hold s.mu.
+func (s *WebUIServer) attachLocked(chatID int64, session *Session, sink *WebSocketSink) (replay func()) {
+	if session.graceTimer != nil {
+		session.graceTimer.Stop()
+		session.graceTimer = nil
 	}
+	session.setSink(sink)
 
-	password := r.FormValue("password")
+	bracketed, backlog, resumeToken := session.bracketedPaste, session.backlog, session.resumeToken
+	session.backlog = ""
 
-	if s.config == nil || s.config.WebUIPasswordHash == "" {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
+	sink.mu.Lock()
+	sink.chatID = chatID
+	return func() {
+		defer sink.mu.Unlock()
+
+		// The browser's terminal only wraps pastes in bracketed paste markers
+		// once it has seen the program ask for them, which a fresh page (or a
+		// switch from another session) hasn't
+		sink.sendLocked(WebMessage{Type: "output", Content: bracketedPasteSequence(bracketed)})
+
+		if backlog != "" {
+			sink.sendLocked(WebMessage{Type: "output", Content: backlog})
+		}
+
+		// The client now owns this session; it should resume it, not its old one
+		if resumeToken != "" {
+			sink.sendLocked(WebMessage{Type: "resume", Content: resumeToken})
+		}
+	}
+}
+
+// issueResumeToken gives a session a fresh resume token and tells the client,
+// which presents it in a "resume" message after reconnecting.
+func (s *WebUIServer) issueResumeToken(session *Session, sink *WebSocketSink) {
+	token := generateSessionToken()
+	s.mu.Lock()
+	session.resumeToken = token
+	s.mu.Unlock()
+	sink.send(WebMessage{Type: "resume", Content: token})
+}
+
+// detachSession keeps a session running without a sink after its WebSocket
+// drops. Output is buffered; if nobody resumes within Config.WebUIResumeGrace
+// the session is cleaned up.
+func (s *WebUIServer) detachSession(chatID int64) {
+	grace := s.config.resumeGrace()
+	if grace <= 0 {
+		s.cleanup(chatID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, exists := s.sessions[chatID]
+	if !exists || !session.Active {
+		return
+	}
+	session.setSink(nil)
+	session.graceTimer = time.AfterFunc(grace, func() { s.expireDetached(chatID, session) })
+	log.Printf("[WebUI-%d] detached, waiting %s for resume\n", chatID, grace)
+}
+
+// expireDetached cleans up a session whose grace period ran out, unless it
+// was resumed or replaced in the meantime.
+func (s *WebUIServer) expireDetached(chatID int64, session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[chatID] != session || session.attached() {
+		return
+	}
+	log.Printf("[WebUI-%d] resume grace period expired\n", chatID)
+	s.cleanupLocked(chatID)
+}
+
+// resumeSession reattaches sink to the session holding token after a
+// reconnect, discarding the fresh shell the new connection was given.
+// Returns the resumed session's ID.
+func (s *WebUIServer) resumeSession(currentID int64, token string, sink *WebSocketSink) (int64, bool) {
+	s.mu.Lock()
+	targetID, target := int64(0), (*Session)(nil)
+	for id, session := range s.sessions {
+		if session.Active && session.resumeToken != "" &&
+			subtle.ConstantTimeCompare([]byte(session.resumeToken), []byte(token)) == 1 {
+			targetID, target = id, session
+			break
+		}
+	}
+	if target == nil {
+		s.mu.Unlock()
+		sink.SendStatus("⚠️ Previous session expired — started a new one")
+		return 0, false
+	}
+	if targetID == currentID {
+		s.mu.Unlock()
+		return currentID, true
+	}
+
+	// Drop the placeholder shell started for this connection
+	if current, ok := s.sessions[currentID]; ok && current.hasSink(sink) {
+		current.setSink(nil)
+		s.cleanupLocked(currentID)
+	}
+	replay := s.attachLocked(targetID, target, sink)
+	s.mu.Unlock()
+	replay()
+
+	log.Printf("[WebUI-%d] → [resume session %d]\n", currentID, targetID)
+	sink.SendStatus(fmt.Sprintf("🔁 Resumed session %d (%s)", targetID, target.Command))
+	return targetID, true
+}
+
+// sessionInfo is the JSON shape returned by the /sessions endpoint.
+type sessionInfo struct {
+	ID        int64     `json:"id"`
+	Command   string    `json:"command"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  string    `json:"duration"`
+	Attached  bool      `json:"attached"`
+}
+
+// listSessions returns active sessions ordered by ID.
+func (s *WebUIServer) listSessions() []sessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]sessionInfo, 0, len(s.sessions))
+	for id, session := range s.sessions {
+		if !session.Active {
+			continue
+		}
+		list = append(list, sessionInfo{
+			ID:        id,
+			Command:   session.Command,
+			PID:       session.Terminal.PID(),
+			StartedAt: session.StartedAt,
+			Duration:  time.Since(session.StartedAt).Round(time.Second).String(),
+			Attached:  session.attached(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// handleSessions serves the active session list as JSON
+func (s *WebUIServer) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.listSessions()); err != nil {
+		log.Printf("Error encoding sessions: %v\n", err)
+	}
+}
+
+// handleSessionLog serves the raw output a session has produced, up to
+// maxSessionLog, as a downloadable .log file.
+func (s *WebUIServer) handleSessionLog(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session id", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	var output string
+	if ok {
+		output = session.outputLog
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	name := fmt.Sprintf("session-%d-%s.log", id, time.Now().Format("20060102-150405"))
+	log.Printf("🌐 [session-log] session %d (%d bytes)\n", id, len(output))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	io.WriteString(w, output)
+}
+
+// errOutsideFileRoot rejects file browser paths that escape Config.FileRoot.
+var errOutsideFileRoot = errors.New("path is outside the file root")
+
+// isWithin reports whether the clean absolute path is root or below it.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveInRoot resolves path (absolute, or relative to root) with symlinks
+// followed, and rejects paths outside root so neither ".." nor a link can
+// escape it. The check runs before resolving too, so paths outside root are
+// refused without revealing whether they exist. An empty path is the root.
+func resolveInRoot(root, path string) (string, error) {
+	if root == "" {
+		return "", errors.New("no file root configured")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+	if path == "" {
+		return root, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if !isWithin(root, path) {
+		return "", errOutsideFileRoot
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(root, resolved) {
+		return "", errOutsideFileRoot
+	}
+	return resolved, nil
+}
+
+// resolveFilePath resolves a file browser path against the configured root.
+func (s *WebUIServer) resolveFilePath(path string) (resolved, root string, err error) {
+	s.mu.Lock()
+	root = s.config.fileRoot()
+	s.mu.Unlock()
+	if root, err = resolveInRoot(root, ""); err != nil {
+		return "", "", err
+	}
+	resolved, err = resolveInRoot(root, path)
+	return resolved, root, err
+}
+
+// fileErrorStatus maps a file browser error to an HTTP status.
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errOutsideFileRoot):
+		return http.StatusForbidden
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// fileEntry is one item in a /fs directory listing.
+type fileEntry struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"isDir"`
+}
+
+// fileListing is the JSON shape returned by the /fs endpoint. Parent is
+// empty at the root, where the browser can't go up.
+type fileListing struct {
+	Path    string      `json:"path"`
+	Parent  string      `json:"parent,omitempty"`
+	Entries []fileEntry `json:"entries"`
+}
+
+// handleFS lists a directory under the file root as JSON, directories first.
+func (s *WebUIServer) handleFS(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dir, root, err := s.resolveFilePath(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), fileErrorStatus(err))
+		return
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, "Not a readable directory", http.StatusBadRequest)
+		return
+	}
+
+	listing := fileListing{Path: dir, Entries: make([]fileEntry, 0, len(dirEntries))}
+	if dir != root {
+		listing.Parent = filepath.Dir(dir)
+	}
+	for _, e := range dirEntries {
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed since ReadDir
+		}
+		listing.Entries = append(listing.Entries, fileEntry{Name: e.Name(), Size: info.Size(), IsDir: info.IsDir()})
+	}
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		a, b := listing.Entries[i], listing.Entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		return a.Name < b.Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(listing); err != nil {
+		log.Printf("Error encoding file listing: %v\n", err)
+	}
+}
+
+// handleDownload sends a file under the file root as an attachment.
+func (s *WebUIServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path, _, err := s.resolveFilePath(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), fileErrorStatus(err))
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), fileErrorStatus(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.Error(w, "Not a regular file", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("🌐 [download] %s (%d bytes)\n", path, info.Size())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *WebUIServer) cleanup(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanupLocked(chatID)
+}
+
+// cleanupLocked closes and forgets a session. Caller must hold s.mu.
+func (s *WebUIServer) cleanupLocked(chatID int64) {
+	if session, exists := s.sessions[chatID]; exists && session.Active {
+		if session.graceTimer != nil {
+			session.graceTimer.Stop()
+		}
+		session.Active = false
+		session.Terminal.Close()
+		delete(s.sessions, chatID)
+		log.Printf("Cleaned up session for WebUI-%d\n", chatID)
+	}
+}
+
+// isLoopbackHost reports whether host only accepts local connections.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *WebUIServer) Start(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleRoot)
+	mux.HandleFunc("/setup-password", s.handleSetupPassword)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/logout", s.handleLogout)
+	mux.HandleFunc("/change-password", s.handleChangePassword)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/sessions", s.handleSessions)
+	mux.HandleFunc("/upload", s.handleUpload)
+	mux.HandleFunc("/theme", s.handleTheme)
+	mux.HandleFunc("/fs", s.handleFS)
+	mux.HandleFunc("/download", s.handleDownload)
+	mux.HandleFunc("/session-log", s.handleSessionLog)
+
+	s.port = port
+	addr := net.JoinHostPort(s.host, strconv.Itoa(port))
+
+	if (s.tlsCert == "") != (s.tlsKey == "") {
+		log.Fatalf("WebUI TLS requires both a certificate and a key (--web-cert and --web-key)\n")
+	}
+	useTLS := s.tlsCert != ""
+
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	log.Printf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	log.Printf("🌐 WebUI started: %s://%s\n", scheme, addr)
+	log.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	if !useTLS && !isLoopbackHost(s.host) {
+		log.Printf("⚠️  WebUI is reachable from other machines over plain HTTP; consider --web-cert/--web-key\n")
+	}
+
+	// Let the Telegram bridge hand out /upload links to this server
+	publicURL := scheme + "://" + addr
+	if s.config != nil && s.config.WebUIURL != "" {
+		publicURL = s.config.WebUIURL
+	}
+	if err := publishUploadURL(publicURL); err != nil {
+		log.Printf("Warning: could not publish upload URL: %v\n", err)
+	}
+
+	var err error
+	if useTLS {
+		err = http.ListenAndServeTLS(addr, s.tlsCert, s.tlsKey, mux)
+	} else {
+		err = http.ListenAndServe(addr, mux)
+	}
+	if err != nil {
+		log.Fatalf("WebUI server error: %v\n", err)
+	}
+}
+
+// handleRoot serves the appropriate page based on auth state
+func (s *WebUIServer) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+
+	// No password set yet → setup page
+	if s.passwordHash() == "" {
+		fmt.Fprint(w, setupPasswordHTML)
+		return
+	}
+
+	// Not authenticated → login page
+	if !s.isAuthenticated(r) {
+		fmt.Fprint(w, loginHTML)
+		return
+	}
+
+	// Authenticated → terminal
+	fmt.Fprint(w, s.terminalHTML())
+}
+
+// handleSetupPassword creates the initial password (first-time setup)
+func (s *WebUIServer) handleSetupPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Block if password already set
+	if s.passwordHash() != "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	password := r.FormValue("password")
+	confirm := r.FormValue("confirm")
+
+	if password == "" {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, setupPasswordHTMLWithError("Password cannot be empty"))
+		return
+	}
+
+	if password != confirm {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, setupPasswordHTMLWithError("Passwords do not match"))
+		return
+	}
+
+	// Hash with bcrypt
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.bcryptCost())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Save it unless another setup request got there first
+	s.mu.Lock()
+	set := s.replacePasswordHashLocked("", string(hash))
+	s.mu.Unlock()
+	if !set {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Auto-login: create session
+	token := s.createAuthSession()
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.tlsCert != "", // Never sent over plain HTTP when serving TLS
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleLogin validates password and creates a session
+func (s *WebUIServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	password := r.FormValue("password")
+
+	stored := s.passwordHash()
+	if stored == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, loginHTMLWithError("Invalid password"))
+		return
+	}
+
+	s.upgradePasswordHash(password, stored)
+
+	token := s.createAuthSession()
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.tlsCert != "", // Never sent over plain HTTP when serving TLS
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// passwordHash returns the stored WebUI password hash, or "" before the
+// password has been set up.
+func (s *WebUIServer) passwordHash() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.WebUIPasswordHash
+}
+
+// replacePasswordHashLocked stores hash and saves it to the config file, but
+// only if the stored hash is still old: a request that verified a password
+// against old mustn't undo a change another request made in the meantime.
+// Only the hash is written, so whitelist changes the Telegram daemon saved
+// since the WebUI started are kept. It reports whether the hash was
+// replaced. Caller must hold s.mu.
+func (s *WebUIServer) replacePasswordHashLocked(old, hash string) bool {
+	if s.config.WebUIPasswordHash != old {
+		return false
+	}
+	err := updateConfig(s.config, func(config *Config) error {
+		config.WebUIPasswordHash = hash
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: could not save config: %v", err)
+	}
+	s.config.WebUIPasswordHash = hash
+	return true
+}
+
+// upgradePasswordHash rehashes a just-verified password when the stored hash
+// was made with a lower cost than Config.BcryptCost, so raising the cost
+// takes effect on the next login without resetting the password. verified
+// is the hash the password was checked against.
+func (s *WebUIServer) upgradePasswordHash(password, verified string) {
+	cost, err := bcrypt.Cost([]byte(verified))
+	if err != nil || cost >= s.config.bcryptCost() {
+		return
+	}
+
+	// bcrypt is slow, so hash before taking the lock
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.bcryptCost())
+	if err != nil {
+		log.Printf("Warning: could not rehash password: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.replacePasswordHashLocked(verified, string(hash))
+	s.mu.Unlock()
+}
+
+// handleChangePassword serves the password change form (GET) and replaces
+// the password (POST) once the current one is confirmed. Every other login
+// is ended, so a leaked password or session stops working; the login that
+// made the change stays signed in.
+func (s *WebUIServer) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	stored := s.passwordHash()
+	if stored == "" || !s.isAuthenticated(r) {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	switch r.Method {
+	case http.MethodGet:
+		fmt.Fprint(w, changePasswordHTML)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := r.FormValue("current")
+	password := r.FormValue("password")
+	confirm := r.FormValue("confirm")
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(current)) != nil {
+		fmt.Fprint(w, changePasswordHTMLWithError("Current password is incorrect"))
+		return
+	}
+	if password == "" {
+		fmt.Fprint(w, changePasswordHTMLWithError("New password cannot be empty"))
+		return
+	}
+	if password != confirm {
+		fmt.Fprint(w, changePasswordHTMLWithError("New passwords do not match"))
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.bcryptCost())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cookie, _ := r.Cookie("session") // Present: isAuthenticated passed
+	s.mu.Lock()
+	changed := s.replacePasswordHashLocked(stored, string(hash))
+	if changed {
+		for token := range s.authSessions {
+			if token != cookie.Value {
+				delete(s.authSessions, token)
+			}
+		}
+		s.saveAuthSessionsLocked()
+	}
+	s.mu.Unlock()
+	if !changed {
+		// Changed by another request since current was checked
+		fmt.Fprint(w, changePasswordHTMLWithError("Current password is incorrect"))
+		return
+	}
+	log.Printf("🔑 WebUI password changed; other logins ended\n")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleUpload serves the form behind a /upload link (GET) and saves the
+// submitted file into the working directory (POST). Links come from the
+// Telegram /upload command and work once, without a WebUI login.
+func (s *WebUIServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	switch r.Method {
+	case http.MethodGet:
+		if !uploadTokenValid(token) {
+			http.Error(w, "Upload link expired or already used", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, uploadHTML(token))
+
+	case http.MethodPost:
+		if !redeemUploadToken(token) {
+			http.Error(w, "Upload link expired or already used", http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxWebUploadSize+1024*1024) // Room for multipart headers
+
+		path, size, err := s.saveMultipartUpload(r)
+		if err != nil {
+			log.Printf("❌ WebUI upload failed: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("🌐 [upload] %s (%d bytes)\n", path, size)
+		fmt.Fprintf(w, "Saved %s (%d bytes)\n", path, size)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveMultipartUpload streams the first file part of r to the working
+// directory via saveUpload.
+func (s *WebUIServer) saveMultipartUpload(r *http.Request) (string, int64, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", 0, fmt.Errorf("expected a multipart upload: %w", err)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return "", 0, fmt.Errorf("no file in upload")
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read upload: %w", err)
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		defer part.Close()
+		return saveUpload(dir, part.FileName(), part, maxWebUploadSize)
+	}
+}
+
+// defaultWebUITheme is the original green-on-black look.
+const defaultWebUITheme = "matrix"
+
+// webUIThemes are the xterm.js theme objects selectable in the WebUI.
+// The background and foreground also color the page header.
+var webUIThemes = map[string]map[string]string{
+	"matrix": {
+		"background": "#0a0a0a", "foreground": "#00ff00",
+		"cursor": "#00ff00", "cursorAccent": "#1a1a1a", "selectionBackground": "rgba(0, 255, 0, 0.3)",
+		"black": "#000000", "red": "#ff0000", "green": "#00ff00", "yellow": "#ffaa00",
+		"blue": "#0066ff", "magenta": "#ff00ff", "cyan": "#00ffff", "white": "#ffffff",
+		"brightBlack": "#808080", "brightRed": "#ff6666", "brightGreen": "#66ff66", "brightYellow": "#ffdd66",
+		"brightBlue": "#6699ff", "brightMagenta": "#ff66ff", "brightCyan": "#66ffff", "brightWhite": "#ffffff",
+	},
+	"solarized-dark": {
+		"background": "#002b36", "foreground": "#839496",
+		"cursor": "#93a1a1", "cursorAccent": "#002b36", "selectionBackground": "rgba(147, 161, 161, 0.3)",
+		"black": "#073642", "red": "#dc322f", "green": "#859900", "yellow": "#b58900",
+		"blue": "#268bd2", "magenta": "#d33682", "cyan": "#2aa198", "white": "#eee8d5",
+		"brightBlack": "#586e75", "brightRed": "#cb4b16", "brightGreen": "#586e75", "brightYellow": "#657b83",
+		"brightBlue": "#839496", "brightMagenta": "#6c71c4", "brightCyan": "#93a1a1", "brightWhite": "#fdf6e3",
+	},
+	"light": {
+		"background": "#ffffff", "foreground": "#1e1e1e",
+		"cursor": "#1e1e1e", "cursorAccent": "#ffffff", "selectionBackground": "rgba(0, 0, 0, 0.2)",
+		"black": "#000000", "red": "#c91b00", "green": "#00a600", "yellow": "#a68a00",
+		"blue": "#0225c7", "magenta": "#c930c7", "cyan": "#00a6b2", "white": "#bfbfbf",
+		"brightBlack": "#666666", "brightRed": "#e13a2c", "brightGreen": "#2db72d", "brightYellow": "#b59b00",
+		"brightBlue": "#3a5bd9", "brightMagenta": "#d44fd1", "brightCyan": "#19b5c2", "brightWhite": "#e5e5e5",
+	},
+}
+
+// themeName returns the saved WebUI theme, or the default if unset or unknown.
+func (s *WebUIServer) themeName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config != nil {
+		if _, ok := webUIThemes[s.config.WebUITheme]; ok {
+			return s.config.WebUITheme
+		}
+	}
+	return defaultWebUITheme
+}
+
+// quickCommands returns the configured quick-button commands.
+func (s *WebUIServer) quickCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config == nil {
+		return nil
 	}
+	return append([]string(nil), s.config.QuickCommands...)
+}
+
+// autoStartShell reports whether tabs get a shell as soon as they connect.
+func (s *WebUIServer) autoStartShell() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.webUIAutoStartShell()
+}
 
-	err := bcrypt.CompareHashAndPassword([]byte(s.config.WebUIPasswordHash), []byte(password))
+// terminalHTML renders the terminal page with the theme list, the saved
+// theme, and the quick-button commands injected. json.Marshal escapes <, >
+// and &, so config values can't break out of the <script> block.
+func (s *WebUIServer) terminalHTML() string {
+	themes, err := json.Marshal(webUIThemes)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprint(w, loginHTMLWithError("Invalid password"))
+		log.Printf("Error encoding themes: %v\n", err)
+		themes = []byte("{}")
+	}
+	quick, err := json.Marshal(s.quickCommands())
+	if err != nil || string(quick) == "null" {
+		quick = []byte("[]")
+	}
+	return strings.NewReplacer(
+		"{{THEMES}}", string(themes),
+		"{{THEME}}", s.themeName(),
+		"{{QUICK_COMMANDS}}", string(quick),
+		"{{HEARTBEAT_MS}}", strconv.FormatInt(heartbeatInterval.Milliseconds(), 10),
+		"{{AUTO_START}}", strconv.FormatBool(s.autoStartShell()),
+	).Replace(htmlContent)
+}
+
+// handleTheme saves the theme picked in the WebUI so it persists across logins
+func (s *WebUIServer) handleTheme(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	token := s.createAuthSession()
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
+	theme := r.FormValue("theme")
+	if _, ok := webUIThemes[theme]; !ok {
+		http.Error(w, "Unknown theme", http.StatusBadRequest)
+		return
+	}
+
+	// Only the theme is written, keeping what the daemon saved since the
+	// WebUI loaded its config
+	s.mu.Lock()
+	err := updateConfig(s.config, func(config *Config) error {
+		config.WebUITheme = theme
+		return nil
 	})
+	s.config.WebUITheme = theme
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: could not save config: %v", err)
+	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleLogout clears the session
@@ -536,6 +1835,7 @@ func (s *WebUIServer) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if cookie, err := r.Cookie("session"); err == nil {
 		s.mu.Lock()
 		delete(s.authSessions, cookie.Value)
+		s.saveAuthSessionsLocked()
 		s.mu.Unlock()
 	}
 
@@ -545,35 +1845,44 @@ func (s *WebUIServer) handleLogout(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
+		Secure:   s.tlsCert != "", // Never sent over plain HTTP when serving TLS
 		SameSite: http.SameSiteStrictMode,
 	})
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// isAuthenticated checks whether the request has a valid session cookie
+// isAuthenticated checks whether the request has a valid session cookie,
+// counting the request as activity that keeps the login alive
 func (s *WebUIServer) isAuthenticated(r *http.Request) bool {
 	cookie, err := r.Cookie("session")
 	if err != nil {
 		return false
 	}
+	return s.touchAuthSession(cookie.Value)
+}
 
+// touchAuthSession reports whether token is a valid login and, if so,
+// slides its idle expiry forward. Expired logins are removed.
+func (s *WebUIServer) touchAuthSession(token string) bool {
 	s.mu.Lock()
-	expiry, exists := s.authSessions[cookie.Value]
-	s.mu.Unlock()
+	defer s.mu.Unlock()
 
+	session, exists := s.authSessions[token]
 	if !exists {
 		return false
 	}
-
-	if time.Now().After(expiry) {
-		// Expired — clean up
-		s.mu.Lock()
-		delete(s.authSessions, cookie.Value)
-		s.mu.Unlock()
+	now := time.Now()
+	if !session.valid(now) {
+		delete(s.authSessions, token)
 		return false
 	}
 
+	session.extend(now, s.config.webUIIdleLogout())
+	if session.Expires.Sub(session.saved) >= authSessionSaveInterval {
+		session.saved = session.Expires
+		s.saveAuthSessionsLocked()
+	}
 	return true
 }
 
@@ -581,7 +1890,8 @@ func (s *WebUIServer) isAuthenticated(r *http.Request) bool {
 func (s *WebUIServer) createAuthSession() string {
 	token := generateSessionToken()
 	s.mu.Lock()
-	s.authSessions[token] = time.Now().Add(24 * time.Hour)
+	s.authSessions[token] = newAuthSession(time.Now(), s.config.webUIIdleLogout(), s.config.webUILoginMax())
+	s.saveAuthSessionsLocked()
 	s.mu.Unlock()
 	return token
 }
@@ -751,6 +2061,146 @@ func loginHTMLWithError(errMsg string) string {
 
 var loginHTML = loginHTMLWithError("")
 
+func changePasswordHTMLWithError(errMsg string) string {
+	errorBlock := ""
+	if errMsg != "" {
+		errorBlock = `<div class="error">` + errMsg + `</div>`
+	}
+	return `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Change Password - Remote Terminal</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: 'SF Mono', 'Monaco', 'Courier New', monospace;
+            background: #1a1a1a;
+            color: #c0c0c0;
+            height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .card {
+            background: #0a0a0a;
+            border: 1px solid #333;
+            border-radius: 8px;
+            padding: 40px;
+            width: 400px;
+        }
+        h1 { color: #00ff00; font-size: 18px; margin-bottom: 8px; }
+        .subtitle { color: #888; font-size: 13px; margin-bottom: 24px; }
+        label { display: block; margin-bottom: 6px; font-size: 13px; color: #888; }
+        input[type="password"] {
+            width: 100%;
+            padding: 10px;
+            background: #1a1a1a;
+            border: 1px solid #333;
+            border-radius: 4px;
+            color: #c0c0c0;
+            font-family: inherit;
+            font-size: 14px;
+            margin-bottom: 16px;
+        }
+        input[type="password"]:focus { outline: none; border-color: #00ff00; }
+        button {
+            width: 100%;
+            padding: 10px;
+            background: #00ff00;
+            color: #0a0a0a;
+            border: none;
+            border-radius: 4px;
+            font-family: inherit;
+            font-size: 14px;
+            font-weight: bold;
+            cursor: pointer;
+        }
+        button:hover { background: #00cc00; }
+        .error { background: #3a1010; border: 1px solid #ff4444; color: #ff6666; padding: 10px; border-radius: 4px; margin-bottom: 16px; font-size: 13px; }
+        .back { display: block; margin-top: 16px; text-align: center; font-size: 13px; color: #888; }
+    </style>
+</head>
+<body>
+    <div class="card">
+        <h1>Change Password</h1>
+        <div class="subtitle">Other browsers signed in to the WebUI will be logged out</div>
+        ` + errorBlock + `
+        <form method="POST" action="/change-password">
+            <label for="current">Current Password</label>
+            <input type="password" id="current" name="current" required autofocus>
+            <label for="password">New Password</label>
+            <input type="password" id="password" name="password" required>
+            <label for="confirm">Confirm New Password</label>
+            <input type="password" id="confirm" name="confirm" required>
+            <button type="submit">Change Password</button>
+        </form>
+        <a class="back" href="/">Back to terminal</a>
+    </div>
+</body>
+</html>`
+}
+
+var changePasswordHTML = changePasswordHTMLWithError("")
+
+// uploadHTML renders the file picker behind a /upload link.
+func uploadHTML(token string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Upload - Remote Terminal</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: 'SF Mono', 'Monaco', 'Courier New', monospace;
+            background: #1a1a1a;
+            color: #c0c0c0;
+            height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .card {
+            background: #0a0a0a;
+            border: 1px solid #333;
+            border-radius: 8px;
+            padding: 40px;
+            width: 400px;
+        }
+        h1 { color: #00ff00; font-size: 18px; margin-bottom: 8px; }
+        .subtitle { color: #888; font-size: 13px; margin-bottom: 24px; }
+        input[type="file"] { width: 100%; margin-bottom: 16px; color: #c0c0c0; font-family: inherit; }
+        button {
+            width: 100%;
+            padding: 10px;
+            background: #00ff00;
+            color: #0a0a0a;
+            border: none;
+            border-radius: 4px;
+            font-family: inherit;
+            font-size: 14px;
+            font-weight: bold;
+            cursor: pointer;
+        }
+        button:hover { background: #00cc00; }
+    </style>
+</head>
+<body>
+    <div class="card">
+        <h1>Remote Terminal</h1>
+        <div class="subtitle">Upload a file to the server (single use)</div>
+        <form method="POST" action="/upload?token=` + html.EscapeString(url.QueryEscape(token)) + `" enctype="multipart/form-data">
+            <input type="file" name="file" required>
+            <button type="submit">Upload</button>
+        </form>
+    </div>
+</body>
+</html>`
+}
+
 const htmlContent = `<!DOCTYPE html>
 <html>
 <head>
@@ -763,10 +2213,11 @@ const htmlContent = `<!DOCTYPE html>
     <script src="https://cdn.jsdelivr.net/npm/xterm-addon-fit@0.8.0/lib/xterm-addon-fit.js"></script>
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
+        :root { --bg: #0a0a0a; --fg: #00ff00; }
         body {
             font-family: 'SF Mono', 'Monaco', 'Courier New', monospace;
-            background: #1a1a1a;
-            color: #00ff00;
+            background: var(--bg);
+            color: var(--fg);
             height: 100vh;
             max-height: 100vh;
             display: flex;
@@ -774,11 +2225,22 @@ const htmlContent = `<!DOCTYPE html>
             overflow: hidden;
         }
         header {
-            background: #0a0a0a;
+            background: var(--bg);
             padding: 15px 20px;
-            border-bottom: 2px solid #00ff00;
+            border-bottom: 2px solid var(--fg);
+            display: flex;
+            align-items: center;
+            justify-content: space-between;
         }
         h1 { font-size: 18px; letter-spacing: 2px; }
+        #theme, #split, #share, #files, #session-log, #start-shell, #change-password {
+            background: var(--bg);
+            color: var(--fg);
+            border: 1px solid var(--fg);
+            border-radius: 4px;
+            padding: 4px;
+            font-family: inherit;
+        }
         .status { 
             font-size: 12px; 
             color: #888; 
@@ -786,6 +2248,7 @@ const htmlContent = `<!DOCTYPE html>
         }
         .status.connected { color: #00ff00; }
         .status.disconnected { color: #ff0000; }
+        .status.warning { color: #ffaa00; }
         
         main {
             flex: 1;
@@ -794,13 +2257,66 @@ const htmlContent = `<!DOCTYPE html>
             overflow: hidden;
         }
         
-        #terminal {
+        #quickbar {
+            display: flex;
+            gap: 6px;
+            padding: 6px 10px;
+            overflow-x: auto;
+            border-bottom: 1px solid var(--fg);
+        }
+        #quickbar button {
+            flex: none;
+            background: var(--bg);
+            color: var(--fg);
+            border: 1px solid var(--fg);
+            border-radius: 4px;
+            padding: 6px 10px;
+            font-family: inherit;
+            font-size: 13px;
+            touch-action: manipulation;
+        }
+        #quickbar button.command { font-style: italic; }
+
+        #files-panel {
+            position: fixed;
+            top: 70px;
+            right: 10px;
+            bottom: 10px;
+            width: 300px;
+            overflow: auto;
+            padding: 8px;
+            background: var(--bg);
+            border: 1px solid var(--fg);
+            border-radius: 4px;
+            font-size: 13px;
+            z-index: 10;
+        }
+        #files-panel ul { list-style: none; padding-left: 14px; }
+        #files-panel > ul { padding-left: 0; }
+        #files-panel span { cursor: pointer; }
+        #files-panel a { color: var(--fg); text-decoration: none; }
+
+        #panes {
             flex: 1;
+            display: grid;
+            grid-template-columns: 1fr;
+            gap: 4px;
+            overflow: hidden;
+        }
+        #panes.split { grid-template-columns: 1fr 1fr; }
+        @media (max-width: 700px) {
+            #panes.split { grid-template-columns: 1fr; grid-template-rows: 1fr 1fr; }
+        }
+        .pane {
+            min-width: 0;
+            min-height: 0;
             overflow: hidden;
             padding: 10px;
-            background: #0a0a0a;
+            background: var(--bg);
             cursor: text;
+            border: 1px solid transparent;
         }
+        #panes.split .pane.active { border-color: var(--fg); }
         
         ::-webkit-scrollbar {
             width: 10px;
@@ -821,51 +2337,261 @@ const htmlContent = `<!DOCTYPE html>
 </head>
 <body>
     <header>
-        <h1>REMOTE TERMINAL</h1>
-        <div class="status" id="status">Connecting...</div>
+        <div>
+            <h1>REMOTE TERMINAL</h1>
+            <div class="status" id="status">Connecting...</div>
+        </div>
+        <div>
+            <button id="start-shell" title="Start a shell session" hidden>Start</button>
+            <button id="split" title="Open a second terminal side by side">Split</button>
+            <button id="files" title="Browse server files">Files</button>
+            <button id="share" title="Copy a read-only link to this session">Share</button>
+            <button id="session-log" title="Download this session's output">Log</button>
+            <select id="theme" title="Theme"></select>
+            <button id="change-password" title="Change the WebUI password">Password</button>
+        </div>
     </header>
     
     <main>
-        <div id="terminal"></div>
+        <div id="quickbar"></div>
+        <div id="panes"><div id="terminal" class="pane"></div></div>
     </main>
+    <aside id="files-panel" hidden><ul id="files-tree"></ul></aside>
     
     <script>
-        let ws = null;
-        let chatId = null;
-        let term = null;
-        let fitAddon = null;
+        // ?mode=view&session=ID opens a read-only view of another session
+        const params = new URLSearchParams(window.location.search);
+        const viewMode = params.get('mode') === 'view';
         const statusEl = document.getElementById('status');
+        const themeEl = document.getElementById('theme');
+
+        // Themes and the saved choice are injected by the server
+        const THEMES = {{THEMES}};
+        let currentTheme = '{{THEME}}';
+
+        // applyTheme recolors the terminal and the page chrome
+        function applyTheme(name) {
+            const theme = THEMES[name];
+            if (!theme) return;
+            currentTheme = name;
+            document.documentElement.style.setProperty('--bg', theme.background);
+            document.documentElement.style.setProperty('--fg', theme.foreground);
+            panes.forEach(pane => { pane.term.options.theme = theme; });
+        }
+
+        // Populate the selector and persist changes server-side
+        Object.keys(THEMES).sort().forEach(name => {
+            const opt = document.createElement('option');
+            opt.value = name;
+            opt.textContent = name;
+            themeEl.appendChild(opt);
+        });
+        themeEl.value = currentTheme;
+        themeEl.addEventListener('change', () => {
+            applyTheme(themeEl.value);
+            fetch('/theme', { method: 'POST', body: new URLSearchParams({ theme: themeEl.value }) });
+            focusActive();
+        });
+
+        // Quick buttons for keys that are hard to type on a phone, followed
+        // by the commands from quick_commands (injected by the server)
+        const QUICK_KEYS = [
+            ['Ctrl-C', '\x03'], ['Tab', '\t'], ['Esc', '\x1b'],
+            ['↑', '\x1b[A'], ['↓', '\x1b[B'], ['←', '\x1b[D'], ['→', '\x1b[C']
+        ];
+        const QUICK_COMMANDS = {{QUICK_COMMANDS}};
+
+        // Heartbeats arrive every HEARTBEAT_MS; two missed ones mean the
+        // connection may have stalled even though it hasn't closed
+        const HEARTBEAT_MS = {{HEARTBEAT_MS}};
+        setInterval(() => {
+            panes.forEach(pane => {
+                if (!pane.lastHeartbeat || !pane.isOpen()) return;
+                const silent = Date.now() - pane.lastHeartbeat;
+                if (silent > 2 * HEARTBEAT_MS) {
+                    pane.setStatus('⚠️ No heartbeat for ' + Math.round(silent / 1000) + 's - connection may be stalled', 'warning');
+                }
+            });
+        }, 1000);
+
+        // Reconnects back off from RECONNECT_MIN_MS, doubling up to
+        // RECONNECT_MAX_MS, so a server that is down isn't hammered
+        const RECONNECT_MIN_MS = 2000;
+        const RECONNECT_MAX_MS = 30000;
+
+        // Panes are terminals side by side, each with its own WebSocket and
+        // so its own session. The active pane (the last one focused) gets
+        // the quick buttons, Files, Share and Log, and its status is shown
+        const panes = [];
+        let activePane = null;
+        const panesEl = document.getElementById('panes');
+
+        function focusActive() {
+            if (activePane) activePane.term.focus();
+        }
+
+        function sendInput(content) {
+            if (activePane) activePane.send({ type: 'input', content: content });
+        }
+
+        function addQuickButton(label, content, className) {
+            const btn = document.createElement('button');
+            btn.textContent = label;
+            btn.className = className;
+            // Keep focus (and the phone keyboard) on the terminal
+            btn.addEventListener('mousedown', e => e.preventDefault());
+            btn.addEventListener('click', () => {
+                sendInput(content);
+                focusActive();
+            });
+            document.getElementById('quickbar').appendChild(btn);
+        }
+        QUICK_KEYS.forEach(([label, seq]) => addQuickButton(label, seq, 'key'));
+        QUICK_COMMANDS.forEach(cmd => addQuickButton(cmd, cmd + '\r', 'command'));
+
+        // Share copies a link that opens this session read-only
+        const shareEl = document.getElementById('share');
+        shareEl.addEventListener('click', () => {
+            if (!activePane || !activePane.sessionId) return;
+            const link = window.location.origin + '/?mode=view&session=' + activePane.sessionId;
+            if (navigator.clipboard) navigator.clipboard.writeText(link);
+            activePane.term.writeln('\r\n\x1b[33m🔗 Read-only link (login required): ' + link + '\x1b[0m\r\n');
+            focusActive();
+        });
+        // Without webui_auto_start_shell, tabs connect without a shell and
+        // Start asks for one; it shows while no session is running
+        const AUTO_START = {{AUTO_START}};
+        const startEl = document.getElementById('start-shell');
+        startEl.hidden = AUTO_START || viewMode;
+        startEl.addEventListener('click', () => {
+            if (!activePane || !activePane.isOpen()) return;
+            activePane.send({ type: 'start' });
+            activePane.sendResize();
+            activePane.needsStart = false;
+            startEl.hidden = true;
+            focusActive();
+        });
+        // Log downloads everything this session has printed as a .log file
+        document.getElementById('session-log').addEventListener('click', () => {
+            if (!activePane || !activePane.sessionId) return;
+            window.location.href = '/session-log?id=' + activePane.sessionId;
+        });
+        // Files toggles a lazily loaded tree of the server's file_root.
+        // Clicking a file types "cat <path>" (without Enter); ⬇ downloads it
+        const filesEl = document.getElementById('files');
+        const filesPanel = document.getElementById('files-panel');
+        function shellQuote(s) {
+            return "'" + s.replace(/'/g, "'\\''") + "'";
+        }
+        function loadDir(path, container) {
+            fetch('/fs?path=' + encodeURIComponent(path))
+                .then(r => r.ok ? r.json() : r.text().then(text => { throw new Error(text.trim()); }))
+                .then(listing => {
+                    container.textContent = '';
+                    const base = listing.path.endsWith('/') ? listing.path : listing.path + '/';
+                    listing.entries.forEach(entry => {
+                        const full = base + entry.name;
+                        const item = document.createElement('li');
+                        const label = document.createElement('span');
+                        if (entry.isDir) {
+                            const children = document.createElement('ul');
+                            children.hidden = true;
+                            label.textContent = '▸ ' + entry.name + '/';
+                            label.addEventListener('click', () => {
+                                children.hidden = !children.hidden;
+                                label.textContent = (children.hidden ? '▸ ' : '▾ ') + entry.name + '/';
+                                if (!children.hidden && !children.dataset.loaded) {
+                                    children.dataset.loaded = '1';
+                                    loadDir(full, children);
+                                }
+                            });
+                            item.append(label, children);
+                        } else {
+                            label.textContent = entry.name;
+                            label.title = 'Insert cat command';
+                            label.addEventListener('click', () => {
+                                sendInput('cat ' + shellQuote(full));
+                                focusActive();
+                            });
+                            const download = document.createElement('a');
+                            download.href = '/download?path=' + encodeURIComponent(full);
+                            download.textContent = ' ⬇';
+                            download.title = 'Download (' + entry.size + ' bytes)';
+                            item.append(label, download);
+                        }
+                        container.appendChild(item);
+                    });
+                })
+                .catch(err => { container.textContent = '❌ ' + err.message; });
+        }
+        document.getElementById('change-password').addEventListener('click', () => {
+            location.href = '/change-password';
+        });
+
+        filesEl.addEventListener('click', () => {
+            filesPanel.hidden = !filesPanel.hidden;
+            if (!filesPanel.hidden && !filesPanel.dataset.loaded) {
+                filesPanel.dataset.loaded = '1';
+                loadDir('', document.getElementById('files-tree'));
+            }
+        });
+
+        if (viewMode) {
+            document.getElementById('quickbar').style.display = 'none';
+            shareEl.style.display = 'none';
+            filesEl.style.display = 'none';
+        }
+
+        // createPane opens terminal number index in el and connects it.
+        // Each pane keeps its own resume token, so a reload reattaches
+        // every pane to its session
+        function createPane(index, el) {
+            const tokenKey = index === 0 ? 'resumeToken' : 'resumeToken-' + index;
+            let resumeToken = sessionStorage.getItem(tokenKey);
+            let ws = null;
+            let viewClosed = false;
+            let removed = false;
+            let reconnectDelay = RECONNECT_MIN_MS;
+            const pane = {
+                el: el,
+                term: null,
+                sessionId: null, // Session shown, for share links and logs
+                lastHeartbeat: 0,
+                needsStart: !AUTO_START && !viewMode, // Start shows while no session runs
+                status: ['Connecting...', ''],
+                isOpen: () => ws !== null && ws.readyState === WebSocket.OPEN,
+                send: msg => {
+                    if (pane.isOpen()) ws.send(JSON.stringify(msg));
+                }
+            };
+            pane.setStatus = (text, className) => {
+                pane.status = [text, className];
+                if (pane === activePane) showStatus();
+            };
+            // Fit the terminal to the pane and give the PTY the same size;
+            // panes differ, so each sends its own
+            pane.sendResize = () => {
+                fitAddon.fit();
+                if (!viewMode) {
+                    pane.send({ type: 'resize', rows: term.rows, cols: term.cols });
+                }
+            };
+            // Close ends the pane's session and removes it
+            pane.close = () => {
+                removed = true;
+                pane.send({ type: 'stop' });
+                if (ws) ws.close();
+                sessionStorage.removeItem(tokenKey);
+                term.dispose();
+                el.remove();
+            };
 
-        // Initialize xterm.js terminal
-        function initTerminal() {
-            term = new Terminal({
+            const term = new Terminal({
                 cursorBlink: true,
                 cursorStyle: 'block',
                 fontSize: 14,
                 fontFamily: "'SF Mono', 'Monaco', 'Courier New', monospace",
-                theme: {
-                    background: '#0a0a0a',
-                    foreground: '#00ff00',
-                    cursor: '#00ff00',
-                    cursorAccent: '#1a1a1a',
-                    selection: 'rgba(0, 255, 0, 0.3)',
-                    black: '#000000',
-                    brightBlack: '#808080',
-                    red: '#ff0000',
-                    brightRed: '#ff6666',
-                    green: '#00ff00',
-                    brightGreen: '#66ff66',
-                    yellow: '#ffaa00',
-                    brightYellow: '#ffdd66',
-                    blue: '#0066ff',
-                    brightBlue: '#6699ff',
-                    magenta: '#ff00ff',
-                    brightMagenta: '#ff66ff',
-                    cyan: '#00ffff',
-                    brightCyan: '#66ffff',
-                    white: '#ffffff',
-                    brightWhite: '#ffffff'
-                },
+                theme: THEMES[currentTheme],
                 rows: 50,
                 cols: 120,
                 scrollback: 10000,
@@ -875,144 +2601,249 @@ const htmlContent = `<!DOCTYPE html>
                 allowProposedApi: true,
                 windowsMode: false,
                 macOptionIsMeta: true,
-                altClickMovesCursor: false
+                altClickMovesCursor: false,
+                // Wrap pastes in bracketed paste markers whenever the
+                // program asks for them (the server restores the mode
+                // after a reload), so pasted lines aren't each run
+                ignoreBracketedPasteMode: false
             });
+            pane.term = term;
 
             // Add FitAddon for responsive sizing
-            fitAddon = new FitAddon.FitAddon();
+            const fitAddon = new FitAddon.FitAddon();
             term.loadAddon(fitAddon);
 
             // Open terminal in container
-            term.open(document.getElementById('terminal'));
+            term.open(el);
             fitAddon.fit();
+            term.textarea.addEventListener('focus', () => setActive(pane));
 
-            // Handle window resize and communicate to backend
-            window.addEventListener('resize', () => {
-                if (fitAddon) {
-                    fitAddon.fit();
-
-                    // Send terminal size to backend
-                    if (ws && ws.readyState === WebSocket.OPEN) {
-                        ws.send(JSON.stringify({
-                            type: 'resize',
-                            rows: term.rows,
-                            cols: term.cols
-                        }));
-                    }
-                }
+            // Auto-focus terminal when clicked
+            el.addEventListener('click', () => {
+                term.focus();
             });
 
-            // No welcome banner - keep terminal clean for TUI apps like Claude Code
-            // that use absolute cursor positioning
-
-            // Enable direct keyboard input with buffering for smooth TUI experience
-            let inputBuffer = '';
-            let inputTimer = null;
-
-            term.onData((data) => {
-                if (ws && ws.readyState === WebSocket.OPEN) {
-                    // Buffer rapid keystrokes to keep TUI apps in sync
-                    inputBuffer += data;
+            // Viewers watch only: no keyboard input, and the PTY keeps the
+            // owner's size, so skip the input buffer
+            if (viewMode) {
+                term.options.disableStdin = true;
+            } else {
+                // No welcome banner - keep terminal clean for TUI apps like Claude Code
+                // that use absolute cursor positioning
 
-                    // Clear existing timer
-                    if (inputTimer) {
-                        clearTimeout(inputTimer);
-                    }
-
-                    // Send buffered input after 10ms of no typing (or immediately for Enter/special keys)
-                    const shouldSendImmediately = data === '\r' || data === '\n' || data.charCodeAt(0) < 32;
+                // Enable direct keyboard input with buffering for smooth TUI experience
+                let inputBuffer = '';
+                let inputTimer = null;
 
-                    if (shouldSendImmediately) {
-                        // Send immediately for Enter and control characters
-                        ws.send(JSON.stringify({
-                            type: 'input',
-                            content: inputBuffer
-                        }));
-                        inputBuffer = '';
-                    } else {
-                        // Buffer regular typing for 10ms
-                        inputTimer = setTimeout(() => {
-                            if (inputBuffer) {
-                                ws.send(JSON.stringify({
-                                    type: 'input',
-                                    content: inputBuffer
-                                }));
-                                inputBuffer = '';
-                            }
-                        }, 10);
+                term.onData((data) => {
+                    if (ws && ws.readyState === WebSocket.OPEN) {
+                        // Buffer rapid keystrokes to keep TUI apps in sync
+                        inputBuffer += data;
+
+                        // Clear existing timer
+                        if (inputTimer) {
+                            clearTimeout(inputTimer);
+                        }
+
+                        // Send buffered input after 10ms of no typing (or immediately for
+                        // Enter/special keys and pastes). A paste arrives as one chunk,
+                        // starting with the ESC of its bracketed paste marker when on
+                        const isPaste = data.length > 1 && /[\r\n]/.test(data);
+                        const shouldSendImmediately = data === '\r' || data === '\n' || data.charCodeAt(0) < 32 || isPaste;
+
+                        if (shouldSendImmediately) {
+                            // Send immediately for Enter and control characters
+                            ws.send(JSON.stringify({
+                                type: 'input',
+                                content: inputBuffer
+                            }));
+                            inputBuffer = '';
+                        } else {
+                            // Buffer regular typing for 10ms
+                            inputTimer = setTimeout(() => {
+                                if (inputBuffer) {
+                                    ws.send(JSON.stringify({
+                                        type: 'input',
+                                        content: inputBuffer
+                                    }));
+                                    inputBuffer = '';
+                                }
+                            }, 10);
+                        }
                     }
+                });
+            }
+
+            function connect() {
+                if (removed) return;
+                // Match the page scheme: https pages must use wss://
+                const wsScheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+                let wsUrl = wsScheme + window.location.host + '/ws';
+                if (viewMode) {
+                    wsUrl += '?mode=view&session=' + encodeURIComponent(params.get('session') || '');
                 }
-            });
-
-            // Auto-focus terminal when clicked
-            document.getElementById('terminal').addEventListener('click', () => {
-                term.focus();
-            });
-
-            // Auto-focus terminal on load
-            term.focus();
-        }
+                ws = new WebSocket(wsUrl);
 
-        function connect() {
-            const wsUrl = 'ws://' + window.location.host + '/ws';
-            ws = new WebSocket(wsUrl);
+                ws.onopen = () => {
+                    reconnectDelay = RECONNECT_MIN_MS;
+                    pane.setStatus(viewMode ? '👀 Read-only' : '✅ Connected', 'connected');
+                    if (viewMode) return;
 
-            ws.onopen = () => {
-                statusEl.textContent = '✅ Connected';
-                statusEl.className = 'status connected';
-
-                // Immediately sync terminal size with backend PTY
-                // This must happen before any interaction so Claude Code
-                // gets the correct dimensions for cursor positioning
-                if (term && fitAddon) {
+                    // Immediately sync terminal size with backend PTY
+                    // This must happen before any interaction so Claude Code
+                    // gets the correct dimensions for cursor positioning
                     fitAddon.fit();
                     ws.send(JSON.stringify({
                         type: 'resize',
                         rows: term.rows,
                         cols: term.cols
                     }));
-                }
-            };
 
-            ws.onclose = () => {
-                statusEl.textContent = '❌ Disconnected - Refresh to reconnect';
-                statusEl.className = 'status disconnected';
+                    // Reattach to the session we had before the connection dropped
+                    // (or before a page refresh); the server keeps it alive briefly
+                    if (resumeToken) {
+                        ws.send(JSON.stringify({
+                            type: 'resume',
+                            content: resumeToken
+                        }));
+                    }
+                };
+
+                ws.onclose = () => {
+                    pane.lastHeartbeat = 0;
+                    if (removed) return;
+                    if (viewClosed) {
+                        pane.setStatus('❌ Session not available', 'disconnected');
+                        return;
+                    }
+                    // A handshake refused for a missing login looks like any
+                    // other drop; ask the server before retrying, and on 401
+                    // reload to get the login page instead
+                    fetch('/sessions').then(resp => {
+                        if (resp.status === 401) {
+                            viewClosed = true;
+                            window.location.reload();
+                            return;
+                        }
+                        reconnect();
+                    }, reconnect);
+                };
+
+                ws.onerror = (error) => {
+                    console.error('WebSocket error:', error);
+                    if (!removed) {
+                        term.writeln('\r\n\x1b[31m❌ WebSocket error\x1b[0m\r\n');
+                    }
+                };
+
+                ws.onmessage = (event) => {
+                    const msg = JSON.parse(event.data);
 
-                if (term) {
-                    term.writeln('\r\n\x1b[31m❌ WebSocket disconnected - Refresh page\x1b[0m\r\n');
-                }
-            };
+                    if (msg.chatId) {
+                        pane.sessionId = msg.chatId;
+                    }
 
-            ws.onerror = (error) => {
-                console.error('WebSocket error:', error);
-                if (term) {
-                    term.writeln('\r\n\x1b[31m❌ WebSocket error\x1b[0m\r\n');
-                }
-            };
+                    if (msg.type === 'output') {
+                        // Write raw ANSI output directly to xterm.js
+                        term.write(msg.content);
+                    } else if (msg.type === 'status') {
+                        // Status messages in yellow
+                        term.writeln('\r\n\x1b[33m' + msg.content + '\x1b[0m\r\n');
+                    } else if (msg.type === 'heartbeat') {
+                        pane.lastHeartbeat = Date.now();
+                        if (!AUTO_START && !viewMode) pane.needsStart = !msg.alive;
+                        const time = new Date().toLocaleTimeString();
+                        if (msg.alive) {
+                            pane.setStatus('🟢 Live · up ' + msg.content + ' · ' + time, 'connected');
+                        } else {
+                            pane.setStatus('🔴 Session ended · ' + time, 'disconnected');
+                        }
+                    } else if (msg.type === 'logout') {
+                        // The login expired; reload to get the login page
+                        viewClosed = true;
+                        window.location.reload();
+                    } else if (msg.type === 'resume') {
+                        // Token for reattaching to this session after a reconnect
+                        resumeToken = msg.content;
+                        sessionStorage.setItem(tokenKey, resumeToken);
+                        pane.needsStart = false;
+                        if (pane === activePane) showStatus();
+                    } else if (msg.type === 'error') {
+                        // Error messages in red with newlines
+                        term.writeln('\r\n\x1b[31m' + msg.content + '\x1b[0m\r\n');
+                        // A viewer's session is gone; reconnecting won't help
+                        if (viewMode) viewClosed = true;
+                    }
+                };
+            }
+
+            // reconnect retries connect after the current backoff delay
+            function reconnect() {
+                if (removed || viewClosed) return;
+                const seconds = Math.round(reconnectDelay / 1000);
+                pane.setStatus('❌ Disconnected - Reconnecting in ' + seconds + 's...', 'disconnected');
+                term.writeln('\r\n\x1b[31m❌ WebSocket disconnected - reconnecting in ' + seconds + 's...\x1b[0m\r\n');
+                setTimeout(connect, reconnectDelay);
+                reconnectDelay = Math.min(reconnectDelay * 2, RECONNECT_MAX_MS);
+            }
+
+            connect();
+            return pane;
+        }
 
-            ws.onmessage = (event) => {
-                const msg = JSON.parse(event.data);
+        // showStatus shows the active pane's connection status, and Start
+        // if it has no session yet
+        function showStatus() {
+            statusEl.textContent = activePane.status[0];
+            statusEl.className = 'status ' + activePane.status[1];
+            startEl.hidden = !activePane.needsStart;
+        }
 
-                if (msg.chatId && !chatId) {
-                    chatId = msg.chatId;
-                }
+        function setActive(pane) {
+            activePane = pane;
+            panes.forEach(p => p.el.classList.toggle('active', p === pane));
+            showStatus();
+        }
 
-                if (msg.type === 'output') {
-                    // Write raw ANSI output directly to xterm.js
-                    term.write(msg.content);
-                } else if (msg.type === 'status') {
-                    // Status messages in yellow
-                    term.writeln('\r\n\x1b[33m' + msg.content + '\x1b[0m\r\n');
-                } else if (msg.type === 'error') {
-                    // Error messages in red with newlines
-                    term.writeln('\r\n\x1b[31m' + msg.content + '\x1b[0m\r\n');
-                }
-            };
+        // Split opens a second pane with a session of its own beside the
+        // first, and closes it again. The layout survives a reload
+        const splitEl = document.getElementById('split');
+        splitEl.hidden = viewMode;
+        function setSplit(split) {
+            if (split && panes.length === 1) {
+                const el = document.createElement('div');
+                el.className = 'pane';
+                panesEl.appendChild(el);
+                panes.push(createPane(1, el));
+                sessionStorage.setItem('split', '1');
+            } else if (!split && panes.length > 1) {
+                panes.pop().close();
+                sessionStorage.removeItem('split');
+            }
+            panesEl.classList.toggle('split', panes.length > 1);
+            splitEl.textContent = panes.length > 1 ? 'Unsplit' : 'Split';
+            // Both panes changed size
+            panes.forEach(pane => pane.sendResize());
+            setActive(panes[panes.length - 1]);
+            focusActive();
         }
+        splitEl.addEventListener('click', () => setSplit(panes.length === 1));
+
+        // Handle window resize and communicate each pane's size to the backend
+        window.addEventListener('resize', () => {
+            panes.forEach(pane => pane.sendResize());
+        });
 
         // Initialize terminal and connect on load
-        initTerminal();
-        connect();
+        panes.push(createPane(0, document.getElementById('terminal')));
+        setActive(panes[0]);
+        applyTheme(currentTheme);
+        if (!viewMode && sessionStorage.getItem('split')) {
+            setSplit(true);
+        }
+
+        // Auto-focus terminal on load
+        focusActive();
     </script>
 </body>
 </html>
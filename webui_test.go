@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,7 +18,9 @@ import (
 	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -236,7 +245,7 @@ func TestWebMessageResizeFields(t *testing.T) {
 // TestTerminalSendRawInput verifies raw input reaches PTY without modification
 func TestTerminalSendRawInput(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -264,7 +273,7 @@ func TestTerminalSendRawInput(t *testing.T) {
 // TestTerminalSendRawInputNoNewline verifies SendRawInput does NOT add newline
 func TestTerminalSendRawInputNoNewline(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -308,7 +317,7 @@ drain:
 // TestTerminalResize verifies PTY resize works
 func TestTerminalResize(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -338,7 +347,7 @@ func TestTerminalResize(t *testing.T) {
 // TestTerminalResizeReflectedInSTTY verifies PTY actually changed size
 func TestTerminalResizeReflectedInSTTY(t *testing.T) {
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -623,7 +632,7 @@ func TestWebUIServerCleanup(t *testing.T) {
 
 	// Create a terminal for the session
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -660,7 +669,7 @@ func TestHandleResizeWithActiveSession(t *testing.T) {
 	server := NewWebUIServer(nil)
 
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -725,7 +734,7 @@ func TestHandleResizeWithZeroDimensions(t *testing.T) {
 	server := NewWebUIServer(nil)
 
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -779,7 +788,7 @@ func TestHandleRawInputWithActiveSession(t *testing.T) {
 	server := NewWebUIServer(nil)
 
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -790,7 +799,7 @@ func TestHandleRawInputWithActiveSession(t *testing.T) {
 
 	session := &Session{
 		Terminal:  term,
-		Sink:     sink,
+		Sinks:     []OutputSink{sink},
 		Active:    true,
 		Command:   "shell",
 		StartedAt: time.Now(),
@@ -843,7 +852,7 @@ func TestStreamSessionOutputCodePath(t *testing.T) {
 	// Verify that the WebUI streaming function sends raw output by checking
 	// that the output received via MockSink contains ANSI escape codes
 	sink := &MockSink{}
-	term, err := NewTerminal(sink)
+	term, err := NewTerminal(sink, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create terminal: %v", err)
 	}
@@ -946,6 +955,16 @@ func TestHTMLWindowResizeHandler(t *testing.T) {
 	}
 }
 
+// TestHTMLSplitPanes verifies the Split button and that each pane has its
+// own terminal, connection and resume token
+func TestHTMLSplitPanes(t *testing.T) {
+	for _, pattern := range []string{`id="split"`, `id="panes"`, "function createPane(", "'resumeToken-' + index", "pane.sendResize()"} {
+		if !strings.Contains(htmlContent, pattern) {
+			t.Errorf("htmlContent missing %q", pattern)
+		}
+	}
+}
+
 // --- WebUI Authentication Tests ---
 
 // newTestServer creates an httptest.Server from a WebUIServer with optional config.
@@ -962,7 +981,14 @@ func newTestServer(config *Config) (*WebUIServer, *httptest.Server, func()) {
 	mux.HandleFunc("/setup-password", srv.handleSetupPassword)
 	mux.HandleFunc("/login", srv.handleLogin)
 	mux.HandleFunc("/logout", srv.handleLogout)
+	mux.HandleFunc("/change-password", srv.handleChangePassword)
 	mux.HandleFunc("/ws", srv.handleWebSocket)
+	mux.HandleFunc("/sessions", srv.handleSessions)
+	mux.HandleFunc("/upload", srv.handleUpload)
+	mux.HandleFunc("/theme", srv.handleTheme)
+	mux.HandleFunc("/fs", srv.handleFS)
+	mux.HandleFunc("/download", srv.handleDownload)
+	mux.HandleFunc("/session-log", srv.handleSessionLog)
 	ts := httptest.NewServer(mux)
 
 	cleanup := func() {
@@ -1104,6 +1130,593 @@ func TestWebUILogin(t *testing.T) {
 	}
 }
 
+// TestWebUILoginRehashesWhenCostIncreased verifies a login upgrades a hash
+// made with a lower cost than Config.BcryptCost and saves it
+func TestWebUILoginRehashesWhenCostIncreased(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	config := &Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost + 1}
+	srv, ts, cleanup := newTestServer(config)
+	defer cleanup()
+
+	resp, err := http.PostForm(ts.URL+"/login", url.Values{"password": {"secret"}})
+	if err != nil {
+		t.Fatalf("POST /login error: %v", err)
+	}
+	resp.Body.Close()
+
+	cost, err := bcrypt.Cost([]byte(srv.config.WebUIPasswordHash))
+	if err != nil || cost != bcrypt.MinCost+1 {
+		t.Fatalf("hash cost = %d (err %v), want %d", cost, err, bcrypt.MinCost+1)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(srv.config.WebUIPasswordHash), []byte("secret")); err != nil {
+		t.Errorf("rehashed password doesn't match: %v", err)
+	}
+
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if saved.WebUIPasswordHash != srv.config.WebUIPasswordHash {
+		t.Error("rehashed password was not saved")
+	}
+}
+
+// TestWebUILoginRehashKeepsSavedSettings verifies a rehash on login only
+// writes the hash, keeping users the daemon approved after the WebUI loaded
+// its config
+func TestWebUILoginRehashKeepsSavedSettings(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost + 1})
+	defer cleanup()
+	if err := saveConfig(&Config{WebUIPasswordHash: string(hash), AllowedUsers: []int64{111, 222}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.PostForm(ts.URL+"/login", url.Values{"password": {"secret"}})
+	if err != nil {
+		t.Fatalf("POST /login error: %v", err)
+	}
+	resp.Body.Close()
+
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if saved.WebUIPasswordHash != srv.passwordHash() || saved.WebUIPasswordHash == string(hash) {
+		t.Error("rehashed password was not saved")
+	}
+	if len(saved.AllowedUsers) != 2 {
+		t.Errorf("saved AllowedUsers = %v, want [111 222] kept", saved.AllowedUsers)
+	}
+}
+
+// TestWebUILoginKeepsHashWhenCostUnchanged verifies hashes at or above the
+// configured cost, and failed logins, leave the stored hash alone
+func TestWebUILoginKeepsHashWhenCostUnchanged(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost+1)
+	config := &Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost}
+	srv, ts, cleanup := newTestServer(config)
+	defer cleanup()
+
+	for _, password := range []string{"secret", "wrong"} {
+		resp, err := http.PostForm(ts.URL+"/login", url.Values{"password": {password}})
+		if err != nil {
+			t.Fatalf("POST /login error: %v", err)
+		}
+		resp.Body.Close()
+
+		if srv.config.WebUIPasswordHash != string(hash) {
+			t.Errorf("password %q: hash changed without a cost increase", password)
+		}
+	}
+	if _, err := loadConfig(); err == nil {
+		t.Error("config saved without a rehash")
+	}
+}
+
+// TestWebUIRehashKeepsNewerPassword verifies a login's rehash doesn't
+// overwrite a password changed after the login verified the old one
+func TestWebUIRehashKeepsNewerPassword(t *testing.T) {
+	oldHash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	newHash, _ := bcrypt.GenerateFromPassword([]byte("n3w"), bcrypt.MinCost)
+	srv, _, cleanup := newTestServer(&Config{WebUIPasswordHash: string(newHash), BcryptCost: bcrypt.MinCost + 1})
+	defer cleanup()
+
+	srv.upgradePasswordHash("secret", string(oldHash))
+	if srv.passwordHash() != string(newHash) {
+		t.Error("rehash of the old password replaced the newer one")
+	}
+	if _, err := loadConfig(); err == nil {
+		t.Error("config saved by a stale rehash")
+	}
+}
+
+// postChangePassword POSTs a password change as the login token and
+// returns the response, without following the redirect
+func postChangePassword(t *testing.T, ts *httptest.Server, token, current, password, confirm string) (int, string) {
+	t.Helper()
+	form := url.Values{"current": {current}, "password": {password}, "confirm": {confirm}}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/change-password", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cookie", "session="+token)
+	client := &http.Client{CheckRedirect: func(r *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST /change-password error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// TestWebUIChangePassword verifies a change rehashes and saves the new
+// password and ends every other login
+func TestWebUIChangePassword(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost})
+	defer cleanup()
+	token := srv.createAuthSession()
+	other := srv.createAuthSession()
+
+	if status, _ := postChangePassword(t, ts, token, "secret", "n3w", "n3w"); status != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", status, http.StatusSeeOther)
+	}
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config not saved: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(saved.WebUIPasswordHash), []byte("n3w")) != nil {
+		t.Error("saved hash doesn't match the new password")
+	}
+	if !srv.touchAuthSession(token) {
+		t.Error("the login that changed the password was ended")
+	}
+	if srv.touchAuthSession(other) {
+		t.Error("other login still valid after the password change")
+	}
+}
+
+// TestWebUIChangePasswordKeepsSavedSettings verifies a password change
+// only writes the hash, keeping users the daemon approved after the WebUI
+// loaded its config
+func TestWebUIChangePasswordKeepsSavedSettings(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost})
+	defer cleanup()
+	token := srv.createAuthSession()
+	if err := saveConfig(&Config{WebUIPasswordHash: string(hash), AllowedUsers: []int64{111, 222}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := postChangePassword(t, ts, token, "secret", "n3w", "n3w"); status != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", status, http.StatusSeeOther)
+	}
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if len(saved.AllowedUsers) != 2 {
+		t.Errorf("saved AllowedUsers = %v, want [111 222] kept", saved.AllowedUsers)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(saved.WebUIPasswordHash), []byte("n3w")) != nil {
+		t.Error("saved hash doesn't match the new password")
+	}
+}
+
+// TestWebUIChangePasswordDuringLogins verifies a password change racing
+// logins that rehash the old password is never undone by them. Run with
+// -race to check every access to the hash is locked.
+func TestWebUIChangePasswordDuringLogins(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash), BcryptCost: bcrypt.MinCost + 1})
+	defer cleanup()
+	token := srv.createAuthSession()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.PostForm(ts.URL+"/login", url.Values{"password": {"secret"}})
+			if err != nil {
+				t.Errorf("POST /login error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	status, _ := postChangePassword(t, ts, token, "secret", "n3w", "n3w")
+	wg.Wait()
+
+	want := "secret"
+	if status == http.StatusSeeOther {
+		want = "n3w"
+	}
+	if bcrypt.CompareHashAndPassword([]byte(srv.passwordHash()), []byte(want)) != nil {
+		t.Errorf("change status %d, but the stored hash doesn't match %q", status, want)
+	}
+}
+
+// TestWebUIChangePasswordRejected verifies a wrong current password or a
+// mismatched confirmation leaves the password and logins alone
+func TestWebUIChangePasswordRejected(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+	token := srv.createAuthSession()
+	other := srv.createAuthSession()
+
+	tests := []struct {
+		name, current, password, confirm, wantErr string
+	}{
+		{"wrong current", "guess", "n3w", "n3w", "Current password is incorrect"},
+		{"mismatch", "secret", "n3w", "new", "New passwords do not match"},
+		{"empty", "secret", "", "", "New password cannot be empty"},
+	}
+	for _, tt := range tests {
+		status, body := postChangePassword(t, ts, token, tt.current, tt.password, tt.confirm)
+		if status != http.StatusOK || !strings.Contains(body, tt.wantErr) {
+			t.Errorf("%s: status %d, want the form with %q", tt.name, status, tt.wantErr)
+		}
+	}
+	if srv.config.WebUIPasswordHash != string(hash) {
+		t.Error("password hash changed by a rejected request")
+	}
+	if !srv.touchAuthSession(other) {
+		t.Error("other login ended by a rejected request")
+	}
+
+	// Without a login the form isn't served, let alone accepted
+	if status, _ := postChangePassword(t, ts, "bogus", "secret", "n3w", "n3w"); status != http.StatusSeeOther {
+		t.Errorf("unauthenticated status = %d, want redirect", status)
+	}
+	if srv.config.WebUIPasswordHash != string(hash) {
+		t.Error("password changed without a login")
+	}
+}
+
+// postUploadFile POSTs content as a multipart file upload to link
+func postUploadFile(t *testing.T, link, name, content string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", name)
+	fw.Write([]byte(content))
+	mw.Close()
+
+	resp, err := http.Post(link, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("POST %s error: %v", link, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// TestWebUIUploadLink verifies an issued /upload link serves a form, saves
+// one file into the working directory, and cannot be reused
+func TestWebUIUploadLink(t *testing.T) {
+	_, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	if err := publishUploadURL(ts.URL); err != nil {
+		t.Fatalf("publishUploadURL() error: %v", err)
+	}
+	link, err := issueUploadLink()
+	if err != nil {
+		t.Fatalf("issueUploadLink() error: %v", err)
+	}
+	if !strings.HasPrefix(link, ts.URL+"/upload?token=") {
+		t.Fatalf("unexpected link: %s", link)
+	}
+
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatalf("GET upload form error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET upload form status = %d, want 200", resp.StatusCode)
+	}
+
+	if resp := postUploadFile(t, link, "big.bin", "payload"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload status = %d, want 200", resp.StatusCode)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "big.bin"))
+	if err != nil || string(data) != "payload" {
+		t.Errorf("saved file = %q (err %v), want %q", data, err, "payload")
+	}
+
+	if resp := postUploadFile(t, link, "again.bin", "x"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("reused link status = %d, want 403", resp.StatusCode)
+	}
+}
+
+// TestWebUIUploadRejectsBadToken verifies unknown and expired tokens are refused
+func TestWebUIUploadRejectsBadToken(t *testing.T) {
+	_, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	uploadStoreMu.Lock()
+	store := loadUploadStore()
+	store.URL = ts.URL
+	store.Tokens["expired"] = time.Now().Add(-time.Minute)
+	store.save()
+	uploadStoreMu.Unlock()
+
+	for _, token := range []string{"", "bogus", "expired"} {
+		resp, err := http.Get(ts.URL + "/upload?token=" + token)
+		if err != nil {
+			t.Fatalf("GET error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("token %q: status = %d, want 403", token, resp.StatusCode)
+		}
+	}
+}
+
+// TestIssueUploadLinkWithoutWebUI verifies no link is issued until a WebUI
+// has published its address
+func TestIssueUploadLinkWithoutWebUI(t *testing.T) {
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = "" }()
+
+	if _, err := issueUploadLink(); err != errWebUINotRunning {
+		t.Errorf("issueUploadLink() error = %v, want errWebUINotRunning", err)
+	}
+}
+
+// TestWebUIThemePersists verifies a theme chosen via /theme is saved to the
+// config and injected into the terminal page on later visits
+func TestWebUIThemePersists(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+	cookie := "session=" + srv.createAuthSession()
+
+	do := func(method, path string, form url.Values) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Cookie", cookie)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error: %v", method, path, err)
+		}
+		return resp
+	}
+	page := func() string {
+		resp := do(http.MethodGet, "/", nil)
+		defer resp.Body.Close()
+		var b strings.Builder
+		io.Copy(&b, resp.Body)
+		return b.String()
+	}
+
+	if !strings.Contains(page(), "let currentTheme = 'matrix'") {
+		t.Error("default theme not injected")
+	}
+
+	// Users the daemon approved since the WebUI loaded its config survive
+	if err := saveConfig(&Config{WebUIPasswordHash: string(hash), AllowedUsers: []int64{111}}); err != nil {
+		t.Fatal(err)
+	}
+	resp := do(http.MethodPost, "/theme", url.Values{"theme": {"light"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("POST /theme status = %d, want 204", resp.StatusCode)
+	}
+	if saved, err := loadConfig(); err != nil || saved.WebUITheme != "light" {
+		t.Errorf("theme not saved to config (err %v)", err)
+	} else if len(saved.AllowedUsers) != 1 {
+		t.Errorf("saved AllowedUsers = %v, want [111] kept", saved.AllowedUsers)
+	}
+	body := page()
+	if !strings.Contains(body, "let currentTheme = 'light'") {
+		t.Error("saved theme not injected")
+	}
+	if !strings.Contains(body, `"solarized-dark":{`) {
+		t.Error("theme list not injected")
+	}
+
+	resp = do(http.MethodPost, "/theme", url.Values{"theme": {"neon"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown theme status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, err := http.PostForm(ts.URL+"/theme", url.Values{"theme": {"matrix"}})
+	if err != nil {
+		t.Fatalf("POST /theme error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", resp.StatusCode)
+	}
+}
+
+// TestResolveInRoot verifies file browser paths can't escape the root via
+// "..", absolute paths, or symlinks.
+func TestResolveInRoot(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(t.TempDir())
+	outside, _ := filepath.EvalSymlinks(t.TempDir())
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("a"), 0644)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "inside"))
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{"", root, nil},
+		{"sub", filepath.Join(root, "sub"), nil},
+		{filepath.Join(root, "sub", "a.txt"), filepath.Join(root, "sub", "a.txt"), nil},
+		{"sub/../sub/a.txt", filepath.Join(root, "sub", "a.txt"), nil},
+		{"inside/a.txt", filepath.Join(root, "sub", "a.txt"), nil},
+		{"..", "", errOutsideFileRoot},
+		{"sub/../../", "", errOutsideFileRoot},
+		{outside, "", errOutsideFileRoot},
+		{"escape", "", errOutsideFileRoot},
+		{"missing", "", fs.ErrNotExist},
+		{"../missing", "", errOutsideFileRoot},
+	}
+	for _, tt := range tests {
+		got, err := resolveInRoot(root, tt.path)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("resolveInRoot(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveInRoot(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+// TestWebUIFileBrowser lists directories and downloads files through /fs and
+// /download, and verifies both require login and stay inside file_root.
+func TestWebUIFileBrowser(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(t.TempDir())
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello files"), 0644)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash), FileRoot: root})
+	defer cleanup()
+	cookie := "session=" + srv.createAuthSession()
+
+	get := func(path string, auth bool) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if auth {
+			req.Header.Set("Cookie", cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/fs", true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /fs status = %d, want 200 (%s)", resp.StatusCode, body)
+	}
+	var listing fileListing
+	if err := json.Unmarshal([]byte(body), &listing); err != nil {
+		t.Fatalf("decoding listing: %v", err)
+	}
+	want := []fileEntry{{Name: "docs", Size: listing.Entries[0].Size, IsDir: true}, {Name: "notes.txt", Size: 11}}
+	if listing.Path != root || listing.Parent != "" || len(listing.Entries) != 2 ||
+		listing.Entries[0] != want[0] || listing.Entries[1] != want[1] {
+		t.Errorf("root listing = %+v, want path %q, no parent, entries %+v", listing, root, want)
+	}
+
+	resp, body = get("/fs?path=docs", true)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"parent":`) {
+		t.Errorf("GET /fs?path=docs = %d %s, want 200 with a parent", resp.StatusCode, body)
+	}
+
+	resp, body = get("/download?path="+url.QueryEscape(filepath.Join(root, "notes.txt")), true)
+	if resp.StatusCode != http.StatusOK || body != "hello files" {
+		t.Errorf("download = %d %q, want 200 with file content", resp.StatusCode, body)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, `attachment; filename=notes.txt`) {
+		t.Errorf("Content-Disposition = %q, want attachment for notes.txt", cd)
+	}
+
+	tests := []struct {
+		path string
+		auth bool
+		want int
+	}{
+		{"/fs", false, http.StatusUnauthorized},
+		{"/download?path=notes.txt", false, http.StatusUnauthorized},
+		{"/fs?path=..", true, http.StatusForbidden},
+		{"/download?path=" + url.QueryEscape("../../etc/passwd"), true, http.StatusForbidden},
+		{"/fs?path=missing", true, http.StatusNotFound},
+		{"/fs?path=notes.txt", true, http.StatusBadRequest},
+		{"/download?path=docs", true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp, _ := get(tt.path, tt.auth); resp.StatusCode != tt.want {
+			t.Errorf("GET %s (auth %v) status = %d, want %d", tt.path, tt.auth, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestWebUISessionLog verifies /session-log serves everything a session
+// printed, even while detached, keeps only the latest maxSessionLog bytes,
+// and requires login.
+func TestWebUISessionLog(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+	cookie := "session=" + srv.createAuthSession()
+
+	session := &Session{Active: true, Sinks: []OutputSink{&MockSink{}}, StartedAt: time.Now()}
+	srv.mu.Lock()
+	srv.sessions[7] = session
+	srv.mu.Unlock()
+	srv.sendOutput(session, "\x1b[32mfirst\x1b[0m\r\n")
+	session.setSink(nil)
+	srv.sendOutput(session, "second\r\n")
+
+	get := func(path string, auth bool) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if auth {
+			req.Header.Set("Cookie", cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/session-log?id=7", true)
+	if resp.StatusCode != http.StatusOK || body != "\x1b[32mfirst\x1b[0m\r\nsecond\r\n" {
+		t.Errorf("GET /session-log = %d %q, want 200 with all raw output", resp.StatusCode, body)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "attachment; filename=session-7-") || !strings.HasSuffix(cd, ".log") {
+		t.Errorf("Content-Disposition = %q, want a session-7-*.log attachment", cd)
+	}
+
+	srv.sendOutput(session, strings.Repeat("x", maxSessionLog))
+	if _, body := get("/session-log?id=7", true); len(body) != maxSessionLog || strings.Contains(body, "second") {
+		t.Errorf("log kept %d bytes, want the last %d", len(body), maxSessionLog)
+	}
+
+	tests := []struct {
+		path string
+		auth bool
+		want int
+	}{
+		{"/session-log?id=7", false, http.StatusUnauthorized},
+		{"/session-log?id=8", true, http.StatusNotFound},
+		{"/session-log?id=abc", true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp, _ := get(tt.path, tt.auth); resp.StatusCode != tt.want {
+			t.Errorf("GET %s (auth %v) status = %d, want %d", tt.path, tt.auth, resp.StatusCode, tt.want)
+		}
+	}
+}
+
 // TestWebUIWebSocketRejectsUnauthenticated verifies /ws returns 401 without cookie
 func TestWebUIWebSocketRejectsUnauthenticated(t *testing.T) {
 	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
@@ -1210,7 +1823,7 @@ func TestWebUISessionExpiry(t *testing.T) {
 	// Create an already-expired session
 	token := generateSessionToken()
 	srv.mu.Lock()
-	srv.authSessions[token] = time.Now().Add(-1 * time.Hour) // expired 1 hour ago
+	srv.authSessions[token] = newAuthSession(time.Now().Add(-25*time.Hour), 0, 24*time.Hour) // expired 1 hour ago
 	srv.mu.Unlock()
 
 	// Request with expired token should show login page, not terminal
@@ -1290,3 +1903,1029 @@ func TestGenerateSessionToken(t *testing.T) {
 		t.Error("Two generated tokens are identical — crypto/rand failure")
 	}
 }
+
+// TestWebUIAuthSessionsPersistAcrossRestart verifies login tokens survive a new server instance
+func TestWebUIAuthSessionsPersistAcrossRestart(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	config := &Config{WebUIPasswordHash: string(hash)}
+	srv, _, cleanup := newTestServer(config)
+	defer cleanup()
+
+	token := srv.createAuthSession()
+
+	// File must be owner-only, like the config
+	info, err := os.Stat(authSessionsPath())
+	if err != nil {
+		t.Fatalf("sessions file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("sessions file permissions = %o, want 0600", perm)
+	}
+
+	// "Restart": a fresh server loads the persisted token
+	restarted := NewWebUIServer(config)
+	restarted.mu.Lock()
+	_, exists := restarted.authSessions[token]
+	restarted.mu.Unlock()
+	if !exists {
+		t.Error("auth token not restored after restart")
+	}
+}
+
+// TestWebUIAuthSessionsPruneExpiredOnLoad verifies expired tokens are dropped when loading
+func TestWebUIAuthSessionsPruneExpiredOnLoad(t *testing.T) {
+	srv, _, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	srv.mu.Lock()
+	srv.authSessions["expired"] = newAuthSession(time.Now().Add(-2*time.Hour), 0, time.Hour)
+	srv.authSessions["valid"] = newAuthSession(time.Now(), 0, time.Hour)
+	srv.saveAuthSessionsLocked()
+	srv.mu.Unlock()
+
+	loaded := loadAuthSessions()
+	if _, ok := loaded["expired"]; ok {
+		t.Error("expired token should be pruned on load")
+	}
+	if _, ok := loaded["valid"]; !ok {
+		t.Error("valid token should be loaded")
+	}
+}
+
+// TestAuthSessionSlidingExpiry verifies activity extends a login by the
+// idle window, never past its absolute deadline
+func TestAuthSessionSlidingExpiry(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := newAuthSession(start, 2*time.Hour, 24*time.Hour)
+	if !a.Expires.Equal(start.Add(2*time.Hour)) || !a.Deadline.Equal(start.Add(24*time.Hour)) {
+		t.Fatalf("new login expires %v, deadline %v", a.Expires, a.Deadline)
+	}
+	if a.valid(start.Add(3 * time.Hour)) {
+		t.Error("login should idle out after 2h without activity")
+	}
+
+	a.extend(start.Add(time.Hour), 2*time.Hour)
+	if !a.Expires.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("activity at 1h: expires %v, want 3h", a.Expires)
+	}
+	a.extend(start.Add(23*time.Hour), 2*time.Hour)
+	if !a.Expires.Equal(a.Deadline) || a.valid(start.Add(24*time.Hour)) {
+		t.Errorf("activity near the deadline: expires %v, want capped at %v", a.Expires, a.Deadline)
+	}
+
+	if b := newAuthSession(start, 0, 24*time.Hour); !b.Expires.Equal(b.Deadline) {
+		t.Errorf("without an idle limit expires %v, want the deadline", b.Expires)
+	}
+}
+
+// TestWebUIIdleLogout verifies requests keep a login alive and an idle
+// login is rejected and removed
+func TestWebUIIdleLogout(t *testing.T) {
+	srv, ts, cleanup := newTestServer(&Config{WebUIIdleLogout: 30})
+	defer cleanup()
+	token := srv.createAuthSession()
+
+	status := func() int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/sessions", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: token})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /sessions error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Almost idle: a request slides the expiry a full window forward
+	srv.mu.Lock()
+	srv.authSessions[token].Expires = time.Now().Add(time.Second)
+	srv.authSessions[token].saved = srv.authSessions[token].Expires
+	srv.mu.Unlock()
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("active login status = %d, want 200", got)
+	}
+	srv.mu.Lock()
+	expires := srv.authSessions[token].Expires
+	srv.mu.Unlock()
+	if left := time.Until(expires); left < 29*time.Minute || left > 30*time.Minute {
+		t.Errorf("after activity the login expires in %v, want 30m", left)
+	}
+	if saved := loadAuthSessions()[token]; saved == nil || !saved.Expires.Equal(expires) {
+		t.Error("extended expiry not persisted")
+	}
+
+	srv.mu.Lock()
+	srv.authSessions[token].Expires = time.Now().Add(-time.Second)
+	srv.mu.Unlock()
+	if got := status(); got != http.StatusUnauthorized {
+		t.Errorf("idle login status = %d, want 401", got)
+	}
+	srv.mu.Lock()
+	_, exists := srv.authSessions[token]
+	srv.mu.Unlock()
+	if exists {
+		t.Error("idle login was not removed")
+	}
+}
+
+// TestLoadAuthSessionsLegacyFormat verifies a sessions file from before
+// sliding expiry (token → expiry) still loads
+func TestLoadAuthSessionsLegacyFormat(t *testing.T) {
+	_, _, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	expiry := time.Now().Add(time.Hour).UTC().Round(time.Second)
+	data, _ := json.Marshal(map[string]time.Time{"old": expiry, "stale": time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(authSessionsPath(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadAuthSessions()
+	if a := loaded["old"]; a == nil || !a.Expires.Equal(expiry) || !a.Deadline.Equal(expiry) {
+		t.Errorf("legacy token = %+v, want expiry and deadline %v", a, expiry)
+	}
+	if _, ok := loaded["stale"]; ok {
+		t.Error("expired legacy token should be pruned")
+	}
+}
+
+// TestWebUILogoutPersists verifies logout removes the token from disk
+func TestWebUILogoutPersists(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+
+	token := srv.createAuthSession()
+
+	req, _ := http.NewRequest("POST", ts.URL+"/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: token})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /logout error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, ok := loadAuthSessions()[token]; ok {
+		t.Error("logged-out token still persisted")
+	}
+}
+
+// TestUpgraderCheckOriginTLS verifies only https origins are accepted over TLS
+func TestUpgraderCheckOriginTLS(t *testing.T) {
+	tests := []struct {
+		name   string
+		tls    bool
+		origin string
+		want   bool
+	}{
+		{"plain_http_origin", false, "http://example.com:8080", true},
+		{"plain_https_origin", false, "https://example.com:8080", true},
+		{"tls_https_origin", true, "https://example.com:8080", true},
+		{"tls_http_origin_rejected", true, "http://example.com:8080", false},
+		{"foreign_origin_rejected", true, "https://evil.com", false},
+		{"no_origin", true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ws", nil)
+			req.Host = "example.com:8080"
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if got := upgrader.CheckOrigin(req); got != tt.want {
+				t.Errorf("CheckOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHTMLUsesWSSOverHTTPS verifies the client picks wss:// on https pages
+func TestHTMLUsesWSSOverHTTPS(t *testing.T) {
+	if !strings.Contains(htmlContent, "'wss://'") {
+		t.Error("htmlContent missing wss:// scheme selection for HTTPS")
+	}
+}
+
+// TestWebUIServerTLSConfig verifies TLS files come from config and flags override them
+func TestWebUIServerTLSConfig(t *testing.T) {
+	srv := NewWebUIServer(&Config{TLSCert: "cfg.crt", TLSKey: "cfg.key"})
+	if srv.tlsCert != "cfg.crt" || srv.tlsKey != "cfg.key" {
+		t.Errorf("TLS from config = (%q, %q)", srv.tlsCert, srv.tlsKey)
+	}
+
+	srv.SetTLS("flag.crt", "flag.key")
+	if srv.tlsCert != "flag.crt" || srv.tlsKey != "flag.key" {
+		t.Errorf("TLS after flags = (%q, %q)", srv.tlsCert, srv.tlsKey)
+	}
+
+	srv.SetTLS("", "")
+	if srv.tlsCert != "flag.crt" {
+		t.Error("empty flags should not clear configured TLS files")
+	}
+
+	// Session cookies are marked Secure when serving TLS, and only then
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() { configPathOverride = "" })
+	plain := NewWebUIServer(nil)
+	for _, tt := range []struct {
+		name    string
+		handler func(*WebUIServer) http.HandlerFunc
+		form    url.Values
+	}{
+		{"setup", func(s *WebUIServer) http.HandlerFunc { return s.handleSetupPassword }, url.Values{"password": {"pw"}, "confirm": {"pw"}}},
+		{"login", func(s *WebUIServer) http.HandlerFunc { return s.handleLogin }, url.Values{"password": {"pw"}}},
+		{"logout", func(s *WebUIServer) http.HandlerFunc { return s.handleLogout }, nil},
+	} {
+		for _, s := range []*WebUIServer{srv, plain} {
+			if tt.name == "setup" {
+				s.config.WebUIPasswordHash = ""
+				s.config.BcryptCost = bcrypt.MinCost
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			tt.handler(s)(rec, req)
+			cookies := rec.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("%s: got %d cookies, want 1", tt.name, len(cookies))
+			}
+			if want := s == srv; cookies[0].Secure != want {
+				t.Errorf("%s: cookie Secure = %v, want %v", tt.name, cookies[0].Secure, want)
+			}
+		}
+	}
+}
+
+// --- Session listing and switching ---
+
+// TestWebUISessionsRequiresAuth verifies /sessions returns 401 without cookie
+func TestWebUISessionsRequiresAuth(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	_, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/sessions")
+	if err != nil {
+		t.Fatalf("GET /sessions error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /sessions without auth: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+// TestWebUISessionsListsActive verifies /sessions returns active sessions sorted by ID
+func TestWebUISessionsListsActive(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	srv, ts, cleanup := newTestServer(&Config{WebUIPasswordHash: string(hash)})
+	defer cleanup()
+
+	started := time.Now().Add(-time.Minute)
+	srv.mu.Lock()
+	srv.sessions[7] = &Session{Active: true, Command: "python3", StartedAt: started}
+	srv.sessions[3] = &Session{Active: true, Command: "shell", StartedAt: started, Sinks: []OutputSink{&MockSink{}}}
+	srv.sessions[5] = &Session{Active: false, Command: "vim", StartedAt: started}
+	srv.mu.Unlock()
+
+	token := srv.createAuthSession()
+	req, _ := http.NewRequest("GET", ts.URL+"/sessions", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: token})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /sessions error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var list []sessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d sessions, want 2 (inactive excluded): %+v", len(list), list)
+	}
+	if list[0].ID != 3 || list[1].ID != 7 {
+		t.Errorf("sessions not sorted by ID: %+v", list)
+	}
+	if list[1].Command != "python3" || list[1].Duration != "1m0s" {
+		t.Errorf("session 7 = %+v, want command python3 and duration 1m0s", list[1])
+	}
+	if !list[0].Attached || list[1].Attached {
+		t.Errorf("attached flags wrong: %+v", list)
+	}
+}
+
+// dialTestWebSocket opens an authenticated WebSocket to the test server
+func dialTestWebSocket(t *testing.T, srv *WebUIServer, ts *httptest.Server) *websocket.Conn {
+	t.Helper()
+	header := http.Header{}
+	header.Set("Cookie", "session="+srv.createAuthSession())
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("dial: %v (status %d)", err, status)
+	}
+	return conn
+}
+
+// TestWebUISplitPanes verifies two connections from one login, as the
+// split layout opens, get separate sessions whose PTYs take each pane's
+// size
+func TestWebUISplitPanes(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	left := dialTestWebSocket(t, srv, ts)
+	defer left.Close()
+	right := dialTestWebSocket(t, srv, ts)
+	defer right.Close()
+	leftID := readUntil(t, left, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+	rightID := readUntil(t, right, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+	if leftID == rightID {
+		t.Fatalf("both panes attached to session %d", leftID)
+	}
+
+	for _, pane := range []struct {
+		conn       *websocket.Conn
+		rows, cols int
+	}{{left, 30, 70}, {right, 30, 71}} {
+		if err := pane.conn.WriteJSON(WebMessage{Type: "resize", Rows: pane.rows, Cols: pane.cols}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, pane := range []struct {
+		conn *websocket.Conn
+		want string
+	}{{left, "30 70"}, {right, "30 71"}} {
+		if err := pane.conn.WriteJSON(WebMessage{Type: "command", Content: "stty size"}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		readUntil(t, pane.conn, func(m WebMessage) bool { return strings.Contains(m.Content, pane.want) })
+	}
+}
+
+// TestWebUISwitchSession verifies a "switch" message reattaches the
+// connection to another session and detaches it from its own shell.
+func TestWebUISwitchSession(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	target := &Session{Terminal: term, Active: true, Command: "python3", StartedAt: time.Now(), done: make(chan struct{})}
+	srv.mu.Lock()
+	srv.sessions[42] = target
+	srv.mu.Unlock()
+
+	// The connection's own shell session is the first ID handed out
+	const ownID = int64(1)
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+	sinkOf := func(id int64) OutputSink {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		if session, ok := srv.sessions[id]; ok {
+			if sinks := session.sinkList(); len(sinks) > 0 {
+				return sinks[0]
+			}
+		}
+		return nil
+	}
+	if !waitFor(func() bool { return sinkOf(ownID) != nil }) {
+		t.Fatal("shell session was not started")
+	}
+
+	if err := conn.WriteJSON(WebMessage{Type: "switch", ChatID: 42}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg WebMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("no switch confirmation: %v", err)
+		}
+		if msg.Type == "status" && strings.Contains(msg.Content, "session 42") {
+			if msg.ChatID != 42 {
+				t.Errorf("status chatId = %d, want 42", msg.ChatID)
+			}
+			break
+		}
+	}
+
+	if sinkOf(42) == nil {
+		t.Error("target session has no sink after switch")
+	}
+	if sinkOf(ownID) != nil {
+		t.Error("previous session still attached after switch")
+	}
+
+	// Disconnecting detaches the attached session for the resume grace period
+	conn.Close()
+	if !waitFor(func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		session, exists := srv.sessions[42]
+		return exists && !session.attached() && session.graceTimer != nil
+	}) {
+		t.Error("attached session not detached on disconnect")
+	}
+
+	srv.cleanup(42)
+	srv.cleanup(ownID)
+}
+
+// TestWebUISwitchUnknownSession verifies switching to a missing session is refused
+func TestWebUISwitchUnknownSession(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	defer srv.cleanup(1)
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+
+	if err := conn.WriteJSON(WebMessage{Type: "switch", ChatID: 999}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg WebMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("no refusal status: %v", err)
+		}
+		if msg.Type == "status" && strings.Contains(msg.Content, "No active session 999") {
+			break
+		}
+	}
+}
+
+// TestWebUIServerHostConfig verifies the bind host defaults to localhost and
+// can be set from config or overridden by --web-host
+func TestWebUIServerHostConfig(t *testing.T) {
+	if got := NewWebUIServer(nil).host; got != "localhost" {
+		t.Errorf("default host = %q, want localhost", got)
+	}
+
+	srv := NewWebUIServer(&Config{WebUIHost: "0.0.0.0"})
+	if srv.host != "0.0.0.0" {
+		t.Errorf("config host = %q, want 0.0.0.0", srv.host)
+	}
+
+	srv.SetHost("")
+	if srv.host != "0.0.0.0" {
+		t.Errorf("empty flag should keep config host, got %q", srv.host)
+	}
+
+	srv.SetHost("192.168.1.10")
+	if srv.host != "192.168.1.10" {
+		t.Errorf("flag host = %q, want 192.168.1.10", srv.host)
+	}
+}
+
+// TestWebUICheckOriginConfiguredHost verifies origins naming the bind host
+// and port are accepted
+func TestWebUICheckOriginConfiguredHost(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   string
+		tls    bool
+		origin string
+		want   bool
+	}{
+		{"same_host", "localhost", false, "http://proxy.lan:8080", true},
+		{"configured_host", "192.168.1.10", false, "http://192.168.1.10:9000", true},
+		{"configured_host_tls", "192.168.1.10", true, "https://192.168.1.10:9000", true},
+		{"configured_host_http_over_tls", "192.168.1.10", true, "http://192.168.1.10:9000", false},
+		{"configured_host_other_port", "192.168.1.10", false, "http://192.168.1.10:9001", false},
+		{"configured_host_default_port", "192.168.1.10", false, "http://192.168.1.10", false},
+		{"other_host", "192.168.1.10", false, "http://192.168.1.11", false},
+		{"wildcard_adds_nothing", "0.0.0.0", false, "http://0.0.0.0", false},
+		{"foreign_origin", "localhost", false, "http://evil.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewWebUIServer(&Config{WebUIHost: tt.host})
+			srv.port = 9000
+			req := httptest.NewRequest("GET", "/ws", nil)
+			req.Host = "proxy.lan:8080"
+			req.Header.Set("Origin", tt.origin)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if got := srv.checkOrigin(req); got != tt.want {
+				t.Errorf("checkOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":    true,
+		"127.0.0.1":    true,
+		"::1":          true,
+		"0.0.0.0":      false,
+		"192.168.1.10": false,
+	} {
+		if got := isLoopbackHost(host); got != want {
+			t.Errorf("isLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+// readUntil reads WebSocket messages until match returns true
+func readUntil(t *testing.T, conn *websocket.Conn, match func(WebMessage) bool) WebMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg WebMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("expected message not received: %v", err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+// TestWebUIIdleTimeoutFires verifies a session with no output is stopped
+// once the configured idle timeout passes
+func TestWebUIIdleTimeoutFires(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	srv.idleTimeout = 500 * time.Millisecond
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+
+	msg := readUntil(t, conn, func(m WebMessage) bool {
+		return m.Type == "status" && strings.Contains(m.Content, "timed out")
+	})
+	if !strings.Contains(msg.Content, "500ms idle") {
+		t.Errorf("timeout message = %q, want the configured duration", msg.Content)
+	}
+}
+
+// TestWebUIIdleTimeoutDisabled verifies a zero idle timeout keeps quiet
+// sessions running
+func TestWebUIIdleTimeoutDisabled(t *testing.T) {
+	zero := 0
+	srv, ts, cleanup := newTestServer(&Config{IdleTimeoutMinutes: &zero})
+	defer cleanup()
+	if srv.idleTimeout != 0 {
+		t.Fatalf("idleTimeout = %v, want 0", srv.idleTimeout)
+	}
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+	tokenMsg := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" })
+	defer srv.cleanup(tokenMsg.ChatID)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var msg WebMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			break // Deadline reached without a timeout
+		}
+		if strings.Contains(msg.Content, "timed out") {
+			t.Fatalf("session timed out with idle timeout disabled: %q", msg.Content)
+		}
+	}
+	if len(srv.listSessions()) != 1 {
+		t.Error("session stopped with idle timeout disabled")
+	}
+}
+
+// TestWebUIResumeAfterDisconnect verifies a dropped connection can reattach
+// to its session with the resume token and receives output buffered meanwhile
+func TestWebUIResumeAfterDisconnect(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	tokenMsg := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" })
+	ownID := tokenMsg.ChatID
+	defer srv.cleanup(ownID)
+
+	conn.Close()
+
+	// Wait for the server to notice and detach
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.mu.Lock()
+		detached := srv.sessions[ownID] != nil && !srv.sessions[ownID].attached()
+		srv.mu.Unlock()
+		if detached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session was not detached after disconnect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Output produced while detached is buffered
+	srv.mu.Lock()
+	session := srv.sessions[ownID]
+	srv.mu.Unlock()
+	srv.sendOutput(session, "missed-while-away")
+
+	conn2 := dialTestWebSocket(t, srv, ts)
+	defer conn2.Close()
+	freshID := readUntil(t, conn2, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+
+	if err := conn2.WriteJSON(WebMessage{Type: "resume", Content: tokenMsg.Content}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readUntil(t, conn2, func(m WebMessage) bool {
+		return m.Type == "output" && strings.Contains(m.Content, "missed-while-away")
+	})
+	status := readUntil(t, conn2, func(m WebMessage) bool { return m.Type == "status" })
+	if !strings.Contains(status.Content, "Resumed session") || status.ChatID != ownID {
+		t.Errorf("status = %+v, want resumed session %d", status, ownID)
+	}
+
+	srv.mu.Lock()
+	_, freshExists := srv.sessions[freshID]
+	attached := session.attached() && session.graceTimer == nil
+	srv.mu.Unlock()
+	if freshExists {
+		t.Error("placeholder shell for the new connection should be cleaned up")
+	}
+	if !attached {
+		t.Error("resumed session should be attached with no pending cleanup")
+	}
+}
+
+// TestWebUIResumeInvalidToken verifies an unknown token keeps the new session
+// TestWebUIResumeRestoresBracketedPaste verifies a resumed session tells the
+// new page's terminal that the program has bracketed paste on
+func TestWebUIResumeRestoresBracketedPaste(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	tokenMsg := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" })
+	ownID := tokenMsg.ChatID
+	defer srv.cleanup(ownID)
+
+	srv.mu.Lock()
+	session := srv.sessions[ownID]
+	srv.mu.Unlock()
+	srv.sendOutput(session, "$ "+bracketedPasteOn)
+	conn.Close()
+
+	conn2 := dialTestWebSocket(t, srv, ts)
+	defer conn2.Close()
+	readUntil(t, conn2, func(m WebMessage) bool { return m.Type == "resume" })
+	if err := conn2.WriteJSON(WebMessage{Type: "resume", Content: tokenMsg.Content}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readUntil(t, conn2, func(m WebMessage) bool {
+		return m.Type == "output" && m.ChatID == ownID && m.Content == bracketedPasteOn
+	})
+}
+
+func TestBracketedPasteMode(t *testing.T) {
+	tests := []struct {
+		output   string
+		on, want bool
+	}{
+		{"plain output", false, false},
+		{"plain output", true, true},
+		{"$ \x1b[?2004h", false, true},
+		{"\x1b[?2004l\r\nls\r\n", true, false},
+		{"\x1b[?2004l out \x1b[?2004h$ ", false, true},
+		{"\x1b[?2004h$ ls\x1b[?2004l", true, false},
+	}
+	for _, tt := range tests {
+		if got := bracketedPasteMode(tt.output, tt.on); got != tt.want {
+			t.Errorf("bracketedPasteMode(%q, %v) = %v, want %v", tt.output, tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestWebUIResumeInvalidToken(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	defer srv.cleanup(1)
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+
+	if err := conn.WriteJSON(WebMessage{Type: "resume", Content: "bogus"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readUntil(t, conn, func(m WebMessage) bool {
+		return m.Type == "status" && strings.Contains(m.Content, "expired")
+	})
+}
+
+// TestWebUIDetachedSessionExpires verifies cleanup after the grace period
+func TestWebUIDetachedSessionExpires(t *testing.T) {
+	srv := NewWebUIServer(&Config{WebUIResumeGrace: 1})
+	term, err := NewTerminal(&MockSink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create terminal: %v", err)
+	}
+	srv.mu.Lock()
+	srv.sessions[1] = &Session{Terminal: term, Sinks: []OutputSink{&MockSink{}}, Active: true, StartedAt: time.Now(), done: make(chan struct{})}
+	srv.mu.Unlock()
+
+	srv.detachSession(1)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		srv.mu.Lock()
+		_, exists := srv.sessions[1]
+		srv.mu.Unlock()
+		if !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			srv.cleanup(1)
+			t.Fatal("detached session not cleaned up after grace period")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestHTMLResumesSession(t *testing.T) {
+	for _, want := range []string{"type: 'resume'", "msg.type === 'resume'", "setTimeout(connect"} {
+		if !strings.Contains(htmlContent, want) {
+			t.Errorf("htmlContent missing %q", want)
+		}
+	}
+}
+
+// TestWebUIHeartbeat verifies heartbeats report the attached session's
+// uptime while its PTY runs, and that the page is told the interval
+func TestWebUIHeartbeat(t *testing.T) {
+	srv := NewWebUIServer(nil)
+	sink := &WebSocketSink{chatID: 1}
+
+	srv.mu.Lock()
+	srv.sessions[1] = &Session{Active: true, StartedAt: time.Now().Add(-90 * time.Second)}
+	srv.mu.Unlock()
+	if msg := srv.heartbeat(sink); msg.Type != "heartbeat" || !msg.Alive || msg.Content != "1m30s" {
+		t.Errorf("live session heartbeat = %+v, want alive with uptime 1m30s", msg)
+	}
+
+	srv.mu.Lock()
+	srv.sessions[1].Active = false
+	srv.mu.Unlock()
+	if msg := srv.heartbeat(sink); msg.Alive || msg.Content != "" {
+		t.Errorf("ended session heartbeat = %+v, want not alive", msg)
+	}
+	if msg := srv.heartbeat(&WebSocketSink{chatID: 2}); msg.Alive {
+		t.Errorf("missing session heartbeat = %+v, want not alive", msg)
+	}
+
+	if page := srv.terminalHTML(); !strings.Contains(page, "const HEARTBEAT_MS = 5000;") {
+		t.Error("heartbeat interval not injected into the page")
+	}
+	if !strings.Contains(htmlContent, "msg.type === 'heartbeat'") || !strings.Contains(htmlContent, "2 * HEARTBEAT_MS") {
+		t.Error("page does not handle heartbeats or warn when they stop")
+	}
+}
+
+// TestWebUIQuickCommandsInjected verifies configured quick commands are
+// rendered into the terminal page as escaped JSON
+func TestWebUIQuickCommandsInjected(t *testing.T) {
+	srv := NewWebUIServer(&Config{QuickCommands: []string{"git status", "</script><b>"}})
+
+	page := srv.terminalHTML()
+	if !strings.Contains(page, `const QUICK_COMMANDS = ["git status","\u003c/script\u003e\u003cb\u003e"];`) {
+		t.Errorf("quick commands not injected as escaped JSON")
+	}
+	if !strings.Contains(page, `<div id="quickbar"></div>`) {
+		t.Error("quick bar container missing")
+	}
+
+	if page := NewWebUIServer(&Config{}).terminalHTML(); !strings.Contains(page, "const QUICK_COMMANDS = [];") {
+		t.Error("empty quick commands should render as []")
+	}
+}
+
+// TestWebUIViewerIsReadOnly verifies a ?mode=view connection receives a
+// session's output but cannot send it input
+func TestWebUIViewerIsReadOnly(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+	owner := dialTestWebSocket(t, srv, ts)
+	defer owner.Close()
+	chatID := readUntil(t, owner, func(m WebMessage) bool { return m.ChatID != 0 }).ChatID
+
+	header := http.Header{}
+	header.Set("Cookie", "session="+srv.createAuthSession())
+	viewURL := fmt.Sprintf("ws%s/ws?mode=view&session=%d", strings.TrimPrefix(ts.URL, "http"), chatID)
+	viewer, _, err := websocket.DefaultDialer.Dial(viewURL, header)
+	if err != nil {
+		t.Fatalf("viewer dial: %v", err)
+	}
+	defer viewer.Close()
+	readUntil(t, viewer, func(m WebMessage) bool { return m.Type == "status" && strings.Contains(m.Content, "read-only") })
+
+	// Input from the viewer is dropped
+	viewer.WriteJSON(WebMessage{Type: "input", Content: "echo hacked-$((6*7))\r"})
+	viewer.WriteJSON(WebMessage{Type: "command", Content: "echo hacked-$((6*7))"})
+
+	owner.WriteJSON(WebMessage{Type: "input", Content: "echo viewer-$((6*7))\r"})
+	var seen strings.Builder
+	readUntil(t, viewer, func(m WebMessage) bool {
+		seen.WriteString(m.Content)
+		return strings.Contains(seen.String(), "viewer-42")
+	})
+	if strings.Contains(seen.String(), "hacked-42") {
+		t.Errorf("viewer input reached the session: %q", seen.String())
+	}
+}
+
+// TestWebUIViewerUnknownSession verifies viewing a missing session fails
+// with an error instead of starting a shell
+func TestWebUIViewerUnknownSession(t *testing.T) {
+	srv, ts, cleanup := newTestServer(nil)
+	defer cleanup()
+
+	header := http.Header{}
+	header.Set("Cookie", "session="+srv.createAuthSession())
+	viewer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?mode=view&session=999", header)
+	if err != nil {
+		t.Fatalf("viewer dial: %v", err)
+	}
+	defer viewer.Close()
+
+	msg := readUntil(t, viewer, func(m WebMessage) bool { return m.Type == "error" })
+	if !strings.Contains(msg.Content, "No active session 999") {
+		t.Errorf("unexpected error: %q", msg.Content)
+	}
+	if sessions := srv.listSessions(); len(sessions) != 0 {
+		t.Errorf("viewer should not start a session, got %v", sessions)
+	}
+}
+
+// TestSplitIncompleteTail verifies incomplete escape sequences and partial
+// UTF-8 runes are held back while complete output is sent
+func TestSplitIncompleteTail(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ready string
+		held  string
+	}{
+		{"plain", "hello", "hello", ""},
+		{"complete CSI", "a\x1b[31mred\x1b[0m", "a\x1b[31mred\x1b[0m", ""},
+		{"lone ESC", "abc\x1b", "abc", "\x1b"},
+		{"partial CSI", "abc\x1b[3", "abc", "\x1b[3"},
+		{"partial private CSI", "x\x1b[?25", "x", "\x1b[?25"},
+		{"partial OSC", "x\x1b]0;title", "x", "\x1b]0;title"},
+		{"OSC split in ST", "x\x1b]0;title\x1b", "x", "\x1b]0;title\x1b"},
+		{"OSC ended by BEL", "x\x1b]0;t\x07y", "x\x1b]0;t\x07y", ""},
+		{"OSC ended by ST", "x\x1b]0;t\x1b\\y", "x\x1b]0;t\x1b\\y", ""},
+		{"partial charset", "x\x1b(", "x", "\x1b("},
+		{"two-byte escape", "x\x1b7y", "x\x1b7y", ""},
+		{"partial rune", "caf\xc3", "caf", "\xc3"},
+		{"partial 4-byte rune", "ok\xf0\x9f\x98", "ok", "\xf0\x9f\x98"},
+		{"complete rune", "café", "café", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, held := splitIncompleteTail(tt.input)
+			if ready != tt.ready || held != tt.held {
+				t.Errorf("splitIncompleteTail(%q) = %q, %q; want %q, %q", tt.input, ready, held, tt.ready, tt.held)
+			}
+		})
+	}
+}
+
+// TestSplitIncompleteTailChunked feeds output a byte at a time, as a slow
+// PTY might, and verifies every flushed chunk is intact and nothing is lost
+func TestSplitIncompleteTailChunked(t *testing.T) {
+	input := "\x1b[1;32m✓\x1b[0m done \x1b]0;build 🚀\x07\x1b(B\x1b[?25h€\r\n"
+	var sent []string
+	buffer := ""
+	for i := 0; i < len(input); i++ {
+		buffer += input[i : i+1]
+		ready, held := splitIncompleteTail(buffer)
+		if ready != "" {
+			sent = append(sent, ready)
+		}
+		buffer = held
+	}
+
+	if buffer != "" {
+		t.Errorf("output left held after complete input: %q", buffer)
+	}
+	if got := strings.Join(sent, ""); got != input {
+		t.Errorf("forwarded %q, want %q", got, input)
+	}
+	for _, chunk := range sent {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk splits a rune: %q", chunk)
+		}
+		if ready, held := splitIncompleteTail(chunk); ready != chunk || held != "" {
+			t.Errorf("chunk ends mid-sequence: %q", chunk)
+		}
+	}
+}
+
+// TestWebUIMaxSessions verifies connections past max_sessions get no shell
+// and that ending a session frees its slot
+func TestWebUIMaxSessions(t *testing.T) {
+	srv, ts, cleanup := newTestServer(&Config{MaxSessions: 1})
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+	firstID := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+
+	refused := dialTestWebSocket(t, srv, ts)
+	defer refused.Close()
+	status := readUntil(t, refused, func(m WebMessage) bool { return m.Type == "status" })
+	if status.Content != "⚠️ too many active sessions" {
+		t.Errorf("status = %q, want too many active sessions", status.Content)
+	}
+	if n := len(srv.listSessions()); n != 1 {
+		t.Errorf("%d sessions active, want 1", n)
+	}
+
+	srv.cleanup(firstID)
+	conn2 := dialTestWebSocket(t, srv, ts)
+	defer conn2.Close()
+	secondID := readUntil(t, conn2, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+	srv.cleanup(secondID)
+}
+
+// TestWebUIAutoStartShellDisabled verifies a tab gets no shell until it
+// sends "start" when webui_auto_start_shell is false
+func TestWebUIAutoStartShellDisabled(t *testing.T) {
+	off := false
+	srv, ts, cleanup := newTestServer(&Config{WebUIAutoStartShell: &off})
+	defer cleanup()
+
+	conn := dialTestWebSocket(t, srv, ts)
+	defer conn.Close()
+	status := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "status" })
+	if !strings.Contains(status.Content, "No shell running") {
+		t.Errorf("status = %q, want no shell running", status.Content)
+	}
+	if n := len(srv.listSessions()); n != 0 {
+		t.Fatalf("%d sessions started before start was sent", n)
+	}
+
+	if err := conn.WriteJSON(WebMessage{Type: "start"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	id := readUntil(t, conn, func(m WebMessage) bool { return m.Type == "resume" }).ChatID
+	defer srv.cleanup(id)
+	if n := len(srv.listSessions()); n != 1 {
+		t.Errorf("%d sessions after start, want 1", n)
+	}
+
+	if err := conn.WriteJSON(WebMessage{Type: "start"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	status = readUntil(t, conn, func(m WebMessage) bool { return m.Type == "status" })
+	if !strings.Contains(status.Content, "already running") {
+		t.Errorf("second start status = %q", status.Content)
+	}
+}
+
+func TestWebUIAutoStartShellDefault(t *testing.T) {
+	off := false
+	if !(*Config)(nil).webUIAutoStartShell() || !(&Config{}).webUIAutoStartShell() {
+		t.Error("auto start should default to true")
+	}
+	if (&Config{WebUIAutoStartShell: &off}).webUIAutoStartShell() {
+		t.Error("auto start not disabled by webui_auto_start_shell: false")
+	}
+	if !strings.Contains(NewWebUIServer(&Config{WebUIAutoStartShell: &off}).terminalHTML(), "const AUTO_START = false;") {
+		t.Error("page not told auto start is off")
+	}
+}